@@ -12,6 +12,7 @@ import (
 	"mcs-backend/internal/api"
 	"mcs-backend/internal/config"
 	"mcs-backend/internal/database"
+	"mcs-backend/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +20,9 @@ import (
 func main() {
 	// 加载配置
 	cfg := config.LoadConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	log.Printf("Starting MCS Backend Server on port %s", cfg.Server.Port)
 
 	// 设置Gin模式
@@ -45,6 +49,11 @@ func main() {
 		log.Printf("Warning: Failed to seed data: %v", err)
 	}
 
+	// 初始化文件存储
+	if err := storage.InitStorage(cfg); err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
+	}
+
 	// 初始化路由
 	router := api.SetupRoutes(cfg)
 
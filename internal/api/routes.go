@@ -1,11 +1,14 @@
 package api
 
 import (
+	"time"
+
 	"mcs-backend/internal/config"
 	"mcs-backend/internal/database"
 	"mcs-backend/internal/handlers"
 	"mcs-backend/internal/middleware"
 	"mcs-backend/internal/services"
+	"mcs-backend/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -82,38 +85,108 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		}
 
 		// 文件上传路由
-		uploadHandler := handlers.NewUploadHandler(cfg)
+		uploadService := services.NewUploadService(cfg)
+		uploadService.StartJanitor()
+		uploadHandler := handlers.NewUploadHandler(uploadService)
 		upload := v1.Group("/upload")
 		upload.Use(middleware.AuthMiddleware(cfg))
 		{
 			upload.POST("/init", uploadHandler.InitUpload)
+			upload.POST("/direct", uploadHandler.DirectUpload)
 			upload.POST("/chunk", uploadHandler.UploadChunk)
 			upload.POST("/complete/:upload_id", uploadHandler.CompleteUpload)
 			upload.GET("/progress/:upload_id", uploadHandler.GetUploadProgress)
+			upload.POST("/resume", uploadHandler.ResumeUpload)
 			upload.DELETE("/cancel/:upload_id", uploadHandler.CancelUpload)
 		}
 
 		// 文件管理路由
 		fileService := services.NewFileService(cfg)
+		fileResolver := services.NewFileResolver(storage.GetStorage(), cfg.File.UploadPath)
+		services.NewSearchIndexer(database.GetDB()).StartIndexer(time.Duration(cfg.File.SearchIndexEvery) * time.Minute)
+		blobService := services.NewBlobService(database.GetDB(), storage.GetStorage())
+		blobService.StartGC(time.Duration(cfg.File.BlobGCInterval) * time.Minute)
 		fileHandler := handlers.NewFileHandler(fileService)
+		thumbnailService := services.NewThumbnailServiceFromConfig(cfg)
+		thumbnailHandler := handlers.NewThumbnailHandler(thumbnailService)
 		files := v1.Group("/files")
 		files.Use(middleware.AuthMiddleware(cfg))
 		{
 			files.POST("/folders", fileHandler.CreateFolder)
 			files.GET("/list", fileHandler.GetFileList)
+			files.GET("/tree", fileHandler.GetFolderTree)
 			files.GET("/:id", fileHandler.GetFile)
 			files.PUT("/:id", fileHandler.UpdateFile)
 			files.DELETE("/:id", fileHandler.DeleteFile)
+			files.DELETE("/:id/purge", middleware.RequireAdmin(), fileHandler.PurgeFile)
 			files.PUT("/folders/:id", fileHandler.UpdateFolder)
 			files.DELETE("/folders/:id", fileHandler.DeleteFolder)
+			files.POST("/folders/:id/move", fileHandler.MoveFolder)
+			files.POST("/folders/:id/copy", fileHandler.CopyFolder)
 			files.GET("/search", fileHandler.SearchFiles)
 			files.GET("/:id/versions", fileHandler.GetFileVersions)
+			files.GET("/:id/versions/diff", fileHandler.DiffFileVersions)
+			files.GET("/:id/versions/:version/download", fileHandler.DownloadFileVersion)
+			files.HEAD("/:id/versions/:version/download", fileHandler.DownloadFileVersion)
+			files.POST("/:id/versions/:version/restore", fileHandler.RestoreFileVersion)
 			files.GET("/:id/download", fileHandler.DownloadFile)
+			files.HEAD("/:id/download", fileHandler.DownloadFile)
+			files.POST("/:id/move", fileHandler.MoveFile)
+			files.POST("/:id/copy", fileHandler.CopyFile)
+			files.GET("/:id/thumbnail", thumbnailHandler.GetThumbnail)
+		}
+
+		// 标签管理路由
+		tagHandler := handlers.NewTagHandler(services.NewTagService(database.GetDB()))
+		tags := v1.Group("/tags")
+		tags.Use(middleware.AuthMiddleware(cfg))
+		{
+			tags.GET("", tagHandler.ListTags)
+			tags.GET("/:id", tagHandler.GetTag)
+			tags.POST("/bulk/add", tagHandler.TagFiles)
+			tags.POST("/bulk/remove", tagHandler.UntagFiles)
+
+			// 管理员接口
+			tags.POST("", middleware.RequireAdmin(), tagHandler.CreateTag)
+			tags.PUT("/:id", middleware.RequireAdmin(), tagHandler.UpdateTag)
+			tags.DELETE("/:id", middleware.RequireAdmin(), tagHandler.DeleteTag)
+			tags.POST("/merge", middleware.RequireAdmin(), tagHandler.MergeTags)
+		}
+
+		// 文件分享路由
+		shareService := services.NewShareService(database.GetDB(), fileResolver, cfg.Server.BaseURL)
+		shareHandler := handlers.NewShareHandler(shareService)
+		router.GET("/s/:code", shareHandler.AccessShare)
+		router.POST("/s/:code", shareHandler.AccessShare)
+		shares := v1.Group("/shares")
+		shares.Use(middleware.AuthMiddleware(cfg))
+		{
+			shares.POST("", shareHandler.CreateShare)
+			shares.GET("", shareHandler.ListShares)
+			shares.PUT("/:id", shareHandler.UpdateShare)
+			shares.DELETE("/:id", shareHandler.RevokeShare)
+		}
+
+		// 回收站路由
+		trashService := services.NewTrashService(database.GetDB(), blobService, time.Duration(cfg.File.TrashRetention)*24*time.Hour)
+		trashService.StartPurge(time.Duration(cfg.File.TrashPurgeInterval) * time.Minute)
+		trashHandler := handlers.NewTrashHandler(trashService)
+		trash := v1.Group("/trash")
+		trash.Use(middleware.AuthMiddleware(cfg))
+		{
+			trash.GET("", trashHandler.ListTrash)
+			trash.DELETE("", trashHandler.EmptyTrash)
+			trash.POST("/purge", middleware.RequireAdmin(), trashHandler.PurgeExpired)
+			trash.POST("/:type/:id/restore", trashHandler.RestoreItem)
+			trash.DELETE("/:type/:id", trashHandler.PurgeItem)
 		}
 
 		// 工作流管理路由
 		workflowService := services.NewWorkflowService(cfg)
 		workflowHandler := handlers.NewWorkflowHandler(workflowService)
+		retentionService := services.NewRetentionService(database.GetDB(), blobService, time.Duration(cfg.File.RetentionGrace)*time.Hour)
+		retentionService.StartRetention(time.Duration(cfg.File.RetentionInterval) * time.Minute)
+		retentionHandler := handlers.NewRetentionHandler(retentionService)
 		workflows := v1.Group("/workflows")
 		workflows.Use(middleware.AuthMiddleware(cfg))
 		{
@@ -126,6 +199,12 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			workflows.GET("/:id/members", workflowHandler.GetMembers)
 			workflows.DELETE("/:id/members/:member_id", workflowHandler.RemoveMember)
 			workflows.PUT("/:id/members/:member_id/role", workflowHandler.UpdateMemberRole)
+			workflows.GET("/:id/upload-policy", workflowHandler.GetUploadPolicy)
+			workflows.PUT("/:id/upload-policy", workflowHandler.SetUploadPolicy)
+			workflows.DELETE("/:id/upload-policy", workflowHandler.DeleteUploadPolicy)
+			workflows.PUT("/:id/status", workflowHandler.ChangeStatus)
+			workflows.GET("/:id/retention", retentionHandler.PreviewWorkflow)
+			workflows.POST("/retention/run", middleware.RequireAdmin(), retentionHandler.Run)
 		}
 
 		// 任务管理路由
@@ -165,6 +244,7 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 		// 统计报表路由
 		statisticsService := services.NewStatisticsService(database.GetDB())
+		statisticsService.SetStorageCapacity(cfg.Storage.Capacity * 1024 * 1024 * 1024)
 		statisticsHandler := handlers.NewStatisticsHandler(statisticsService)
 		stats := v1.Group("/stats")
 		stats.Use(middleware.AuthMiddleware(cfg))
@@ -181,8 +261,22 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			stats.PUT("/user-activity", middleware.RequireAdmin(), statisticsHandler.UpdateUserActivityStats)
 		}
 
+		// 存储配额路由
+		quotaHandler := handlers.NewQuotaHandler(services.NewQuotaService(database.GetDB()))
+		quotas := v1.Group("/quotas")
+		quotas.Use(middleware.AuthMiddleware(cfg))
+		{
+			quotas.GET("/usage", quotaHandler.GetMyUsage)
+
+			// 管理员接口
+			quotas.GET("", middleware.RequireAdmin(), quotaHandler.ListQuotas)
+			quotas.PUT("", middleware.RequireAdmin(), quotaHandler.SetQuota)
+			quotas.GET("/:scope_type/:scope_id", middleware.RequireAdmin(), quotaHandler.GetQuota)
+			quotas.DELETE("/:scope_type/:scope_id", middleware.RequireAdmin(), quotaHandler.DeleteQuota)
+		}
+
 		// 下载管理路由
-		downloadService := services.NewDownloadService(database.GetDB(), cfg.File.UploadPath, cfg.File.DownloadPath, cfg.Server.BaseURL)
+		downloadService := services.NewDownloadService(database.GetDB(), fileResolver, cfg.File.DownloadPath, cfg.Server.BaseURL)
 		downloadHandler := handlers.NewDownloadHandler(downloadService)
 		download := v1.Group("/download")
 		download.Use(middleware.AuthMiddleware(cfg))
@@ -192,11 +286,29 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			download.GET("/tasks/:id", downloadHandler.GetDownloadTask)
 			download.GET("/zip/:filename", downloadHandler.DownloadZipFile)
 			download.GET("/file/:id", downloadHandler.DownloadSingleFile)
+			download.HEAD("/file/:id", downloadHandler.DownloadSingleFile)
 			download.GET("/stats", downloadHandler.GetDownloadStats)
 			
 			// 管理员接口
 			download.GET("/stats/global", middleware.RequireAdmin(), downloadHandler.GetGlobalDownloadStats)
 		}
+
+		// 后台任务队列
+		jobQueue := services.NewJobQueue(database.GetDB())
+		jobQueue.Register(services.JobTypeDownloadZip, downloadService.HandleZipJob)
+		jobQueue.Register(services.JobTypeFileMetadata, services.NewMetadataService(database.GetDB(), storage.GetStorage(), cfg.File.UploadPath).HandleJob)
+		jobQueue.Register(services.JobTypeFileThumbnail, thumbnailService.HandleJob)
+		jobQueue.Start(cfg.Job.Workers, time.Duration(cfg.Job.PollInterval)*time.Second, time.Duration(cfg.Job.Retention)*time.Hour)
+		jobHandler := handlers.NewJobHandler(jobQueue)
+		jobs := v1.Group("/jobs")
+		jobs.Use(middleware.AuthMiddleware(cfg), middleware.RequireAdmin())
+		{
+			jobs.GET("", jobHandler.ListJobs)
+			jobs.GET("/stats", jobHandler.GetJobStats)
+			jobs.GET("/:id", jobHandler.GetJob)
+			jobs.POST("/:id/retry", jobHandler.RetryJob)
+			jobs.POST("/:id/cancel", jobHandler.CancelJob)
+		}
 	}
 
 	return router
@@ -207,7 +319,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Share-Password")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
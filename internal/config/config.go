@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -14,7 +15,9 @@ type Config struct {
 	Database DatabaseConfig `json:"database"`
 	JWT      JWTConfig      `json:"jwt"`
 	File     FileConfig     `json:"file"`
+	Storage  StorageConfig  `json:"storage"`
 	Redis    RedisConfig    `json:"redis"`
+	Job      JobConfig      `json:"job"`
 }
 
 // ServerConfig 服务器配置
@@ -43,12 +46,34 @@ type JWTConfig struct {
 
 // FileConfig 文件存储配置
 type FileConfig struct {
-	UploadPath    string `json:"upload_path"`
-	ThumbnailPath string `json:"thumbnail_path"`
-	TempPath      string `json:"temp_path"`
-	DownloadPath  string `json:"download_path"`
-	MaxFileSize   int64  `json:"max_file_size"` // MB
-	AllowedTypes  string `json:"allowed_types"`
+	UploadPath         string `json:"upload_path"`
+	ThumbnailPath      string `json:"thumbnail_path"`
+	TempPath           string `json:"temp_path"`
+	DownloadPath       string `json:"download_path"`
+	MaxFileSize        int64  `json:"max_file_size"` // MB
+	AllowedTypes       string `json:"allowed_types"`
+	UploadSessionStore string `json:"upload_session_store"` // 上传会话存储: memory, postgres
+	UploadSessionTTL   int    `json:"upload_session_ttl"`   // 上传会话过期时间（小时）
+	UploadCleanupEvery int    `json:"upload_cleanup_every"` // 过期上传清理间隔（分钟）
+	BlobGCInterval     int    `json:"blob_gc_interval"`     // 无引用文件内容回收间隔（分钟）
+	RetentionGrace     int    `json:"retention_grace"`      // 工作流完成后保留历史版本的宽限期（小时）
+	RetentionInterval  int    `json:"retention_interval"`   // 历史版本清理检查间隔（分钟）
+	TrashRetention     int    `json:"trash_retention"`      // 回收站中的文件和文件夹保留天数，之后彻底删除
+	TrashPurgeInterval int    `json:"trash_purge_interval"` // 回收站过期清理间隔（分钟）
+	SearchIndexEvery   int    `json:"search_index_every"`   // 检索文档补建间隔（分钟）
+	ThumbnailVideoCmd  string `json:"thumbnail_video_cmd"`  // 视频封面帧提取命令，{input} 替换为视频路径，为空时不生成视频缩略图
+}
+
+// StorageConfig 文件内容存储配置
+type StorageConfig struct {
+	Driver         string `json:"driver"` // local（保存在 UPLOAD_PATH）, s3（S3兼容对象存储，如 MinIO）
+	S3Endpoint     string `json:"s3_endpoint"`
+	S3Region       string `json:"s3_region"`
+	S3Bucket       string `json:"s3_bucket"`
+	S3AccessKey    string `json:"-"`
+	S3SecretKey    string `json:"-"`
+	S3UsePathStyle bool   `json:"s3_use_path_style"`
+	Capacity       int64  `json:"capacity"` // 存储总容量（GB），用于计算存储使用率，0 表示不计算
 }
 
 // RedisConfig Redis配置
@@ -59,6 +84,13 @@ type RedisConfig struct {
 	DB       int    `json:"db"`
 }
 
+// JobConfig 后台任务队列配置
+type JobConfig struct {
+	Workers      int `json:"workers"`       // 工作协程数
+	PollInterval int `json:"poll_interval"` // 空闲时轮询间隔（秒）
+	Retention    int `json:"retention"`     // 已完成、已取消任务的保留时间（小时）
+}
+
 // LoadConfig 加载配置
 func LoadConfig() *Config {
 	// 加载.env文件
@@ -86,12 +118,32 @@ func LoadConfig() *Config {
 			ExpirationHours: getEnvAsInt("JWT_EXPIRE_TIME", 24),
 		},
 		File: FileConfig{
-			UploadPath:    getEnv("UPLOAD_PATH", "./uploads"),
-			ThumbnailPath: getEnv("THUMBNAIL_PATH", "./thumbnails"),
-			TempPath:      getEnv("TEMP_PATH", "./temp"),
-			DownloadPath:  getEnv("DOWNLOAD_PATH", "./downloads"),
-			MaxFileSize:   getEnvAsInt64("MAX_FILE_SIZE", 100), // 100MB
-			AllowedTypes:  getEnv("ALLOWED_TYPES", "jpg,jpeg,png,gif,mp4,mov,avi,raw,cr2,nef"),
+			UploadPath:         getEnv("UPLOAD_PATH", "./uploads"),
+			ThumbnailPath:      getEnv("THUMBNAIL_PATH", "./thumbnails"),
+			TempPath:           getEnv("TEMP_PATH", "./temp"),
+			DownloadPath:       getEnv("DOWNLOAD_PATH", "./downloads"),
+			MaxFileSize:        getEnvAsInt64("MAX_FILE_SIZE", 100), // 100MB
+			AllowedTypes:       getEnv("ALLOWED_TYPES", "jpg,jpeg,png,gif,mp4,mov,avi,raw,cr2,nef"),
+			UploadSessionStore: getEnv("UPLOAD_SESSION_STORE", "postgres"),
+			UploadSessionTTL:   getEnvAsInt("UPLOAD_SESSION_TTL", 72),
+			UploadCleanupEvery: getEnvAsInt("UPLOAD_CLEANUP_INTERVAL", 60),
+			BlobGCInterval:     getEnvAsInt("BLOB_GC_INTERVAL", 360),
+			RetentionGrace:     getEnvAsInt("VERSION_RETENTION_GRACE", 168),
+			RetentionInterval:  getEnvAsInt("VERSION_RETENTION_INTERVAL", 60),
+			TrashRetention:     getEnvAsInt("TRASH_RETENTION_DAYS", 30),
+			TrashPurgeInterval: getEnvAsInt("TRASH_PURGE_INTERVAL", 60),
+			SearchIndexEvery:   getEnvAsInt("SEARCH_INDEX_INTERVAL", 10),
+			ThumbnailVideoCmd:  getEnv("THUMBNAIL_VIDEO_COMMAND", ""),
+		},
+		Storage: StorageConfig{
+			Driver:         getEnv("STORAGE_DRIVER", "local"),
+			S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+			S3Region:       getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:       getEnv("S3_BUCKET", ""),
+			S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+			S3UsePathStyle: getEnvAsBool("S3_USE_PATH_STYLE", true),
+			Capacity:       getEnvAsInt64("STORAGE_CAPACITY", 0),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -99,11 +151,24 @@ func LoadConfig() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Job: JobConfig{
+			Workers:      getEnvAsInt("JOB_WORKERS", 2),
+			PollInterval: getEnvAsInt("JOB_POLL_INTERVAL", 2),
+			Retention:    getEnvAsInt("JOB_RETENTION", 168),
+		},
 	}
 
 	return config
 }
 
+// Validate 检查配置取值是否有效
+func (c *Config) Validate() error {
+	if c.File.TrashRetention <= 0 {
+		return fmt.Errorf("TRASH_RETENTION_DAYS 必须大于 0，当前为 %d", c.File.TrashRetention)
+	}
+	return nil
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -141,4 +206,14 @@ func (c *Config) GetDSN() string {
 		" port=" + c.Database.Port +
 		" sslmode=" + c.Database.SSLMode +
 		" TimeZone=" + c.Database.TimeZone
-}
\ No newline at end of file
+}
+
+// getEnvAsBool 获取环境变量并转换为bool
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,17 @@
+package config
+
+import "testing"
+
+func TestValidateTrashRetention(t *testing.T) {
+	for _, days := range []int{0, -1} {
+		cfg := &Config{File: FileConfig{TrashRetention: days}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("TrashRetention %d accepted", days)
+		}
+	}
+
+	cfg := &Config{File: FileConfig{TrashRetention: 30}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -68,13 +68,19 @@ func AutoMigrate() error {
 		// 文件相关
 		&models.File{},
 		&models.FileVersion{},
+		&models.Blob{},
 		&models.FileShare{},
 		&models.Tag{},
 		&models.FileTag{},
+		&models.UploadSession{},
+		&models.FileSearchDocument{},
+		&models.FileMetadata{},
+		&models.Job{},
 
 		// 工作流相关
 		&models.Workflow{},
 		&models.WorkflowMember{},
+		&models.WorkflowUploadPolicy{},
 		&models.Task{},
 		&models.TaskMember{},
 		&models.TaskStatusLog{},
@@ -90,6 +96,8 @@ func AutoMigrate() error {
 		// 统计和日志
 		&models.Statistics{},
 		&models.ActivityLog{},
+		&models.StorageStats{},
+		&models.StorageQuota{},
 		&models.UserFavorite{},
 		&models.PopularityScore{},
 		&models.ReportData{},
@@ -117,6 +125,13 @@ func CreateIndexes() error {
 		"CREATE INDEX IF NOT EXISTS idx_activity_logs_user_action ON activity_logs(user_id, action, created_at)",
 		"CREATE INDEX IF NOT EXISTS idx_statistics_target ON statistics(target_type, target_id, date)",
 		"CREATE INDEX IF NOT EXISTS idx_notifications_user_read ON notifications(user_id, is_read, created_at)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_file_tags_tag_file ON file_tags(tag_id, file_id)",
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_file_search_document ON file_search_documents USING GIN (document)",
+		"CREATE INDEX IF NOT EXISTS idx_file_search_content_trgm ON file_search_documents USING GIN (content gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(run_at, id) WHERE status = 'pending'",
+		"CREATE INDEX IF NOT EXISTS idx_files_trash ON files(owner_id, deleted_at) WHERE is_deleted = true",
+		"CREATE INDEX IF NOT EXISTS idx_file_folders_trash ON file_folders(creator_id, deleted_at) WHERE is_deleted = true",
 	}
 
 	for _, index := range indexes {
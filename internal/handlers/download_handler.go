@@ -1,13 +1,15 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 
 	"mcs-backend/internal/middleware"
 	"mcs-backend/internal/models"
 	"mcs-backend/internal/services"
+	"mcs-backend/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -185,7 +187,7 @@ func (h *DownloadHandler) DownloadZipFile(c *gin.Context) {
 	// 设置响应头
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	c.Header("Content-Disposition", contentDisposition(fileName))
 	c.Header("Content-Type", "application/zip")
 
 	// 发送文件
@@ -194,19 +196,22 @@ func (h *DownloadHandler) DownloadZipFile(c *gin.Context) {
 
 // DownloadSingleFile 下载单个文件
 // @Summary 下载单个文件
-// @Description 下载指定的单个文件
+// @Description 下载指定的单个文件并记录下载日志，支持单段及多段 Range 请求、If-Range、If-None-Match 条件请求
 // @Tags 下载管理
 // @Accept json
 // @Produce application/octet-stream
 // @Param Authorization header string true "Bearer token"
 // @Param id path int true "文件ID"
+// @Param Range header string false "字节范围，如 bytes=0-1023，可包含多段"
 // @Success 200 {file} binary "文件内容"
+// @Success 206 {file} binary "部分内容"
+// @Success 304 "内容未变化"
 // @Failure 400 {object} Response "请求参数错误"
 // @Failure 401 {object} Response "未授权"
 // @Failure 404 {object} Response "文件不存在"
 // @Router /api/v1/download/file/{id} [get]
 func (h *DownloadHandler) DownloadSingleFile(c *gin.Context) {
-	_, exists := middleware.GetUserID(c)
+	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
 		return
@@ -219,11 +224,25 @@ func (h *DownloadHandler) DownloadSingleFile(c *gin.Context) {
 		return
 	}
 
-	// 返回提示信息
-	c.JSON(http.StatusOK, gin.H{
-		"message": "请使用 `/api/v1/files/{id}/download` 接口下载单个文件",
-		"redirect_url": fmt.Sprintf("/api/v1/files/%d/download", fileID),
-	})
+	content, err := h.downloadService.OpenFile(userID, uint(fileID))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, "文件不存在于服务器"))
+			return
+		}
+		c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+		return
+	}
+	defer content.Close()
+
+	// 只记录完整下载，断点续传和拖动播放的 Range 请求不重复记录
+	if c.Request.Method == http.MethodGet && c.GetHeader("Range") == "" {
+		if err := h.downloadService.LogDownload(userID, uint(fileID), content.Name, content.Size, c.ClientIP(), c.Request.UserAgent()); err != nil {
+			log.Printf("Warning: Failed to log download of file %d: %v", fileID, err)
+		}
+	}
+
+	serveFileContent(c, content)
 }
 
 // GetDownloadStats 获取下载统计
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveFileContent 以附件形式发送文件内容
+// 由 http.ServeContent 处理单段及多段 Range 请求（206，多段时为 multipart/byteranges）、If-Range、
+// If-None-Match、If-Modified-Since 等条件请求；ETag 为内容 MD5，内容不变时保持不变
+func serveFileContent(c *gin.Context, content *services.FileContent) {
+	if content.MD5Hash != "" {
+		c.Header("ETag", `"`+content.MD5Hash+`"`)
+	}
+	mimeType := content.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	c.Header("Content-Type", mimeType)
+	c.Header("Content-Disposition", contentDisposition(content.Name))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-cache")
+
+	http.ServeContent(c.Writer, c.Request, "", content.ModTime, content)
+}
+
+// contentDisposition 生成附件的 Content-Disposition
+// filename 为 ASCII 回退名称，包含非 ASCII 字符（如中文）时按 RFC 5987 附加 UTF-8 编码的 filename*
+func contentDisposition(name string) string {
+	var fallback strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r >= 0x7f || r == '"' || r == '\\' || r == '/':
+			fallback.WriteByte('_')
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	value := `attachment; filename="` + fallback.String() + `"`
+	if fallback.String() != name {
+		value += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return value
+}
+
+// encodeRFC5987 按 RFC 5987 的 attr-char 规则百分号编码 UTF-8 字符串
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+			strings.IndexByte("!#$&+-.^_`|~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0F])
+	}
+	return b.String()
+}
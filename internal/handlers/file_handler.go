@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"mcs-backend/internal/services"
+	"mcs-backend/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -50,7 +53,11 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 
 	folder, err := h.fileService.CreateFolder(&req, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "创建文件夹失败: "+err.Error()))
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidName) {
+			status = http.StatusBadRequest
+		}
+		respondError(c, status, "创建文件夹失败: ", err)
 		return
 	}
 
@@ -67,10 +74,18 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 // @Param workflow_id query int false "工作流ID"
 // @Param task_id query int false "任务ID"
 // @Param keyword query string false "搜索关键词"
-// @Param mime_type query string false "文件类型"
+// @Param mime_type query string false "文件类型（MIME前缀，如 image）"
+// @Param tag_ids query string false "标签ID，逗号分隔"
+// @Param tag_mode query string false "标签筛选方式：and（包含全部）或 or（包含任一）" default(and)
+// @Param owner_ids query string false "上传者ID，逗号分隔"
+// @Param min_size query int false "最小文件大小（字节）"
+// @Param max_size query int false "最大文件大小（字节）"
+// @Param created_from query string false "上传时间下限（RFC3339 或 YYYY-MM-DD）"
+// @Param created_to query string false "上传时间上限（RFC3339 或 YYYY-MM-DD，日期包含当天）"
+// @Param facets query bool false "是否返回分面统计"
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(20)
-// @Param sort_by query string false "排序字段" default(created_at)
+// @Param sort_by query string false "排序字段：name, size, created_at, updated_at" default(created_at)
 // @Param sort_order query string false "排序方向" default(desc)
 // @Success 200 {object} Response{data=services.FileListResponse} "获取成功"
 // @Failure 400 {object} Response "请求参数错误"
@@ -78,11 +93,17 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 // @Router /api/files [get]
 // @Security BearerAuth
 func (h *FileHandler) GetFileList(c *gin.Context) {
+	filter, err := parseFileFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, err.Error()))
+		return
+	}
+
 	req := services.FileListRequest{
-		Keyword:   c.Query("keyword"),
-		MimeType:  c.Query("mime_type"),
-		SortBy:    c.DefaultQuery("sort_by", "created_at"),
-		SortOrder: c.DefaultQuery("sort_order", "desc"),
+		FileFilter: *filter,
+		Keyword:    c.Query("keyword"),
+		SortBy:     c.DefaultQuery("sort_by", "created_at"),
+		SortOrder:  c.DefaultQuery("sort_order", "desc"),
 	}
 
 	// 解析查询参数
@@ -127,6 +148,87 @@ func (h *FileHandler) GetFileList(c *gin.Context) {
 	c.JSON(http.StatusOK, SuccessResponse("获取文件列表成功", response))
 }
 
+// parseFileFilter 解析文件筛选参数
+func parseFileFilter(c *gin.Context) (*services.FileFilter, error) {
+	filter := &services.FileFilter{
+		MimeType: c.Query("mime_type"),
+		TagMode:  c.Query("tag_mode"),
+	}
+
+	var err error
+	if filter.TagIDs, err = parseIDList(c.Query("tag_ids")); err != nil {
+		return nil, fmt.Errorf("无效的标签ID: %v", err)
+	}
+	if filter.OwnerIDs, err = parseIDList(c.Query("owner_ids")); err != nil {
+		return nil, fmt.Errorf("无效的上传者ID: %v", err)
+	}
+	if value := c.Query("min_size"); value != "" {
+		if filter.MinSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("无效的最小文件大小: %s", value)
+		}
+	}
+	if value := c.Query("max_size"); value != "" {
+		if filter.MaxSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("无效的最大文件大小: %s", value)
+		}
+	}
+	if filter.CreatedFrom, err = parseTimeBound(c.Query("created_from"), false); err != nil {
+		return nil, fmt.Errorf("无效的开始时间: %v", err)
+	}
+	if filter.CreatedTo, err = parseTimeBound(c.Query("created_to"), true); err != nil {
+		return nil, fmt.Errorf("无效的结束时间: %v", err)
+	}
+	if value := c.Query("facets"); value != "" {
+		if filter.WithFacets, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("无效的 facets 参数: %s", value)
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// parseIDList 解析逗号分隔的ID列表
+func parseIDList(value string) ([]uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// parseTimeBound 解析 RFC3339 时间或 YYYY-MM-DD 日期，endOfDay 为 true 时日期表示包含当天（返回次日零点）
+func parseTimeBound(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
 // GetFile 获取文件信息
 // @Summary 获取文件信息
 // @Description 根据文件ID获取详细信息
@@ -197,7 +299,7 @@ func (h *FileHandler) UpdateFile(c *gin.Context) {
 
 	file, err := h.fileService.UpdateFile(uint(fileID), &req, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "更新文件信息失败: "+err.Error()))
+		respondError(c, http.StatusInternalServerError, "更新文件信息失败: ", err)
 		return
 	}
 
@@ -231,13 +333,41 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 	}
 
 	if err := h.fileService.DeleteFile(uint(fileID), userID.(uint)); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "删除文件失败: "+err.Error()))
+		respondError(c, http.StatusInternalServerError, "删除文件失败: ", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse("删除文件成功", nil))
 }
 
+// PurgeFile 彻底删除文件（管理员）
+// @Summary 彻底删除文件
+// @Description 删除文件记录及其全部版本，存储内容在不再被引用后由垃圾回收清理
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件ID"
+// @Success 200 {object} Response "删除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "权限不足"
+// @Router /api/files/{id}/purge [delete]
+// @Security BearerAuth
+func (h *FileHandler) PurgeFile(c *gin.Context) {
+	idStr := c.Param("id")
+	fileID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "文件ID格式错误"))
+		return
+	}
+
+	if err := h.fileService.PurgeFile(uint(fileID)); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "彻底删除文件失败: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("彻底删除文件成功", nil))
+}
+
 // UpdateFolder 更新文件夹
 // @Summary 更新文件夹
 // @Description 更新文件夹的基本信息
@@ -273,7 +403,11 @@ func (h *FileHandler) UpdateFolder(c *gin.Context) {
 
 	folder, err := h.fileService.UpdateFolder(uint(folderID), &req, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "更新文件夹失败: "+err.Error()))
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidName) {
+			status = http.StatusBadRequest
+		}
+		respondError(c, status, "更新文件夹失败: ", err)
 		return
 	}
 
@@ -282,14 +416,16 @@ func (h *FileHandler) UpdateFolder(c *gin.Context) {
 
 // DeleteFolder 删除文件夹
 // @Summary 删除文件夹
-// @Description 删除空的文件夹
+// @Description 删除空的文件夹；recursive=true 时将文件夹及其全部子文件夹和文件一起移入回收站
 // @Tags 文件管理
 // @Accept json
 // @Produce json
 // @Param id path int true "文件夹ID"
+// @Param recursive query bool false "是否递归删除" default(false)
 // @Success 200 {object} Response "删除成功"
 // @Failure 400 {object} Response "请求参数错误"
 // @Failure 404 {object} Response "文件夹不存在"
+// @Failure 409 {object} Response "文件夹不为空"
 // @Router /api/files/folders/{id} [delete]
 // @Security BearerAuth
 func (h *FileHandler) DeleteFolder(c *gin.Context) {
@@ -306,8 +442,19 @@ func (h *FileHandler) DeleteFolder(c *gin.Context) {
 		return
 	}
 
-	if err := h.fileService.DeleteFolder(uint(folderID), userID.(uint)); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "删除文件夹失败: "+err.Error()))
+	recursive, err := strconv.ParseBool(c.DefaultQuery("recursive", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "recursive 参数格式错误"))
+		return
+	}
+
+	err = h.fileService.DeleteFolder(uint(folderID), userID.(uint), recursive)
+	if errors.Is(err, services.ErrFolderNotEmpty) {
+		c.JSON(http.StatusConflict, ErrorResponse(409, "删除文件夹失败: "+err.Error()+"，可使用 recursive=true 连同内容一起删除"))
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "删除文件夹失败: ", err)
 		return
 	}
 
@@ -316,11 +463,21 @@ func (h *FileHandler) DeleteFolder(c *gin.Context) {
 
 // SearchFiles 搜索文件
 // @Summary 搜索文件
-// @Description 根据关键词搜索文件
+// @Description 在文件名、描述、标签、上传者及相机型号中全文搜索，结果按相关度排序并返回高亮
+// @Description 关键词支持 tag:人像 uploader:alice camera:"Canon R5" before:2026-01-01 after:2025-12-01 等条件
 // @Tags 文件管理
 // @Accept json
 // @Produce json
-// @Param keyword query string true "搜索关键词"
+// @Param keyword query string true "搜索语句"
+// @Param mime_type query string false "文件类型（MIME前缀，如 image）"
+// @Param tag_ids query string false "标签ID，逗号分隔"
+// @Param tag_mode query string false "标签筛选方式：and（包含全部）或 or（包含任一）" default(and)
+// @Param owner_ids query string false "上传者ID，逗号分隔"
+// @Param min_size query int false "最小文件大小（字节）"
+// @Param max_size query int false "最大文件大小（字节）"
+// @Param created_from query string false "上传时间下限（RFC3339 或 YYYY-MM-DD）"
+// @Param created_to query string false "上传时间上限（RFC3339 或 YYYY-MM-DD，日期包含当天）"
+// @Param facets query bool false "是否返回分面统计"
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(20)
 // @Success 200 {object} Response{data=services.FileListResponse} "搜索成功"
@@ -334,6 +491,21 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, ErrorResponse(400, "搜索关键词不能为空"))
 		return
 	}
+	query, err := services.ParseSearchQuery(keyword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, err.Error()))
+		return
+	}
+	if query.IsEmpty() {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "搜索关键词不能为空"))
+		return
+	}
+
+	filter, err := parseFileFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, err.Error()))
+		return
+	}
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
@@ -344,7 +516,7 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		return
 	}
 
-	response, err := h.fileService.SearchFiles(keyword, userID.(uint), page, pageSize)
+	response, err := h.fileService.SearchFiles(query, filter, userID.(uint), page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "搜索文件失败: "+err.Error()))
 		return
@@ -388,14 +560,154 @@ func (h *FileHandler) GetFileVersions(c *gin.Context) {
 	c.JSON(http.StatusOK, SuccessResponse("获取文件版本成功", versions))
 }
 
+// DownloadFileVersion 下载文件的历史版本
+// @Summary 下载文件历史版本
+// @Description 下载指定文件的某个历史版本，支持 Range 与条件请求，ETag 为该版本内容的 MD5
+// @Tags 文件管理
+// @Accept json
+// @Produce application/octet-stream
+// @Param id path int true "文件ID"
+// @Param version path int true "版本号"
+// @Param Range header string false "字节范围，如 bytes=0-1023，可包含多段"
+// @Success 200 {file} binary "文件内容"
+// @Success 206 {file} binary "部分内容"
+// @Success 304 "内容未变化"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 404 {object} Response "文件或版本不存在"
+// @Router /api/files/{id}/versions/{version}/download [get]
+// @Security BearerAuth
+func (h *FileHandler) DownloadFileVersion(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "文件ID格式错误"))
+		return
+	}
+	version, err := strconv.ParseUint(c.Param("version"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "版本号格式错误"))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	content, err := h.fileService.OpenFileVersion(uint(fileID), version, userID.(uint))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.JSON(http.StatusNotFound, ErrorResponse(404, "文件不存在于服务器"))
+			return
+		}
+		c.JSON(http.StatusNotFound, ErrorResponse(404, err.Error()))
+		return
+	}
+	defer content.Close()
+
+	serveFileContent(c, content)
+}
+
+// RestoreFileVersion 恢复文件的历史版本
+// @Summary 恢复文件历史版本
+// @Description 以历史版本的内容创建一个新版本并设为当前版本，仅文件所有者可操作
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件ID"
+// @Param version path int true "版本号"
+// @Success 200 {object} Response{data=models.FileVersion} "恢复成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/files/{id}/versions/{version}/restore [post]
+// @Security BearerAuth
+func (h *FileHandler) RestoreFileVersion(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "文件ID格式错误"))
+		return
+	}
+	version, err := strconv.ParseUint(c.Param("version"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "版本号格式错误"))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	restored, err := h.fileService.RestoreFileVersion(uint(fileID), version, userID.(uint))
+	if errors.Is(err, services.ErrWorkflowReadOnly) {
+		c.JSON(http.StatusForbidden, ErrorResponse(403, "恢复文件版本失败: "+err.Error()))
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "恢复文件版本失败: ", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("恢复文件版本成功", restored))
+}
+
+// DiffFileVersions 比较文件两个版本的元数据
+// @Summary 比较文件版本
+// @Description 比较文件两个版本的大小、MD5、上传者和变更说明
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件ID"
+// @Param from query int true "起始版本号"
+// @Param to query int true "目标版本号"
+// @Success 200 {object} Response{data=services.FileVersionDiff} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/files/{id}/versions/diff [get]
+// @Security BearerAuth
+func (h *FileHandler) DiffFileVersions(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "文件ID格式错误"))
+		return
+	}
+	from, err := strconv.ParseUint(c.Query("from"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "起始版本号格式错误"))
+		return
+	}
+	to, err := strconv.ParseUint(c.Query("to"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "目标版本号格式错误"))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	diff, err := h.fileService.DiffFileVersions(uint(fileID), from, to, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "比较文件版本失败: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("比较文件版本成功", diff))
+}
+
 // DownloadFile 下载文件
 // @Summary 下载文件
-// @Description 下载指定文件
+// @Description 下载指定文件，支持单段及多段 Range 请求、If-Range、If-None-Match 条件请求，ETag 为内容 MD5
 // @Tags 文件管理
 // @Accept json
 // @Produce application/octet-stream
 // @Param id path string true "文件ID"
+// @Param Range header string false "字节范围，如 bytes=0-1023，可包含多段"
 // @Success 200 {file} binary "文件内容"
+// @Success 206 {file} binary "部分内容"
+// @Success 304 "内容未变化"
+// @Failure 416 "请求的范围无效"
 // @Failure 400 {object} handlers.Response "请求参数错误"
 // @Failure 404 {object} handlers.Response "文件不存在"
 // @Failure 500 {object} handlers.Response "服务器内部错误"
@@ -422,28 +734,18 @@ func (h *FileHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// 获取文件信息
-	fileInfo, err := h.fileService.GetFileByID(uint(fileID), userID.(uint))
+	// 获取文件信息并打开文件内容
+	content, err := h.fileService.OpenFile(uint(fileID), userID.(uint))
 	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.JSON(http.StatusNotFound, ErrorResponse(404, "文件不存在于服务器"))
+			return
+		}
 		c.JSON(http.StatusNotFound, ErrorResponse(404, "文件不存在"))
 		return
 	}
+	defer content.Close()
 
-	// 构建文件路径（需要从数据库获取实际的文件路径）
-	filePath := fmt.Sprintf("%s/%s", h.fileService.GetConfig().File.UploadPath, fileInfo.FileName)
-
-	// 检查文件是否存在于磁盘
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, ErrorResponse(404, "文件不存在于服务器"))
-		return
-	}
-
-	// 设置响应头
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileInfo.FileName))
-	c.Header("Content-Type", "application/octet-stream")
-
-	// 发送文件
-	c.File(filePath)
-}
\ No newline at end of file
+	// 发送文件，支持断点续传和条件请求
+	serveFileContent(c, content)
+}
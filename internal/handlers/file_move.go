@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MoveFile 移动文件
+// @Summary 移动文件
+// @Description 将文件移动到其他文件夹或工作流，可同时重命名（仅文件所有者）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件ID"
+// @Param request body services.MoveRequest true "目标位置"
+// @Success 200 {object} Response{data=services.FileInfo} "移动成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "无权限访问目标工作流或工作流只读"
+// @Failure 409 {object} Response "目标位置已存在同名文件"
+// @Router /api/files/{id}/move [post]
+// @Security BearerAuth
+func (h *FileHandler) MoveFile(c *gin.Context) {
+	h.moveOrCopy(c, "文件ID格式错误", "移动文件", func(id uint, req *services.MoveRequest, userID uint) (interface{}, error) {
+		return h.fileService.MoveFile(id, req, userID)
+	})
+}
+
+// CopyFile 复制文件
+// @Summary 复制文件
+// @Description 将文件复制到其他文件夹或工作流，副本与原文件共享存储内容
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件ID"
+// @Param request body services.MoveRequest true "目标位置"
+// @Success 200 {object} Response{data=services.FileInfo} "复制成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "无权限访问目标工作流或工作流只读"
+// @Failure 409 {object} Response "目标位置已存在同名文件"
+// @Failure 507 {object} Response "存储配额不足"
+// @Router /api/files/{id}/copy [post]
+// @Security BearerAuth
+func (h *FileHandler) CopyFile(c *gin.Context) {
+	h.moveOrCopy(c, "文件ID格式错误", "复制文件", func(id uint, req *services.MoveRequest, userID uint) (interface{}, error) {
+		return h.fileService.CopyFile(id, req, userID)
+	})
+}
+
+// MoveFolder 移动文件夹
+// @Summary 移动文件夹
+// @Description 将文件夹及其全部内容移动到其他文件夹或工作流，可同时重命名（仅文件夹创建者）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件夹ID"
+// @Param request body services.MoveRequest true "目标位置"
+// @Success 200 {object} Response{data=services.FolderInfo} "移动成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "无权限访问目标工作流或工作流只读"
+// @Failure 409 {object} Response "目标位置已存在同名文件夹"
+// @Router /api/files/folders/{id}/move [post]
+// @Security BearerAuth
+func (h *FileHandler) MoveFolder(c *gin.Context) {
+	h.moveOrCopy(c, "文件夹ID格式错误", "移动文件夹", func(id uint, req *services.MoveRequest, userID uint) (interface{}, error) {
+		return h.fileService.MoveFolder(id, req, userID)
+	})
+}
+
+// CopyFolder 复制文件夹
+// @Summary 复制文件夹
+// @Description 将文件夹及其全部内容复制到其他文件夹或工作流，只复制当前用户可访问的文件
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param id path int true "文件夹ID"
+// @Param request body services.MoveRequest true "目标位置"
+// @Success 200 {object} Response{data=services.FolderInfo} "复制成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "无权限访问目标工作流或工作流只读"
+// @Failure 409 {object} Response "目标位置已存在同名文件夹"
+// @Failure 507 {object} Response "存储配额不足"
+// @Router /api/files/folders/{id}/copy [post]
+// @Security BearerAuth
+func (h *FileHandler) CopyFolder(c *gin.Context) {
+	h.moveOrCopy(c, "文件夹ID格式错误", "复制文件夹", func(id uint, req *services.MoveRequest, userID uint) (interface{}, error) {
+		return h.fileService.CopyFolder(id, req, userID)
+	})
+}
+
+// moveOrCopy 解析移动或复制请求并执行操作
+func (h *FileHandler) moveOrCopy(c *gin.Context, invalidID, action string, op func(id uint, req *services.MoveRequest, userID uint) (interface{}, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, invalidID))
+		return
+	}
+
+	var req services.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	result, err := op(uint(id), &req, userID.(uint))
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrNameConflict):
+			status = http.StatusConflict
+		case isPermissionError(err), errors.Is(err, services.ErrWorkflowReadOnly):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrQuotaExceeded):
+			status = http.StatusInsufficientStorage
+		}
+		c.JSON(status, ErrorResponse(status, action+"失败: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(action+"成功", result))
+}
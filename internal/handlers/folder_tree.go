@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFolderTree 获取工作流的文件夹树
+// @Summary 获取文件夹树
+// @Description 返回工作流的完整文件夹层级，每个文件夹包含直接文件数、大小及包括子文件夹在内的总文件数和总大小（只统计自己可见的文件）
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param workflow_id query int true "工作流ID"
+// @Success 200 {object} Response{data=services.FolderTree} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "无权限访问该工作流"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/files/tree [get]
+// @Security BearerAuth
+func (h *FileHandler) GetFolderTree(c *gin.Context) {
+	workflowID, err := strconv.ParseUint(c.Query("workflow_id"), 10, 32)
+	if err != nil || workflowID == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "工作流ID格式错误"))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	tree, err := h.fileService.GetFolderTree(uint(workflowID), userID.(uint))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "获取文件夹树失败: ", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取文件夹树成功", tree))
+}
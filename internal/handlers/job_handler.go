@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler 后台任务处理器
+type JobHandler struct {
+	jobQueue *services.JobQueue
+}
+
+// NewJobHandler 创建后台任务处理器
+func NewJobHandler(jobQueue *services.JobQueue) *JobHandler {
+	return &JobHandler{
+		jobQueue: jobQueue,
+	}
+}
+
+// ListJobs 获取后台任务列表（管理员）
+// @Summary 获取后台任务列表
+// @Description 按创建时间倒序分页获取后台任务，可按状态和类型筛选
+// @Tags 后台任务
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param status query string false "任务状态：pending、running、completed、dead、cancelled"
+// @Param type query string false "任务类型，如 download.zip"
+// @Param page query int false "页码，默认 1"
+// @Param page_size query int false "每页数量，默认 20，最大 100"
+// @Success 200 {object} Response "获取成功"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/v1/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	jobs, total, err := h.jobQueue.ListJobs(c.Query("status"), c.Query("type"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取任务列表成功", gin.H{
+		"jobs": jobs,
+		"pagination": gin.H{
+			"page":       page,
+			"page_size":  pageSize,
+			"total":      total,
+			"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}))
+}
+
+// GetJobStats 获取后台任务统计（管理员）
+// @Summary 获取后台任务统计
+// @Description 按任务类型和状态统计任务数量
+// @Tags 后台任务
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} Response{data=[]services.JobStat} "获取成功"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/v1/jobs/stats [get]
+func (h *JobHandler) GetJobStats(c *gin.Context) {
+	stats, err := h.jobQueue.GetStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取任务统计成功", stats))
+}
+
+// GetJob 获取后台任务详情（管理员）
+// @Summary 获取后台任务详情
+// @Description 获取任务参数、执行次数和最近一次错误
+// @Tags 后台任务
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "任务ID"
+// @Success 200 {object} Response{data=models.Job} "获取成功"
+// @Failure 404 {object} Response "任务不存在"
+// @Router /api/v1/jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobQueue.GetJob(jobID)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取任务成功", job))
+}
+
+// RetryJob 重试后台任务（管理员）
+// @Summary 重试后台任务
+// @Description 重新执行已进入死信或已取消的任务，执行次数清零
+// @Tags 后台任务
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "任务ID"
+// @Success 200 {object} Response{data=models.Job} "重试成功"
+// @Failure 404 {object} Response "任务不存在"
+// @Failure 409 {object} Response "任务状态不允许重试"
+// @Router /api/v1/jobs/{id}/retry [post]
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobQueue.RetryJob(jobID)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("任务已重新加入队列", job))
+}
+
+// CancelJob 取消后台任务（管理员）
+// @Summary 取消后台任务
+// @Description 取消等待执行（包括等待重试）的任务
+// @Tags 后台任务
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "任务ID"
+// @Success 200 {object} Response{data=models.Job} "取消成功"
+// @Failure 404 {object} Response "任务不存在"
+// @Failure 409 {object} Response "任务状态不允许取消"
+// @Router /api/v1/jobs/{id}/cancel [post]
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobQueue.CancelJob(jobID)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("任务已取消", job))
+}
+
+// parseJobID 解析路径中的任务ID，失败时写入错误响应
+func parseJobID(c *gin.Context) (uint, bool) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的任务ID"))
+		return 0, false
+	}
+	return uint(jobID), true
+}
+
+// respondJobError 按错误类型写入后台任务接口的错误响应
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+	case errors.Is(err, services.ErrJobNotRetryable), errors.Is(err, services.ErrJobNotPending):
+		c.JSON(http.StatusConflict, ErrorResponse(http.StatusConflict, err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+}
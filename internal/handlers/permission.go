@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isPermissionError 判断是否为权限服务返回的无权限错误
+func isPermissionError(err error) bool {
+	return errors.Is(err, services.ErrPermissionDenied) || errors.Is(err, services.ErrWorkflowAccessDenied)
+}
+
+// respondError 服务错误响应，无权限时返回 403，其他错误返回 status
+func respondError(c *gin.Context, status int, prefix string, err error) {
+	if isPermissionError(err) {
+		status = http.StatusForbidden
+	}
+	c.JSON(status, ErrorResponse(status, prefix+err.Error()))
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/middleware"
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaHandler 存储配额处理器
+type QuotaHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewQuotaHandler 创建存储配额处理器
+func NewQuotaHandler(quotaService *services.QuotaService) *QuotaHandler {
+	return &QuotaHandler{
+		quotaService: quotaService,
+	}
+}
+
+// GetMyUsage 获取当前用户的配额使用情况
+// @Summary 获取配额使用情况
+// @Description 获取当前用户、所在用户组以及指定工作流的已使用空间与配额
+// @Tags 存储配额
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param workflow_id query int false "工作流ID"
+// @Success 200 {object} Response{data=[]services.QuotaUsage} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/v1/quotas/usage [get]
+func (h *QuotaHandler) GetMyUsage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	var workflowID uint64
+	if idStr := c.Query("workflow_id"); idStr != "" {
+		var err error
+		if workflowID, err = strconv.ParseUint(idStr, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+			return
+		}
+	}
+
+	usages, err := h.quotaService.GetUserUsage(userID, uint(workflowID))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取配额使用情况成功", usages))
+}
+
+// ListQuotas 获取配额列表（管理员）
+// @Summary 获取配额列表
+// @Description 获取已设置的配额及其使用情况
+// @Tags 存储配额
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param scope_type query string false "配额范围：user, group, workflow"
+// @Success 200 {object} Response{data=[]services.QuotaUsage} "获取成功"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/v1/quotas [get]
+func (h *QuotaHandler) ListQuotas(c *gin.Context) {
+	usages, err := h.quotaService.ListQuotas(c.Query("scope_type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取配额列表成功", usages))
+}
+
+// SetQuota 设置配额（管理员）
+// @Summary 设置配额
+// @Description 为用户、用户组或工作流设置存储配额，已存在时更新
+// @Tags 存储配额
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body services.SetQuotaRequest true "配额"
+// @Success 200 {object} Response{data=services.QuotaUsage} "设置成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/quotas [put]
+func (h *QuotaHandler) SetQuota(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	var req services.SetQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	usage, err := h.quotaService.SetQuota(&req, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("设置配额成功", usage))
+}
+
+// GetQuota 获取指定范围的配额使用情况（管理员）
+// @Summary 获取配额
+// @Description 获取用户、用户组或工作流的已使用空间与配额
+// @Tags 存储配额
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param scope_type path string true "配额范围：user, group, workflow"
+// @Param scope_id path int true "用户、用户组或工作流ID"
+// @Success 200 {object} Response{data=services.QuotaUsage} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/quotas/{scope_type}/{scope_id} [get]
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+	scopeType, scopeID, ok := parseQuotaScope(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.quotaService.GetUsage(scopeType, scopeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取配额成功", usage))
+}
+
+// DeleteQuota 删除配额（管理员）
+// @Summary 删除配额
+// @Description 删除用户、用户组或工作流的存储配额，删除后不再限制
+// @Tags 存储配额
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param scope_type path string true "配额范围：user, group, workflow"
+// @Param scope_id path int true "用户、用户组或工作流ID"
+// @Success 200 {object} Response "删除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/quotas/{scope_type}/{scope_id} [delete]
+func (h *QuotaHandler) DeleteQuota(c *gin.Context) {
+	scopeType, scopeID, ok := parseQuotaScope(c)
+	if !ok {
+		return
+	}
+
+	if err := h.quotaService.DeleteQuota(scopeType, scopeID); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("删除配额成功", nil))
+}
+
+// parseQuotaScope 解析路径中的配额范围，失败时写入错误响应
+func parseQuotaScope(c *gin.Context) (string, uint, bool) {
+	scopeType := c.Param("scope_type")
+	switch scopeType {
+	case models.QuotaScopeUser, models.QuotaScopeGroup, models.QuotaScopeWorkflow:
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的配额范围"))
+		return "", 0, false
+	}
+
+	scopeID, err := strconv.ParseUint(c.Param("scope_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的ID"))
+		return "", 0, false
+	}
+	return scopeType, uint(scopeID), true
+}
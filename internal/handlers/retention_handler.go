@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/middleware"
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler 历史版本清理处理器
+type RetentionHandler struct {
+	retentionService *services.RetentionService
+}
+
+// NewRetentionHandler 创建历史版本清理处理器
+func NewRetentionHandler(retentionService *services.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: retentionService,
+	}
+}
+
+// PreviewWorkflow 预览工作流的历史版本清理
+// @Summary 预览历史版本清理
+// @Description 统计工作流中非当前版本的数量和大小，以及宽限期结束时间，不会删除任何数据
+// @Tags 工作流管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "工作流ID"
+// @Success 200 {object} Response{data=services.WorkflowRetention} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/workflows/{id}/retention [get]
+func (h *RetentionHandler) PreviewWorkflow(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+		return
+	}
+
+	result, err := h.retentionService.Preview(uint(workflowID), userID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取历史版本清理预览成功", result))
+}
+
+// Run 执行历史版本清理（管理员）
+// @Summary 执行历史版本清理
+// @Description 清理所有已完成且超过宽限期的工作流的非当前版本，dry_run 为 true 时只返回报告
+// @Tags 工作流管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param dry_run query bool false "是否试运行，默认 true"
+// @Success 200 {object} Response{data=services.RetentionReport} "执行成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/workflows/retention/run [post]
+func (h *RetentionHandler) Run(c *gin.Context) {
+	dryRun := true
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的 dry_run 参数"))
+			return
+		}
+	}
+
+	report, err := h.retentionService.Run(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("历史版本清理完成", report))
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/middleware"
+	"mcs-backend/internal/services"
+	"mcs-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShareHandler 文件分享处理器
+type ShareHandler struct {
+	shareService *services.ShareService
+}
+
+// NewShareHandler 创建文件分享处理器
+func NewShareHandler(shareService *services.ShareService) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+	}
+}
+
+// CreateShare 创建分享
+// @Summary 创建分享
+// @Description 为文件或文件夹创建分享链接，可设置密码、过期时间和访问次数限制
+// @Tags 文件分享
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body services.CreateShareRequest true "创建分享请求"
+// @Success 200 {object} Response{data=services.ShareInfo} "创建成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/v1/shares [post]
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	var req services.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	share, err := h.shareService.CreateShare(&req, userID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("创建分享成功", share))
+}
+
+// ListShares 获取我的分享列表
+// @Summary 获取分享列表
+// @Description 获取当前用户创建的分享，包括已撤销的分享
+// @Tags 文件分享
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Success 200 {object} Response "获取成功"
+// @Failure 401 {object} Response "未授权"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/v1/shares [get]
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	page := 1
+	pageSize := 10
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 && ps <= 100 {
+			pageSize = ps
+		}
+	}
+
+	shares, total, err := h.shareService.ListShares(userID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取分享列表成功", gin.H{
+		"shares": shares,
+		"pagination": gin.H{
+			"page":       page,
+			"page_size":  pageSize,
+			"total":      total,
+			"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}))
+}
+
+// UpdateShare 更新分享
+// @Summary 更新分享
+// @Description 修改分享的密码、过期时间或访问次数限制，仅分享创建者可操作
+// @Tags 文件分享
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "分享ID"
+// @Param request body services.UpdateShareRequest true "更新分享请求"
+// @Success 200 {object} Response{data=services.ShareInfo} "更新成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/v1/shares/{id} [put]
+func (h *ShareHandler) UpdateShare(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	shareID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的分享ID"))
+		return
+	}
+
+	var req services.UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	share, err := h.shareService.UpdateShare(uint(shareID), &req, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("更新分享成功", share))
+}
+
+// RevokeShare 撤销分享
+// @Summary 撤销分享
+// @Description 撤销分享链接，撤销后链接立即失效，仅分享创建者可操作
+// @Tags 文件分享
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "分享ID"
+// @Success 200 {object} Response "撤销成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/v1/shares/{id} [delete]
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	shareID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的分享ID"))
+		return
+	}
+
+	if err := h.shareService.RevokeShare(uint(shareID), userID); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("撤销分享成功", nil))
+}
+
+// AccessShare 通过分享链接下载（无需登录）
+// @Summary 访问分享
+// @Description 下载分享的文件，分享的是文件夹时下载包含其全部内容的ZIP；有密码的分享通过 X-Share-Password 请求头或 POST 请求体提供密码
+// @Tags 文件分享
+// @Accept json,x-www-form-urlencoded
+// @Produce application/octet-stream
+// @Param code path string true "分享码"
+// @Param X-Share-Password header string false "分享密码"
+// @Param request body services.AccessShareRequest false "分享密码（POST）"
+// @Success 200 {file} binary "文件内容"
+// @Failure 401 {object} Response "需要密码或密码错误"
+// @Failure 404 {object} Response "分享不存在或已失效"
+// @Failure 410 {object} Response "分享已过期或访问次数已达上限"
+// @Failure 429 {object} Response "密码错误次数过多"
+// @Router /s/{code} [get]
+// @Router /s/{code} [post]
+func (h *ShareHandler) AccessShare(c *gin.Context) {
+	// 密码不通过查询参数传递，避免出现在访问日志、浏览器历史和 Referer 中
+	password := c.GetHeader("X-Share-Password")
+	if password == "" && c.Request.Method == http.MethodPost {
+		var req services.AccessShareRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+			return
+		}
+		password = req.Password
+	}
+
+	content, err := h.shareService.OpenShare(c.Param("code"), &services.ShareAccess{
+		Password:  password,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		shareErrorResponse(c, err)
+		return
+	}
+
+	if content.Folder != nil {
+		c.Header("Content-Description", "File Transfer")
+		c.Header("Content-Transfer-Encoding", "binary")
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", contentDisposition(content.Folder.Name+".zip"))
+		c.Status(http.StatusOK)
+		if err := h.shareService.WriteFolderZip(c.Writer, content.Share, content.Folder); err != nil {
+			// 响应已开始发送，只能中断连接
+			c.Error(err)
+			c.Abort()
+		}
+		return
+	}
+
+	file, err := h.shareService.OpenFile(content.File)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, "文件不存在于服务器"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, "读取文件失败"))
+		return
+	}
+	defer file.Close()
+
+	serveFileContent(c, file)
+}
+
+// shareErrorResponse 分享访问错误响应
+func shareErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSharePasswordRequired), errors.Is(err, services.ErrSharePasswordInvalid):
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, err.Error()))
+	case errors.Is(err, services.ErrShareNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+	case errors.Is(err, services.ErrShareExpired), errors.Is(err, services.ErrShareViewLimit):
+		c.JSON(http.StatusGone, ErrorResponse(http.StatusGone, err.Error()))
+	case errors.Is(err, services.ErrShareTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, ErrorResponse(http.StatusTooManyRequests, err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, "访问分享失败"))
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/middleware"
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TagHandler 标签处理器
+type TagHandler struct {
+	tagService *services.TagService
+}
+
+// NewTagHandler 创建标签处理器
+func NewTagHandler(tagService *services.TagService) *TagHandler {
+	return &TagHandler{
+		tagService: tagService,
+	}
+}
+
+// ListTags 获取标签列表
+// @Summary 获取标签列表
+// @Description 获取全部标签及其使用次数，按使用次数降序排列
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param keyword query string false "标签名称关键词"
+// @Success 200 {object} Response{data=[]services.TagDetail} "获取成功"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/v1/tags [get]
+func (h *TagHandler) ListTags(c *gin.Context) {
+	tags, err := h.tagService.ListTags(c.Query("keyword"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取标签列表成功", tags))
+}
+
+// GetTag 获取标签详情
+// @Summary 获取标签详情
+// @Description 获取标签及其使用次数
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "标签ID"
+// @Success 200 {object} Response{data=services.TagDetail} "获取成功"
+// @Failure 404 {object} Response "标签不存在"
+// @Router /api/v1/tags/{id} [get]
+func (h *TagHandler) GetTag(c *gin.Context) {
+	tagID, ok := parseTagID(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.tagService.GetTag(tagID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取标签成功", tag))
+}
+
+// CreateTag 创建标签（管理员）
+// @Summary 创建标签
+// @Description 创建全局标签
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body services.TagRequest true "标签"
+// @Success 200 {object} Response{data=services.TagDetail} "创建成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/tags [post]
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	var req services.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	tag, err := h.tagService.CreateTag(&req, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("创建标签成功", tag))
+}
+
+// UpdateTag 更新标签（管理员）
+// @Summary 更新标签
+// @Description 重命名标签或修改标签颜色
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "标签ID"
+// @Param request body services.TagRequest true "标签"
+// @Success 200 {object} Response{data=services.TagDetail} "更新成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/tags/{id} [put]
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	tagID, ok := parseTagID(c)
+	if !ok {
+		return
+	}
+
+	var req services.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	tag, err := h.tagService.UpdateTag(tagID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("更新标签成功", tag))
+}
+
+// DeleteTag 删除标签（管理员）
+// @Summary 删除标签
+// @Description 删除标签，同时移除所有文件上的该标签
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "标签ID"
+// @Success 200 {object} Response "删除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/tags/{id} [delete]
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	tagID, ok := parseTagID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tagService.DeleteTag(tagID); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("删除标签成功", nil))
+}
+
+// MergeTags 合并标签（管理员）
+// @Summary 合并标签
+// @Description 将源标签合并到目标标签，使用源标签的文件改为使用目标标签，合并后删除源标签
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body services.MergeTagsRequest true "合并标签请求"
+// @Success 200 {object} Response{data=services.TagDetail} "合并成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/tags/merge [post]
+func (h *TagHandler) MergeTags(c *gin.Context) {
+	var req services.MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	tag, err := h.tagService.MergeTags(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("合并标签成功", tag))
+}
+
+// TagFiles 批量添加标签
+// @Summary 批量添加标签
+// @Description 为多个文件添加标签，只处理有修改权限的文件，不存在或无权限的文件在 skipped 中返回
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body services.BulkTagRequest true "批量标签请求"
+// @Success 200 {object} Response{data=services.BulkTagResult} "添加成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/tags/bulk/add [post]
+func (h *TagHandler) TagFiles(c *gin.Context) {
+	h.bulk(c, h.tagService.TagFiles, "批量添加标签成功")
+}
+
+// UntagFiles 批量移除标签
+// @Summary 批量移除标签
+// @Description 从多个文件移除标签，只处理有修改权限的文件，不存在或无权限的文件在 skipped 中返回
+// @Tags 标签管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param request body services.BulkTagRequest true "批量标签请求"
+// @Success 200 {object} Response{data=services.BulkTagResult} "移除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/v1/tags/bulk/remove [post]
+func (h *TagHandler) UntagFiles(c *gin.Context) {
+	h.bulk(c, h.tagService.UntagFiles, "批量移除标签成功")
+}
+
+// bulk 执行批量添加或移除标签
+func (h *TagHandler) bulk(c *gin.Context, apply func(*services.BulkTagRequest, uint) (*services.BulkTagResult, error), message string) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	var req services.BulkTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	result, err := apply(&req, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse(message, result))
+}
+
+// parseTagID 解析路径中的标签ID，失败时写入错误响应
+func parseTagID(c *gin.Context) (uint, bool) {
+	tagID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的标签ID"))
+		return 0, false
+	}
+	return uint(tagID), true
+}
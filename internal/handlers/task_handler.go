@@ -50,7 +50,7 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 
 	task, err := h.taskService.CreateTask(&req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -86,7 +86,7 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 
 	task, err := h.taskService.GetTaskByID(uint(taskID), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+		respondError(c, http.StatusNotFound, "", err)
 		return
 	}
 
@@ -224,7 +224,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 
 	task, err := h.taskService.UpdateTask(uint(taskID), &req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -259,7 +259,7 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	}
 
 	if err := h.taskService.DeleteTask(uint(taskID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -302,7 +302,7 @@ func (h *TaskHandler) ChangeTaskStatus(c *gin.Context) {
 
 	task, err := h.taskService.ChangeTaskStatus(uint(taskID), &req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -345,7 +345,7 @@ func (h *TaskHandler) AddToStagingArea(c *gin.Context) {
 
 	staging, err := h.taskService.AddToStagingArea(uint(taskID), &req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -381,7 +381,7 @@ func (h *TaskHandler) GetStagingArea(c *gin.Context) {
 
 	staging, err := h.taskService.GetStagingArea(uint(taskID), userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
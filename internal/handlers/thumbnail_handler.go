@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"mcs-backend/internal/media"
+	"mcs-backend/internal/middleware"
+	"mcs-backend/internal/services"
+	"mcs-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// thumbnailCacheControl 缩略图缓存策略，内容变化后 ETag 随之变化
+const thumbnailCacheControl = "private, max-age=3600"
+
+// ThumbnailHandler 缩略图处理器
+type ThumbnailHandler struct {
+	thumbnailService *services.ThumbnailService
+}
+
+// NewThumbnailHandler 创建缩略图处理器
+func NewThumbnailHandler(thumbnailService *services.ThumbnailService) *ThumbnailHandler {
+	return &ThumbnailHandler{
+		thumbnailService: thumbnailService,
+	}
+}
+
+// GetThumbnail 获取文件缩略图
+// @Summary 获取文件缩略图
+// @Description 获取图片、RAW 文件或视频的 JPEG 缩略图，首次请求时生成；支持 If-None-Match、If-Modified-Since 条件请求
+// @Tags 文件管理
+// @Produce image/jpeg
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "文件ID"
+// @Param size query string false "缩略图尺寸：small(256)、medium(720)、large(1600)，默认 medium"
+// @Success 200 {file} binary "缩略图"
+// @Success 304 "缩略图未变化"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 404 {object} Response "文件不存在"
+// @Failure 415 {object} Response "文件类型不支持缩略图"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/v1/files/{id}/thumbnail [get]
+func (h *ThumbnailHandler) GetThumbnail(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的文件ID"))
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权访问"))
+		return
+	}
+
+	thumbnail, err := h.thumbnailService.GetThumbnail(c.Request.Context(), uint(fileID), userID, c.Query("size"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrThumbnailSize):
+			c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		case errors.Is(err, services.ErrThumbnailUnsupported), errors.Is(err, media.ErrImageTooLarge):
+			c.JSON(http.StatusUnsupportedMediaType, ErrorResponse(http.StatusUnsupportedMediaType, err.Error()))
+		case errors.Is(err, storage.ErrNotExist):
+			c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, "文件不存在于服务器"))
+		case errors.Is(err, services.ErrThumbnailNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		return
+	}
+
+	content, err := os.Open(thumbnail.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, "读取缩略图失败"))
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("ETag", thumbnail.ETag)
+	c.Header("Cache-Control", thumbnailCacheControl)
+	http.ServeContent(c.Writer, c.Request, "", thumbnail.ModTime, content)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mcs-backend/internal/middleware"
+	"mcs-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrashHandler 回收站处理器
+type TrashHandler struct {
+	trashService *services.TrashService
+}
+
+// NewTrashHandler 创建回收站处理器
+func NewTrashHandler(trashService *services.TrashService) *TrashHandler {
+	return &TrashHandler{
+		trashService: trashService,
+	}
+}
+
+// ListTrash 获取回收站列表
+// @Summary 获取回收站列表
+// @Description 不指定工作流时返回自己删除的文件和文件夹；指定工作流时返回工作流中所有成员删除的项目（需有查看工作流的权限）
+// @Tags 回收站
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param workflow_id query int false "工作流ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} Response{data=services.TrashListResponse} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "不是工作流成员"
+// @Router /api/trash [get]
+func (h *TrashHandler) ListTrash(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, ok := parseTrashWorkflowID(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.trashService.ListTrash(userID, workflowID, page, pageSize)
+	if err != nil {
+		respondTrashError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取回收站成功", result))
+}
+
+// RestoreItem 恢复回收站项目
+// @Summary 恢复回收站项目
+// @Description 恢复自己删除的文件或文件夹，原文件夹已删除时一并恢复，恢复文件夹时与其同时删除的内容一起恢复
+// @Tags 回收站
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param type path string true "项目类型：file 或 folder"
+// @Param id path int true "文件或文件夹ID"
+// @Success 200 {object} Response "恢复成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 404 {object} Response "回收站中不存在该项目"
+// @Failure 409 {object} Response "原位置已存在同名文件或文件夹"
+// @Router /api/trash/{type}/{id}/restore [post]
+func (h *TrashHandler) RestoreItem(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	itemType, id, ok := parseTrashItem(c)
+	if !ok {
+		return
+	}
+
+	if err := h.trashService.Restore(itemType, id, userID); err != nil {
+		respondTrashError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("恢复成功", nil))
+}
+
+// PurgeItem 彻底删除回收站项目
+// @Summary 彻底删除回收站项目
+// @Description 彻底删除自己删除的文件或文件夹（包括其中的全部内容），不可恢复
+// @Tags 回收站
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param type path string true "项目类型：file 或 folder"
+// @Param id path int true "文件或文件夹ID"
+// @Success 200 {object} Response{data=services.TrashPurgeResult} "删除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 404 {object} Response "回收站中不存在该项目"
+// @Router /api/trash/{type}/{id} [delete]
+func (h *TrashHandler) PurgeItem(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	itemType, id, ok := parseTrashItem(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.trashService.Purge(itemType, id, userID)
+	if err != nil {
+		respondTrashError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("彻底删除成功", result))
+}
+
+// EmptyTrash 清空回收站
+// @Summary 清空回收站
+// @Description 彻底删除自己回收站中的全部项目，指定工作流时只删除该工作流中的项目
+// @Tags 回收站
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param workflow_id query int false "工作流ID"
+// @Success 200 {object} Response{data=services.TrashPurgeResult} "清空成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Router /api/trash [delete]
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, ok := parseTrashWorkflowID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.trashService.EmptyTrash(userID, workflowID)
+	if err != nil {
+		respondTrashError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("清空回收站成功", result))
+}
+
+// PurgeExpired 立即清理过期的回收站项目（管理员）
+// @Summary 清理过期的回收站项目
+// @Description 彻底删除所有用户回收站中超过保留期的项目，与后台定时清理相同
+// @Tags 回收站
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} Response{data=services.TrashPurgeResult} "清理成功"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/trash/purge [post]
+func (h *TrashHandler) PurgeExpired(c *gin.Context) {
+	result, err := h.trashService.PurgeExpired()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(http.StatusInternalServerError, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("清理过期回收站项目成功", result))
+}
+
+// parseTrashWorkflowID 解析可选的 workflow_id 查询参数
+func parseTrashWorkflowID(c *gin.Context) (uint, bool) {
+	idStr := c.Query("workflow_id")
+	if idStr == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseTrashItem 解析回收站项目的类型和ID
+func parseTrashItem(c *gin.Context) (string, uint, bool) {
+	itemType := c.Param("type")
+	if itemType != services.TrashItemFile && itemType != services.TrashItemFolder {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的项目类型，应为 file 或 folder"))
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的ID"))
+		return "", 0, false
+	}
+	return itemType, uint(id), true
+}
+
+// respondTrashError 回收站操作错误响应
+func respondTrashError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrTrashItemNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrNameConflict), errors.Is(err, services.ErrTrashFolderNotEmpty):
+		status = http.StatusConflict
+	case isPermissionError(err), errors.Is(err, services.ErrWorkflowReadOnly):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrQuotaExceeded):
+		status = http.StatusInsufficientStorage
+	}
+	c.JSON(status, ErrorResponse(status, err.Error()))
+}
@@ -1,8 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"io"
-	"mcs-backend/internal/config"
 	"mcs-backend/internal/services"
 	"net/http"
 	"strconv"
@@ -16,21 +17,24 @@ type UploadHandler struct {
 }
 
 // NewUploadHandler 创建文件上传处理器
-func NewUploadHandler(cfg *config.Config) *UploadHandler {
+func NewUploadHandler(uploadService *services.UploadService) *UploadHandler {
 	return &UploadHandler{
-		uploadService: services.NewUploadService(cfg),
+		uploadService: uploadService,
 	}
 }
 
 // InitUpload 初始化上传
 // @Summary 初始化文件上传
-// @Description 初始化文件上传，支持秒传检测
+// @Description 初始化文件上传，支持秒传检测；提供 file_id 时上传为该文件的新版本
 // @Tags 文件上传
 // @Accept json
 // @Produce json
 // @Param request body services.InitUploadRequest true "初始化上传请求"
 // @Success 200 {object} Response{data=services.InitUploadResponse} "初始化成功"
 // @Failure 400 {object} Response "请求参数错误"
+// @Failure 413 {object} Response "文件过大（error_code: FILE_TOO_LARGE）"
+// @Failure 415 {object} Response "文件类型不允许（error_code: FILE_TYPE_NOT_ALLOWED）"
+// @Failure 507 {object} Response "超出存储配额（error_code: QUOTA_EXCEEDED）"
 // @Failure 500 {object} Response "服务器错误"
 // @Router /api/upload/init [post]
 // @Security BearerAuth
@@ -50,7 +54,7 @@ func (h *UploadHandler) InitUpload(c *gin.Context) {
 
 	response, err := h.uploadService.InitUpload(&req, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "初始化上传失败: "+err.Error()))
+		uploadErrorResponse(c, "初始化上传失败: ", err)
 		return
 	}
 
@@ -73,6 +77,7 @@ func (h *UploadHandler) InitUpload(c *gin.Context) {
 // @Param chunk formData file true "分片文件"
 // @Success 200 {object} Response "上传成功"
 // @Failure 400 {object} Response "请求参数错误"
+// @Failure 404 {object} Response "上传任务不存在"
 // @Failure 500 {object} Response "服务器错误"
 // @Router /api/upload/chunk [post]
 // @Security BearerAuth
@@ -117,9 +122,15 @@ func (h *UploadHandler) UploadChunk(c *gin.Context) {
 		return
 	}
 
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
 	// 上传分片
-	if err := h.uploadService.UploadChunk(uploadID, chunkIndex, chunkData, chunkMD5); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "上传分片失败: "+err.Error()))
+	if err := h.uploadService.UploadChunk(uploadID, userID.(uint), chunkIndex, chunkData, chunkMD5); err != nil {
+		uploadErrorResponse(c, "上传分片失败: ", err)
 		return
 	}
 
@@ -135,6 +146,9 @@ func (h *UploadHandler) UploadChunk(c *gin.Context) {
 // @Param upload_id path string true "上传ID"
 // @Success 200 {object} Response{data=services.File} "上传完成"
 // @Failure 400 {object} Response "请求参数错误"
+// @Failure 403 {object} Response "无上传权限或工作流只读"
+// @Failure 404 {object} Response "上传任务不存在"
+// @Failure 415 {object} Response "文件内容与扩展名不符（error_code: CONTENT_TYPE_MISMATCH）"
 // @Failure 500 {object} Response "服务器错误"
 // @Router /api/upload/complete/{upload_id} [post]
 // @Security BearerAuth
@@ -145,15 +159,172 @@ func (h *UploadHandler) CompleteUpload(c *gin.Context) {
 		return
 	}
 
-	file, err := h.uploadService.CompleteUpload(uploadID)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	file, err := h.uploadService.CompleteUpload(uploadID, userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "完成上传失败: "+err.Error()))
+		uploadErrorResponse(c, "完成上传失败: ", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse("文件上传完成", file))
 }
 
+// DirectUpload 单次请求上传文件
+// @Summary 单次请求上传文件
+// @Description 以 multipart/form-data 单次上传小文件，表单字段需位于文件之前；提供 md5_hash 和 file_size 时支持秒传，提供 file_id 时上传为该文件的新版本
+// @Tags 文件上传
+// @Accept multipart/form-data
+// @Produce json
+// @Param md5_hash formData string false "文件MD5"
+// @Param file_size formData int false "文件大小"
+// @Param folder_id formData int false "文件夹ID"
+// @Param workflow_id formData int false "工作流ID"
+// @Param task_id formData int false "任务ID"
+// @Param file_id formData int false "上传为该文件的新版本"
+// @Param change_log formData string false "新版本的变更说明"
+// @Param description formData string false "文件描述"
+// @Param is_private formData bool false "是否私有"
+// @Param file formData file true "文件"
+// @Success 200 {object} Response{data=services.File} "上传成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 413 {object} Response "文件过大（error_code: FILE_TOO_LARGE）"
+// @Failure 415 {object} Response "文件类型不允许或内容与扩展名不符（error_code: FILE_TYPE_NOT_ALLOWED, CONTENT_TYPE_MISMATCH）"
+// @Failure 507 {object} Response "超出存储配额（error_code: QUOTA_EXCEEDED）"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/upload/direct [post]
+// @Security BearerAuth
+func (h *UploadHandler) DirectUpload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "请求格式错误: "+err.Error()))
+		return
+	}
+
+	// 逐个读取表单部分，文件内容直接流式处理，不在内存或磁盘上缓存整个请求
+	var req services.DirectUploadRequest
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, ErrorResponse(400, "缺少上传文件"))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(400, "读取请求失败: "+err.Error()))
+			return
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, 4096))
+			part.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse(400, "读取表单字段失败: "+err.Error()))
+				return
+			}
+			if err := setDirectUploadField(&req, part.FormName(), string(value)); err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse(400, "请求参数错误: "+err.Error()))
+				return
+			}
+			continue
+		}
+
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		file, err := h.uploadService.DirectUpload(&req, part.FileName(), part, userID.(uint))
+		part.Close()
+		if err != nil {
+			uploadErrorResponse(c, "上传文件失败: ", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, SuccessResponse("文件上传成功", file))
+		return
+	}
+}
+
+// uploadErrorResponse 上传错误响应，会话不存在、违反上传策略、超出存储配额、无上传权限或工作流只读时返回对应的错误码
+func uploadErrorResponse(c *gin.Context, prefix string, err error) {
+	if errors.Is(err, services.ErrUploadSessionNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse(404, prefix+err.Error()))
+		return
+	}
+	if errors.Is(err, services.ErrVersionUnchanged) {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, prefix+err.Error()))
+		return
+	}
+	if errors.Is(err, services.ErrWorkflowReadOnly) || isPermissionError(err) {
+		c.JSON(http.StatusForbidden, ErrorResponse(403, prefix+err.Error()))
+		return
+	}
+
+	var policyErr *services.UploadPolicyError
+	if !errors.As(err, &policyErr) {
+		c.JSON(http.StatusInternalServerError, ErrorResponse(500, prefix+err.Error()))
+		return
+	}
+
+	status := http.StatusUnsupportedMediaType
+	switch policyErr.Code {
+	case services.UploadErrFileTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case services.UploadErrQuotaExceeded:
+		status = http.StatusInsufficientStorage
+	}
+	c.JSON(status, Response{
+		Code:    status,
+		Message: prefix + policyErr.Error(),
+		Data:    gin.H{"error_code": policyErr.Code},
+	})
+}
+
+// setDirectUploadField 设置单次上传的表单字段
+func setDirectUploadField(req *services.DirectUploadRequest, name, value string) error {
+	var err error
+	switch name {
+	case "md5_hash":
+		req.MD5Hash = value
+	case "file_size":
+		req.FileSize, err = strconv.ParseInt(value, 10, 64)
+	case "folder_id":
+		req.FolderID, err = parseUintField(value)
+	case "workflow_id":
+		req.WorkflowID, err = parseUintField(value)
+	case "task_id":
+		req.TaskID, err = parseUintField(value)
+	case "description":
+		req.Description = value
+	case "is_private":
+		req.IsPrivate, err = strconv.ParseBool(value)
+	case "file_id":
+		req.FileID, err = parseUintField(value)
+	case "change_log":
+		req.ChangeLog = value
+	}
+	if err != nil {
+		return fmt.Errorf("%s 格式错误", name)
+	}
+	return nil
+}
+
+// parseUintField 解析无符号整数表单字段
+func parseUintField(value string) (uint, error) {
+	v, err := strconv.ParseUint(value, 10, 32)
+	return uint(v), err
+}
+
 // GetUploadProgress 获取上传进度
 // @Summary 获取上传进度
 // @Description 获取指定上传任务的进度信息
@@ -173,7 +344,13 @@ func (h *UploadHandler) GetUploadProgress(c *gin.Context) {
 		return
 	}
 
-	progress, err := h.uploadService.GetUploadProgress(uploadID)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	progress, err := h.uploadService.GetUploadProgress(uploadID, userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse(404, "获取上传进度失败: "+err.Error()))
 		return
@@ -182,6 +359,45 @@ func (h *UploadHandler) GetUploadProgress(c *gin.Context) {
 	c.JSON(http.StatusOK, SuccessResponse("获取上传进度成功", progress))
 }
 
+// ResumeUpload 断点续传
+// @Summary 查询断点续传信息
+// @Description 根据文件MD5和大小查找当前用户未完成的上传会话，返回缺失的分片索引
+// @Tags 文件上传
+// @Accept json
+// @Produce json
+// @Param request body services.ResumeUploadRequest true "断点续传查询请求"
+// @Success 200 {object} Response{data=services.ResumeUploadResponse} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 404 {object} Response "没有可续传的上传任务"
+// @Failure 500 {object} Response "服务器错误"
+// @Router /api/upload/resume [post]
+// @Security BearerAuth
+func (h *UploadHandler) ResumeUpload(c *gin.Context) {
+	var req services.ResumeUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(400, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	resume, err := h.uploadService.ResumeUpload(&req, userID.(uint))
+	if err != nil {
+		if errors.Is(err, services.ErrUploadSessionNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse(404, "没有可续传的上传任务"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse(500, "获取续传信息失败: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取续传信息成功", resume))
+}
+
 // CancelUpload 取消上传
 // @Summary 取消文件上传
 // @Description 取消指定的上传任务并清理临时文件
@@ -201,10 +417,16 @@ func (h *UploadHandler) CancelUpload(c *gin.Context) {
 		return
 	}
 
-	if err := h.uploadService.CancelUpload(uploadID); err != nil {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(401, "未授权访问"))
+		return
+	}
+
+	if err := h.uploadService.CancelUpload(uploadID, userID.(uint)); err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse(404, "取消上传失败: "+err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse("取消上传成功", nil))
-}
\ No newline at end of file
+}
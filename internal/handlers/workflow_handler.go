@@ -86,7 +86,7 @@ func (h *WorkflowHandler) GetWorkflow(c *gin.Context) {
 
 	workflow, err := h.workflowService.GetWorkflowByID(uint(workflowID), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse(http.StatusNotFound, err.Error()))
+		respondError(c, http.StatusNotFound, "", err)
 		return
 	}
 
@@ -178,7 +178,7 @@ func (h *WorkflowHandler) UpdateWorkflow(c *gin.Context) {
 
 	workflow, err := h.workflowService.UpdateWorkflow(uint(workflowID), &req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -213,7 +213,7 @@ func (h *WorkflowHandler) DeleteWorkflow(c *gin.Context) {
 	}
 
 	if err := h.workflowService.DeleteWorkflow(uint(workflowID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -256,7 +256,7 @@ func (h *WorkflowHandler) AddMember(c *gin.Context) {
 
 	member, err := h.workflowService.AddMember(uint(workflowID), &req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -299,7 +299,7 @@ func (h *WorkflowHandler) RemoveMember(c *gin.Context) {
 	}
 
 	if err := h.workflowService.RemoveMember(uint(workflowID), uint(memberID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -350,7 +350,7 @@ func (h *WorkflowHandler) UpdateMemberRole(c *gin.Context) {
 
 	member, err := h.workflowService.UpdateMemberRole(uint(workflowID), uint(memberID), &req, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
@@ -389,9 +389,157 @@ func (h *WorkflowHandler) GetMembers(c *gin.Context) {
 
 	members, err := h.workflowService.GetWorkflowMembers(uint(workflowID), userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, err.Error()))
+		respondError(c, http.StatusBadRequest, "", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse("获取成员列表成功", members))
-}
\ No newline at end of file
+}
+// GetUploadPolicy 获取工作流上传策略
+// @Summary 获取工作流上传策略
+// @Description 获取工作流当前生效的上传策略（文件大小和类型限制）及其覆盖项
+// @Tags 工作流管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "工作流ID"
+// @Success 200 {object} Response{data=services.WorkflowUploadPolicyInfo} "获取成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/workflows/{id}/upload-policy [get]
+func (h *WorkflowHandler) GetUploadPolicy(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+		return
+	}
+
+	policy, err := h.workflowService.GetUploadPolicy(uint(workflowID), userID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("获取上传策略成功", policy))
+}
+
+// SetUploadPolicy 设置工作流上传策略
+// @Summary 设置工作流上传策略
+// @Description 覆盖全局的文件大小和类型限制，仅工作流主管可操作
+// @Tags 工作流管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "工作流ID"
+// @Param request body services.UploadPolicyRequest true "上传策略"
+// @Success 200 {object} Response{data=services.WorkflowUploadPolicyInfo} "设置成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/workflows/{id}/upload-policy [put]
+func (h *WorkflowHandler) SetUploadPolicy(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+		return
+	}
+
+	var req services.UploadPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	policy, err := h.workflowService.SetUploadPolicy(uint(workflowID), &req, userID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("设置上传策略成功", policy))
+}
+
+// DeleteUploadPolicy 删除工作流上传策略
+// @Summary 删除工作流上传策略
+// @Description 删除工作流的上传策略覆盖项，恢复使用全局配置，仅工作流主管可操作
+// @Tags 工作流管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "工作流ID"
+// @Success 200 {object} Response "删除成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/workflows/{id}/upload-policy [delete]
+func (h *WorkflowHandler) DeleteUploadPolicy(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+		return
+	}
+
+	if err := h.workflowService.DeleteUploadPolicy(uint(workflowID), userID); err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("删除上传策略成功", nil))
+}
+
+// ChangeStatus 变更工作流状态
+// @Summary 变更工作流状态
+// @Description 将工作流设为进行中、已归档或已完成，仅工作流主管可操作；归档或完成后不能再上传或修改文件内容
+// @Tags 工作流管理
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "工作流ID"
+// @Param request body services.ChangeWorkflowStatusRequest true "工作流状态"
+// @Success 200 {object} Response{data=services.WorkflowInfo} "变更成功"
+// @Failure 400 {object} Response "请求参数错误"
+// @Failure 401 {object} Response "未授权"
+// @Router /api/workflows/{id}/status [put]
+func (h *WorkflowHandler) ChangeStatus(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse(http.StatusUnauthorized, "未授权"))
+		return
+	}
+
+	workflowID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "无效的工作流ID"))
+		return
+	}
+
+	var req services.ChangeWorkflowStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(http.StatusBadRequest, "请求参数错误: "+err.Error()))
+		return
+	}
+
+	workflow, err := h.workflowService.ChangeStatus(uint(workflowID), &req, userID)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse("变更工作流状态成功", workflow))
+}
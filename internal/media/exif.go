@@ -0,0 +1,427 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrNoEXIF 内容中没有可识别的 EXIF 信息
+var ErrNoEXIF = errors.New("没有 EXIF 信息")
+
+// EXIFReadLimit 解析 EXIF 时读取的文件头部长度
+// JPEG 的 EXIF 位于文件开头的 APP1 段，CR2、NEF 等 TIFF 结构的 RAW 文件的 IFD0 与 Exif IFD 也位于文件开头
+const EXIFReadLimit = 2 << 20
+
+// maxIFDEntries 单个 IFD 的最大条目数，超过时视为损坏的数据
+const maxIFDEntries = 1024
+
+// EXIF 照片拍摄信息，未记录的字段为零值
+type EXIF struct {
+	Make            string     // 相机品牌
+	Model           string     // 相机型号
+	LensModel       string     // 镜头型号
+	ISO             int        // 感光度
+	FNumber         float64    // 光圈值，如 2.8
+	ExposureTime    float64    // 曝光时间（秒）
+	FocalLength     float64    // 焦距（毫米）
+	FocalLength35mm int        // 等效 35mm 焦距（毫米）
+	Width           int        // 图像宽度（像素）
+	Height          int        // 图像高度（像素）
+	Orientation     int        // 方向，1-8，0 表示未记录
+	Latitude        *float64   // 纬度，南纬为负
+	Longitude       *float64   // 经度，西经为负
+	Altitude        *float64   // 海拔（米），海平面以下为负
+	CapturedAt      *time.Time // 拍摄时间，未记录时区时按本地时间解析
+}
+
+// TIFF 标签
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExposureTime       = 0x829A
+	tagFNumber            = 0x829D
+	tagExifIFD            = 0x8769
+	tagISOSpeedRatings    = 0x8827
+	tagGPSIFD             = 0x8825
+	tagISOSpeed           = 0x8833
+	tagDateTimeOriginal   = 0x9003
+	tagDateTimeDigitized  = 0x9004
+	tagOffsetTimeOriginal = 0x9011
+	tagShutterSpeedValue  = 0x9201
+	tagApertureValue      = 0x9202
+	tagFocalLength        = 0x920A
+	tagPixelXDimension    = 0xA002
+	tagPixelYDimension    = 0xA003
+	tagFocalLength35mm    = 0xA405
+	tagLensModel          = 0xA434
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+)
+
+// TIFF 数据类型
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+	typeIFD       = 13
+)
+
+// typeSizes 各数据类型单个值的字节数
+var typeSizes = map[uint16]uint32{
+	typeByte:      1,
+	typeASCII:     1,
+	typeShort:     2,
+	typeLong:      4,
+	typeRational:  8,
+	typeUndefined: 1,
+	typeSLong:     4,
+	typeSRational: 8,
+	typeIFD:       4,
+}
+
+// ParseEXIF 从文件头部解析 EXIF 信息，支持 JPEG 及 TIFF 结构的文件（TIFF、CR2、NEF、DNG 等）
+// data 可以只包含文件开头的部分内容，超出 data 范围的字段会被忽略
+func ParseEXIF(data []byte) (*EXIF, error) {
+	var tiff []byte
+	switch {
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		tiff = jpegEXIF(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		tiff = data
+	}
+	if tiff == nil {
+		return nil, ErrNoEXIF
+	}
+
+	r, ifd0Offset, err := newTIFFReader(tiff)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, err := r.readIFD(ifd0Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &EXIF{
+		Make:        r.string(ifd0, tagMake),
+		Model:       r.string(ifd0, tagModel),
+		Orientation: r.int(ifd0, tagOrientation),
+	}
+	dateTime := r.string(ifd0, tagDateTime)
+
+	if offset := r.int(ifd0, tagExifIFD); offset > 0 {
+		if sub, err := r.readIFD(uint32(offset)); err == nil {
+			exif.LensModel = r.string(sub, tagLensModel)
+			exif.ISO = r.int(sub, tagISOSpeedRatings)
+			if exif.ISO == 0 {
+				exif.ISO = r.int(sub, tagISOSpeed)
+			}
+			exif.ExposureTime = r.rational(sub, tagExposureTime, 0)
+			if exif.ExposureTime == 0 {
+				if apex, ok := r.signedRational(sub, tagShutterSpeedValue); ok {
+					exif.ExposureTime = math.Pow(2, -apex)
+				}
+			}
+			exif.FNumber = r.rational(sub, tagFNumber, 0)
+			if exif.FNumber == 0 {
+				if apex := r.rational(sub, tagApertureValue, 0); apex > 0 {
+					exif.FNumber = math.Round(math.Pow(2, apex/2)*10) / 10
+				}
+			}
+			exif.FocalLength = r.rational(sub, tagFocalLength, 0)
+			exif.FocalLength35mm = r.int(sub, tagFocalLength35mm)
+			exif.Width = r.int(sub, tagPixelXDimension)
+			exif.Height = r.int(sub, tagPixelYDimension)
+
+			offsetTime := r.string(sub, tagOffsetTimeOriginal)
+			if original := r.string(sub, tagDateTimeOriginal); original != "" {
+				exif.CapturedAt = parseEXIFTime(original, offsetTime)
+			}
+			if exif.CapturedAt == nil {
+				if digitized := r.string(sub, tagDateTimeDigitized); digitized != "" {
+					exif.CapturedAt = parseEXIFTime(digitized, "")
+				}
+			}
+		}
+	}
+	if exif.CapturedAt == nil && dateTime != "" {
+		exif.CapturedAt = parseEXIFTime(dateTime, "")
+	}
+
+	if offset := r.int(ifd0, tagGPSIFD); offset > 0 {
+		if gps, err := r.readIFD(uint32(offset)); err == nil {
+			exif.Latitude = r.coordinate(gps, tagGPSLatitude, tagGPSLatitudeRef, "S")
+			exif.Longitude = r.coordinate(gps, tagGPSLongitude, tagGPSLongitudeRef, "W")
+			if entry, ok := gps[tagGPSAltitude]; ok && entry.count >= 1 {
+				altitude := r.rational(gps, tagGPSAltitude, 0)
+				if ref, ok := gps[tagGPSAltitudeRef]; ok && len(ref.value) > 0 && ref.value[0] == 1 {
+					altitude = -altitude
+				}
+				exif.Altitude = &altitude
+			}
+		}
+	}
+
+	return exif, nil
+}
+
+// ShutterSpeed 快门速度的常用写法，如 1/250、2s；未记录时返回空字符串
+func (e *EXIF) ShutterSpeed() string {
+	switch {
+	case e.ExposureTime <= 0:
+		return ""
+	case e.ExposureTime >= 0.4:
+		return fmt.Sprintf("%gs", math.Round(e.ExposureTime*10)/10)
+	default:
+		return fmt.Sprintf("1/%d", int(math.Round(1/e.ExposureTime)))
+	}
+}
+
+// jpegEXIF 查找 JPEG 文件 APP1 段中的 TIFF 数据
+func jpegEXIF(data []byte) []byte {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// 段之间的填充字节
+			pos++
+			continue
+		}
+		if marker == 0xD9 || marker == 0xDA {
+			// 图像结束或图像数据开始，之后不再有元数据段
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		pos += 2 + length
+	}
+	return nil
+}
+
+// tiffReader 读取 TIFF 结构中的 IFD
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry IFD 条目，value 为条目的全部原始数据
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// newTIFFReader 解析 TIFF 文件头，返回 IFD0 的偏移
+func newTIFFReader(data []byte) (*tiffReader, uint32, error) {
+	if len(data) < 8 {
+		return nil, 0, ErrNoEXIF
+	}
+
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, 0, ErrNoEXIF
+	}
+	if r.order.Uint16(data[2:]) != 42 {
+		return nil, 0, ErrNoEXIF
+	}
+	return r, r.order.Uint32(data[4:]), nil
+}
+
+// readIFD 读取指定偏移处的 IFD，超出数据范围的条目会被忽略
+func (r *tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	start := uint64(offset)
+	if start+2 > uint64(len(r.data)) {
+		return nil, errors.New("IFD 偏移超出范围")
+	}
+	count := int(r.order.Uint16(r.data[start:]))
+	if count > maxIFDEntries {
+		return nil, errors.New("IFD 条目数异常")
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		pos := start + 2 + uint64(i)*12
+		if pos+12 > uint64(len(r.data)) {
+			break
+		}
+		tag := r.order.Uint16(r.data[pos:])
+		typ := r.order.Uint16(r.data[pos+2:])
+		n := r.order.Uint32(r.data[pos+4:])
+		size, ok := typeSizes[typ]
+		if !ok {
+			continue
+		}
+
+		total := uint64(size) * uint64(n)
+		var value []byte
+		if total <= 4 {
+			value = r.data[pos+8 : pos+8+total]
+		} else {
+			valueOffset := uint64(r.order.Uint32(r.data[pos+8:]))
+			if valueOffset+total > uint64(len(r.data)) {
+				continue
+			}
+			value = r.data[valueOffset : valueOffset+total]
+		}
+		entries[tag] = ifdEntry{typ: typ, count: n, value: value}
+	}
+	return entries, nil
+}
+
+// string 读取 ASCII 字段，去除结尾的空字符和空白
+func (r *tiffReader) string(ifd map[uint16]ifdEntry, tag uint16) string {
+	entry, ok := ifd[tag]
+	if !ok || (entry.typ != typeASCII && entry.typ != typeUndefined) {
+		return ""
+	}
+	value := entry.value
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(value), ""))
+}
+
+// int 读取整数字段的第一个值
+func (r *tiffReader) int(ifd map[uint16]ifdEntry, tag uint16) int {
+	entry, ok := ifd[tag]
+	if !ok || entry.count == 0 {
+		return 0
+	}
+	switch entry.typ {
+	case typeByte, typeUndefined:
+		return int(entry.value[0])
+	case typeShort:
+		return int(r.order.Uint16(entry.value))
+	case typeLong, typeIFD:
+		return int(r.order.Uint32(entry.value))
+	case typeSLong:
+		return int(int32(r.order.Uint32(entry.value)))
+	}
+	return 0
+}
+
+// uints 读取无符号整数字段的全部值
+func (r *tiffReader) uints(ifd map[uint16]ifdEntry, tag uint16) []uint32 {
+	entry, ok := ifd[tag]
+	if !ok {
+		return nil
+	}
+	values := make([]uint32, 0, entry.count)
+	for i := uint32(0); i < entry.count; i++ {
+		switch entry.typ {
+		case typeShort:
+			values = append(values, uint32(r.order.Uint16(entry.value[i*2:])))
+		case typeLong, typeIFD:
+			values = append(values, r.order.Uint32(entry.value[i*4:]))
+		default:
+			return nil
+		}
+	}
+	return values
+}
+
+// nextIFD 读取 IFD 之后的下一个 IFD 偏移，没有时返回 0
+func (r *tiffReader) nextIFD(offset uint32) uint32 {
+	start := uint64(offset)
+	if start+2 > uint64(len(r.data)) {
+		return 0
+	}
+	pos := start + 2 + uint64(r.order.Uint16(r.data[start:]))*12
+	if pos+4 > uint64(len(r.data)) {
+		return 0
+	}
+	return r.order.Uint32(r.data[pos:])
+}
+
+// rational 读取无符号分数字段的第 i 个值，分母为 0 时返回 0
+func (r *tiffReader) rational(ifd map[uint16]ifdEntry, tag uint16, i int) float64 {
+	entry, ok := ifd[tag]
+	if !ok || entry.typ != typeRational || uint32(i) >= entry.count {
+		return 0
+	}
+	num := r.order.Uint32(entry.value[i*8:])
+	den := r.order.Uint32(entry.value[i*8+4:])
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// signedRational 读取有符号分数字段的第一个值
+func (r *tiffReader) signedRational(ifd map[uint16]ifdEntry, tag uint16) (float64, bool) {
+	entry, ok := ifd[tag]
+	if !ok || entry.typ != typeSRational || entry.count == 0 {
+		return 0, false
+	}
+	num := int32(r.order.Uint32(entry.value))
+	den := int32(r.order.Uint32(entry.value[4:]))
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// coordinate 读取以度、分、秒记录的 GPS 坐标，参考方向为 negative 时取负值
+func (r *tiffReader) coordinate(ifd map[uint16]ifdEntry, tag, refTag uint16, negative string) *float64 {
+	entry, ok := ifd[tag]
+	if !ok || entry.typ != typeRational || entry.count < 3 {
+		return nil
+	}
+	value := r.rational(ifd, tag, 0) + r.rational(ifd, tag, 1)/60 + r.rational(ifd, tag, 2)/3600
+	if strings.EqualFold(r.string(ifd, refTag), negative) {
+		value = -value
+	}
+	return &value
+}
+
+// parseEXIFTime 解析 EXIF 时间，如 2026:01:02 15:04:05，offset 为 +08:00 形式的时区，为空时按本地时间解析
+func parseEXIFTime(value, offset string) *time.Time {
+	const layout = "2006:01:02 15:04:05"
+	if len(value) > len(layout) {
+		value = value[:len(layout)]
+	}
+
+	var t time.Time
+	var err error
+	if offset != "" {
+		t, err = time.Parse(layout+"-07:00", value+offset)
+	}
+	if offset == "" || err != nil {
+		t, err = time.ParseInLocation(layout, value, time.Local)
+	}
+	if err != nil {
+		return nil
+	}
+	return &t
+}
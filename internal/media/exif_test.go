@@ -0,0 +1,279 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// testEntry 测试用 IFD 条目，ifds 不为空时值为这些 IFD 的偏移；
+// next 大于 0 的条目不写入 IFD，而是将所在 IFD 的下一个 IFD 指向第 next 个 IFD
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+	ifds  []int
+	next  int
+}
+
+func asciiEntry(tag uint16, value string) testEntry {
+	return testEntry{tag: tag, typ: typeASCII, count: uint32(len(value) + 1), data: append([]byte(value), 0)}
+}
+
+func shortEntry(order binary.ByteOrder, tag uint16, value uint16) testEntry {
+	data := make([]byte, 2)
+	order.PutUint16(data, value)
+	return testEntry{tag: tag, typ: typeShort, count: 1, data: data}
+}
+
+func rationalEntry(order binary.ByteOrder, tag uint16, values ...uint32) testEntry {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(data[i*4:], v)
+	}
+	return testEntry{tag: tag, typ: typeRational, count: uint32(len(values) / 2), data: data}
+}
+
+func ifdPointer(tag uint16, ifds ...int) testEntry {
+	return testEntry{tag: tag, typ: typeLong, count: uint32(len(ifds)), ifds: ifds}
+}
+
+func nextIFDEntry(ifd int) testEntry {
+	return testEntry{next: ifd}
+}
+
+// buildTIFF 按顺序写入多个 IFD，IFD 之后为超过 4 字节的条目值
+func buildTIFF(order binary.ByteOrder, ifds ...[]testEntry) []byte {
+	offsets := make([]uint32, len(ifds))
+	next := uint32(8)
+	for i, entries := range ifds {
+		offsets[i] = next
+		next += 2 + 12*uint32(countEntries(entries)) + 4
+	}
+
+	out := make([]byte, next)
+	if order == binary.LittleEndian {
+		copy(out, "II")
+	} else {
+		copy(out, "MM")
+	}
+	order.PutUint16(out[2:], 42)
+	order.PutUint32(out[4:], offsets[0])
+
+	for i, entries := range ifds {
+		pos := offsets[i]
+		n := uint32(countEntries(entries))
+		order.PutUint16(out[pos:], uint16(n))
+		j := uint32(0)
+		for _, entry := range entries {
+			if entry.next > 0 {
+				order.PutUint32(out[pos+2+12*n:], offsets[entry.next])
+				continue
+			}
+			if len(entry.ifds) > 0 {
+				entry.data = make([]byte, 4*len(entry.ifds))
+				for k, ifd := range entry.ifds {
+					order.PutUint32(entry.data[k*4:], offsets[ifd])
+				}
+			}
+
+			p := pos + 2 + 12*j
+			j++
+			order.PutUint16(out[p:], entry.tag)
+			order.PutUint16(out[p+2:], entry.typ)
+			order.PutUint32(out[p+4:], entry.count)
+			if len(entry.data) <= 4 {
+				copy(out[p+8:p+12], entry.data)
+			} else {
+				order.PutUint32(out[p+8:], uint32(len(out)))
+				out = append(out, entry.data...)
+			}
+		}
+	}
+	return out
+}
+
+// countEntries IFD 中实际写入的条目数
+func countEntries(entries []testEntry) int {
+	n := 0
+	for _, entry := range entries {
+		if entry.next == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// sampleTIFF 包含常用拍摄信息的 TIFF 数据
+func sampleTIFF(order binary.ByteOrder) []byte {
+	return buildTIFF(order,
+		[]testEntry{
+			asciiEntry(tagMake, "Canon"),
+			asciiEntry(tagModel, "Canon EOS R5"),
+			shortEntry(order, tagOrientation, 6),
+			asciiEntry(tagDateTime, "2026:03:01 10:00:00"),
+			ifdPointer(tagExifIFD, 1),
+			ifdPointer(tagGPSIFD, 2),
+		},
+		[]testEntry{
+			rationalEntry(order, tagExposureTime, 1, 250),
+			rationalEntry(order, tagFNumber, 28, 10),
+			shortEntry(order, tagISOSpeedRatings, 400),
+			asciiEntry(tagDateTimeOriginal, "2025:12:31 18:30:15"),
+			asciiEntry(tagOffsetTimeOriginal, "+08:00"),
+			rationalEntry(order, tagFocalLength, 85, 1),
+			asciiEntry(tagLensModel, "RF85mm F1.2 L USM"),
+			shortEntry(order, tagPixelXDimension, 8192),
+			shortEntry(order, tagPixelYDimension, 5464),
+		},
+		[]testEntry{
+			asciiEntry(tagGPSLatitudeRef, "N"),
+			rationalEntry(order, tagGPSLatitude, 39, 1, 54, 1, 2700, 100),
+			asciiEntry(tagGPSLongitudeRef, "W"),
+			rationalEntry(order, tagGPSLongitude, 116, 1, 23, 1, 0, 1),
+			{tag: tagGPSAltitudeRef, typ: typeByte, count: 1, data: []byte{1}},
+			rationalEntry(order, tagGPSAltitude, 125, 10),
+		},
+	)
+}
+
+func checkSample(t *testing.T, exif *EXIF) {
+	t.Helper()
+
+	if exif.Make != "Canon" || exif.Model != "Canon EOS R5" || exif.LensModel != "RF85mm F1.2 L USM" {
+		t.Errorf("camera = %q %q %q", exif.Make, exif.Model, exif.LensModel)
+	}
+	if exif.ISO != 400 || exif.FNumber != 2.8 || exif.FocalLength != 85 || exif.ExposureTime != 1.0/250 {
+		t.Errorf("exposure = ISO %d f/%g %gmm %gs", exif.ISO, exif.FNumber, exif.FocalLength, exif.ExposureTime)
+	}
+	if exif.ShutterSpeed() != "1/250" {
+		t.Errorf("ShutterSpeed() = %q, want 1/250", exif.ShutterSpeed())
+	}
+	if exif.Orientation != 6 || exif.Width != 8192 || exif.Height != 5464 {
+		t.Errorf("image = orientation %d %dx%d", exif.Orientation, exif.Width, exif.Height)
+	}
+
+	want := time.Date(2025, 12, 31, 10, 30, 15, 0, time.UTC)
+	if exif.CapturedAt == nil || !exif.CapturedAt.Equal(want) {
+		t.Errorf("CapturedAt = %v, want %v", exif.CapturedAt, want)
+	}
+
+	if exif.Latitude == nil || math.Abs(*exif.Latitude-39.9075) > 1e-9 {
+		t.Errorf("Latitude = %v, want 39.9075", exif.Latitude)
+	}
+	if exif.Longitude == nil || math.Abs(*exif.Longitude+116.383333333) > 1e-6 {
+		t.Errorf("Longitude = %v, want -116.3833", exif.Longitude)
+	}
+	if exif.Altitude == nil || *exif.Altitude != -12.5 {
+		t.Errorf("Altitude = %v, want -12.5", exif.Altitude)
+	}
+}
+
+func TestParseEXIFTIFF(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		exif, err := ParseEXIF(sampleTIFF(order))
+		if err != nil {
+			t.Fatalf("ParseEXIF(%v): %v", order, err)
+		}
+		checkSample(t, exif)
+	}
+}
+
+func TestParseEXIFJPEG(t *testing.T) {
+	tiff := sampleTIFF(binary.BigEndian)
+
+	var jpeg bytes.Buffer
+	jpeg.Write([]byte{0xFF, 0xD8})
+	// APP0 (JFIF) 位于 APP1 之前
+	jpeg.Write([]byte{0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00})
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg.Write([]byte{0xFF, 0xE1})
+	binary.Write(&jpeg, binary.BigEndian, uint16(len(app1)+2))
+	jpeg.Write(app1)
+	jpeg.Write([]byte{0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9})
+
+	exif, err := ParseEXIF(jpeg.Bytes())
+	if err != nil {
+		t.Fatalf("ParseEXIF: %v", err)
+	}
+	checkSample(t, exif)
+}
+
+func TestParseEXIFFallbacks(t *testing.T) {
+	order := binary.LittleEndian
+	// ShutterSpeedValue = -1，即曝光 2 秒
+	shutter := make([]byte, 8)
+	order.PutUint32(shutter, 0xFFFFFFFF)
+	order.PutUint32(shutter[4:], 1)
+
+	exif, err := ParseEXIF(buildTIFF(order,
+		[]testEntry{
+			asciiEntry(tagMake, "NIKON CORPORATION"),
+			asciiEntry(tagDateTime, "2026:03:01 10:00:00"),
+			ifdPointer(tagExifIFD, 1),
+		},
+		[]testEntry{
+			{tag: tagShutterSpeedValue, typ: typeSRational, count: 1, data: shutter},
+			rationalEntry(order, tagApertureValue, 3, 1),
+			asciiEntry(tagDateTimeOriginal, "0000:00:00 00:00:00"),
+		},
+	))
+	if err != nil {
+		t.Fatalf("ParseEXIF: %v", err)
+	}
+	if exif.ShutterSpeed() != "2s" {
+		t.Errorf("ShutterSpeed() = %q, want 2s", exif.ShutterSpeed())
+	}
+	if exif.FNumber != 2.8 {
+		t.Errorf("FNumber = %g, want 2.8", exif.FNumber)
+	}
+	want := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	if exif.CapturedAt == nil || !exif.CapturedAt.Equal(want) {
+		t.Errorf("CapturedAt = %v, want %v", exif.CapturedAt, want)
+	}
+	if exif.Latitude != nil || exif.Altitude != nil {
+		t.Errorf("unexpected GPS data")
+	}
+}
+
+func TestParseEXIFInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":         nil,
+		"png":           []byte("\x89PNG\r\n\x1a\n"),
+		"jpeg no exif":  {0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02},
+		"bad magic":     []byte("II\x2b\x00\x08\x00\x00\x00"),
+		"short header":  []byte("II*\x00"),
+		"ifd0 overflow": []byte("II*\x00\xff\xff\xff\xff"),
+	} {
+		if _, err := ParseEXIF(data); err == nil {
+			t.Errorf("%s: ParseEXIF succeeded, want error", name)
+		}
+	}
+}
+
+func TestParseEXIFTruncated(t *testing.T) {
+	data := sampleTIFF(binary.LittleEndian)
+	// 截断的数据不应导致越界，能读到的字段照常返回
+	for n := 8; n < len(data); n++ {
+		if exif, err := ParseEXIF(data[:n]); err == nil && exif == nil {
+			t.Fatalf("ParseEXIF(data[:%d]) returned nil without error", n)
+		}
+	}
+}
+
+func TestShutterSpeed(t *testing.T) {
+	for value, want := range map[float64]string{
+		0:          "",
+		1.0 / 8000: "1/8000",
+		1.0 / 3:    "1/3",
+		0.5:        "0.5s",
+		30:         "30s",
+	} {
+		if got := (&EXIF{ExposureTime: value}).ShutterSpeed(); got != want {
+			t.Errorf("ShutterSpeed(%g) = %q, want %q", value, got, want)
+		}
+	}
+}
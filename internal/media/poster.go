@@ -0,0 +1,80 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxPosterSize 封面帧图片的最大字节数
+const maxPosterSize = 32 << 20
+
+// PosterExtractor 从视频中截取封面帧，返回 JPEG 或 PNG 图片
+type PosterExtractor interface {
+	Poster(ctx context.Context, path string) ([]byte, error)
+}
+
+// CommandPoster 调用外部命令（如 ffmpeg）截取视频封面帧
+// 命令参数中的 {input} 替换为视频文件路径，命令需将图片写到标准输出，例如：
+// ffmpeg -v error -ss 1 -i {input} -frames:v 1 -f image2pipe -vcodec mjpeg -
+type CommandPoster struct {
+	args    []string
+	timeout time.Duration
+}
+
+// NewCommandPoster 创建外部命令封面帧提取器，command 按空白拆分为参数
+func NewCommandPoster(command string, timeout time.Duration) (*CommandPoster, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("封面帧提取命令不能为空")
+	}
+	if !strings.Contains(command, "{input}") {
+		return nil, errors.New("封面帧提取命令缺少 {input} 参数")
+	}
+	return &CommandPoster{args: args, timeout: timeout}, nil
+}
+
+// Poster 执行命令并返回标准输出中的图片
+func (p *CommandPoster) Poster(ctx context.Context, path string) ([]byte, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	args := make([]string, len(p.args))
+	for i, arg := range p.args {
+		args[i] = strings.ReplaceAll(arg, "{input}", path)
+	}
+
+	var stdout limitedBuffer
+	var stderr bytes.Buffer
+	stdout.limit = maxPosterSize
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("截取视频封面失败: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New("截取视频封面失败: 命令没有输出图片")
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer 超过长度限制时返回错误的缓冲区
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errors.New("输出超出长度限制")
+	}
+	return b.Buffer.Write(p)
+}
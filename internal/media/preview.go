@@ -0,0 +1,100 @@
+package media
+
+import (
+	"bytes"
+	"sort"
+)
+
+// TIFF 图像数据相关标签
+const (
+	tagCompression     = 0x0103
+	tagPhotometric     = 0x0106
+	tagStripOffsets    = 0x0111
+	tagStripByteCounts = 0x0117
+	tagSubIFDs         = 0x014A
+	tagJPEGOffset      = 0x0201
+	tagJPEGLength      = 0x0202
+	tagCR2Slice        = 0xC5D8
+)
+
+// 压缩方式与颜色空间
+const (
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+	photometricCFA     = 32803
+	photometricLinear  = 34892
+)
+
+// maxPreviewIFDs 查找预览图时最多检查的 IFD 数，避免循环引用
+const maxPreviewIFDs = 32
+
+// Segment 文件中的一段数据
+type Segment struct {
+	Offset int64
+	Length int64
+}
+
+// EmbeddedJPEGs 在 CR2、NEF、DNG 等 TIFF 结构的 RAW 文件头部查找内嵌的 JPEG 预览图，按数据长度降序返回
+// 只根据 IFD 中记录的位置查找，预览图数据本身可以位于 header 之外；RAW 数据本身（无损 JPEG 压缩的传感器数据）会被排除
+func EmbeddedJPEGs(header []byte) []Segment {
+	if !bytes.HasPrefix(header, []byte("II*\x00")) && !bytes.HasPrefix(header, []byte("MM\x00*")) {
+		return nil
+	}
+	r, offset, err := newTIFFReader(header)
+	if err != nil {
+		return nil
+	}
+
+	var segments []Segment
+	visited := make(map[uint32]bool)
+	queue := []uint32{offset}
+	for len(queue) > 0 && len(visited) < maxPreviewIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+
+		ifd, err := r.readIFD(offset)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, r.nextIFD(offset))
+		queue = append(queue, r.uints(ifd, tagSubIFDs)...)
+
+		if segment, ok := r.previewSegment(ifd); ok {
+			segments = append(segments, segment)
+		}
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Length > segments[j].Length
+	})
+	return segments
+}
+
+// previewSegment 获取 IFD 中的 JPEG 图像位置
+func (r *tiffReader) previewSegment(ifd map[uint16]ifdEntry) (Segment, bool) {
+	if offset, length := r.int(ifd, tagJPEGOffset), r.int(ifd, tagJPEGLength); offset > 0 && length > 0 {
+		return Segment{Offset: int64(offset), Length: int64(length)}, true
+	}
+
+	switch r.int(ifd, tagPhotometric) {
+	case photometricCFA, photometricLinear:
+		return Segment{}, false
+	}
+	if _, ok := ifd[tagCR2Slice]; ok {
+		return Segment{}, false
+	}
+	if compression := r.int(ifd, tagCompression); compression != compressionOldJPEG && compression != compressionJPEG {
+		return Segment{}, false
+	}
+
+	offsets := r.uints(ifd, tagStripOffsets)
+	lengths := r.uints(ifd, tagStripByteCounts)
+	if len(offsets) != 1 || len(lengths) != 1 || offsets[0] == 0 || lengths[0] == 0 {
+		return Segment{}, false
+	}
+	return Segment{Offset: int64(offsets[0]), Length: int64(lengths[0])}, true
+}
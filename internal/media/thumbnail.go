@@ -0,0 +1,154 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// 注册 GIF、PNG 解码器，GIF 只取第一帧
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxImagePixels 生成缩略图时允许解码的最大像素数，避免超大图片耗尽内存
+const MaxImagePixels = 100_000_000
+
+// ThumbnailQuality 缩略图 JPEG 质量
+const ThumbnailQuality = 85
+
+// ErrImageTooLarge 图片像素数超出限制
+var ErrImageTooLarge = errors.New("图片尺寸过大")
+
+// DecodeImage 解码 JPEG、PNG 或 GIF 图片，解码前检查图片尺寸
+func DecodeImage(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Thumbnail 将图片缩小到长边不超过 maxSize（不放大），按 EXIF 方向旋转，透明部分填充为白色
+func Thumbnail(src image.Image, maxSize, orientation int) *image.RGBA {
+	return Orient(Resize(src, maxSize), orientation)
+}
+
+// EncodeJPEG 以缩略图质量编码为 JPEG
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: ThumbnailQuality})
+}
+
+// Resize 按区域平均将图片缩小到长边不超过 maxSize，保持宽高比，不放大
+func Resize(src image.Image, maxSize int) *image.RGBA {
+	rgba := flatten(src)
+	w, h := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	if maxSize <= 0 || (w <= maxSize && h <= maxSize) {
+		return rgba
+	}
+
+	dw, dh := maxSize, maxSize
+	if w >= h {
+		dh = h * maxSize / w
+	} else {
+		dw = w * maxSize / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*h/dh, (y+1)*h/dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*w/dw, (x+1)*w/dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// Orient 按 EXIF 方向（1-8）旋转或翻转图片，使其以正常方向显示
+func Orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// 5-8 需要旋转 90 度，宽高互换
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		for dx := 0; dx < dw; dx++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-dx, dy
+			case 3: // 旋转 180 度
+				sx, sy = w-1-dx, h-1-dy
+			case 4: // 垂直翻转
+				sx, sy = dx, h-1-dy
+			case 5: // 沿左上-右下对角线翻转
+				sx, sy = dy, dx
+			case 6: // 顺时针旋转 90 度
+				sx, sy = dy, h-1-dx
+			case 7: // 沿右上-左下对角线翻转
+				sx, sy = w-1-dy, h-1-dx
+			case 8: // 逆时针旋转 90 度
+				sx, sy = w-1-dy, dx
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// flatten 转换为从 (0,0) 开始的 RGBA 图片，透明部分填充为白色
+func flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	if opaque, ok := src.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+		return dst
+	}
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
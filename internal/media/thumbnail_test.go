@@ -0,0 +1,178 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	for _, tc := range []struct {
+		w, h, max    int
+		wantW, wantH int
+	}{
+		{4000, 3000, 720, 720, 540},
+		{3000, 4000, 720, 540, 720},
+		{100, 50, 720, 100, 50},
+		{5000, 2, 100, 100, 1},
+	} {
+		img := Resize(image.NewRGBA(image.Rect(0, 0, tc.w, tc.h)), tc.max)
+		if got := img.Bounds().Size(); got.X != tc.wantW || got.Y != tc.wantH {
+			t.Errorf("Resize(%dx%d, %d) = %dx%d, want %dx%d", tc.w, tc.h, tc.max, got.X, got.Y, tc.wantW, tc.wantH)
+		}
+	}
+}
+
+func TestResizeAveragesAndFlattens(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 14, 12))
+	for y := 10; y < 12; y++ {
+		src.Set(10, y, color.NRGBA{R: 255, A: 255})
+		src.Set(11, y, color.NRGBA{B: 255, A: 255})
+		// 右半部分透明，应填充为白色
+	}
+
+	img := Resize(src, 2)
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{R: 127, B: 127, A: 255}) {
+		t.Errorf("left pixel = %v", got)
+	}
+	if got := img.RGBAAt(1, 0); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("right pixel = %v", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 2x3 图片，像素值为行号*2+列号，便于检查像素位置
+	src := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 2; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(y*2 + x), A: 255})
+		}
+	}
+	pixels := func(img *image.RGBA) [][]uint8 {
+		var rows [][]uint8
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			var row []uint8
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				row = append(row, img.RGBAAt(x, y).R)
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	for orientation, want := range map[int][][]uint8{
+		1: {{0, 1}, {2, 3}, {4, 5}},
+		2: {{1, 0}, {3, 2}, {5, 4}},
+		3: {{5, 4}, {3, 2}, {1, 0}},
+		4: {{4, 5}, {2, 3}, {0, 1}},
+		5: {{0, 2, 4}, {1, 3, 5}},
+		6: {{4, 2, 0}, {5, 3, 1}},
+		7: {{5, 3, 1}, {4, 2, 0}},
+		8: {{1, 3, 5}, {0, 2, 4}},
+	} {
+		if got := pixels(Orient(src, orientation)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Orient(%d) = %v, want %v", orientation, got, want)
+		}
+	}
+}
+
+func TestDecodeImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	img, err := DecodeImage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("DecodeImage: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(3, 2) {
+		t.Errorf("size = %v, want 3x2", got)
+	}
+
+	if _, err := DecodeImage([]byte("not an image")); err == nil {
+		t.Error("DecodeImage succeeded on invalid data")
+	}
+}
+
+func TestEmbeddedJPEGs(t *testing.T) {
+	order := binary.LittleEndian
+	long := func(tag uint16, value uint32) testEntry {
+		data := make([]byte, 4)
+		order.PutUint32(data, value)
+		return testEntry{tag: tag, typ: typeLong, count: 1, data: data}
+	}
+
+	// IFD0：小缩略图；SubIFD 1：大预览图；SubIFD 2：RAW 数据（应排除）；IFD0 之后的 IFD 3：条带方式存储的 JPEG
+	data := buildTIFF(order,
+		[]testEntry{
+			long(tagJPEGOffset, 1000),
+			long(tagJPEGLength, 5000),
+			ifdPointer(tagSubIFDs, 1, 2),
+			nextIFDEntry(3),
+		},
+		[]testEntry{
+			long(tagJPEGOffset, 10000),
+			long(tagJPEGLength, 900000),
+		},
+		[]testEntry{
+			shortEntry(order, tagCompression, compressionJPEG),
+			shortEntry(order, tagPhotometric, photometricCFA),
+			long(tagStripOffsets, 2000000),
+			long(tagStripByteCounts, 20000000),
+		},
+		[]testEntry{
+			shortEntry(order, tagCompression, compressionOldJPEG),
+			long(tagStripOffsets, 950000),
+			long(tagStripByteCounts, 300000),
+		},
+	)
+
+	want := []Segment{{10000, 900000}, {950000, 300000}, {1000, 5000}}
+	if got := EmbeddedJPEGs(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("EmbeddedJPEGs = %v, want %v", got, want)
+	}
+
+	if got := EmbeddedJPEGs([]byte{0xFF, 0xD8}); got != nil {
+		t.Errorf("EmbeddedJPEGs(jpeg) = %v, want nil", got)
+	}
+}
+
+func TestCommandPoster(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, []byte("poster"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	poster, err := NewCommandPoster("cat {input}", 0)
+	if err != nil {
+		t.Fatalf("NewCommandPoster: %v", err)
+	}
+	data, err := poster.Poster(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Poster: %v", err)
+	}
+	if string(data) != "poster" {
+		t.Errorf("Poster = %q, want poster", data)
+	}
+
+	if _, err := poster.Poster(context.Background(), filepath.Join(t.TempDir(), "missing.mp4")); err == nil {
+		t.Error("Poster succeeded for missing input")
+	}
+	for _, command := range []string{"", "ffmpeg -i video.mp4 -"} {
+		if _, err := NewCommandPoster(command, 0); err == nil {
+			t.Errorf("NewCommandPoster(%q) succeeded, want error", command)
+		}
+	}
+}
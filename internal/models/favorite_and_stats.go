@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 )
 
@@ -82,3 +83,10 @@ type ReportData struct {
 }
 
 // GetStorageUsagePercent 获取存储使用百分比
+// used 为已使用字节数，capacity 为总容量，容量未设置（<=0）时返回 0，结果保留两位小数
+func GetStorageUsagePercent(used, capacity int64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return math.Round(float64(used)/float64(capacity)*10000) / 100
+}
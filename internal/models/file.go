@@ -44,7 +44,7 @@ type FileFolder struct {
 type FileVersion struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	FileID    uint      `gorm:"not null;index" json:"file_id"`
-	Version   uint      `gorm:"not null" json:"version"`
+	Version   uint64    `gorm:"not null;index" json:"version"` // 版本号，为版本创建时间的毫秒时间戳
 	FilePath  string    `gorm:"not null;size:500" json:"file_path"`
 	FileSize  int64     `gorm:"not null" json:"file_size"`
 	MD5Hash   string    `gorm:"size:32" json:"md5_hash"`
@@ -54,16 +54,36 @@ type FileVersion struct {
 	IsActive  bool      `gorm:"default:true" json:"is_active"`
 }
 
+// Blob 按内容哈希存储的文件内容，多个文件及文件版本可共享同一个 Blob
+type Blob struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Hash       string    `gorm:"uniqueIndex;not null;size:32" json:"hash"` // 内容MD5
+	Size       int64     `gorm:"not null" json:"size"`
+	StorageKey string    `gorm:"uniqueIndex;not null;size:500" json:"storage_key"`
+	RefCount   int64     `gorm:"not null;default:0;index" json:"ref_count"` // 引用该内容的 File 与 FileVersion 记录数
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// 分享对象类型
+const (
+	ShareTargetFile   = "file"
+	ShareTargetFolder = "folder"
+)
+
 // FileShare 文件分享
 type FileShare struct {
-	ID        uint       `gorm:"primaryKey" json:"id"`
-	FileID    uint       `gorm:"not null;index" json:"file_id"`
-	ShareCode string     `gorm:"unique;not null;size:32" json:"share_code"`
-	CreatorID uint       `gorm:"not null" json:"creator_id"`
-	Password  string     `gorm:"size:32" json:"password"` // 分享密码
-	ExpiresAt *time.Time `json:"expires_at"`
-	MaxViews  uint       `gorm:"default:0" json:"max_views"` // 0表示无限制
-	ViewCount uint       `gorm:"default:0" json:"view_count"`
-	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	IsActive  bool       `gorm:"default:true" json:"is_active"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	TargetType string     `gorm:"size:20;not null;default:'file'" json:"target_type"` // file, folder
+	FileID     uint       `gorm:"index" json:"file_id"`
+	FolderID   uint       `gorm:"index" json:"folder_id"`
+	ShareCode  string     `gorm:"unique;not null;size:32" json:"share_code"`
+	CreatorID  uint       `gorm:"not null;index" json:"creator_id"`
+	Password   string     `gorm:"size:100" json:"-"` // 分享密码的 bcrypt 哈希，为空表示无需密码
+	ExpiresAt  *time.Time `json:"expires_at"`
+	MaxViews   uint       `gorm:"default:0" json:"max_views"` // 0表示无限制
+	ViewCount  uint       `gorm:"default:0" json:"view_count"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	IsActive   bool       `gorm:"default:true" json:"is_active"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// 后台任务状态
+const (
+	JobStatusPending   = "pending"   // 等待执行（包括等待重试）
+	JobStatusRunning   = "running"   // 执行中
+	JobStatusCompleted = "completed" // 已完成
+	JobStatusDead      = "dead"      // 重试次数用尽或遇到不可重试的错误（死信），需人工重试
+	JobStatusCancelled = "cancelled" // 执行前被取消
+)
+
+// Job 持久化的后台任务，由多个工作进程通过 SELECT ... FOR UPDATE SKIP LOCKED 领取
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"size:100;not null;index" json:"type"`                    // 任务类型，如 download.zip
+	Payload     JSONField  `gorm:"type:jsonb" json:"payload"`                              // 任务参数
+	Status      string     `gorm:"size:20;not null;default:'pending';index" json:"status"` // 任务状态
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`                     // 已执行次数
+	MaxAttempts int        `gorm:"not null;default:5" json:"max_attempts"`                 // 最多执行次数
+	RunAt       time.Time  `gorm:"not null" json:"run_at"`                                 // 最早执行时间，失败后按退避策略推迟
+	LockedBy    string     `gorm:"size:100" json:"locked_by,omitempty"`                    // 正在执行的工作进程
+	LockedAt    *time.Time `json:"locked_at,omitempty"`                                    // 开始执行时间
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`                  // 最近一次失败的错误信息
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// FileMetadata 从文件内容中提取的拍摄信息（EXIF），每个文件一条，没有可识别信息的文件不保存
+type FileMetadata struct {
+	FileID          uint       `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	CameraMake      string     `gorm:"size:100;index" json:"camera_make"`  // 相机品牌
+	CameraModel     string     `gorm:"size:100;index" json:"camera_model"` // 相机型号
+	LensModel       string     `gorm:"size:200;index" json:"lens_model"`   // 镜头型号
+	ISO             int        `gorm:"index" json:"iso"`                   // 感光度
+	Aperture        float64    `json:"aperture"`                           // 光圈值，如 2.8
+	ExposureTime    float64    `json:"exposure_time"`                      // 曝光时间（秒）
+	ShutterSpeed    string     `gorm:"size:20" json:"shutter_speed"`       // 快门速度，如 1/250
+	FocalLength     float64    `json:"focal_length"`                       // 焦距（毫米）
+	FocalLength35mm int        `json:"focal_length_35mm"`                  // 等效 35mm 焦距（毫米）
+	Width           int        `json:"width"`                              // 图像宽度（像素）
+	Height          int        `json:"height"`                             // 图像高度（像素）
+	Orientation     int        `json:"orientation"`                        // EXIF 方向，1-8
+	Latitude        *float64   `json:"latitude"`                           // 纬度，南纬为负
+	Longitude       *float64   `json:"longitude"`                          // 经度，西经为负
+	Altitude        *float64   `json:"altitude"`                           // 海拔（米）
+	CapturedAt      *time.Time `gorm:"index" json:"captured_at"`           // 拍摄时间
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 设置表名
+func (FileMetadata) TableName() string {
+	return "file_metadata"
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// 存储配额作用范围
+const (
+	QuotaScopeUser     = "user"
+	QuotaScopeGroup    = "group"
+	QuotaScopeWorkflow = "workflow"
+)
+
+// StorageQuota 存储配额
+// 用户配额统计其拥有的文件，用户组配额统计组内有效成员的文件之和，工作流配额统计工作流内的文件
+type StorageQuota struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ScopeType string    `gorm:"not null;size:20;uniqueIndex:idx_quota_scope" json:"scope_type"` // user, group, workflow
+	ScopeID   uint      `gorm:"not null;uniqueIndex:idx_quota_scope" json:"scope_id"`
+	MaxBytes  int64     `gorm:"not null" json:"max_bytes"` // 允许使用的最大字节数
+	UpdatedBy uint      `json:"updated_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// FileSearchDocument 文件全文检索文档
+// 汇总文件名、描述、标签、上传者及照片信息，Document 上建有 GIN 索引用于全文检索，
+// Content 上建有 pg_trgm 索引，用于中文等无法按空格分词的文本的模糊匹配。
+type FileSearchDocument struct {
+	FileID    uint      `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	Tags      string    `gorm:"type:text" json:"tags"`    // 标签名称，空格分隔
+	Uploader  string    `gorm:"size:200" json:"uploader"` // 上传者用户名及姓名
+	Camera    string    `gorm:"size:200" json:"camera"`   // 相机品牌及型号
+	Content   string    `gorm:"type:text" json:"content"` // 全部可检索文本
+	Document  string    `gorm:"type:tsvector" json:"-"`   // 加权的全文检索向量
+	UpdatedAt time.Time `gorm:"index" json:"updated_at"`
+}
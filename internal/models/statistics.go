@@ -33,6 +33,7 @@ type StorageStats struct {
 	UploadedSize    int64     `json:"uploaded_size"`    // 当日上传大小
 	DeletedFiles    int64     `json:"deleted_files"`    // 当日删除文件数
 	DeletedSize     int64     `json:"deleted_size"`     // 当日删除大小
+	ReclaimedSize   int64     `json:"reclaimed_size"`   // 当日清理过期上传及无引用文件内容回收的空间
 	ActiveUsers     int64     `json:"active_users"`     // 活跃用户数
 	StorageUsage    float64   `json:"storage_usage"`    // 存储使用率
 	CreatedAt       time.Time `json:"created_at"`
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// UploadSession 分片上传会话（持久化，用于服务重启后的断点续传）
+type UploadSession struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UploadID    string    `gorm:"uniqueIndex;not null;size:100" json:"upload_id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	FileName    string    `gorm:"not null;size:255" json:"file_name"`
+	FileSize    int64     `gorm:"not null" json:"file_size"`
+	MD5Hash     string    `gorm:"size:32;index" json:"md5_hash"`
+	ChunkSize   int64     `gorm:"not null" json:"chunk_size"`
+	TotalChunks int       `gorm:"not null" json:"total_chunks"`
+	FolderID    uint      `json:"folder_id"`
+	WorkflowID  uint      `json:"workflow_id"`
+	TaskID      uint      `json:"task_id"`
+	Description string    `gorm:"size:500" json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+	FileID      uint      `json:"file_id"`                           // 非0时为该文件的新版本
+	ChangeLog   string    `gorm:"size:1000" json:"change_log"`       // 新版本的变更说明
+	TempDir     string    `gorm:"not null;size:500" json:"temp_dir"` // 分片临时目录，分片状态由该目录重建
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
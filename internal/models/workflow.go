@@ -2,12 +2,22 @@ package models
 
 import "time"
 
+// 工作流状态
+const (
+	WorkflowStatusOpen      = "open"      // 进行中
+	WorkflowStatusArchived  = "archived"  // 已归档（只读，保留所有历史版本）
+	WorkflowStatusCompleted = "completed" // 已完成（只读，宽限期后仅保留最终版本）
+)
+
 type Workflow struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"unique" json:"name"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	MasterID    uint      `gorm:"not null" json:"master_id"`
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Name            string     `gorm:"unique" json:"name"`
+	Description     string     `json:"description,omitempty"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	MasterID        uint       `gorm:"not null" json:"master_id"`
+	Status          string     `gorm:"size:20;not null;default:'open';index" json:"status"` // open, archived, completed
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+	CompletedAt     *time.Time `gorm:"index" json:"completed_at"` // 完成时间，历史版本在宽限期后清理
 }
 
 type WorkflowMember struct {
@@ -18,3 +28,14 @@ type WorkflowMember struct {
 	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
+
+// WorkflowUploadPolicy 工作流上传策略（覆盖全局的文件大小和类型限制，字段为空时沿用全局配置）
+type WorkflowUploadPolicy struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	WorkflowID   uint      `gorm:"uniqueIndex;not null" json:"workflow_id"`
+	MaxFileSize  *int64    `json:"max_file_size"`                 // 最大文件大小（MB），0 表示不限制
+	AllowedTypes *string   `gorm:"size:500" json:"allowed_types"` // 逗号分隔的扩展名，空字符串表示不限制
+	UpdatedBy    uint      `json:"updated_by"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 资源类型
+const (
+	ResourceWorkflow = "workflow"
+	ResourceFolder   = "folder"
+	ResourceFile     = "file"
+	ResourceTask     = "task"
+)
+
+// 权限操作
+const (
+	ActionView   = "view"   // 查看、下载
+	ActionUpload = "upload" // 在工作流、文件夹或任务中添加内容（上传文件、创建文件夹或任务、加入暂存区）
+	ActionEdit   = "edit"   // 修改、移动、上传新版本
+	ActionDelete = "delete" // 删除
+	ActionShare  = "share"  // 创建分享链接
+	ActionManage = "manage" // 管理工作流或任务（如取消任务）
+)
+
+// 工作流成员角色，未知角色按普通成员处理
+const (
+	WorkflowRoleMaster = "master" // 主管，可管理工作流中的全部内容
+	WorkflowRoleNormal = "normal" // 普通成员，可查看公开文件并上传
+	WorkflowRoleViewer = "viewer" // 只读成员，只能查看公开文件
+)
+
+// 任务成员角色
+const (
+	TaskRoleResponsible = "responsible" // 负责人
+	TaskRoleReviewer    = "reviewer"    // 审核人
+	TaskRoleMember      = "member"      // 协作成员
+)
+
+var (
+	// ErrPermissionDenied 没有对文件、文件夹或任务执行操作的权限
+	ErrPermissionDenied = errors.New("无权限执行该操作")
+	// ErrWorkflowAccessDenied 没有访问或修改工作流的权限
+	ErrWorkflowAccessDenied = errors.New("无权限访问该工作流")
+)
+
+// AuthzService 统一的权限服务
+// 上传、文件、文件夹、任务、下载和分享均通过该服务判断权限，规则见 decideAccess
+type AuthzService struct {
+	db *gorm.DB
+}
+
+// NewAuthzService 创建权限服务
+func NewAuthzService(db *gorm.DB) *AuthzService {
+	return &AuthzService{db: db}
+}
+
+// accessFacts 判断权限所需的用户与资源的关系
+type accessFacts struct {
+	admin      bool   // 全局管理员（admin、super_admin）
+	workflow   bool   // 资源属于某个工作流
+	memberRole string // 在资源所属工作流中的角色，不是成员时为空
+	taskRole   string // 在资源所属任务中的角色，不是任务成员时为空
+	owner      bool   // 文件所有者，文件夹或任务的创建者
+	private    bool   // 私有文件
+}
+
+// decideAccess 权限规则
+//
+//   - 全局管理员拥有全部权限
+//   - 工作流：成员可查看，主管和普通成员可添加内容，主管可管理
+//   - 文件夹：创建者和成员可查看；创建者、主管和普通成员可在其中添加内容和分享；创建者和主管可修改、删除
+//   - 文件：所有者、主管及所属任务的成员可查看；公开文件对工作流成员可见，不属于工作流的公开文件对所有用户可见；
+//     所有者和主管可修改、删除；所有者可分享，主管和普通成员可分享公开文件
+//   - 任务：工作流成员和任务成员可查看；主管、普通成员和任务成员可添加暂存文件；
+//     创建者、主管和负责人可修改；创建者和主管可删除、取消
+func decideAccess(resource, action string, f accessFacts) bool {
+	if f.admin {
+		return true
+	}
+	member := f.memberRole != ""
+	master := f.memberRole == WorkflowRoleMaster
+	contributor := member && f.memberRole != WorkflowRoleViewer
+
+	switch resource {
+	case ResourceWorkflow:
+		switch action {
+		case ActionView:
+			return member
+		case ActionUpload:
+			return contributor
+		case ActionEdit, ActionDelete, ActionManage:
+			return master
+		}
+	case ResourceFolder:
+		switch action {
+		case ActionView:
+			return f.owner || member
+		case ActionUpload, ActionShare:
+			return f.owner || contributor
+		case ActionEdit, ActionDelete:
+			return f.owner || master
+		}
+	case ResourceFile:
+		switch action {
+		case ActionView:
+			return f.owner || master || f.taskRole != "" || (!f.private && (member || !f.workflow))
+		case ActionEdit, ActionDelete:
+			return f.owner || master
+		case ActionShare:
+			return f.owner || (!f.private && contributor)
+		}
+	case ResourceTask:
+		switch action {
+		case ActionView:
+			return f.owner || member || f.taskRole != ""
+		case ActionUpload:
+			return f.owner || contributor || f.taskRole != ""
+		case ActionEdit:
+			return f.owner || master || f.taskRole == TaskRoleResponsible
+		case ActionDelete, ActionManage:
+			return f.owner || master
+		}
+	}
+	return false
+}
+
+// IsAdmin 判断用户是否为全局管理员
+func (s *AuthzService) IsAdmin(userID uint) (bool, error) {
+	var user models.User
+	if err := s.db.Select("id, role").Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("获取用户信息失败: %v", err)
+	}
+	return user.Role == "admin" || user.Role == "super_admin", nil
+}
+
+// WorkflowRole 获取用户在工作流中的角色，工作流的主管始终为 master，不是成员时返回空字符串
+func (s *AuthzService) WorkflowRole(workflowID, userID uint) (string, error) {
+	if workflowID == 0 {
+		return "", nil
+	}
+	var workflow models.Workflow
+	if err := s.db.Select("id, master_id").Where("id = ?", workflowID).First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("获取工作流失败: %v", err)
+	}
+	if workflow.MasterID == userID {
+		return WorkflowRoleMaster, nil
+	}
+
+	var member models.WorkflowMember
+	if err := s.db.Where("workflow_id = ? AND user_id = ?", workflowID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("检查工作流成员失败: %v", err)
+	}
+	if member.Role == "" {
+		return WorkflowRoleNormal, nil
+	}
+	return member.Role, nil
+}
+
+// taskMemberRole 获取用户在任务成员表中的角色，不是成员时返回空字符串
+func (s *AuthzService) taskMemberRole(taskID, userID uint) (string, error) {
+	if taskID == 0 {
+		return "", nil
+	}
+	var member models.TaskMember
+	if err := s.db.Where("task_id = ? AND user_id = ?", taskID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("检查任务成员失败: %v", err)
+	}
+	if member.Role == "" {
+		return TaskRoleMember, nil
+	}
+	return member.Role, nil
+}
+
+// facts 加载用户在工作流中的身份，管理员不再查询工作流角色
+func (s *AuthzService) facts(userID, workflowID uint) (accessFacts, error) {
+	admin, err := s.IsAdmin(userID)
+	if err != nil || admin {
+		return accessFacts{admin: admin}, err
+	}
+	role, err := s.WorkflowRole(workflowID, userID)
+	if err != nil {
+		return accessFacts{}, err
+	}
+	return accessFacts{workflow: workflowID != 0, memberRole: role}, nil
+}
+
+// CheckWorkflow 检查用户能否对工作流执行操作，无权限时返回 ErrWorkflowAccessDenied
+func (s *AuthzService) CheckWorkflow(userID, workflowID uint, action string) error {
+	f, err := s.facts(userID, workflowID)
+	if err != nil {
+		return err
+	}
+	if !decideAccess(ResourceWorkflow, action, f) {
+		return ErrWorkflowAccessDenied
+	}
+	return nil
+}
+
+// CheckFolder 检查用户能否对文件夹执行操作，无权限时返回 ErrPermissionDenied
+func (s *AuthzService) CheckFolder(userID uint, folder *models.FileFolder, action string) error {
+	f, err := s.facts(userID, folder.WorkflowID)
+	if err != nil {
+		return err
+	}
+	return s.checkFolder(f, userID, folder, action)
+}
+
+// checkFolder 根据用户在文件夹所属工作流中的身份检查文件夹权限
+func (s *AuthzService) checkFolder(f accessFacts, userID uint, folder *models.FileFolder, action string) error {
+	f.owner = folder.CreatorID == userID
+	if !decideAccess(ResourceFolder, action, f) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// CheckFile 检查用户能否对文件执行操作，无权限时返回 ErrPermissionDenied
+func (s *AuthzService) CheckFile(userID uint, file *models.File, action string) error {
+	f, err := s.facts(userID, file.WorkflowID)
+	if err != nil {
+		return err
+	}
+	return s.checkFile(f, userID, file, action)
+}
+
+// checkFile 根据用户在文件所属工作流中的身份检查文件权限
+func (s *AuthzService) checkFile(f accessFacts, userID uint, file *models.File, action string) error {
+	f.owner = file.OwnerID == userID
+	f.private = file.IsPrivate
+	if !f.admin && !f.owner && file.TaskID != 0 {
+		var task models.TaskEnhanced
+		if err := s.db.Select("id, responsible_id, reviewer_id").Where("id = ?", file.TaskID).First(&task).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("获取任务信息失败: %v", err)
+			}
+		} else if f.taskRole, err = s.taskRole(&task, userID); err != nil {
+			return err
+		}
+	}
+	if !decideAccess(ResourceFile, action, f) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// batchChecker 逐个检查同一用户对大量文件和文件夹的权限，按工作流缓存用户身份，避免每个项目重复查询
+type batchChecker struct {
+	authz  *AuthzService
+	userID uint
+	facts  map[uint]accessFacts
+}
+
+// newBatchChecker 创建批量权限检查器
+func (s *AuthzService) newBatchChecker(userID uint) *batchChecker {
+	return &batchChecker{authz: s, userID: userID, facts: make(map[uint]accessFacts)}
+}
+
+// workflowFacts 获取用户在工作流中的身份
+func (c *batchChecker) workflowFacts(workflowID uint) (accessFacts, error) {
+	if f, ok := c.facts[workflowID]; ok {
+		return f, nil
+	}
+	f, err := c.authz.facts(c.userID, workflowID)
+	if err != nil {
+		return accessFacts{}, err
+	}
+	c.facts[workflowID] = f
+	return f, nil
+}
+
+// File 与 CheckFile 相同
+func (c *batchChecker) File(file *models.File, action string) error {
+	f, err := c.workflowFacts(file.WorkflowID)
+	if err != nil {
+		return err
+	}
+	return c.authz.checkFile(f, c.userID, file, action)
+}
+
+// Folder 与 CheckFolder 相同
+func (c *batchChecker) Folder(folder *models.FileFolder, action string) error {
+	f, err := c.workflowFacts(folder.WorkflowID)
+	if err != nil {
+		return err
+	}
+	return c.authz.checkFolder(f, c.userID, folder, action)
+}
+
+// CheckTask 检查用户能否对任务执行操作，无权限时返回 ErrPermissionDenied
+func (s *AuthzService) CheckTask(userID uint, task *models.TaskEnhanced, action string) error {
+	f, err := s.facts(userID, task.WorkflowID)
+	if err != nil {
+		return err
+	}
+	f.owner = task.CreatorID == userID
+	if f.taskRole, err = s.taskRole(task, userID); err != nil {
+		return err
+	}
+	if !decideAccess(ResourceTask, action, f) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// taskRole 获取用户在任务中的角色：任务的负责人和审核人以任务字段为准（早于任务成员表创建的任务没有对应的成员记录），
+// 其他成员查询任务成员表
+func (s *AuthzService) taskRole(task *models.TaskEnhanced, userID uint) (string, error) {
+	switch userID {
+	case task.ResponsibleID:
+		return TaskRoleResponsible, nil
+	case task.ReviewerID:
+		return TaskRoleReviewer, nil
+	}
+	return s.taskMemberRole(task.ID, userID)
+}
+
+// memberWorkflows 用户所在工作流 ID 的子查询
+func (s *AuthzService) memberWorkflows(userID uint) *gorm.DB {
+	return s.db.Model(&models.WorkflowMember{}).Select("workflow_id").Where("user_id = ?", userID)
+}
+
+// masterWorkflows 用户担任主管的工作流 ID 的子查询
+func (s *AuthzService) masterWorkflows(userID uint) *gorm.DB {
+	return s.db.Model(&models.Workflow{}).Select("id").Where("master_id = ?", userID)
+}
+
+// masterRoleWorkflows 用户以 master 角色加入的工作流 ID 的子查询
+func (s *AuthzService) masterRoleWorkflows(userID uint) *gorm.DB {
+	return s.memberWorkflows(userID).Where("role = ?", WorkflowRoleMaster)
+}
+
+// memberTasks 用户作为任务成员的任务 ID 的子查询
+func (s *AuthzService) memberTasks(userID uint) *gorm.DB {
+	return s.db.Model(&models.TaskMember{}).Select("task_id").Where("user_id = ?", userID)
+}
+
+// assignedTasks 用户担任负责人或审核人的任务 ID 的子查询
+func (s *AuthzService) assignedTasks(userID uint) *gorm.DB {
+	return s.db.Model(&models.TaskEnhanced{}).Select("id").Where("responsible_id = ? OR reviewer_id = ?", userID, userID)
+}
+
+// VisibleFiles 返回只保留用户可查看文件的查询条件，规则与 CheckFile(ActionView) 相同
+// table 为查询中文件表的名称或别名，为空时不加前缀
+func (s *AuthzService) VisibleFiles(userID uint, table string) (func(*gorm.DB) *gorm.DB, error) {
+	admin, err := s.IsAdmin(userID)
+	if err != nil {
+		return nil, err
+	}
+	if admin {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	c := columnPrefix(table)
+	cond := fmt.Sprintf("(%[1]sowner_id = ? OR %[1]sworkflow_id IN (?) OR %[1]sworkflow_id IN (?) OR %[1]stask_id IN (?) OR %[1]stask_id IN (?)"+
+		" OR (%[1]sis_private = false AND (%[1]sworkflow_id = 0 OR %[1]sworkflow_id IN (?))))", c)
+	args := []interface{}{userID, s.masterWorkflows(userID), s.masterRoleWorkflows(userID), s.memberTasks(userID), s.assignedTasks(userID),
+		s.memberWorkflows(userID)}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(cond, args...)
+	}, nil
+}
+
+// VisibleFolders 返回只保留用户可查看文件夹的查询条件，规则与 CheckFolder(ActionView) 相同
+func (s *AuthzService) VisibleFolders(userID uint, table string) (func(*gorm.DB) *gorm.DB, error) {
+	admin, err := s.IsAdmin(userID)
+	if err != nil {
+		return nil, err
+	}
+	if admin {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	c := columnPrefix(table)
+	cond := fmt.Sprintf("(%[1]screator_id = ? OR %[1]sworkflow_id IN (?) OR %[1]sworkflow_id IN (?))", c)
+	args := []interface{}{userID, s.masterWorkflows(userID), s.memberWorkflows(userID)}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(cond, args...)
+	}, nil
+}
+
+// VisibleTasks 返回只保留用户可查看任务的查询条件，规则与 CheckTask(ActionView) 相同
+func (s *AuthzService) VisibleTasks(userID uint) (func(*gorm.DB) *gorm.DB, error) {
+	admin, err := s.IsAdmin(userID)
+	if err != nil {
+		return nil, err
+	}
+	if admin {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(creator_id = ? OR workflow_id IN (?) OR workflow_id IN (?) OR id IN (?) OR responsible_id = ? OR reviewer_id = ?)",
+			userID, s.masterWorkflows(userID), s.memberWorkflows(userID), s.memberTasks(userID), userID, userID)
+	}, nil
+}
+
+// columnPrefix 返回表名前缀
+func columnPrefix(table string) string {
+	if table == "" {
+		return ""
+	}
+	return table + "."
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// authzActors 权限矩阵中的用户身份，均针对属于某个工作流的资源
+var authzActors = map[string]accessFacts{
+	"admin":       {admin: true, workflow: true},
+	"master":      {workflow: true, memberRole: WorkflowRoleMaster},
+	"normal":      {workflow: true, memberRole: WorkflowRoleNormal},
+	"viewer":      {workflow: true, memberRole: WorkflowRoleViewer},
+	"outsider":    {workflow: true},
+	"owner":       {workflow: true, owner: true},
+	"responsible": {workflow: true, taskRole: TaskRoleResponsible},
+	"reviewer":    {workflow: true, taskRole: TaskRoleReviewer},
+	"taskmember":  {workflow: true, taskRole: TaskRoleMember},
+}
+
+func TestDecideAccessMatrix(t *testing.T) {
+	tests := []struct {
+		resource string
+		action   string
+		private  bool
+		allowed  string
+	}{
+		{ResourceWorkflow, ActionView, false, "admin master normal viewer"},
+		{ResourceWorkflow, ActionUpload, false, "admin master normal"},
+		{ResourceWorkflow, ActionEdit, false, "admin master"},
+		{ResourceWorkflow, ActionDelete, false, "admin master"},
+		{ResourceWorkflow, ActionShare, false, "admin"},
+		{ResourceWorkflow, ActionManage, false, "admin master"},
+
+		{ResourceFolder, ActionView, false, "admin master normal viewer owner"},
+		{ResourceFolder, ActionUpload, false, "admin master normal owner"},
+		{ResourceFolder, ActionEdit, false, "admin master owner"},
+		{ResourceFolder, ActionDelete, false, "admin master owner"},
+		{ResourceFolder, ActionShare, false, "admin master normal owner"},
+		{ResourceFolder, ActionManage, false, "admin"},
+
+		{ResourceFile, ActionView, false, "admin master normal viewer owner responsible reviewer taskmember"},
+		{ResourceFile, ActionUpload, false, "admin"},
+		{ResourceFile, ActionEdit, false, "admin master owner"},
+		{ResourceFile, ActionDelete, false, "admin master owner"},
+		{ResourceFile, ActionShare, false, "admin master normal owner"},
+		{ResourceFile, ActionManage, false, "admin"},
+
+		{ResourceFile, ActionView, true, "admin master owner responsible reviewer taskmember"},
+		{ResourceFile, ActionEdit, true, "admin master owner"},
+		{ResourceFile, ActionDelete, true, "admin master owner"},
+		{ResourceFile, ActionShare, true, "admin owner"},
+
+		{ResourceTask, ActionView, false, "admin master normal viewer owner responsible reviewer taskmember"},
+		{ResourceTask, ActionUpload, false, "admin master normal owner responsible reviewer taskmember"},
+		{ResourceTask, ActionEdit, false, "admin master owner responsible"},
+		{ResourceTask, ActionDelete, false, "admin master owner"},
+		{ResourceTask, ActionShare, false, "admin"},
+		{ResourceTask, ActionManage, false, "admin master owner"},
+	}
+
+	for _, tt := range tests {
+		allowed := make(map[string]bool)
+		for _, name := range strings.Fields(tt.allowed) {
+			if _, ok := authzActors[name]; !ok {
+				t.Fatalf("unknown actor %q", name)
+			}
+			allowed[name] = true
+		}
+
+		var got []string
+		for name, facts := range authzActors {
+			facts.private = tt.private
+			if decideAccess(tt.resource, tt.action, facts) {
+				got = append(got, name)
+			}
+		}
+		sort.Strings(got)
+
+		want := strings.Fields(tt.allowed)
+		sort.Strings(want)
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s %s (private=%v) allowed %v, want %v", tt.resource, tt.action, tt.private, got, want)
+		}
+	}
+}
+
+func TestDecideAccessFileWithoutWorkflow(t *testing.T) {
+	// 不属于工作流的公开文件对所有用户可见，但只有所有者可以修改
+	stranger := accessFacts{}
+	if !decideAccess(ResourceFile, ActionView, stranger) {
+		t.Error("public file without workflow should be visible to everyone")
+	}
+	if decideAccess(ResourceFile, ActionEdit, stranger) || decideAccess(ResourceFile, ActionShare, stranger) {
+		t.Error("stranger should not edit or share a file without workflow")
+	}
+
+	stranger.private = true
+	if decideAccess(ResourceFile, ActionView, stranger) {
+		t.Error("private file without workflow should not be visible to others")
+	}
+	owner := accessFacts{owner: true, private: true}
+	if !decideAccess(ResourceFile, ActionView, owner) || !decideAccess(ResourceFile, ActionShare, owner) {
+		t.Error("owner should view and share own private file")
+	}
+}
+
+func TestDecideAccessUnknownRole(t *testing.T) {
+	// 未知的工作流角色按普通成员处理
+	facts := accessFacts{workflow: true, memberRole: "editor"}
+	if !decideAccess(ResourceWorkflow, ActionUpload, facts) {
+		t.Error("unknown role should be able to upload like a normal member")
+	}
+	if decideAccess(ResourceWorkflow, ActionManage, facts) {
+		t.Error("unknown role should not manage the workflow")
+	}
+	if decideAccess("unknown", ActionView, authzActors["master"]) {
+		t.Error("unknown resource should be denied")
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// blobGCGracePeriod Blob 最后一次被登记或引用后，至少经过该时间才会被回收，
+// 避免回收正在上传、尚未创建文件记录的内容
+const blobGCGracePeriod = time.Hour
+
+// BlobService 内容寻址存储服务
+// 文件内容按MD5保存为 Blob，File 与 FileVersion 通过 FilePath 引用 Blob 的存储键，
+// RefCount 记录引用数，引用数归零的 Blob 由垃圾回收删除。
+type BlobService struct {
+	db      *gorm.DB
+	storage storage.Storage
+}
+
+// NewBlobService 创建内容寻址存储服务
+func NewBlobService(db *gorm.DB, store storage.Storage) *BlobService {
+	return &BlobService{
+		db:      db,
+		storage: store,
+	}
+}
+
+// BlobGCResult Blob 垃圾回收结果
+type BlobGCResult struct {
+	Scanned        int   `json:"scanned"`         // 检查的零引用 Blob 数
+	Deleted        int   `json:"deleted"`         // 删除的 Blob 数
+	Repaired       int   `json:"repaired"`        // 引用数与实际引用不一致而修正的 Blob 数
+	ReclaimedBytes int64 `json:"reclaimed_bytes"` // 回收的空间（字节）
+}
+
+// BlobStorageKey 获取内容哈希对应的存储键
+func BlobStorageKey(hash string) string {
+	if len(hash) < 2 {
+		return "blobs/" + hash
+	}
+	return fmt.Sprintf("blobs/%s/%s", hash[:2], hash)
+}
+
+// lockBlobHash 在事务中对内容哈希加事务级咨询锁，同一内容的登记与垃圾回收串行执行
+func lockBlobHash(tx *gorm.DB, hash string) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "blob:"+hash).Error; err != nil {
+		return fmt.Errorf("锁定文件内容失败: %v", err)
+	}
+	return nil
+}
+
+// Store 登记 Blob 并将本地文件写入存储，返回存储键
+// 登记后的 Blob 引用数不变，需在创建文件记录的事务中调用 Acquire；
+// 未被引用的 Blob 会在宽限期后被垃圾回收。
+func (s *BlobService) Store(hash string, size int64, localPath string) (string, error) {
+	key := BlobStorageKey(hash)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 持有锁直到写入完成：垃圾回收删除记录和存储内容的过程不会与登记、写入交错
+		if err := lockBlobHash(tx, hash); err != nil {
+			return err
+		}
+
+		blob := models.Blob{
+			Hash:       hash,
+			Size:       size,
+			StorageKey: key,
+		}
+		// 已存在时刷新更新时间，使其在宽限期内不会被回收
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "hash"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at"}),
+		}).Create(&blob).Error; err != nil {
+			return fmt.Errorf("登记文件内容失败: %v", err)
+		}
+
+		// 内容相同，重复写入是幂等的
+		if err := storage.PutFile(s.storage, key, localPath); err != nil {
+			return fmt.Errorf("保存文件失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Acquire 增加存储键对应 Blob 的引用数（未纳入内容寻址存储的旧文件路径将被忽略）
+func (s *BlobService) Acquire(tx *gorm.DB, key string) error {
+	return tx.Model(&models.Blob{}).Where("storage_key = ?", key).
+		Updates(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1")}).Error
+}
+
+// Release 减少存储键对应 Blob 的引用数
+func (s *BlobService) Release(tx *gorm.DB, key string) error {
+	return tx.Model(&models.Blob{}).Where("storage_key = ? AND ref_count > 0", key).
+		Updates(map[string]interface{}{"ref_count": gorm.Expr("ref_count - 1")}).Error
+}
+
+// countReferences 统计实际引用存储键的 File 与 FileVersion 记录数
+func countReferences(tx *gorm.DB, key string) (int64, error) {
+	var files, versions int64
+	if err := tx.Model(&models.File{}).Where("file_path = ?", key).Count(&files).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.FileVersion{}).Where("file_path = ?", key).Count(&versions).Error; err != nil {
+		return 0, err
+	}
+	return files + versions, nil
+}
+
+// CollectGarbage 删除没有任何 File 或 FileVersion 引用的 Blob
+// 引用数归零的 Blob 在删除前会再次核对实际引用，引用数有误时予以修正而不删除。
+func (s *BlobService) CollectGarbage() (*BlobGCResult, error) {
+	result := &BlobGCResult{}
+	cutoff := time.Now().Add(-blobGCGracePeriod)
+
+	var candidates []models.Blob
+	if err := s.db.Where("ref_count <= 0 AND updated_at < ?", cutoff).Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("查询待回收文件内容失败: %v", err)
+	}
+
+	for i := range candidates {
+		blob := &candidates[i]
+		result.Scanned++
+
+		outcome, err := s.collectBlob(blob, cutoff)
+		if err != nil {
+			log.Printf("Warning: Failed to collect blob %s: %v", blob.Hash, err)
+			continue
+		}
+		switch outcome {
+		case blobDeleted:
+			result.Deleted++
+			result.ReclaimedBytes += blob.Size
+		case blobRepaired:
+			result.Repaired++
+		}
+	}
+
+	if result.ReclaimedBytes > 0 {
+		if err := NewStatisticsService(s.db).RecordReclaimedStorage(result.ReclaimedBytes); err != nil {
+			log.Printf("Warning: Failed to record reclaimed storage: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// 单个 Blob 的回收结果
+const (
+	blobKept = iota
+	blobDeleted
+	blobRepaired
+)
+
+// collectBlob 在持有内容哈希锁的事务中回收单个 Blob
+// 再次核对实际引用，引用数有误时修正；确无引用且期间未被重新登记时删除记录和存储内容，
+// 存储内容删除失败时记录的删除一并回滚，下次回收时重试。
+func (s *BlobService) collectBlob(blob *models.Blob, cutoff time.Time) (int, error) {
+	outcome := blobKept
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBlobHash(tx, blob.Hash); err != nil {
+			return err
+		}
+
+		refs, err := countReferences(tx, blob.StorageKey)
+		if err != nil {
+			return fmt.Errorf("统计引用失败: %v", err)
+		}
+		if refs > 0 {
+			if err := tx.Model(&models.Blob{}).Where("id = ?", blob.ID).UpdateColumn("ref_count", refs).Error; err != nil {
+				return fmt.Errorf("修正引用数失败: %v", err)
+			}
+			outcome = blobRepaired
+			return nil
+		}
+
+		// 条件删除：期间被重新引用（引用数增加或更新时间刷新）的 Blob 不会被删除
+		res := tx.Where("id = ? AND ref_count <= 0 AND updated_at < ?", blob.ID, cutoff).Delete(&models.Blob{})
+		if res.Error != nil {
+			return fmt.Errorf("删除记录失败: %v", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := s.storage.Delete(blob.StorageKey); err != nil {
+			return fmt.Errorf("删除存储内容失败: %v", err)
+		}
+		outcome = blobDeleted
+		return nil
+	})
+	return outcome, err
+}
+
+// StartGC 启动后台垃圾回收任务
+func (s *BlobService) StartGC(interval time.Duration) {
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			result, err := s.CollectGarbage()
+			if err != nil {
+				log.Printf("Warning: Failed to collect unreferenced blobs: %v", err)
+				continue
+			}
+			if result.Deleted > 0 || result.Repaired > 0 {
+				log.Printf("Blob GC deleted %d blobs (%d bytes), repaired %d reference counts",
+					result.Deleted, result.ReclaimedBytes, result.Repaired)
+			}
+		}
+	}()
+}
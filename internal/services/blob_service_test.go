@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+)
+
+// storeTestBlob 登记一个测试 Blob，并将其引用数和更新时间设置为指定值
+func storeTestBlob(t *testing.T, s *BlobService, n int, refCount int64, updatedAt time.Time) *models.Blob {
+	t.Helper()
+
+	hash := fmt.Sprintf("%032x", n)
+	src := filepath.Join(t.TempDir(), hash)
+	if err := os.WriteFile(src, []byte(hash), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.Store(hash, int64(len(hash)), src)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	var blob models.Blob
+	if err := s.db.Where("storage_key = ?", key).First(&blob).Error; err != nil {
+		t.Fatalf("load blob: %v", err)
+	}
+	if err := s.db.Model(&blob).UpdateColumns(map[string]interface{}{"ref_count": refCount, "updated_at": updatedAt}).Error; err != nil {
+		t.Fatalf("update blob: %v", err)
+	}
+	return &blob
+}
+
+func TestCollectGarbage(t *testing.T) {
+	db := newTestDB(t)
+	store := storage.NewLocalStorage(t.TempDir())
+	s := NewBlobService(db, store)
+	old := time.Now().Add(-2 * blobGCGracePeriod)
+
+	orphan := storeTestBlob(t, s, 1, 0, old)
+	// 引用数为 0 但仍被文件或版本引用的 Blob 只修正引用数
+	referenced := storeTestBlob(t, s, 2, 0, old)
+	createTestFile(t, db, &models.File{FileName: "a.jpg", FilePath: referenced.StorageKey, OwnerID: 1})
+	versioned := storeTestBlob(t, s, 3, 0, old)
+	if err := db.Create(&models.FileVersion{FileID: 1, Version: 1, FilePath: versioned.StorageKey, CreatedBy: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 重新登记的 Blob 刷新了更新时间，在宽限期内不会被回收
+	reStored := storeTestBlob(t, s, 4, 0, old)
+	src := filepath.Join(t.TempDir(), "again")
+	if err := os.WriteFile(src, []byte(reStored.Hash), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Store(reStored.Hash, reStored.Size, src); err != nil {
+		t.Fatalf("Store again: %v", err)
+	}
+
+	result, err := s.CollectGarbage()
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if result.Scanned != 3 || result.Deleted != 1 || result.Repaired != 2 || result.ReclaimedBytes != orphan.Size {
+		t.Errorf("result = %+v, want 3 scanned, 1 deleted, 2 repaired", result)
+	}
+
+	var count int64
+	db.Model(&models.Blob{}).Where("id = ?", orphan.ID).Count(&count)
+	if count != 0 {
+		t.Error("unreferenced blob not deleted")
+	}
+	if _, err := store.Stat(orphan.StorageKey); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("unreferenced blob content still exists: %v", err)
+	}
+
+	for _, blob := range []*models.Blob{referenced, versioned, reStored} {
+		var kept models.Blob
+		if err := db.First(&kept, blob.ID).Error; err != nil {
+			t.Errorf("blob %s deleted: %v", blob.Hash, err)
+			continue
+		}
+		if _, err := store.Stat(blob.StorageKey); err != nil {
+			t.Errorf("blob %s content deleted: %v", blob.Hash, err)
+		}
+		if blob != reStored && kept.RefCount != 1 {
+			t.Errorf("blob %s ref count = %d, want 1", blob.Hash, kept.RefCount)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -18,20 +20,29 @@ import (
 
 type DownloadService struct {
 	db           *gorm.DB
-	uploadPath   string
+	files        *FileResolver
+	authz        *AuthzService
+	jobs         *JobQueue
 	downloadPath string
 	baseURL      string
 }
 
-func NewDownloadService(db *gorm.DB, uploadPath, downloadPath, baseURL string) *DownloadService {
+func NewDownloadService(db *gorm.DB, files *FileResolver, downloadPath, baseURL string) *DownloadService {
 	return &DownloadService{
 		db:           db,
-		uploadPath:   uploadPath,
+		files:        files,
+		authz:        NewAuthzService(db),
+		jobs:         NewJobQueue(db),
 		downloadPath: downloadPath,
 		baseURL:      baseURL,
 	}
 }
 
+// downloadZipPayload ZIP 打包任务参数
+type downloadZipPayload struct {
+	TaskID uint `json:"task_id"`
+}
+
 // CreateBatchDownloadTask 创建批量下载任务
 func (s *DownloadService) CreateBatchDownloadTask(userID uint, req *models.BatchDownloadRequest) (*models.DownloadTaskInfo, error) {
 	// 验证文件权限
@@ -58,13 +69,17 @@ func (s *DownloadService) CreateBatchDownloadTask(userID uint, req *models.Batch
 		Status:   "pending",
 	}
 
-	if err := s.db.Create(&task).Error; err != nil {
+	// 下载任务与ZIP打包任务在同一事务中创建，由后台任务队列执行打包
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		_, err := s.jobs.EnqueueTx(tx, JobTypeDownloadZip, downloadZipPayload{TaskID: task.ID})
+		return err
+	}); err != nil {
 		return nil, fmt.Errorf("创建下载任务失败: %v", err)
 	}
 
-	// 异步处理ZIP打包
-	go s.processDownloadTask(task.ID)
-
 	return &models.DownloadTaskInfo{
 		ID:        task.ID,
 		TaskName:  task.TaskName,
@@ -77,101 +92,101 @@ func (s *DownloadService) CreateBatchDownloadTask(userID uint, req *models.Batch
 
 // validateFilePermissions 验证文件权限
 func (s *DownloadService) validateFilePermissions(userID uint, fileIDs []uint) ([]uint, error) {
-	var validFileIDs []uint
-	var files []models.File
-
-	// 查询文件信息
-	if err := s.db.Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
+	visible, err := s.authz.VisibleFiles(userID, "")
+	if err != nil {
 		return nil, err
 	}
 
-	for _, file := range files {
-		// 检查用户是否有权限访问该文件
-		if s.hasFilePermission(userID, &file) {
-			validFileIDs = append(validFileIDs, file.ID)
-		}
+	// 只保留有权查看的文件
+	var validFileIDs []uint
+	if err := s.db.Model(&models.File{}).Scopes(visible).
+		Where("id IN ? AND is_deleted = false", fileIDs).Pluck("id", &validFileIDs).Error; err != nil {
+		return nil, err
 	}
 
 	return validFileIDs, nil
 }
 
-// hasFilePermission 检查用户是否有文件权限
-func (s *DownloadService) hasFilePermission(userID uint, file *models.File) bool {
-	// 如果是文件上传者，有权限
-	if file.OwnerID == userID {
-		return true
+// OpenFile 打开单个文件的内容用于下载，权限规则与批量下载相同；内容不存在时返回 storage.ErrNotExist
+func (s *DownloadService) OpenFile(userID, fileID uint) (*FileContent, error) {
+	var file models.File
+	if err := s.db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在或无权限访问")
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
 	}
-
-	// 检查用户是否是工作流成员
-	var count int64
-	s.db.Table("workflow_members").Where("workflow_id = ? AND user_id = ?", file.WorkflowID, userID).Count(&count)
-	return count > 0
+	if err := s.authz.CheckFile(userID, &file, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, errors.New("文件不存在或无权限访问")
+		}
+		return nil, err
+	}
+	return s.files.Open(&file)
 }
 
-// processDownloadTask 处理下载任务（异步）
-func (s *DownloadService) processDownloadTask(taskID uint) {
-	// 更新任务状态为处理中
-	s.db.Model(&models.DownloadTask{}).Where("id = ?", taskID).Update("status", "processing")
+// HandleZipJob 处理ZIP打包后台任务，失败时重试，重试次数用尽后将下载任务标记为失败
+func (s *DownloadService) HandleZipJob(ctx context.Context, job *models.Job) error {
+	var payload downloadZipPayload
+	if err := decodeJobPayload(job, &payload); err != nil {
+		return err
+	}
+
+	err := s.processDownloadTask(ctx, payload.TaskID)
+	if err == nil {
+		return nil
+	}
+	if isLastAttempt(job, err) {
+		s.updateTaskError(payload.TaskID, err.Error())
+	} else {
+		// 等待重试期间保留最近一次的错误信息
+		s.db.Model(&models.DownloadTask{}).Where("id = ?", payload.TaskID).
+			Updates(map[string]interface{}{"status": "pending", "error_msg": err.Error()})
+	}
+	return err
+}
 
+// processDownloadTask 将下载任务中的文件打包为ZIP文件
+func (s *DownloadService) processDownloadTask(ctx context.Context, taskID uint) error {
 	// 获取任务信息
 	var task models.DownloadTask
 	if err := s.db.First(&task, taskID).Error; err != nil {
-		s.updateTaskError(taskID, fmt.Sprintf("获取任务信息失败: %v", err))
-		return
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return PermanentJobError(errors.New("下载任务不存在"))
+		}
+		return fmt.Errorf("获取任务信息失败: %v", err)
 	}
 
+	// 更新任务状态为处理中
+	s.db.Model(&models.DownloadTask{}).Where("id = ?", taskID).Update("status", "processing")
+
 	// 解析文件ID列表
 	var fileIDs []uint
 	if err := json.Unmarshal([]byte(task.FileIDs), &fileIDs); err != nil {
-		s.updateTaskError(taskID, fmt.Sprintf("解析文件ID列表失败: %v", err))
-		return
+		return PermanentJobError(fmt.Errorf("解析文件ID列表失败: %v", err))
 	}
 
 	// 获取文件信息
 	var files []models.File
 	if err := s.db.Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
-		s.updateTaskError(taskID, fmt.Sprintf("获取文件信息失败: %v", err))
-		return
+		return fmt.Errorf("获取文件信息失败: %v", err)
 	}
 
-	// 创建ZIP文件
-	zipFileName := fmt.Sprintf("%s_%d_%d.zip", task.TaskName, task.UserID, time.Now().Unix())
-	zipFilePath := filepath.Join(s.downloadPath, zipFileName)
-
 	// 确保下载目录存在
 	if err := os.MkdirAll(s.downloadPath, 0755); err != nil {
-		s.updateTaskError(taskID, fmt.Sprintf("创建下载目录失败: %v", err))
-		return
+		return fmt.Errorf("创建下载目录失败: %v", err)
 	}
 
-	// 创建ZIP文件
-	zipFile, err := os.Create(zipFilePath)
+	// 创建ZIP文件，文件名由任务ID生成，不使用用户提供的任务名称
+	zipFileName := fmt.Sprintf("download_%d_%d_%d.zip", task.ID, task.UserID, time.Now().Unix())
+	zipFilePath, err := downloadZipPath(s.downloadPath, zipFileName)
 	if err != nil {
-		s.updateTaskError(taskID, fmt.Sprintf("创建ZIP文件失败: %v", err))
-		return
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	var totalSize int64
-
-	// 添加文件到ZIP
-	for _, file := range files {
-		filePath := filepath.Join(s.uploadPath, file.FilePath)
-		if err := s.addFileToZip(zipWriter, filePath, file.FileName); err != nil {
-			s.updateTaskError(taskID, fmt.Sprintf("添加文件到ZIP失败: %v", err))
-			return
-		}
-		totalSize += file.FileSize
+		return PermanentJobError(err)
 	}
-
-	// 获取ZIP文件大小
-	zipInfo, err := zipFile.Stat()
+	zipSize, err := s.writeZip(ctx, zipFilePath, files)
 	if err != nil {
-		s.updateTaskError(taskID, fmt.Sprintf("获取ZIP文件信息失败: %v", err))
-		return
+		os.Remove(zipFilePath)
+		return err
 	}
 
 	// 生成下载URL
@@ -184,30 +199,68 @@ func (s *DownloadService) processDownloadTask(taskID uint) {
 	updates := map[string]interface{}{
 		"status":        "completed",
 		"zip_file_path": zipFilePath,
-		"file_size":     zipInfo.Size(),
+		"file_size":     zipSize,
 		"download_url":  downloadURL,
 		"expires_at":    expiresAt,
+		"error_msg":     "",
 	}
 
-	s.db.Model(&models.DownloadTask{}).Where("id = ?", taskID).Updates(updates)
+	if err := s.db.Model(&models.DownloadTask{}).Where("id = ?", taskID).Updates(updates).Error; err != nil {
+		os.Remove(zipFilePath)
+		return fmt.Errorf("更新下载任务失败: %v", err)
+	}
+	return nil
 }
 
-// addFileToZip 添加文件到ZIP
-func (s *DownloadService) addFileToZip(zipWriter *zip.Writer, filePath, fileName string) error {
-	file, err := os.Open(filePath)
+// writeZip 将文件写入ZIP文件并返回ZIP文件大小，任务超时或取消时停止
+func (s *DownloadService) writeZip(ctx context.Context, zipFilePath string, files []models.File) (int64, error) {
+	zipFile, err := os.Create(zipFilePath)
+	if err != nil {
+		return 0, fmt.Errorf("创建ZIP文件失败: %v", err)
+	}
+	defer zipFile.Close()
+
+	zipWriter := zip.NewWriter(zipFile)
+
+	// 添加文件到ZIP，条目名称去除路径分隔符，重名文件添加序号
+	used := make(map[string]bool)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("打包被中断: %v", err)
+		}
+		name := uniqueZipName(sanitizeZipName(file.FileName), used)
+		if err := s.addFileToZip(zipWriter, name, &file); err != nil {
+			return 0, fmt.Errorf("添加文件到ZIP失败: %v", err)
+		}
+	}
+
+	// 写入ZIP目录后再获取文件大小
+	if err := zipWriter.Close(); err != nil {
+		return 0, fmt.Errorf("写入ZIP文件失败: %v", err)
+	}
+	zipInfo, err := zipFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("获取ZIP文件信息失败: %v", err)
+	}
+	return zipInfo.Size(), nil
+}
+
+// addFileToZip 从文件存储读取文件并添加到ZIP
+func (s *DownloadService) addFileToZip(zipWriter *zip.Writer, name string, file *models.File) error {
+	content, err := s.files.Reader(file)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer content.Close()
 
 	// 创建ZIP文件条目
-	zipEntry, err := zipWriter.Create(fileName)
+	zipEntry, err := zipWriter.Create(name)
 	if err != nil {
 		return err
 	}
 
 	// 复制文件内容
-	_, err = io.Copy(zipEntry, file)
+	_, err = io.Copy(zipEntry, content)
 	return err
 }
 
@@ -290,6 +343,12 @@ func (s *DownloadService) GetUserDownloadTasks(userID uint, page, pageSize int)
 
 // DownloadZipFile 下载ZIP文件
 func (s *DownloadService) DownloadZipFile(userID uint, fileName string) (string, error) {
+	// 构建文件路径，文件名不能包含路径
+	filePath, err := downloadZipPath(s.downloadPath, fileName)
+	if err != nil {
+		return "", err
+	}
+
 	// 从文件名解析用户ID和任务信息
 	parts := strings.Split(fileName, "_")
 	if len(parts) < 3 {
@@ -302,9 +361,6 @@ func (s *DownloadService) DownloadZipFile(userID uint, fileName string) (string,
 		return "", fmt.Errorf("无权限下载此文件")
 	}
 
-	// 构建文件路径
-	filePath := filepath.Join(s.downloadPath, fileName)
-
 	// 检查文件是否存在
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return "", fmt.Errorf("文件不存在或已过期")
@@ -313,6 +369,18 @@ func (s *DownloadService) DownloadZipFile(userID uint, fileName string) (string,
 	return filePath, nil
 }
 
+// downloadZipPath 返回下载目录中ZIP文件的路径，文件名不能包含路径分隔符，结果须直接位于下载目录中
+func downloadZipPath(downloadPath, fileName string) (string, error) {
+	if fileName == "" || fileName == "." || fileName == ".." || strings.ContainsAny(fileName, "/\\\x00") {
+		return "", errors.New("无效的文件名格式")
+	}
+	filePath := filepath.Join(downloadPath, fileName)
+	if filepath.Dir(filePath) != filepath.Clean(downloadPath) {
+		return "", errors.New("无效的文件名格式")
+	}
+	return filePath, nil
+}
+
 // LogDownload 记录下载日志
 func (s *DownloadService) LogDownload(userID, fileID uint, fileName string, fileSize int64, ipAddress, userAgent string) error {
 	log := models.DownloadLog{
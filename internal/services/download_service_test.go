@@ -0,0 +1,24 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadZipPath(t *testing.T) {
+	dir := filepath.Join("data", "downloads")
+
+	got, err := downloadZipPath(dir, "download_12_3_1700000000.zip")
+	if err != nil {
+		t.Fatalf("downloadZipPath: %v", err)
+	}
+	if want := filepath.Join(dir, "download_12_3_1700000000.zip"); got != want {
+		t.Errorf("downloadZipPath = %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"", ".", "..", "../etc_1_2.zip", "a/../../b_1_2.zip", `..\b_1_2.zip`, "a\x00_1_2.zip"} {
+		if _, err := downloadZipPath(dir, name); err == nil {
+			t.Errorf("downloadZipPath(%q) accepted an invalid name", name)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"io"
+	"time"
+)
+
+// FileContent 打开用于下载的文件内容，支持定位读取以响应 Range 请求
+type FileContent struct {
+	io.ReadSeekCloser
+	Name     string    // 下载文件名
+	MimeType string    // 内容类型，未知时为空
+	Size     int64     // 内容大小（字节）
+	MD5Hash  string    // 内容 MD5，用作 ETag
+	ModTime  time.Time // 内容修改时间，用作 Last-Modified
+}
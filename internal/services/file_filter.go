@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 标签筛选方式
+const (
+	TagMatchAll = "and" // 包含全部标签
+	TagMatchAny = "or"  // 包含任一标签
+)
+
+// facetLimit 每个分面最多返回的取值数
+const facetLimit = 50
+
+// 分面维度，计算某一维度的分面时不应用该维度自身的筛选条件
+const (
+	facetTag   = "tag"
+	facetMime  = "mime"
+	facetOwner = "owner"
+)
+
+// FileFilter 文件筛选条件，GetFileList 与 SearchFiles 共用
+type FileFilter struct {
+	MimeType    string     `json:"mime_type"`    // MIME 前缀，如 image 或 image/jpeg
+	TagIDs      []uint     `json:"tag_ids"`      // 标签筛选
+	TagMode     string     `json:"tag_mode"`     // and（默认）：包含全部标签；or：包含任一标签
+	OwnerIDs    []uint     `json:"owner_ids"`    // 上传者筛选，包含任一即可
+	MinSize     int64      `json:"min_size"`     // 最小文件大小（字节），0 表示不限制
+	MaxSize     int64      `json:"max_size"`     // 最大文件大小（字节），0 表示不限制
+	CreatedFrom *time.Time `json:"created_from"` // 上传时间下限（含）
+	CreatedTo   *time.Time `json:"created_to"`   // 上传时间上限（不含）
+	WithFacets  bool       `json:"with_facets"`  // 是否返回分面统计
+}
+
+// FileFacets 文件分面统计，用于构建筛选侧边栏
+// 每个分面按除自身维度外的全部筛选条件统计，便于在同一维度内多选
+type FileFacets struct {
+	Tags      []TagFacet   `json:"tags"`
+	MimeTypes []MimeFacet  `json:"mime_types"`
+	Owners    []OwnerFacet `json:"owners"`
+}
+
+// TagFacet 标签分面
+type TagFacet struct {
+	ID      uint   `json:"id"`
+	TagName string `json:"tag_name"`
+	Color   string `json:"color"`
+	Count   int64  `json:"count"`
+}
+
+// MimeFacet MIME 大类分面，如 image、video
+type MimeFacet struct {
+	Family string `json:"family"`
+	Count  int64  `json:"count"`
+}
+
+// OwnerFacet 上传者分面
+type OwnerFacet struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	RealName string `json:"real_name"`
+	Count    int64  `json:"count"`
+}
+
+// Validate 检查筛选条件
+func (f *FileFilter) Validate() error {
+	switch f.TagMode {
+	case "":
+		f.TagMode = TagMatchAll
+	case TagMatchAll, TagMatchAny:
+	default:
+		return fmt.Errorf("不支持的标签筛选方式: %s", f.TagMode)
+	}
+	if f.MinSize < 0 || f.MaxSize < 0 {
+		return errors.New("文件大小不能为负数")
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return errors.New("最小文件大小不能大于最大文件大小")
+	}
+	if f.CreatedFrom != nil && f.CreatedTo != nil && !f.CreatedFrom.Before(*f.CreatedTo) {
+		return errors.New("开始时间必须早于结束时间")
+	}
+	return nil
+}
+
+// apply 将筛选条件应用到文件查询，skip 为不应用的维度
+func (f *FileFilter) apply(query *gorm.DB, skip string) *gorm.DB {
+	if f.MimeType != "" && skip != facetMime {
+		query = query.Where("files.mime_type LIKE ?", f.MimeType+"%")
+	}
+	if len(f.TagIDs) > 0 && skip != facetTag {
+		tagIDs := uniqueIDs(f.TagIDs)
+		tagged := query.Session(&gorm.Session{NewDB: true}).Model(&models.FileTag{}).
+			Select("file_id").Where("tag_id IN ?", tagIDs)
+		if f.TagMode == TagMatchAny {
+			query = query.Where("files.id IN (?)", tagged)
+		} else {
+			query = query.Where("files.id IN (?)", tagged.Group("file_id").Having("COUNT(DISTINCT tag_id) = ?", len(tagIDs)))
+		}
+	}
+	if len(f.OwnerIDs) > 0 && skip != facetOwner {
+		query = query.Where("files.owner_id IN ?", f.OwnerIDs)
+	}
+	if f.MinSize > 0 {
+		query = query.Where("files.file_size >= ?", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		query = query.Where("files.file_size <= ?", f.MaxSize)
+	}
+	if f.CreatedFrom != nil {
+		query = query.Where("files.created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		query = query.Where("files.created_at < ?", *f.CreatedTo)
+	}
+	return query
+}
+
+// fileFacets 统计文件分面，base 返回应用了筛选条件以外的基础查询（权限、文件夹、关键词等）
+func (s *FileService) fileFacets(base func() *gorm.DB, filter *FileFilter) (*FileFacets, error) {
+	facets := &FileFacets{
+		Tags:      []TagFacet{},
+		MimeTypes: []MimeFacet{},
+		Owners:    []OwnerFacet{},
+	}
+
+	tagged := filter.apply(base(), facetTag).Select("files.id")
+	if err := s.db.Table("file_tags").
+		Select("tags.id, tags.tag_name, tags.color, COUNT(*) AS count").
+		Joins("JOIN tags ON tags.id = file_tags.tag_id").
+		Where("file_tags.file_id IN (?)", tagged).
+		Group("tags.id").Order("count DESC, tags.tag_name").Limit(facetLimit).
+		Scan(&facets.Tags).Error; err != nil {
+		return nil, fmt.Errorf("统计标签分面失败: %v", err)
+	}
+
+	family := "COALESCE(NULLIF(split_part(files.mime_type, '/', 1), ''), 'unknown')"
+	if err := filter.apply(base(), facetMime).
+		Select(family + " AS family, COUNT(*) AS count").
+		Group(family).Order("count DESC, family").Limit(facetLimit).
+		Scan(&facets.MimeTypes).Error; err != nil {
+		return nil, fmt.Errorf("统计文件类型分面失败: %v", err)
+	}
+
+	if err := filter.apply(base(), facetOwner).
+		Select("files.owner_id AS id, COALESCE(users.username, '') AS username, COALESCE(users.real_name, '') AS real_name, COUNT(*) AS count").
+		Joins("LEFT JOIN users ON users.id = files.owner_id").
+		Group("files.owner_id, users.username, users.real_name").Order("count DESC, users.username").Limit(facetLimit).
+		Scan(&facets.Owners).Error; err != nil {
+		return nil, fmt.Errorf("统计上传者分面失败: %v", err)
+	}
+
+	return facets, nil
+}
+
+// fileSortColumn 文件列表排序字段，只允许预定义的字段
+func fileSortColumn(sortBy, sortOrder string) string {
+	column := "files.created_at"
+	switch sortBy {
+	case "name", "file_name":
+		column = "files.file_name"
+	case "size", "file_size":
+		column = "files.file_size"
+	case "updated_at":
+		column = "files.updated_at"
+	}
+
+	if strings.EqualFold(sortOrder, "asc") {
+		return column + " ASC"
+	}
+	return column + " DESC"
+}
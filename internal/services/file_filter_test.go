@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileFilterValidate(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	filter := &FileFilter{}
+	if err := filter.Validate(); err != nil {
+		t.Fatalf("Validate empty filter = %v", err)
+	}
+	if filter.TagMode != TagMatchAll {
+		t.Errorf("default TagMode = %q, want %q", filter.TagMode, TagMatchAll)
+	}
+
+	for name, filter := range map[string]*FileFilter{
+		"unknown tag mode": {TagMode: "xor"},
+		"negative size":    {MinSize: -1},
+		"min above max":    {MinSize: 100, MaxSize: 10},
+		"reversed dates":   {CreatedFrom: &now, CreatedTo: &earlier},
+		"empty date range": {CreatedFrom: &now, CreatedTo: &now},
+	} {
+		if err := filter.Validate(); err == nil {
+			t.Errorf("%s: Validate = nil, want error", name)
+		}
+	}
+
+	for name, filter := range map[string]*FileFilter{
+		"or tag mode":   {TagMode: TagMatchAny},
+		"min only":      {MinSize: 100},
+		"size range":    {MinSize: 10, MaxSize: 10},
+		"ordered dates": {CreatedFrom: &earlier, CreatedTo: &now},
+	} {
+		if err := filter.Validate(); err != nil {
+			t.Errorf("%s: Validate = %v, want nil", name, err)
+		}
+	}
+}
+
+func TestFileSortColumn(t *testing.T) {
+	for _, tc := range []struct {
+		sortBy, sortOrder, want string
+	}{
+		{"", "", "files.created_at DESC"},
+		{"name", "asc", "files.file_name ASC"},
+		{"file_size", "desc", "files.file_size DESC"},
+		{"updated_at", "ASC", "files.updated_at ASC"},
+		{"id; DROP TABLE files", "asc", "files.created_at ASC"},
+	} {
+		if got := fileSortColumn(tc.sortBy, tc.sortOrder); got != tc.want {
+			t.Errorf("fileSortColumn(%q, %q) = %q, want %q", tc.sortBy, tc.sortOrder, got, tc.want)
+		}
+	}
+}
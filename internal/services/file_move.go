@@ -0,0 +1,583 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNameConflict 目标位置已存在同名文件或文件夹
+	ErrNameConflict = errors.New("目标位置已存在同名文件或文件夹")
+	// ErrMoveIntoSelf 文件夹不能移动或复制到自身及其子文件夹中
+	ErrMoveIntoSelf = errors.New("不能将文件夹移动或复制到自身或其子文件夹中")
+	// ErrInvalidName 文件或文件夹名称无效
+	ErrInvalidName = errors.New("名称不能为空，且不能包含路径分隔符")
+)
+
+// MoveRequest 移动或复制请求
+// FolderID 为 0 表示目标工作流的根目录；目标工作流由目标文件夹决定，
+// 移动到根目录时由 WorkflowID 指定，为 0 时沿用原工作流。
+type MoveRequest struct {
+	FolderID   uint   `json:"folder_id"`
+	WorkflowID uint   `json:"workflow_id"`
+	Name       string `json:"name"` // 目标名称，为空时沿用原名称
+}
+
+// moveTarget 解析后的目标位置
+type moveTarget struct {
+	folderID   uint
+	workflowID uint
+	path       string // 目标文件夹的物化路径，根目录为空
+}
+
+// validateItemName 检查文件或文件夹名称，名称会成为物化路径的一段
+func validateItemName(name string) error {
+	if strings.TrimSpace(name) == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// joinFolderPath 拼接文件夹的物化路径
+func joinFolderPath(parentPath, name string) string {
+	if parentPath == "" {
+		return name
+	}
+	return path.Join(parentPath, name)
+}
+
+// rebaseFolderPaths 以 rootPath 作为子树根文件夹的新路径，重新计算子树中每个文件夹的路径
+// folders 为 folderSubtree 的结果，父文件夹排在子文件夹之前
+func rebaseFolderPaths(folders []models.FileFolder, rootPath string) map[uint]string {
+	paths := make(map[uint]string, len(folders))
+	for i, folder := range folders {
+		if i == 0 {
+			paths[folder.ID] = rootPath
+			continue
+		}
+		paths[folder.ID] = joinFolderPath(paths[folder.ParentID], folder.Name)
+	}
+	return paths
+}
+
+// folderSubtree 获取文件夹及其全部子孙文件夹，父文件夹排在子文件夹之前
+// includeDeleted 为 true 时包含已删除的子文件夹，使其在回收站中的位置随父文件夹一起变化
+func folderSubtree(db *gorm.DB, root *models.FileFolder, includeDeleted bool) ([]models.FileFolder, error) {
+	folders := []models.FileFolder{*root}
+	seen := map[uint]bool{root.ID: true}
+	level := []uint{root.ID}
+	for len(level) > 0 {
+		query := db.Where("parent_id IN ? AND workflow_id = ?", level, root.WorkflowID)
+		if !includeDeleted {
+			query = query.Where("is_deleted = false")
+		}
+		var children []models.FileFolder
+		if err := query.Order("id").Find(&children).Error; err != nil {
+			return nil, fmt.Errorf("获取子文件夹失败: %v", err)
+		}
+
+		level = level[:0]
+		for _, child := range children {
+			if seen[child.ID] {
+				continue
+			}
+			seen[child.ID] = true
+			folders = append(folders, child)
+			level = append(level, child.ID)
+		}
+	}
+	return folders, nil
+}
+
+// folderIDs 获取文件夹列表的 ID
+func folderIDs(folders []models.FileFolder) []uint {
+	ids := make([]uint, len(folders))
+	for i, folder := range folders {
+		ids[i] = folder.ID
+	}
+	return ids
+}
+
+// checkSubtreeAccess 逐个检查用户对子树中子文件夹和文件的权限，整体操作文件夹时不能绕过对其中内容的权限
+// （如其他用户的私有文件）；includeDeleted 为 true 时同时检查回收站中的文件
+func (s *FileService) checkSubtreeAccess(db *gorm.DB, subtree []models.FileFolder, userID uint, action string, includeDeleted bool) error {
+	checker := s.authz.newBatchChecker(userID)
+	for i := range subtree[1:] {
+		folder := &subtree[i+1]
+		if err := checker.Folder(folder, action); err != nil {
+			if errors.Is(err, ErrPermissionDenied) {
+				return fmt.Errorf("文件夹中包含无权操作的子文件夹 %s: %w", folder.Name, err)
+			}
+			return err
+		}
+	}
+
+	query := db.Select("id, file_name, owner_id, workflow_id, task_id, is_private").
+		Where("folder_id IN ? AND workflow_id = ?", folderIDs(subtree), subtree[0].WorkflowID)
+	if !includeDeleted {
+		query = query.Where("is_deleted = false")
+	}
+	var files []models.File
+	if err := query.Find(&files).Error; err != nil {
+		return fmt.Errorf("获取文件列表失败: %v", err)
+	}
+	for i := range files {
+		if err := checker.File(&files[i], action); err != nil {
+			if errors.Is(err, ErrPermissionDenied) {
+				return fmt.Errorf("文件夹中包含无权操作的文件 %s: %w", files[i].FileName, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveMoveTarget 解析并检查目标位置
+// 须有在目标文件夹（或目标工作流的根目录）中添加内容的权限，目标工作流须允许修改文件
+func (s *FileService) resolveMoveTarget(tx *gorm.DB, req *MoveRequest, sourceWorkflowID, userID uint) (*moveTarget, error) {
+	target := &moveTarget{workflowID: req.WorkflowID}
+	if req.FolderID > 0 {
+		var folder models.FileFolder
+		if err := tx.Where("id = ? AND is_deleted = false", req.FolderID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("目标文件夹不存在")
+			}
+			return nil, fmt.Errorf("获取目标文件夹失败: %v", err)
+		}
+		if req.WorkflowID != 0 && req.WorkflowID != folder.WorkflowID {
+			return nil, errors.New("目标文件夹不属于指定的工作流")
+		}
+		if err := s.authz.CheckFolder(userID, &folder, ActionUpload); err != nil {
+			return nil, err
+		}
+		target.folderID = folder.ID
+		target.workflowID = folder.WorkflowID
+		target.path = folder.Path
+	} else {
+		if target.workflowID == 0 {
+			target.workflowID = sourceWorkflowID
+		}
+		if target.workflowID != 0 {
+			if err := s.authz.CheckWorkflow(userID, target.workflowID, ActionUpload); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := checkWorkflowWritable(tx, target.workflowID); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// checkFileNameConflict 检查目标文件夹下是否存在同名文件，excludeID 为被移动的文件
+func checkFileNameConflict(tx *gorm.DB, target *moveTarget, name string, excludeID uint) error {
+	var count int64
+	if err := tx.Model(&models.File{}).
+		Where("folder_id = ? AND workflow_id = ? AND file_name = ? AND id != ? AND is_deleted = false",
+			target.folderID, target.workflowID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查同名文件失败: %v", err)
+	}
+	if count > 0 {
+		return ErrNameConflict
+	}
+	return nil
+}
+
+// checkFolderNameConflict 检查目标文件夹下是否存在同名文件夹，excludeID 为被移动的文件夹
+func checkFolderNameConflict(tx *gorm.DB, target *moveTarget, name string, excludeID uint) error {
+	var count int64
+	if err := tx.Model(&models.FileFolder{}).
+		Where("parent_id = ? AND workflow_id = ? AND name = ? AND id != ? AND is_deleted = false",
+			target.folderID, target.workflowID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查同名文件夹失败: %v", err)
+	}
+	if count > 0 {
+		return ErrNameConflict
+	}
+	return nil
+}
+
+// MoveFile 移动文件到其他文件夹或工作流（需有修改文件的权限），可同时重命名
+// 移动到其他工作流时检查目标工作流的配额，原有的任务关联失效，task_id 置为 0
+func (s *FileService) MoveFile(fileID uint, req *MoveRequest, userID uint) (*FileInfo, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var file models.File
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("文件不存在或无权限修改")
+			}
+			return fmt.Errorf("获取文件信息失败: %v", err)
+		}
+		if err := s.authz.CheckFile(userID, &file, ActionEdit); err != nil {
+			return err
+		}
+		if err := checkWorkflowWritable(tx, file.WorkflowID); err != nil {
+			return err
+		}
+
+		target, err := s.resolveMoveTarget(tx, req, file.WorkflowID, userID)
+		if err != nil {
+			return err
+		}
+		name := file.FileName
+		if req.Name != "" {
+			name = req.Name
+		}
+		if err := validateItemName(name); err != nil {
+			return err
+		}
+		if err := checkFileNameConflict(tx, target, name, file.ID); err != nil {
+			return err
+		}
+		if target.workflowID != file.WorkflowID {
+			if err := s.quotas.CheckWorkflowQuota(tx, target.workflowID, file.FileSize); err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{
+			"file_name":   name,
+			"folder_id":   target.folderID,
+			"workflow_id": target.workflowID,
+		}
+		if target.workflowID != file.WorkflowID {
+			updates["task_id"] = 0
+		}
+		if err := tx.Model(&file).Updates(updates).Error; err != nil {
+			return fmt.Errorf("移动文件失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.search.indexQuietly(fileID)
+
+	return s.GetFileByID(fileID, userID)
+}
+
+// CopyFile 复制文件到其他文件夹或工作流，副本归复制者所有
+// 副本与原文件共享同一份存储内容，并复制标签；历史版本不复制。
+func (s *FileService) CopyFile(fileID uint, req *MoveRequest, userID uint) (*FileInfo, error) {
+	if _, err := s.GetFileByID(fileID, userID); err != nil {
+		return nil, err
+	}
+	var file models.File
+	if err := s.db.First(&file, fileID).Error; err != nil {
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+
+	var copied *models.File
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		target, err := s.resolveMoveTarget(tx, req, file.WorkflowID, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.quotas.CheckQuota(tx, userID, target.workflowID, file.FileSize); err != nil {
+			return err
+		}
+		name := file.FileName
+		if req.Name != "" {
+			name = req.Name
+		}
+		if err := validateItemName(name); err != nil {
+			return err
+		}
+		if err := checkFileNameConflict(tx, target, name, 0); err != nil {
+			return err
+		}
+
+		source := file
+		source.FileName = name
+		copies, err := s.copyFiles(tx, []models.File{source}, target.workflowID, userID, func(*models.File) uint {
+			return target.folderID
+		})
+		if err != nil {
+			return err
+		}
+		copied = &copies[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.search.indexQuietly(copied.ID)
+
+	return s.GetFileByID(copied.ID, userID)
+}
+
+// copyFiles 在事务中为文件创建副本，folderOf 返回副本所在的文件夹
+// 副本引用原文件的存储内容并复制标签，拍摄信息与缩略图由后台任务重新生成
+func (s *FileService) copyFiles(tx *gorm.DB, files []models.File, workflowID, userID uint, folderOf func(*models.File) uint) ([]models.File, error) {
+	copies := make([]models.File, 0, len(files))
+	for i := range files {
+		file := &files[i]
+		taskID := file.TaskID
+		if workflowID != file.WorkflowID {
+			taskID = 0
+		}
+		copied := models.File{
+			FileName:    file.FileName,
+			FilePath:    file.FilePath,
+			FileSize:    file.FileSize,
+			MD5Hash:     file.MD5Hash,
+			MimeType:    file.MimeType,
+			OwnerID:     userID,
+			FolderID:    folderOf(file),
+			WorkflowID:  workflowID,
+			TaskID:      taskID,
+			IsPrivate:   file.IsPrivate,
+			Description: file.Description,
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return nil, fmt.Errorf("复制文件失败: %v", err)
+		}
+		if err := s.blobs.Acquire(tx, copied.FilePath); err != nil {
+			return nil, fmt.Errorf("更新文件引用失败: %v", err)
+		}
+
+		var tags []models.FileTag
+		if err := tx.Where("file_id = ?", file.ID).Find(&tags).Error; err != nil {
+			return nil, fmt.Errorf("获取文件标签失败: %v", err)
+		}
+		for _, tag := range tags {
+			if err := tx.Create(&models.FileTag{TagID: tag.TagID, FileID: copied.ID}).Error; err != nil {
+				return nil, fmt.Errorf("复制文件标签失败: %v", err)
+			}
+		}
+
+		if err := s.jobs.enqueueFileProcessing(tx, copied.ID); err != nil {
+			return nil, err
+		}
+		copies = append(copies, copied)
+	}
+	return copies, nil
+}
+
+// MoveFolder 移动文件夹及其全部内容（需有修改文件夹的权限），可同时重命名
+// 子树中所有文件夹的物化路径在同一事务中重新计算；移动到其他工作流时，子树中的文件夹和文件一起转移，
+// 须对其中每个子文件夹和文件都有修改权限，按文件总大小检查目标工作流的配额，文件原有的任务关联失效。
+func (s *FileService) MoveFolder(folderID uint, req *MoveRequest, userID uint) (*FolderInfo, error) {
+	var moved models.FileFolder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var folder models.FileFolder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_deleted = false", folderID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("文件夹不存在或无权限修改")
+			}
+			return fmt.Errorf("获取文件夹信息失败: %v", err)
+		}
+		if err := s.authz.CheckFolder(userID, &folder, ActionEdit); err != nil {
+			return err
+		}
+		if err := checkWorkflowWritable(tx, folder.WorkflowID); err != nil {
+			return err
+		}
+
+		target, err := s.resolveMoveTarget(tx, req, folder.WorkflowID, userID)
+		if err != nil {
+			return err
+		}
+		name := folder.Name
+		if req.Name != "" {
+			name = req.Name
+		}
+		if err := validateItemName(name); err != nil {
+			return err
+		}
+
+		subtree, err := folderSubtree(tx, &folder, true)
+		if err != nil {
+			return err
+		}
+		for _, id := range folderIDs(subtree) {
+			if id == target.folderID {
+				return ErrMoveIntoSelf
+			}
+		}
+		if err := checkFolderNameConflict(tx, target, name, folder.ID); err != nil {
+			return err
+		}
+		if target.workflowID != folder.WorkflowID {
+			if err := s.checkSubtreeAccess(tx, subtree, userID, ActionEdit, true); err != nil {
+				return err
+			}
+			var totalSize int64
+			if err := tx.Model(&models.File{}).
+				Where("folder_id IN ? AND workflow_id = ? AND is_deleted = false", folderIDs(subtree), folder.WorkflowID).
+				Select("COALESCE(SUM(file_size), 0)").Scan(&totalSize).Error; err != nil {
+				return fmt.Errorf("统计文件大小失败: %v", err)
+			}
+			if err := s.quotas.CheckWorkflowQuota(tx, target.workflowID, totalSize); err != nil {
+				return err
+			}
+		}
+
+		subtree[0].Name = name
+		paths := rebaseFolderPaths(subtree, joinFolderPath(target.path, name))
+		for _, f := range subtree {
+			updates := map[string]interface{}{
+				"path":        paths[f.ID],
+				"workflow_id": target.workflowID,
+			}
+			if f.ID == folder.ID {
+				updates["name"] = name
+				updates["parent_id"] = target.folderID
+			}
+			if err := tx.Model(&models.FileFolder{}).Where("id = ?", f.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("移动文件夹失败: %v", err)
+			}
+		}
+
+		if target.workflowID != folder.WorkflowID {
+			if err := tx.Model(&models.File{}).Where("folder_id IN ? AND workflow_id = ?", folderIDs(subtree), folder.WorkflowID).
+				Updates(map[string]interface{}{"workflow_id": target.workflowID, "task_id": 0}).Error; err != nil {
+				return fmt.Errorf("移动文件失败: %v", err)
+			}
+		}
+
+		return tx.First(&moved, folder.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toFolderInfo(&moved), nil
+}
+
+// CopyFolder 复制文件夹及其全部内容，副本归复制者所有
+// 须有查看文件夹的权限；只复制复制者可以查看的文件
+func (s *FileService) CopyFolder(folderID uint, req *MoveRequest, userID uint) (*FolderInfo, error) {
+	var folder models.FileFolder
+	if err := s.db.Where("id = ? AND is_deleted = false", folderID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件夹不存在")
+		}
+		return nil, fmt.Errorf("获取文件夹信息失败: %v", err)
+	}
+	if err := s.authz.CheckFolder(userID, &folder, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, errors.New("文件夹不存在或无权限访问")
+		}
+		return nil, err
+	}
+
+	subtree, err := folderSubtree(s.db, &folder, false)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := s.authz.VisibleFiles(userID, "")
+	if err != nil {
+		return nil, err
+	}
+	var files []models.File
+	if err := s.db.Scopes(visible).Where("folder_id IN ? AND workflow_id = ? AND is_deleted = false",
+		folderIDs(subtree), folder.WorkflowID).Order("id").Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("获取文件列表失败: %v", err)
+	}
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.FileSize
+	}
+
+	var root models.FileFolder
+	var copiedIDs []uint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		target, err := s.resolveMoveTarget(tx, req, folder.WorkflowID, userID)
+		if err != nil {
+			return err
+		}
+		for _, id := range folderIDs(subtree) {
+			if id == target.folderID {
+				return ErrMoveIntoSelf
+			}
+		}
+		if err := s.quotas.CheckQuota(tx, userID, target.workflowID, totalSize); err != nil {
+			return err
+		}
+		name := folder.Name
+		if req.Name != "" {
+			name = req.Name
+		}
+		if err := validateItemName(name); err != nil {
+			return err
+		}
+		if err := checkFolderNameConflict(tx, target, name, 0); err != nil {
+			return err
+		}
+
+		subtree[0].Name = name
+		paths := rebaseFolderPaths(subtree, joinFolderPath(target.path, name))
+		// 原文件夹 ID 到副本 ID 的映射，父文件夹先于子文件夹创建
+		newIDs := make(map[uint]uint, len(subtree))
+		for _, f := range subtree {
+			parentID := target.folderID
+			if f.ID != folder.ID {
+				parentID = newIDs[f.ParentID]
+			}
+			copied := models.FileFolder{
+				Name:        f.Name,
+				Path:        paths[f.ID],
+				ParentID:    parentID,
+				WorkflowID:  target.workflowID,
+				CreatorID:   userID,
+				Description: f.Description,
+				SortOrder:   f.SortOrder,
+			}
+			if err := tx.Create(&copied).Error; err != nil {
+				return fmt.Errorf("复制文件夹失败: %v", err)
+			}
+			newIDs[f.ID] = copied.ID
+			if f.ID == folder.ID {
+				root = copied
+			}
+		}
+
+		copies, err := s.copyFiles(tx, files, target.workflowID, userID, func(file *models.File) uint {
+			return newIDs[file.FolderID]
+		})
+		if err != nil {
+			return err
+		}
+		for _, copied := range copies {
+			copiedIDs = append(copiedIDs, copied.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(copiedIDs) > 0 {
+		s.search.indexQuietly(copiedIDs...)
+	}
+
+	return toFolderInfo(&root), nil
+}
+
+// toFolderInfo 转换为文件夹信息
+func toFolderInfo(folder *models.FileFolder) *FolderInfo {
+	return &FolderInfo{
+		ID:          folder.ID,
+		Name:        folder.Name,
+		Path:        folder.Path,
+		ParentID:    folder.ParentID,
+		WorkflowID:  folder.WorkflowID,
+		CreatorID:   folder.CreatorID,
+		Description: folder.Description,
+		SortOrder:   folder.SortOrder,
+		CreatedAt:   folder.CreatedAt,
+		UpdatedAt:   folder.UpdatedAt,
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"mcs-backend/internal/models"
+)
+
+func TestValidateItemName(t *testing.T) {
+	for _, name := range []string{"a.txt", "照片 2024", "..a", "a..b"} {
+		if err := validateItemName(name); err != nil {
+			t.Errorf("validateItemName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "  ", ".", "..", "a/b", `a\b`, "a\x00"} {
+		if err := validateItemName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("validateItemName(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+}
+
+func TestRebaseFolderPaths(t *testing.T) {
+	// 子文件夹的原路径已过期（如改名时未更新），重新计算时只依据名称和父子关系
+	subtree := []models.FileFolder{
+		{ID: 10, Name: "拍摄", Path: "旧/拍摄"},
+		{ID: 11, ParentID: 10, Name: "RAW", Path: "stale/RAW"},
+		{ID: 12, ParentID: 10, Name: "JPG", Path: "旧/拍摄/JPG"},
+		{ID: 13, ParentID: 11, Name: "day1", Path: "x"},
+	}
+
+	paths := rebaseFolderPaths(subtree, joinFolderPath("项目/素材", "拍摄"))
+	want := map[uint]string{
+		10: "项目/素材/拍摄",
+		11: "项目/素材/拍摄/RAW",
+		12: "项目/素材/拍摄/JPG",
+		13: "项目/素材/拍摄/RAW/day1",
+	}
+	for id, p := range want {
+		if paths[id] != p {
+			t.Errorf("path of %d = %q, want %q", id, paths[id], p)
+		}
+	}
+
+	paths = rebaseFolderPaths(subtree, joinFolderPath("", "拍摄"))
+	if paths[13] != "拍摄/RAW/day1" {
+		t.Errorf("path at root = %q, want %q", paths[13], "拍摄/RAW/day1")
+	}
+}
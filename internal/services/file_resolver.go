@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+)
+
+// ErrInvalidStoragePath 文件记录的存储路径无效（为空、包含 ".." 或位于上传目录之外）
+var ErrInvalidStoragePath = errors.New("无效的文件存储路径")
+
+// FileResolver 将文件记录解析为文件存储中的内容
+// 所有下载（单文件、历史版本、批量 ZIP、分享链接）都通过它读取内容：解析只依据记录中保存的 FilePath，
+// 与展示用的文件名无关，因此重命名后的文件、秒传产生的共享同一内容的文件都能读取到各自的内容。
+// FilePath 通常是内容寻址存储的键（blobs/xx/<md5>）；早期版本保存的是上传目录下的磁盘路径
+// （UploadPath/<md5>_<文件名>），解析时转换为相对于上传目录的键。
+type FileResolver struct {
+	storage    storage.Storage
+	uploadPath string
+}
+
+// NewFileResolver 创建文件内容解析器，uploadPath 为本地存储的上传目录，用于解析早期版本保存的磁盘路径
+func NewFileResolver(store storage.Storage, uploadPath string) *FileResolver {
+	return &FileResolver{
+		storage:    store,
+		uploadPath: uploadPath,
+	}
+}
+
+// StorageKey 将文件记录的 FilePath 解析为存储键，路径可能逃逸出存储根目录时返回 ErrInvalidStoragePath
+func (r *FileResolver) StorageKey(filePath string) (string, error) {
+	if filePath == "" || strings.ContainsAny(filePath, "\x00\\") {
+		return "", ErrInvalidStoragePath
+	}
+
+	key := path.Clean(filepath.ToSlash(filePath))
+	if rel, ok := r.legacyKey(key); ok {
+		key = rel
+	}
+
+	if key == "." || key == ".." || path.IsAbs(key) || strings.HasPrefix(key, "../") {
+		return "", ErrInvalidStoragePath
+	}
+	return key, nil
+}
+
+// legacyKey 将上传目录下的磁盘路径转换为相对于上传目录的键
+func (r *FileResolver) legacyKey(key string) (string, bool) {
+	if r.uploadPath == "" {
+		return "", false
+	}
+	root := path.Clean(filepath.ToSlash(r.uploadPath))
+	if root == "." || root == "/" {
+		return "", false
+	}
+
+	if rel := strings.TrimPrefix(key, root+"/"); rel != key {
+		return rel, true
+	}
+	return "", false
+}
+
+// Open 打开文件记录对应的内容用于下载，内容不存在时返回 storage.ErrNotExist
+func (r *FileResolver) Open(file *models.File) (*FileContent, error) {
+	key, err := r.StorageKey(file.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := storage.NewReadSeeker(r.storage, key, file.FileSize)
+	if err != nil {
+		return nil, err
+	}
+	return &FileContent{
+		ReadSeekCloser: content,
+		Name:           file.FileName,
+		MimeType:       file.MimeType,
+		Size:           file.FileSize,
+		MD5Hash:        file.MD5Hash,
+		ModTime:        file.UpdatedAt,
+	}, nil
+}
+
+// Reader 读取文件记录对应的全部内容，内容不存在时返回 storage.ErrNotExist
+func (r *FileResolver) Reader(file *models.File) (io.ReadCloser, error) {
+	key, err := r.StorageKey(file.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return r.storage.Get(key)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+)
+
+func newTestResolver(t *testing.T, uploadPath string, objects map[string]string) *FileResolver {
+	t.Helper()
+	store := storage.NewLocalStorage(t.TempDir())
+	for key, content := range objects {
+		if err := store.Put(key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+	return NewFileResolver(store, uploadPath)
+}
+
+func readContent(t *testing.T, r io.Reader) string {
+	t.Helper()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return string(data)
+}
+
+func TestFileResolverStorageKey(t *testing.T) {
+	r := NewFileResolver(nil, "./uploads")
+	for filePath, want := range map[string]string{
+		"blobs/ab/abcdef":                 "blobs/ab/abcdef",
+		"uploads/abcdef_photo.jpg":        "abcdef_photo.jpg",
+		"./uploads/abcdef_照片.jpg":         "abcdef_照片.jpg",
+		"uploads/sub/../abcdef_photo.jpg": "abcdef_photo.jpg",
+	} {
+		if got, err := r.StorageKey(filePath); err != nil || got != want {
+			t.Errorf("StorageKey(%q) = %q, %v, want %q", filePath, got, err, want)
+		}
+	}
+
+	for _, filePath := range []string{
+		"",
+		"/etc/passwd",
+		"../secret",
+		"blobs/../../secret",
+		"uploads/../../secret",
+		"uploads/..",
+		`blobs\..\..\secret`,
+		"blobs/ab\x00",
+	} {
+		if got, err := r.StorageKey(filePath); !errors.Is(err, ErrInvalidStoragePath) {
+			t.Errorf("StorageKey(%q) = %q, %v, want ErrInvalidStoragePath", filePath, got, err)
+		}
+	}
+
+	abs := NewFileResolver(nil, "/data/uploads")
+	if got, err := abs.StorageKey("/data/uploads/abcdef_a.txt"); err != nil || got != "abcdef_a.txt" {
+		t.Errorf("StorageKey(absolute legacy path) = %q, %v", got, err)
+	}
+	if _, err := abs.StorageKey("/data/other/abcdef_a.txt"); !errors.Is(err, ErrInvalidStoragePath) {
+		t.Errorf("StorageKey(outside upload path) = %v, want ErrInvalidStoragePath", err)
+	}
+}
+
+func TestFileResolverOpen(t *testing.T) {
+	r := newTestResolver(t, "uploads", map[string]string{
+		"blobs/aa/aaaa":     "blob content",
+		"bbbb_original.txt": "legacy content",
+		"renamed.txt":       "wrong file",
+	})
+
+	// 重命名后的文件仍按 FilePath 读取，不会读取到与新名称同名的其他内容
+	renamed := &models.File{FileName: "renamed.txt", FilePath: "blobs/aa/aaaa", FileSize: 12, MD5Hash: "aaaa"}
+	content, err := r.Open(renamed)
+	if err != nil {
+		t.Fatalf("Open(renamed): %v", err)
+	}
+	if got := readContent(t, content); got != "blob content" {
+		t.Errorf("Open(renamed) content = %q", got)
+	}
+	content.Close()
+	if content.Name != "renamed.txt" || content.MD5Hash != "aaaa" || content.Size != 12 {
+		t.Errorf("Open(renamed) = %+v", content)
+	}
+
+	// 秒传产生的文件与原文件共享同一内容
+	dedup := &models.File{FileName: "copy.txt", FilePath: "blobs/aa/aaaa", FileSize: 12}
+	reader, err := r.Reader(dedup)
+	if err != nil {
+		t.Fatalf("Reader(dedup): %v", err)
+	}
+	if got := readContent(t, reader); got != "blob content" {
+		t.Errorf("Reader(dedup) content = %q", got)
+	}
+	reader.Close()
+
+	legacy := &models.File{FileName: "original.txt", FilePath: "uploads/bbbb_original.txt", FileSize: 14}
+	content, err = r.Open(legacy)
+	if err != nil {
+		t.Fatalf("Open(legacy): %v", err)
+	}
+	if got := readContent(t, content); got != "legacy content" {
+		t.Errorf("Open(legacy) content = %q", got)
+	}
+	content.Close()
+
+	if _, err := r.Open(&models.File{FilePath: "blobs/cc/cccc"}); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("Open(missing) = %v, want ErrNotExist", err)
+	}
+	if _, err := r.Open(&models.File{FilePath: "../renamed.txt"}); !errors.Is(err, ErrInvalidStoragePath) {
+		t.Errorf("Open(traversal) = %v, want ErrInvalidStoragePath", err)
+	}
+}
+
+func TestDownloadWriteZip(t *testing.T) {
+	r := newTestResolver(t, "uploads", map[string]string{
+		"blobs/aa/aaaa": "first",
+		"blobs/bb/bbbb": "second",
+	})
+	s := &DownloadService{files: r}
+
+	zipPath := filepath.Join(t.TempDir(), "files.zip")
+	size, err := s.writeZip(context.Background(), zipPath, []models.File{
+		{FileName: "a.txt", FilePath: "blobs/aa/aaaa"},
+		{FileName: "a.txt", FilePath: "blobs/bb/bbbb"},
+		{FileName: "../../evil.txt", FilePath: "blobs/aa/aaaa"},
+	})
+	if err != nil {
+		t.Fatalf("writeZip: %v", err)
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	defer zr.Close()
+
+	if stat, err := os.Stat(zipPath); err != nil || stat.Size() != size {
+		t.Errorf("writeZip size = %d, want size of written file", size)
+	}
+
+	want := map[string]string{"a.txt": "first", "a (1).txt": "second", ".._.._evil.txt": "first"}
+	if len(zr.File) != len(want) {
+		t.Fatalf("zip has %d entries, want %d", len(zr.File), len(want))
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		if got := readContent(t, rc); got != want[f.Name] {
+			t.Errorf("entry %q = %q, want %q", f.Name, got, want[f.Name])
+		}
+		rc.Close()
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// descriptionSnippetLength 描述高亮片段的最大字符数
+const descriptionSnippetLength = 120
+
+// SearchMatch 搜索结果的相关度与高亮，高亮内容已做 HTML 转义，匹配部分用 <mark> 标记
+type SearchMatch struct {
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"` // file_name、description、tags 中有匹配的字段
+}
+
+// searchHit 搜索结果排序
+type searchHit struct {
+	ID    uint
+	Score float64
+}
+
+// SearchFiles 搜索文件，filter 为可选的筛选条件
+// 关键词在文件名、描述、标签、上传者及相机型号的检索文档中全文匹配，
+// 检索文档按空格分词，中文等不以空格分词的文字通过子串匹配（pg_trgm 索引）检索。
+// 结果按相关度降序排列，相关度相同时按上传时间降序排列。
+func (s *FileService) SearchFiles(query *SearchQuery, filter *FileFilter, userID uint, page, pageSize int) (*FileListResponse, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if filter == nil {
+		filter = &FileFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * pageSize
+
+	visible, err := s.authz.VisibleFiles(userID, "files")
+	if err != nil {
+		return nil, err
+	}
+	baseQuery := func() *gorm.DB {
+		return s.searchConditions(s.db.Model(&models.File{}).
+			Where("files.is_deleted = false").Scopes(visible), query)
+	}
+	fileQuery := filter.apply(baseQuery(), "")
+
+	var total int64
+	if err := fileQuery.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("搜索文件失败: %v", err)
+	}
+
+	score, scoreArgs := searchScore(query)
+	var hits []searchHit
+	if err := filter.apply(baseQuery(), "").
+		Select("files.id, "+score+" AS score", scoreArgs...).
+		Joins("LEFT JOIN file_search_documents ON file_search_documents.file_id = files.id").
+		Order("score DESC, files.created_at DESC, files.id DESC").
+		Offset(offset).Limit(pageSize).
+		Scan(&hits).Error; err != nil {
+		return nil, fmt.Errorf("搜索文件失败: %v", err)
+	}
+
+	files, scores, err := s.filesInOrder(hits)
+	if err != nil {
+		return nil, err
+	}
+	fileInfos := s.toFileInfos(files)
+	for i := range fileInfos {
+		fileInfos[i].Search = searchMatch(&fileInfos[i], query, scores[i])
+	}
+
+	response := &FileListResponse{
+		Files:    fileInfos,
+		Folders:  []FolderInfo{},
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if filter.WithFacets {
+		facets, err := s.fileFacets(baseQuery, filter)
+		if err != nil {
+			return nil, err
+		}
+		response.Facets = facets
+	}
+	return response, nil
+}
+
+// searchConditions 将搜索语句应用到文件查询，不连接其他表，便于计数和分面统计
+func (s *FileService) searchConditions(query *gorm.DB, search *SearchQuery) *gorm.DB {
+	for _, term := range search.Terms {
+		pattern := "%" + escapeLike(term) + "%"
+		// 尚未建立检索文档的文件仍可按文件名和描述匹配
+		query = query.Where(`(files.id IN (SELECT file_id FROM file_search_documents
+			WHERE document @@ plainto_tsquery('simple', ?) OR content ILIKE ?)
+			OR files.file_name ILIKE ? OR files.description ILIKE ?)`, term, pattern, pattern, pattern)
+	}
+	for _, tag := range search.Tags {
+		query = query.Where(`EXISTS (SELECT 1 FROM file_tags JOIN tags ON tags.id = file_tags.tag_id
+			WHERE file_tags.file_id = files.id AND LOWER(tags.tag_name) = LOWER(?))`, tag)
+	}
+	for _, uploader := range search.Uploaders {
+		pattern := "%" + escapeLike(uploader) + "%"
+		query = query.Where("files.owner_id IN (SELECT id FROM users WHERE username ILIKE ? OR real_name ILIKE ?)",
+			pattern, pattern)
+	}
+	for _, camera := range search.Cameras {
+		// 按词依次匹配，camera:"Canon R5" 可匹配 Canon EOS R5
+		words := strings.Fields(camera)
+		for i, word := range words {
+			words[i] = escapeLike(word)
+		}
+		query = query.Where("files.id IN (SELECT file_id FROM file_search_documents WHERE camera ILIKE ?)",
+			"%"+strings.Join(words, "%")+"%")
+	}
+	if search.Before != nil {
+		query = query.Where("files.created_at < ?", *search.Before)
+	}
+	if search.After != nil {
+		query = query.Where("files.created_at >= ?", *search.After)
+	}
+	return query
+}
+
+// searchScore 相关度表达式：全文检索得分，文件名包含关键词时额外加分，使子串匹配的中文文件名也能排在前面
+func searchScore(query *SearchQuery) (string, []interface{}) {
+	if len(query.Terms) == 0 {
+		return "0", nil
+	}
+
+	parts := []string{"COALESCE(ts_rank_cd(file_search_documents.document, plainto_tsquery('simple', ?)), 0)"}
+	args := []interface{}{query.Text()}
+	for _, term := range query.Terms {
+		parts = append(parts, "CASE WHEN files.file_name ILIKE ? THEN 0.5 ELSE 0 END")
+		args = append(args, "%"+escapeLike(term)+"%")
+	}
+	return strings.Join(parts, " + "), args
+}
+
+// filesInOrder 按搜索结果顺序获取文件及其相关度，期间被彻底删除的文件不返回
+func (s *FileService) filesInOrder(hits []searchHit) ([]models.File, []float64, error) {
+	if len(hits) == 0 {
+		return []models.File{}, nil, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var found []models.File
+	if err := s.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, nil, fmt.Errorf("搜索文件失败: %v", err)
+	}
+
+	byID := make(map[uint]models.File, len(found))
+	for _, file := range found {
+		byID[file.ID] = file
+	}
+	files := make([]models.File, 0, len(hits))
+	scores := make([]float64, 0, len(hits))
+	for _, hit := range hits {
+		if file, ok := byID[hit.ID]; ok {
+			files = append(files, file)
+			scores = append(scores, hit.Score)
+		}
+	}
+	return files, scores, nil
+}
+
+// searchMatch 计算搜索结果的高亮
+func searchMatch(file *FileInfo, query *SearchQuery, score float64) *SearchMatch {
+	match := &SearchMatch{Score: score, Highlights: map[string]string{}}
+
+	if text := highlightText(file.FileName, query.Terms, 0); text != "" {
+		match.Highlights["file_name"] = text
+	}
+	if text := highlightText(file.Description, query.Terms, descriptionSnippetLength); text != "" {
+		match.Highlights["description"] = text
+	}
+
+	tagTerms := append(append([]string{}, query.Terms...), query.Tags...)
+	var tags []string
+	for _, tag := range file.Tags {
+		if text := highlightText(tag.TagName, tagTerms, 0); text != "" {
+			tags = append(tags, text)
+		}
+	}
+	if len(tags) > 0 {
+		match.Highlights["tags"] = strings.Join(tags, " ")
+	}
+	return match
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"mcs-backend/internal/config"
 	"mcs-backend/internal/database"
+	"log"
 	"mcs-backend/internal/models"
-	"path/filepath"
+	"mcs-backend/internal/storage"
 	"time"
 
 	"gorm.io/gorm"
@@ -14,15 +15,32 @@ import (
 
 // FileService 文件管理服务
 type FileService struct {
-	db     *gorm.DB
-	config *config.Config
+	db       *gorm.DB
+	config   *config.Config
+	files    *FileResolver
+	blobs    *BlobService
+	quotas   *QuotaService
+	authz    *AuthzService
+	search   *SearchIndexer
+	metadata *MetadataService
+	jobs     *JobQueue
 }
 
 // NewFileService 创建文件管理服务
 func NewFileService(cfg *config.Config) *FileService {
+	db := database.GetDB()
+	store := storage.GetStorage()
+
 	return &FileService{
-		db:     database.GetDB(),
-		config: cfg,
+		db:       db,
+		config:   cfg,
+		files:    NewFileResolver(store, cfg.File.UploadPath),
+		blobs:    NewBlobService(db, store),
+		quotas:   NewQuotaService(db),
+		authz:    NewAuthzService(db),
+		search:   NewSearchIndexer(db),
+		metadata: NewMetadataService(db, store, cfg.File.UploadPath),
+		jobs:     NewJobQueue(db),
 	}
 }
 
@@ -48,42 +66,45 @@ type UpdateFolderRequest struct {
 
 // FileListRequest 文件列表请求
 type FileListRequest struct {
+	FileFilter
 	FolderID   uint   `json:"folder_id"`
 	WorkflowID uint   `json:"workflow_id"`
 	TaskID     uint   `json:"task_id"`
 	Keyword    string `json:"keyword"`
-	MimeType   string `json:"mime_type"`
 	Page       int    `json:"page"`
 	PageSize   int    `json:"page_size"`
-	SortBy     string `json:"sort_by"`     // name, size, created_at
-	SortOrder  string `json:"sort_order"`  // asc, desc
+	SortBy     string `json:"sort_by"`    // name, size, created_at, updated_at
+	SortOrder  string `json:"sort_order"` // asc, desc
 }
 
 // FileListResponse 文件列表响应
 type FileListResponse struct {
-	Files   []FileInfo `json:"files"`
-	Folders []FolderInfo `json:"folders"`
-	Total   int64      `json:"total"`
-	Page    int        `json:"page"`
-	PageSize int       `json:"page_size"`
+	Files    []FileInfo   `json:"files"`
+	Folders  []FolderInfo `json:"folders"`
+	Total    int64        `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Facets   *FileFacets  `json:"facets,omitempty"` // 请求分面统计时返回
 }
 
 // FileInfo 文件信息
 type FileInfo struct {
-	ID          uint      `json:"id"`
-	FileName    string    `json:"file_name"`
-	FileSize    int64     `json:"file_size"`
-	MimeType    string    `json:"mime_type"`
-	OwnerID     uint      `json:"owner_id"`
-	OwnerName   string    `json:"owner_name"`
-	FolderID    uint      `json:"folder_id"`
-	WorkflowID  uint      `json:"workflow_id"`
-	TaskID      uint      `json:"task_id"`
-	IsPrivate   bool      `json:"is_private"`
-	Description string    `json:"description"`
-	Tags        []TagInfo `json:"tags"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint                 `json:"id"`
+	FileName    string               `json:"file_name"`
+	FileSize    int64                `json:"file_size"`
+	MimeType    string               `json:"mime_type"`
+	OwnerID     uint                 `json:"owner_id"`
+	OwnerName   string               `json:"owner_name"`
+	FolderID    uint                 `json:"folder_id"`
+	WorkflowID  uint                 `json:"workflow_id"`
+	TaskID      uint                 `json:"task_id"`
+	IsPrivate   bool                 `json:"is_private"`
+	Description string               `json:"description"`
+	Tags        []TagInfo            `json:"tags"`
+	Metadata    *models.FileMetadata `json:"metadata"`         // 拍摄信息，没有时为 null
+	Search      *SearchMatch         `json:"search,omitempty"` // 搜索结果的相关度与高亮
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
 }
 
 // FolderInfo 文件夹信息
@@ -119,15 +140,25 @@ type UpdateFileRequest struct {
 
 // CreateFolder 创建文件夹
 func (s *FileService) CreateFolder(req *CreateFolderRequest, userID uint) (*FolderInfo, error) {
+	if err := validateItemName(req.Name); err != nil {
+		return nil, err
+	}
+
 	// 构建文件夹路径
 	var path string
 	if req.ParentID > 0 {
 		var parentFolder models.FileFolder
-		if err := s.db.Where("id = ? AND is_deleted = false", req.ParentID).First(&parentFolder).Error; err != nil {
+		if err := s.db.Where("id = ? AND is_deleted = false AND workflow_id = ?", req.ParentID, req.WorkflowID).First(&parentFolder).Error; err != nil {
 			return nil, fmt.Errorf("父文件夹不存在")
 		}
-		path = filepath.Join(parentFolder.Path, req.Name)
+		if err := s.authz.CheckFolder(userID, &parentFolder, ActionUpload); err != nil {
+			return nil, err
+		}
+		path = joinFolderPath(parentFolder.Path, req.Name)
 	} else {
+		if err := s.authz.CheckWorkflow(userID, req.WorkflowID, ActionUpload); err != nil {
+			return nil, err
+		}
 		path = req.Name
 	}
 
@@ -173,45 +204,57 @@ func (s *FileService) GetFileList(req *FileListRequest, userID uint) (*FileListR
 	if req.PageSize <= 0 {
 		req.PageSize = 20
 	}
-	if req.SortBy == "" {
-		req.SortBy = "created_at"
-	}
-	if req.SortOrder == "" {
-		req.SortOrder = "desc"
+	if err := req.FileFilter.Validate(); err != nil {
+		return nil, err
 	}
 
 	offset := (req.Page - 1) * req.PageSize
 
-	// 构建查询条件
-	fileQuery := s.db.Model(&models.File{}).Where("is_deleted = false")
-	folderQuery := s.db.Model(&models.FileFolder{}).Where("is_deleted = false")
+	visibleFiles, err := s.authz.VisibleFiles(userID, "files")
+	if err != nil {
+		return nil, err
+	}
+	visibleFolders, err := s.authz.VisibleFolders(userID, "")
+	if err != nil {
+		return nil, err
+	}
 
+	// 构建查询条件，标签、上传者等筛选条件另外应用，以便分面统计时排除
+	baseQuery := func() *gorm.DB {
+		query := s.db.Model(&models.File{}).Where("files.is_deleted = false")
+		if req.FolderID > 0 {
+			query = query.Where("files.folder_id = ?", req.FolderID)
+		}
+		if req.WorkflowID > 0 {
+			query = query.Where("files.workflow_id = ?", req.WorkflowID)
+		}
+		if req.TaskID > 0 {
+			query = query.Where("files.task_id = ?", req.TaskID)
+		}
+		if req.Keyword != "" {
+			query = query.Where("files.file_name ILIKE ?", "%"+req.Keyword+"%")
+		}
+		// 权限过滤：只返回有权查看的文件
+		return query.Scopes(visibleFiles)
+	}
+	fileQuery := req.FileFilter.apply(baseQuery(), "")
+
+	folderQuery := s.db.Model(&models.FileFolder{}).Where("is_deleted = false").Scopes(visibleFolders)
 	if req.FolderID > 0 {
-		fileQuery = fileQuery.Where("folder_id = ?", req.FolderID)
 		folderQuery = folderQuery.Where("parent_id = ?", req.FolderID)
 	}
 	if req.WorkflowID > 0 {
-		fileQuery = fileQuery.Where("workflow_id = ?", req.WorkflowID)
 		folderQuery = folderQuery.Where("workflow_id = ?", req.WorkflowID)
 	}
-	if req.TaskID > 0 {
-		fileQuery = fileQuery.Where("task_id = ?", req.TaskID)
-	}
 	if req.Keyword != "" {
-		keyword := "%" + req.Keyword + "%"
-		fileQuery = fileQuery.Where("file_name ILIKE ?", keyword)
-		folderQuery = folderQuery.Where("name ILIKE ?", keyword)
-	}
-	if req.MimeType != "" {
-		fileQuery = fileQuery.Where("mime_type LIKE ?", req.MimeType+"%")
+		folderQuery = folderQuery.Where("name ILIKE ?", "%"+req.Keyword+"%")
 	}
 
-	// 权限过滤：只能看到自己的私有文件或公开文件
-	fileQuery = fileQuery.Where("(is_private = false OR owner_id = ?)", userID)
-
 	// 获取文件总数
 	var fileTotal int64
-	fileQuery.Count(&fileTotal)
+	if err := fileQuery.Count(&fileTotal).Error; err != nil {
+		return nil, fmt.Errorf("获取文件总数失败: %v", err)
+	}
 
 	// 获取文件夹总数
 	var folderTotal int64
@@ -219,41 +262,18 @@ func (s *FileService) GetFileList(req *FileListRequest, userID uint) (*FileListR
 
 	// 获取文件列表
 	var files []models.File
-	fileQuery = fileQuery.Preload("Owner").Order(fmt.Sprintf("%s %s", req.SortBy, req.SortOrder))
+	fileQuery = fileQuery.Order(fileSortColumn(req.SortBy, req.SortOrder))
 	if err := fileQuery.Offset(offset).Limit(req.PageSize).Find(&files).Error; err != nil {
 		return nil, fmt.Errorf("获取文件列表失败: %v", err)
 	}
 
 	// 获取文件夹列表
 	var folders []models.FileFolder
-	folderQuery = folderQuery.Preload("Creator").Order("sort_order ASC, created_at DESC")
+	folderQuery = folderQuery.Order("sort_order ASC, created_at DESC")
 	if err := folderQuery.Find(&folders).Error; err != nil {
 		return nil, fmt.Errorf("获取文件夹列表失败: %v", err)
 	}
 
-	// 转换为响应格式
-	fileInfos := make([]FileInfo, len(files))
-	for i, file := range files {
-		// 获取文件标签
-		tags := s.getFileTags(file.ID)
-		
-		fileInfos[i] = FileInfo{
-			ID:          file.ID,
-			FileName:    file.FileName,
-			FileSize:    file.FileSize,
-			MimeType:    file.MimeType,
-			OwnerID:     file.OwnerID,
-			FolderID:    file.FolderID,
-			WorkflowID:  file.WorkflowID,
-			TaskID:      file.TaskID,
-			IsPrivate:   file.IsPrivate,
-			Description: file.Description,
-			Tags:        tags,
-			CreatedAt:   file.CreatedAt,
-			UpdatedAt:   file.UpdatedAt,
-		}
-	}
-
 	folderInfos := make([]FolderInfo, len(folders))
 	for i, folder := range folders {
 		folderInfos[i] = FolderInfo{
@@ -270,32 +290,69 @@ func (s *FileService) GetFileList(req *FileListRequest, userID uint) (*FileListR
 		}
 	}
 
-	return &FileListResponse{
-		Files:    fileInfos,
+	response := &FileListResponse{
+		Files:    s.toFileInfos(files),
 		Folders:  folderInfos,
 		Total:    fileTotal + folderTotal,
 		Page:     req.Page,
 		PageSize: req.PageSize,
-	}, nil
+	}
+	if req.WithFacets {
+		facets, err := s.fileFacets(baseQuery, &req.FileFilter)
+		if err != nil {
+			return nil, err
+		}
+		response.Facets = facets
+	}
+	return response, nil
+}
+
+// toFileInfos 将文件记录转换为响应格式
+func (s *FileService) toFileInfos(files []models.File) []FileInfo {
+	ids := make([]uint, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	metadata, err := s.metadata.GetMetadata(ids)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	fileInfos := make([]FileInfo, len(files))
+	for i, file := range files {
+		fileInfos[i] = FileInfo{
+			ID:          file.ID,
+			FileName:    file.FileName,
+			FileSize:    file.FileSize,
+			MimeType:    file.MimeType,
+			OwnerID:     file.OwnerID,
+			FolderID:    file.FolderID,
+			WorkflowID:  file.WorkflowID,
+			TaskID:      file.TaskID,
+			IsPrivate:   file.IsPrivate,
+			Description: file.Description,
+			Tags:        s.getFileTags(file.ID),
+			Metadata:    metadata[file.ID],
+			CreatedAt:   file.CreatedAt,
+			UpdatedAt:   file.UpdatedAt,
+		}
+	}
+	return fileInfos
 }
 
 // GetFileByID 根据ID获取文件信息
 func (s *FileService) GetFileByID(fileID uint, userID uint) (*FileInfo, error) {
-	var file models.File
-	query := s.db.Where("id = ? AND is_deleted = false", fileID)
-	
-	// 权限检查：只能访问自己的私有文件或公开文件
-	query = query.Where("(is_private = false OR owner_id = ?)", userID)
-	
-	if err := query.Preload("Owner").First(&file).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("文件不存在或无权限访问")
-		}
-		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	file, err := s.accessibleFile(s.db, fileID, userID, ActionView)
+	if err != nil {
+		return nil, err
 	}
 
-	// 获取文件标签
+	// 获取文件标签和拍摄信息
 	tags := s.getFileTags(file.ID)
+	metadata, err := s.metadata.GetMetadata([]uint{file.ID})
+	if err != nil {
+		return nil, err
+	}
 
 	return &FileInfo{
 		ID:          file.ID,
@@ -309,21 +366,75 @@ func (s *FileService) GetFileByID(fileID uint, userID uint) (*FileInfo, error) {
 		IsPrivate:   file.IsPrivate,
 		Description: file.Description,
 		Tags:        tags,
+		Metadata:    metadata[file.ID],
 		CreatedAt:   file.CreatedAt,
 		UpdatedAt:   file.UpdatedAt,
 	}, nil
 }
 
-// UpdateFile 更新文件信息
-func (s *FileService) UpdateFile(fileID uint, req *UpdateFileRequest, userID uint) (*FileInfo, error) {
-	// 检查文件是否存在且用户有权限
+// accessibleFile 获取未删除的文件并检查用户的操作权限
+// 文件不存在或无权查看时返回相同的错误，不暴露文件是否存在；其他操作无权限时返回 ErrPermissionDenied
+func (s *FileService) accessibleFile(db *gorm.DB, fileID, userID uint, action string) (*models.File, error) {
 	var file models.File
-	if err := s.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileID, userID).First(&file).Error; err != nil {
+	if err := db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("文件不存在或无权限修改")
+			return nil, errors.New("文件不存在或无权限访问")
 		}
 		return nil, fmt.Errorf("获取文件信息失败: %v", err)
 	}
+	if err := s.authz.CheckFile(userID, &file, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, errors.New("文件不存在或无权限访问")
+		}
+		return nil, err
+	}
+	if action != ActionView {
+		if err := s.authz.CheckFile(userID, &file, action); err != nil {
+			return nil, err
+		}
+	}
+	return &file, nil
+}
+
+// accessibleFolder 获取未删除的文件夹并检查用户的操作权限，规则与 accessibleFile 相同
+func (s *FileService) accessibleFolder(db *gorm.DB, folderID, userID uint, action string) (*models.FileFolder, error) {
+	var folder models.FileFolder
+	if err := db.Where("id = ? AND is_deleted = false", folderID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件夹不存在或无权限访问")
+		}
+		return nil, fmt.Errorf("获取文件夹信息失败: %v", err)
+	}
+	if err := s.authz.CheckFolder(userID, &folder, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, errors.New("文件夹不存在或无权限访问")
+		}
+		return nil, err
+	}
+	if action != ActionView {
+		if err := s.authz.CheckFolder(userID, &folder, action); err != nil {
+			return nil, err
+		}
+	}
+	return &folder, nil
+}
+
+// OpenFile 打开文件内容用于下载，文件内容不存在时返回 storage.ErrNotExist
+func (s *FileService) OpenFile(fileID uint, userID uint) (*FileContent, error) {
+	file, err := s.accessibleFile(s.db, fileID, userID, ActionView)
+	if err != nil {
+		return nil, err
+	}
+	return s.files.Open(file)
+}
+
+// UpdateFile 更新文件信息
+func (s *FileService) UpdateFile(fileID uint, req *UpdateFileRequest, userID uint) (*FileInfo, error) {
+	// 检查文件是否存在且用户有权限
+	file, err := s.accessibleFile(s.db, fileID, userID, ActionEdit)
+	if err != nil {
+		return nil, err
+	}
 
 	// 更新文件信息
 	updates := make(map[string]interface{})
@@ -331,6 +442,9 @@ func (s *FileService) UpdateFile(fileID uint, req *UpdateFileRequest, userID uin
 		updates["file_name"] = req.FileName
 	}
 	if req.FolderID > 0 {
+		if _, err := s.accessibleFolder(s.db, req.FolderID, userID, ActionUpload); err != nil {
+			return nil, err
+		}
 		updates["folder_id"] = req.FolderID
 	}
 	if req.Description != "" {
@@ -338,7 +452,7 @@ func (s *FileService) UpdateFile(fileID uint, req *UpdateFileRequest, userID uin
 	}
 	updates["is_private"] = req.IsPrivate
 
-	if err := s.db.Model(&file).Updates(updates).Error; err != nil {
+	if err := s.db.Model(file).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("更新文件信息失败: %v", err)
 	}
 
@@ -348,6 +462,7 @@ func (s *FileService) UpdateFile(fileID uint, req *UpdateFileRequest, userID uin
 			return nil, fmt.Errorf("更新文件标签失败: %v", err)
 		}
 	}
+	s.search.indexQuietly(fileID)
 
 	// 重新获取更新后的文件信息
 	return s.GetFileByID(fileID, userID)
@@ -356,17 +471,14 @@ func (s *FileService) UpdateFile(fileID uint, req *UpdateFileRequest, userID uin
 // DeleteFile 删除文件
 func (s *FileService) DeleteFile(fileID uint, userID uint) error {
 	// 检查文件是否存在且用户有权限
-	var file models.File
-	if err := s.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileID, userID).First(&file).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("文件不存在或无权限删除")
-		}
-		return fmt.Errorf("获取文件信息失败: %v", err)
+	file, err := s.accessibleFile(s.db, fileID, userID, ActionDelete)
+	if err != nil {
+		return err
 	}
 
 	// 软删除文件记录
 	now := time.Now()
-	if err := s.db.Model(&file).Updates(map[string]interface{}{
+	if err := s.db.Model(file).Updates(map[string]interface{}{
 		"is_deleted": true,
 		"deleted_at": &now,
 	}).Error; err != nil {
@@ -377,14 +489,18 @@ func (s *FileService) DeleteFile(fileID uint, userID uint) error {
 }
 
 // DeleteFolder 删除文件夹
-func (s *FileService) DeleteFolder(folderID uint, userID uint) error {
+// recursive 为 true 时将文件夹及其全部内容一起移入回收站，否则只能删除空文件夹
+func (s *FileService) DeleteFolder(folderID uint, userID uint, recursive bool) error {
+	if recursive {
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			return s.deleteFolderTree(tx, folderID, userID)
+		})
+	}
+
 	// 检查文件夹是否存在且用户有权限
-	var folder models.FileFolder
-	if err := s.db.Where("id = ? AND creator_id = ? AND is_deleted = false", folderID, userID).First(&folder).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("文件夹不存在或无权限删除")
-		}
-		return fmt.Errorf("获取文件夹信息失败: %v", err)
+	folder, err := s.accessibleFolder(s.db, folderID, userID, ActionDelete)
+	if err != nil {
+		return err
 	}
 
 	// 检查文件夹是否为空
@@ -393,12 +509,12 @@ func (s *FileService) DeleteFolder(folderID uint, userID uint) error {
 	s.db.Model(&models.FileFolder{}).Where("parent_id = ? AND is_deleted = false", folderID).Count(&subFolderCount)
 
 	if fileCount > 0 || subFolderCount > 0 {
-		return errors.New("文件夹不为空，无法删除")
+		return ErrFolderNotEmpty
 	}
 
 	// 软删除文件夹
 	now := time.Now()
-	if err := s.db.Model(&folder).Updates(map[string]interface{}{
+	if err := s.db.Model(folder).Updates(map[string]interface{}{
 		"is_deleted": true,
 		"deleted_at": &now,
 	}).Error; err != nil {
@@ -411,36 +527,19 @@ func (s *FileService) DeleteFolder(folderID uint, userID uint) error {
 // UpdateFolder 更新文件夹
 func (s *FileService) UpdateFolder(folderID uint, req *UpdateFolderRequest, userID uint) (*FolderInfo, error) {
 	// 检查文件夹是否存在且用户有权限
-	var folder models.FileFolder
-	if err := s.db.Where("id = ? AND creator_id = ? AND is_deleted = false", folderID, userID).First(&folder).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("文件夹不存在或无权限修改")
+	accessible, err := s.accessibleFolder(s.db, folderID, userID, ActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	folder := *accessible
+	if req.Name != "" {
+		if err := validateItemName(req.Name); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("获取文件夹信息失败: %v", err)
 	}
 
 	// 更新文件夹信息
 	updates := make(map[string]interface{})
-	if req.Name != "" {
-		// 检查同级目录下是否存在同名文件夹
-		var count int64
-		s.db.Model(&models.FileFolder{}).Where("name = ? AND parent_id = ? AND workflow_id = ? AND id != ? AND is_deleted = false", req.Name, folder.ParentID, folder.WorkflowID, folderID).Count(&count)
-		if count > 0 {
-			return nil, errors.New("同级目录下已存在同名文件夹")
-		}
-		updates["name"] = req.Name
-		
-		// 更新路径
-		var newPath string
-		if folder.ParentID > 0 {
-			var parentFolder models.FileFolder
-			s.db.Where("id = ?", folder.ParentID).First(&parentFolder)
-			newPath = filepath.Join(parentFolder.Path, req.Name)
-		} else {
-			newPath = req.Name
-		}
-		updates["path"] = newPath
-	}
 	if req.Description != "" {
 		updates["description"] = req.Description
 	}
@@ -448,8 +547,55 @@ func (s *FileService) UpdateFolder(folderID uint, req *UpdateFolderRequest, user
 		updates["sort_order"] = req.SortOrder
 	}
 
-	if err := s.db.Model(&folder).Updates(updates).Error; err != nil {
-		return nil, fmt.Errorf("更新文件夹失败: %v", err)
+	// 改名时在同一事务中检查同名文件夹，并更新该文件夹及全部子孙文件夹的物化路径
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if req.Name != "" {
+			// 检查同级目录下是否存在同名文件夹
+			var count int64
+			if err := tx.Model(&models.FileFolder{}).Where("name = ? AND parent_id = ? AND workflow_id = ? AND id != ? AND is_deleted = false", req.Name, folder.ParentID, folder.WorkflowID, folderID).Count(&count).Error; err != nil {
+				return fmt.Errorf("检查同名文件夹失败: %v", err)
+			}
+			if count > 0 {
+				return errors.New("同级目录下已存在同名文件夹")
+			}
+
+			var parentPath string
+			if folder.ParentID > 0 {
+				var parentFolder models.FileFolder
+				if err := tx.Where("id = ?", folder.ParentID).First(&parentFolder).Error; err != nil {
+					return fmt.Errorf("获取父文件夹失败: %v", err)
+				}
+				parentPath = parentFolder.Path
+			}
+			updates["name"] = req.Name
+			updates["path"] = joinFolderPath(parentPath, req.Name)
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&folder).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新文件夹失败: %v", err)
+		}
+		newPath, ok := updates["path"].(string)
+		if !ok {
+			return nil
+		}
+		subtree, err := folderSubtree(tx, &folder, true)
+		if err != nil {
+			return err
+		}
+		for id, path := range rebaseFolderPaths(subtree, newPath) {
+			if id == folder.ID {
+				continue
+			}
+			if err := tx.Model(&models.FileFolder{}).Where("id = ?", id).Update("path", path).Error; err != nil {
+				return fmt.Errorf("更新子文件夹路径失败: %v", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	// 重新获取更新后的文件夹信息
@@ -469,60 +615,6 @@ func (s *FileService) UpdateFolder(folderID uint, req *UpdateFolderRequest, user
 	}, nil
 }
 
-// SearchFiles 搜索文件
-func (s *FileService) SearchFiles(keyword string, userID uint, page, pageSize int) (*FileListResponse, error) {
-	if page <= 0 {
-		page = 1
-	}
-	if pageSize <= 0 {
-		pageSize = 20
-	}
-
-	offset := (page - 1) * pageSize
-	keywordPattern := "%" + keyword + "%"
-
-	// 搜索文件
-	var files []models.File
-	fileQuery := s.db.Where("is_deleted = false AND (is_private = false OR owner_id = ?) AND (file_name ILIKE ? OR description ILIKE ?)", userID, keywordPattern, keywordPattern)
-	
-	var total int64
-	fileQuery.Count(&total)
-	
-	if err := fileQuery.Preload("Owner").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&files).Error; err != nil {
-		return nil, fmt.Errorf("搜索文件失败: %v", err)
-	}
-
-	// 转换为响应格式
-	fileInfos := make([]FileInfo, len(files))
-	for i, file := range files {
-		tags := s.getFileTags(file.ID)
-		
-		fileInfos[i] = FileInfo{
-			ID:          file.ID,
-			FileName:    file.FileName,
-			FileSize:    file.FileSize,
-			MimeType:    file.MimeType,
-			OwnerID:     file.OwnerID,
-			FolderID:    file.FolderID,
-			WorkflowID:  file.WorkflowID,
-			TaskID:      file.TaskID,
-			IsPrivate:   file.IsPrivate,
-			Description: file.Description,
-			Tags:        tags,
-			CreatedAt:   file.CreatedAt,
-			UpdatedAt:   file.UpdatedAt,
-		}
-	}
-
-	return &FileListResponse{
-		Files:    fileInfos,
-		Folders:  []FolderInfo{},
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
-	}, nil
-}
-
 // getFileTags 获取文件标签
 func (s *FileService) getFileTags(fileID uint) []TagInfo {
 	var fileTags []models.FileTag
@@ -560,7 +652,7 @@ func (s *FileService) updateFileTags(fileID uint, tagIDs []uint) error {
 	}
 
 	// 添加新的标签关联
-	for _, tagID := range tagIDs {
+	for _, tagID := range uniqueIDs(tagIDs) {
 		fileTag := models.FileTag{
 			FileID: fileID,
 			TagID:  tagID,
@@ -573,57 +665,74 @@ func (s *FileService) updateFileTags(fileID uint, tagIDs []uint) error {
 	return nil
 }
 
-// CreateFileVersion 创建文件版本
-func (s *FileService) CreateFileVersion(fileID uint, filePath string, fileSize int64, md5Hash string, changeLog string, userID uint) error {
-	// 检查文件是否存在
+// CreateFileVersion 为文件创建新版本，版本号为创建时间的毫秒时间戳
+func (s *FileService) CreateFileVersion(fileID uint, filePath string, fileSize int64, md5Hash string, changeLog string, userID uint) (*models.FileVersion, error) {
+	var version *models.FileVersion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		version, err = saveFileVersion(tx, s.blobs, fileID, filePath, fileSize, md5Hash, changeLog, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// PurgeFile 彻底删除文件记录及其版本记录，并释放对存储内容的引用
+// 存储内容在没有其他文件或版本引用后由垃圾回收删除
+func (s *FileService) PurgeFile(fileID uint) error {
 	var file models.File
-	if err := s.db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
-		return fmt.Errorf("文件不存在: %v", err)
+	if err := s.db.First(&file, fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("文件不存在")
+		}
+		return fmt.Errorf("获取文件信息失败: %v", err)
 	}
 
-	// 获取当前最大版本号
-	var maxVersion uint
-	s.db.Model(&models.FileVersion{}).Where("file_id = ?", fileID).Select("COALESCE(MAX(version), 0)").Scan(&maxVersion)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return purgeFile(tx, s.blobs, &file)
+	})
+}
 
-	// 创建新版本记录
-	version := models.FileVersion{
-		FileID:    fileID,
-		Version:   maxVersion + 1,
-		FilePath:  filePath,
-		FileSize:  fileSize,
-		MD5Hash:   md5Hash,
-		CreatedBy: userID,
-		ChangeLog: changeLog,
-		IsActive:  true,
+// purgeFile 在事务中删除文件记录及其版本、标签、拍摄信息和分享，并释放对存储内容的引用
+func purgeFile(tx *gorm.DB, blobs *BlobService, file *models.File) error {
+	var versions []models.FileVersion
+	if err := tx.Where("file_id = ?", file.ID).Find(&versions).Error; err != nil {
+		return fmt.Errorf("获取文件版本失败: %v", err)
 	}
-
-	// 将之前的版本设为非活跃
-	if err := s.db.Model(&models.FileVersion{}).Where("file_id = ? AND is_active = true", fileID).Update("is_active", false).Error; err != nil {
-		return fmt.Errorf("更新版本状态失败: %v", err)
+	for _, version := range versions {
+		if err := blobs.Release(tx, version.FilePath); err != nil {
+			return fmt.Errorf("更新文件引用失败: %v", err)
+		}
 	}
-
-	if err := s.db.Create(&version).Error; err != nil {
-		return fmt.Errorf("创建文件版本失败: %v", err)
+	if err := blobs.Release(tx, file.FilePath); err != nil {
+		return fmt.Errorf("更新文件引用失败: %v", err)
 	}
 
-	// 更新文件主记录
-	if err := s.db.Model(&file).Updates(map[string]interface{}{
-		"file_path": filePath,
-		"file_size": fileSize,
-		"md5_hash":  md5Hash,
-	}).Error; err != nil {
-		return fmt.Errorf("更新文件记录失败: %v", err)
+	if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileVersion{}).Error; err != nil {
+		return fmt.Errorf("删除文件版本失败: %v", err)
+	}
+	if err := tx.Where("file_id = ?", file.ID).Delete(&models.FileTag{}).Error; err != nil {
+		return fmt.Errorf("删除文件标签失败: %v", err)
+	}
+	if err := tx.Delete(&models.FileMetadata{}, file.ID).Error; err != nil {
+		return fmt.Errorf("删除文件拍摄信息失败: %v", err)
+	}
+	if err := tx.Where("target_type = ? AND file_id = ?", models.ShareTargetFile, file.ID).Delete(&models.FileShare{}).Error; err != nil {
+		return fmt.Errorf("删除文件分享失败: %v", err)
+	}
+	if err := tx.Delete(file).Error; err != nil {
+		return fmt.Errorf("删除文件记录失败: %v", err)
 	}
-
 	return nil
 }
 
-// GetFileVersions 获取文件版本列表
+// GetFileVersions 获取文件版本列表（按版本号倒序），文件从未上传过新版本时为空
 func (s *FileService) GetFileVersions(fileID uint, userID uint) ([]models.FileVersion, error) {
 	// 检查用户是否有权限访问该文件
-	var file models.File
-	if err := s.db.Where("id = ? AND (is_private = false OR owner_id = ?) AND is_deleted = false", fileID, userID).First(&file).Error; err != nil {
-		return nil, fmt.Errorf("文件不存在或无权限访问: %v", err)
+	if _, err := s.accessibleFile(s.db, fileID, userID, ActionView); err != nil {
+		return nil, err
 	}
 
 	var versions []models.FileVersion
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrVersionNotFound 文件版本不存在
+	ErrVersionNotFound = errors.New("文件版本不存在")
+	// ErrVersionUnchanged 新版本内容与当前版本相同
+	ErrVersionUnchanged = errors.New("文件内容与当前版本相同")
+)
+
+// FileVersionDiff 两个版本的元数据差异
+type FileVersionDiff struct {
+	From        models.FileVersion   `json:"from"`
+	To          models.FileVersion   `json:"to"`
+	SameContent bool                 `json:"same_content"` // 内容（MD5）是否相同
+	SizeDelta   int64                `json:"size_delta"`   // To 相对 From 的大小变化（字节）
+	Changes     []VersionFieldChange `json:"changes"`
+}
+
+// VersionFieldChange 版本间发生变化的字段
+type VersionFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// nextVersionNumber 生成文件的下一个版本号
+// 版本号为创建时间的毫秒时间戳，同一毫秒内或时钟回拨时顺延，保证单调递增
+func nextVersionNumber(tx *gorm.DB, fileID uint, now time.Time) (uint64, error) {
+	var maxVersion uint64
+	if err := tx.Model(&models.FileVersion{}).Where("file_id = ?", fileID).
+		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+		return 0, err
+	}
+
+	version := uint64(now.UnixMilli())
+	if version <= maxVersion {
+		version = maxVersion + 1
+	}
+	return version, nil
+}
+
+// saveFileVersion 在事务中将文件内容更新为新版本
+// 文件第一次产生新版本时，先将原有内容登记为初始版本，使历史版本完整可追溯；
+// 每个版本记录及文件主记录各持有一个对存储内容的引用。
+func saveFileVersion(tx *gorm.DB, blobs *BlobService, fileID uint, filePath string, fileSize int64, md5Hash, changeLog string, userID uint) (*models.FileVersion, error) {
+	// 锁定文件记录，串行化同一文件的版本创建
+	var file models.File
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	if file.MD5Hash == md5Hash && file.FileSize == fileSize {
+		return nil, ErrVersionUnchanged
+	}
+	if err := checkWorkflowWritable(tx, file.WorkflowID); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.FileVersion{}).Where("file_id = ?", fileID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("获取文件版本失败: %v", err)
+	}
+	if count == 0 {
+		initial := models.FileVersion{
+			FileID:    file.ID,
+			Version:   uint64(file.CreatedAt.UnixMilli()),
+			FilePath:  file.FilePath,
+			FileSize:  file.FileSize,
+			MD5Hash:   file.MD5Hash,
+			CreatedBy: file.OwnerID,
+			CreatedAt: file.CreatedAt,
+			ChangeLog: "初始版本",
+			IsActive:  false,
+		}
+		if err := tx.Create(&initial).Error; err != nil {
+			return nil, fmt.Errorf("创建初始版本失败: %v", err)
+		}
+		if err := blobs.Acquire(tx, initial.FilePath); err != nil {
+			return nil, fmt.Errorf("更新文件引用失败: %v", err)
+		}
+	}
+
+	number, err := nextVersionNumber(tx, fileID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("生成版本号失败: %v", err)
+	}
+
+	// 将之前的版本设为非活跃
+	if err := tx.Model(&models.FileVersion{}).Where("file_id = ? AND is_active = true", fileID).Update("is_active", false).Error; err != nil {
+		return nil, fmt.Errorf("更新版本状态失败: %v", err)
+	}
+
+	version := models.FileVersion{
+		FileID:    fileID,
+		Version:   number,
+		FilePath:  filePath,
+		FileSize:  fileSize,
+		MD5Hash:   md5Hash,
+		CreatedBy: userID,
+		ChangeLog: changeLog,
+		IsActive:  true,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, fmt.Errorf("创建文件版本失败: %v", err)
+	}
+
+	// 更新文件主记录
+	if err := tx.Model(&file).Updates(map[string]interface{}{
+		"file_path": filePath,
+		"file_size": fileSize,
+		"md5_hash":  md5Hash,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新文件记录失败: %v", err)
+	}
+
+	// 新版本记录的引用
+	if err := blobs.Acquire(tx, filePath); err != nil {
+		return nil, fmt.Errorf("更新文件引用失败: %v", err)
+	}
+	// 文件主记录由旧内容改为引用新内容
+	if err := blobs.Acquire(tx, filePath); err != nil {
+		return nil, fmt.Errorf("更新文件引用失败: %v", err)
+	}
+	if err := blobs.Release(tx, file.FilePath); err != nil {
+		return nil, fmt.Errorf("更新文件引用失败: %v", err)
+	}
+
+	return &version, nil
+}
+
+// getFileVersion 获取文件的指定版本（需有文件访问权限）
+func (s *FileService) getFileVersion(fileID uint, number uint64, userID uint) (*models.FileVersion, error) {
+	if _, err := s.GetFileByID(fileID, userID); err != nil {
+		return nil, err
+	}
+
+	var version models.FileVersion
+	if err := s.db.Where("file_id = ? AND version = ?", fileID, number).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("获取文件版本失败: %v", err)
+	}
+	return &version, nil
+}
+
+// OpenFileVersion 打开文件历史版本的内容用于下载，内容不存在时返回 storage.ErrNotExist
+func (s *FileService) OpenFileVersion(fileID uint, number uint64, userID uint) (*FileContent, error) {
+	version, err := s.getFileVersion(fileID, number, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := s.GetFileByID(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.files.Open(&models.File{
+		FileName:  fileInfo.FileName,
+		FilePath:  version.FilePath,
+		FileSize:  version.FileSize,
+		MD5Hash:   version.MD5Hash,
+		MimeType:  fileInfo.MimeType,
+		UpdatedAt: version.CreatedAt,
+	})
+}
+
+// RestoreFileVersion 将历史版本恢复为当前版本（需有修改文件的权限）
+// 恢复操作以历史版本的内容创建一个新版本，不会改写或删除已有的版本记录
+func (s *FileService) RestoreFileVersion(fileID uint, number uint64, userID uint) (*models.FileVersion, error) {
+	if _, err := s.accessibleFile(s.db, fileID, userID, ActionEdit); err != nil {
+		return nil, err
+	}
+
+	var source models.FileVersion
+	if err := s.db.Where("file_id = ? AND version = ?", fileID, number).First(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("获取文件版本失败: %v", err)
+	}
+
+	var restored *models.FileVersion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		restored, err = saveFileVersion(tx, s.blobs, fileID, source.FilePath, source.FileSize, source.MD5Hash,
+			fmt.Sprintf("恢复自版本 %d", source.Version), userID)
+		if err != nil {
+			return err
+		}
+		return s.jobs.enqueueFileProcessing(tx, fileID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// DiffFileVersions 比较文件两个版本的元数据
+func (s *FileService) DiffFileVersions(fileID uint, from, to uint64, userID uint) (*FileVersionDiff, error) {
+	fromVersion, err := s.getFileVersion(fileID, from, userID)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.getFileVersion(fileID, to, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &FileVersionDiff{
+		From:        *fromVersion,
+		To:          *toVersion,
+		SameContent: fromVersion.MD5Hash == toVersion.MD5Hash && fromVersion.FileSize == toVersion.FileSize,
+		SizeDelta:   toVersion.FileSize - fromVersion.FileSize,
+		Changes:     []VersionFieldChange{},
+	}
+
+	addChange := func(field string, a, b interface{}) {
+		if a != b {
+			diff.Changes = append(diff.Changes, VersionFieldChange{Field: field, From: a, To: b})
+		}
+	}
+	addChange("file_size", fromVersion.FileSize, toVersion.FileSize)
+	addChange("md5_hash", fromVersion.MD5Hash, toVersion.MD5Hash)
+	addChange("created_by", fromVersion.CreatedBy, toVersion.CreatedBy)
+	addChange("change_log", fromVersion.ChangeLog, toVersion.ChangeLog)
+
+	return diff, nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// createTestBlob 登记引用数为 refCount 的存储内容
+func createTestBlob(t *testing.T, db *gorm.DB, hash string, refCount int64) *models.Blob {
+	t.Helper()
+	blob := &models.Blob{Hash: hash, Size: 10, StorageKey: "blobs/" + hash, RefCount: refCount}
+	if err := db.Create(blob).Error; err != nil {
+		t.Fatalf("create blob: %v", err)
+	}
+	return blob
+}
+
+// blobRefCount 读取存储内容当前的引用数
+func blobRefCount(t *testing.T, db *gorm.DB, blob *models.Blob) int64 {
+	t.Helper()
+	var current models.Blob
+	if err := db.First(&current, blob.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return current.RefCount
+}
+
+func TestSaveFileVersion(t *testing.T) {
+	db := newTestDB(t)
+	blobs := NewBlobService(db, nil)
+	owner := createTestUser(t, db, "owner", "")
+	original := createTestBlob(t, db, "original", 1)
+	updated := createTestBlob(t, db, "updated", 0)
+	file := createTestFile(t, db, &models.File{FileName: "a.jpg", FilePath: original.StorageKey, FileSize: 10, MD5Hash: original.Hash, OwnerID: owner.ID})
+
+	version, err := saveFileVersion(db, blobs, file.ID, updated.StorageKey, 10, updated.Hash, "修改", owner.ID)
+	if err != nil {
+		t.Fatalf("saveFileVersion: %v", err)
+	}
+
+	// 原有内容被登记为非活跃的初始版本，新版本为活跃版本
+	var versions []models.FileVersion
+	if err := db.Where("file_id = ?", file.ID).Order("version").Find(&versions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("versions = %d, want 2", len(versions))
+	}
+	if versions[0].FilePath != original.StorageKey || versions[0].IsActive {
+		t.Errorf("initial version = %+v, want inactive %s", versions[0], original.StorageKey)
+	}
+	if versions[1].ID != version.ID || !versions[1].IsActive || versions[1].Version <= versions[0].Version {
+		t.Errorf("new version = %+v, want active version after %d", versions[1], versions[0].Version)
+	}
+
+	var current models.File
+	if err := db.First(&current, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.FilePath != updated.StorageKey || current.MD5Hash != updated.Hash {
+		t.Errorf("file = %s %s, want %s %s", current.FilePath, current.MD5Hash, updated.StorageKey, updated.Hash)
+	}
+
+	// 原内容由初始版本引用，新内容由新版本和文件主记录引用
+	if got := blobRefCount(t, db, original); got != 1 {
+		t.Errorf("original ref_count = %d, want 1", got)
+	}
+	if got := blobRefCount(t, db, updated); got != 2 {
+		t.Errorf("updated ref_count = %d, want 2", got)
+	}
+
+	if _, err := saveFileVersion(db, blobs, file.ID, updated.StorageKey, 10, updated.Hash, "", owner.ID); !errors.Is(err, ErrVersionUnchanged) {
+		t.Errorf("save unchanged content = %v, want ErrVersionUnchanged", err)
+	}
+}
+
+func TestRestoreFileVersion(t *testing.T) {
+	db := newTestDB(t)
+	s := &FileService{db: db, blobs: NewBlobService(db, nil), authz: NewAuthzService(db), jobs: NewJobQueue(db)}
+	owner := createTestUser(t, db, "owner", "")
+	other := createTestUser(t, db, "other", "")
+	original := createTestBlob(t, db, "original", 1)
+	updated := createTestBlob(t, db, "updated", 0)
+	file := createTestFile(t, db, &models.File{FileName: "a.jpg", FilePath: original.StorageKey, FileSize: 10, MD5Hash: original.Hash, OwnerID: owner.ID})
+	if _, err := saveFileVersion(db, s.blobs, file.ID, updated.StorageKey, 10, updated.Hash, "", owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var initial models.FileVersion
+	if err := db.Where("file_id = ? AND is_active = false", file.ID).First(&initial).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RestoreFileVersion(file.ID, initial.Version, other.ID); err == nil {
+		t.Error("restore by other user succeeded, want error")
+	}
+	if _, err := s.RestoreFileVersion(file.ID, 1, owner.ID); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("restore missing version = %v, want ErrVersionNotFound", err)
+	}
+
+	restored, err := s.RestoreFileVersion(file.ID, initial.Version, owner.ID)
+	if err != nil {
+		t.Fatalf("RestoreFileVersion: %v", err)
+	}
+	// 恢复创建新版本，已有的版本记录保持不变
+	if restored.FilePath != original.StorageKey || !restored.IsActive {
+		t.Errorf("restored = %+v, want active %s", restored, original.StorageKey)
+	}
+	var count int64
+	if err := db.Model(&models.FileVersion{}).Where("file_id = ?", file.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("versions = %d, want 3", count)
+	}
+	if got := blobRefCount(t, db, original); got != 3 {
+		t.Errorf("original ref_count = %d, want 3", got)
+	}
+	if got := blobRefCount(t, db, updated); got != 1 {
+		t.Errorf("updated ref_count = %d, want 1", got)
+	}
+
+	var jobs int64
+	if err := db.Model(&models.Job{}).Where("type IN ?", []string{JobTypeFileMetadata, JobTypeFileThumbnail}).Count(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if jobs != 2 {
+		t.Errorf("jobs = %d, want 2", jobs)
+	}
+}
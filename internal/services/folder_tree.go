@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFolderNotEmpty 非递归删除时文件夹中仍有文件或子文件夹
+var ErrFolderNotEmpty = errors.New("文件夹不为空，无法删除")
+
+// FolderTree 工作流的完整文件夹树
+// 统计只包含当前用户有权查看的文件
+type FolderTree struct {
+	WorkflowID     uint              `json:"workflow_id"`
+	FileCount      int64             `json:"file_count"`       // 根目录下直接包含的文件数
+	Size           int64             `json:"size"`             // 根目录下直接包含的文件大小
+	TotalFileCount int64             `json:"total_file_count"` // 工作流中的文件总数
+	TotalSize      int64             `json:"total_size"`       // 工作流中的文件总大小
+	Folders        []*FolderTreeNode `json:"folders"`
+}
+
+// FolderTreeNode 文件夹树节点
+type FolderTreeNode struct {
+	ID             uint              `json:"id"`
+	Name           string            `json:"name"`
+	Path           string            `json:"path"`
+	ParentID       uint              `json:"parent_id"`
+	SortOrder      uint              `json:"sort_order"`
+	FileCount      int64             `json:"file_count"`       // 直接包含的文件数
+	Size           int64             `json:"size"`             // 直接包含的文件大小
+	TotalFileCount int64             `json:"total_file_count"` // 包括子文件夹在内的文件总数
+	TotalSize      int64             `json:"total_size"`       // 包括子文件夹在内的文件总大小
+	Children       []*FolderTreeNode `json:"children"`
+}
+
+// folderFileStat 文件夹中直接包含的文件统计
+type folderFileStat struct {
+	FolderID uint
+	Count    int64
+	Size     int64
+}
+
+// GetFolderTree 获取工作流的完整文件夹树及每个文件夹的文件数和大小（需有查看工作流的权限）
+func (s *FileService) GetFolderTree(workflowID, userID uint) (*FolderTree, error) {
+	if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+		return nil, err
+	}
+	visible, err := s.authz.VisibleFiles(userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var folders []models.FileFolder
+	if err := s.db.Where("workflow_id = ? AND is_deleted = false", workflowID).Find(&folders).Error; err != nil {
+		return nil, fmt.Errorf("获取文件夹失败: %v", err)
+	}
+
+	var stats []folderFileStat
+	if err := s.db.Model(&models.File{}).Scopes(visible).
+		Select("folder_id, COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS size").
+		Where("workflow_id = ? AND is_deleted = false", workflowID).
+		Group("folder_id").Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("统计文件失败: %v", err)
+	}
+
+	tree := buildFolderTree(folders, stats)
+	tree.WorkflowID = workflowID
+	return tree, nil
+}
+
+// buildFolderTree 由文件夹列表和文件统计构建文件夹树
+// 上级文件夹不存在（已删除）的文件夹及其中的文件不计入；同级文件夹按排序值、名称排列
+func buildFolderTree(folders []models.FileFolder, stats []folderFileStat) *FolderTree {
+	nodes := make(map[uint]*FolderTreeNode, len(folders))
+	for _, folder := range folders {
+		nodes[folder.ID] = &FolderTreeNode{
+			ID:        folder.ID,
+			Name:      folder.Name,
+			Path:      folder.Path,
+			ParentID:  folder.ParentID,
+			SortOrder: folder.SortOrder,
+			Children:  []*FolderTreeNode{},
+		}
+	}
+
+	tree := &FolderTree{Folders: []*FolderTreeNode{}}
+	for _, stat := range stats {
+		if stat.FolderID == 0 {
+			tree.FileCount += stat.Count
+			tree.Size += stat.Size
+		} else if node, ok := nodes[stat.FolderID]; ok {
+			node.FileCount += stat.Count
+			node.Size += stat.Size
+		}
+	}
+
+	for _, folder := range folders {
+		node := nodes[folder.ID]
+		if folder.ParentID == 0 {
+			tree.Folders = append(tree.Folders, node)
+		} else if parent, ok := nodes[folder.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	// 从根目录遍历汇总，无法从根目录到达的文件夹（包括存在环的异常数据）不会出现在树中
+	visited := make(map[uint]bool, len(nodes))
+	var total func(node *FolderTreeNode)
+	total = func(node *FolderTreeNode) {
+		visited[node.ID] = true
+		sortFolderTreeNodes(node.Children)
+		node.TotalFileCount, node.TotalSize = node.FileCount, node.Size
+		for _, child := range node.Children {
+			if visited[child.ID] {
+				continue
+			}
+			total(child)
+			node.TotalFileCount += child.TotalFileCount
+			node.TotalSize += child.TotalSize
+		}
+	}
+
+	sortFolderTreeNodes(tree.Folders)
+	tree.TotalFileCount, tree.TotalSize = tree.FileCount, tree.Size
+	for _, node := range tree.Folders {
+		total(node)
+		tree.TotalFileCount += node.TotalFileCount
+		tree.TotalSize += node.TotalSize
+	}
+	return tree
+}
+
+// sortFolderTreeNodes 按排序值、名称排列同级文件夹
+func sortFolderTreeNodes(nodes []*FolderTreeNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].SortOrder != nodes[j].SortOrder {
+			return nodes[i].SortOrder < nodes[j].SortOrder
+		}
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
+
+// deleteFolderTree 在事务中将文件夹及其全部子文件夹和文件移入回收站
+// 所有项目使用相同的删除时间，使回收站将其作为一个整体列出和恢复；
+// 须对其中每个子文件夹和文件都有删除权限，否则整体拒绝
+func (s *FileService) deleteFolderTree(tx *gorm.DB, folderID, userID uint) error {
+	folder, err := s.accessibleFolder(tx.Clauses(clause.Locking{Strength: "UPDATE"}), folderID, userID, ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	subtree, err := folderSubtree(tx, folder, false)
+	if err != nil {
+		return err
+	}
+	if err := s.checkSubtreeAccess(tx, subtree, userID, ActionDelete, false); err != nil {
+		return err
+	}
+	ids := folderIDs(subtree)
+
+	now := time.Now()
+	deleted := map[string]interface{}{
+		"is_deleted": true,
+		"deleted_at": &now,
+	}
+	if err := tx.Model(&models.File{}).Where("folder_id IN ? AND workflow_id = ? AND is_deleted = false", ids, folder.WorkflowID).
+		Updates(deleted).Error; err != nil {
+		return fmt.Errorf("删除文件失败: %v", err)
+	}
+	if err := tx.Model(&models.FileFolder{}).Where("id IN ?", ids).Updates(deleted).Error; err != nil {
+		return fmt.Errorf("删除文件夹失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"mcs-backend/internal/models"
+)
+
+func TestBuildFolderTree(t *testing.T) {
+	folders := []models.FileFolder{
+		{ID: 4, ParentID: 1, Name: "RAW"},
+		{ID: 1, Name: "拍摄", SortOrder: 2},
+		{ID: 2, Name: "后期", SortOrder: 1},
+		{ID: 3, ParentID: 1, Name: "JPG"},
+		{ID: 5, ParentID: 4, Name: "day1"},
+		{ID: 6, ParentID: 99, Name: "孤立"},
+	}
+	stats := []folderFileStat{
+		{FolderID: 0, Count: 2, Size: 200},
+		{FolderID: 1, Count: 1, Size: 10},
+		{FolderID: 3, Count: 2, Size: 20},
+		{FolderID: 5, Count: 3, Size: 300},
+		{FolderID: 6, Count: 7, Size: 700},
+		{FolderID: 42, Count: 1, Size: 1},
+	}
+
+	tree := buildFolderTree(folders, stats)
+
+	if tree.FileCount != 2 || tree.Size != 200 {
+		t.Errorf("root direct = %d/%d, want 2/200", tree.FileCount, tree.Size)
+	}
+	if tree.TotalFileCount != 8 || tree.TotalSize != 530 {
+		t.Errorf("root total = %d/%d, want 8/530", tree.TotalFileCount, tree.TotalSize)
+	}
+	if len(tree.Folders) != 2 || tree.Folders[0].ID != 2 || tree.Folders[1].ID != 1 {
+		t.Fatalf("top level folders not sorted by sort order: %+v", tree.Folders)
+	}
+
+	shoot := tree.Folders[1]
+	if len(shoot.Children) != 2 || shoot.Children[0].Name != "JPG" || shoot.Children[1].Name != "RAW" {
+		t.Fatalf("children not sorted by name: %+v", shoot.Children)
+	}
+	if shoot.FileCount != 1 || shoot.TotalFileCount != 6 || shoot.TotalSize != 330 {
+		t.Errorf("拍摄 = %d direct, %d/%d total, want 1, 6/330", shoot.FileCount, shoot.TotalFileCount, shoot.TotalSize)
+	}
+	raw := shoot.Children[1]
+	if raw.FileCount != 0 || raw.TotalFileCount != 3 || len(raw.Children) != 1 {
+		t.Errorf("RAW = %d direct, %d total, %d children", raw.FileCount, raw.TotalFileCount, len(raw.Children))
+	}
+	if post := tree.Folders[0]; post.TotalFileCount != 0 || post.Children == nil {
+		t.Errorf("empty folder = %+v", post)
+	}
+}
+
+func TestBuildFolderTreeCycle(t *testing.T) {
+	folders := []models.FileFolder{
+		{ID: 1, Name: "a"},
+		{ID: 2, ParentID: 3, Name: "b"},
+		{ID: 3, ParentID: 2, Name: "c"},
+	}
+	tree := buildFolderTree(folders, []folderFileStat{{FolderID: 2, Count: 1, Size: 1}})
+	if len(tree.Folders) != 1 || tree.TotalFileCount != 0 {
+		t.Errorf("unreachable folders counted: %+v", tree)
+	}
+}
+
+func TestDeleteFolderTreeChecksEveryFile(t *testing.T) {
+	db := newTestDB(t)
+	master := createTestUser(t, db, "master", "")
+	alice := createTestUser(t, db, "alice", "")
+	bob := createTestUser(t, db, "bob", "")
+	workflow := createTestWorkflow(t, db, "shoot", master.ID, map[uint]string{
+		alice.ID: WorkflowRoleNormal,
+		bob.ID:   WorkflowRoleNormal,
+	})
+
+	// 普通成员 alice 创建的文件夹中有 bob 的私有文件
+	folder := &models.FileFolder{Name: "raw", Path: "raw", WorkflowID: workflow.ID, CreatorID: alice.ID}
+	if err := db.Create(folder).Error; err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, db, &models.File{FileName: "a.cr2", OwnerID: alice.ID, FolderID: folder.ID, WorkflowID: workflow.ID})
+	createTestFile(t, db, &models.File{FileName: "b.cr2", OwnerID: bob.ID, FolderID: folder.ID, WorkflowID: workflow.ID, IsPrivate: true})
+
+	s := &FileService{db: db, authz: NewAuthzService(db)}
+	if err := s.DeleteFolder(folder.ID, alice.ID, true); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("recursive delete by folder creator = %v, want ErrPermissionDenied", err)
+	}
+	var deleted int64
+	db.Model(&models.File{}).Where("is_deleted = true").Count(&deleted)
+	if deleted != 0 {
+		t.Fatalf("%d files deleted after rejected delete", deleted)
+	}
+	db.Model(&models.FileFolder{}).Where("is_deleted = true").Count(&deleted)
+	if deleted != 0 {
+		t.Fatal("folder deleted after rejected delete")
+	}
+
+	// 工作流主管可以删除其中的全部文件
+	if err := s.DeleteFolder(folder.ID, master.ID, true); err != nil {
+		t.Fatalf("recursive delete by master: %v", err)
+	}
+	db.Model(&models.File{}).Where("is_deleted = true").Count(&deleted)
+	if deleted != 2 {
+		t.Errorf("%d files deleted by master, want 2", deleted)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 后台任务类型
+const (
+	JobTypeDownloadZip   = "download.zip"   // 批量下载 ZIP 打包
+	JobTypeFileMetadata  = "file.metadata"  // 提取文件拍摄信息
+	JobTypeFileThumbnail = "file.thumbnail" // 生成文件缩略图
+)
+
+// 后台任务执行参数
+const (
+	defaultJobMaxAttempts = 5
+	jobBackoffBase        = 30 * time.Second
+	jobBackoffMax         = time.Hour
+	jobTimeout            = 30 * time.Minute           // 单次执行超时时间
+	jobStaleAfter         = jobTimeout + 5*time.Minute // 执行中的任务超过该时间未结束视为工作进程已退出
+	jobMaintainEvery      = time.Minute                // 回收超时任务、清理已完成任务的间隔
+	jobStaleError         = "任务执行超时或工作进程已退出"           // 回收超时任务时记录的错误
+)
+
+// 后台任务错误
+var (
+	ErrJobNotFound     = errors.New("任务不存在")
+	ErrJobNotRetryable = errors.New("只能重试已进入死信或已取消的任务")
+	ErrJobNotPending   = errors.New("只能取消等待执行的任务")
+
+	errJobFileNotFound = errors.New("文件不存在")
+)
+
+// JobHandler 后台任务处理函数，返回错误时按退避策略重试，返回 PermanentJobError 包装的错误时直接进入死信
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// permanentJobError 不可重试的任务错误
+type permanentJobError struct {
+	err error
+}
+
+func (e *permanentJobError) Error() string { return e.err.Error() }
+
+func (e *permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError 将错误标记为不可重试，例如任务参数无效或任务对象已不存在
+func PermanentJobError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentJobError{err: err}
+}
+
+// isPermanentJobError 错误是否不可重试
+func isPermanentJobError(err error) bool {
+	var permanent *permanentJobError
+	return errors.As(err, &permanent)
+}
+
+// isLastAttempt 本次失败后任务是否进入死信，处理函数据此决定是否将业务对象标记为失败
+func isLastAttempt(job *models.Job, err error) bool {
+	return isPermanentJobError(err) || job.Attempts >= job.MaxAttempts
+}
+
+// jobBackoff 第 attempts 次执行失败后的重试等待时间，从 30 秒开始指数增长，最长 1 小时
+func jobBackoff(attempts int) time.Duration {
+	backoff := jobBackoffBase
+	for i := 1; i < attempts && backoff < jobBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > jobBackoffMax {
+		backoff = jobBackoffMax
+	}
+	return backoff
+}
+
+// decodeJobPayload 将任务参数解析到结构体
+func decodeJobPayload(job *models.Job, v interface{}) error {
+	data, err := json.Marshal(job.Payload)
+	if err != nil {
+		return PermanentJobError(fmt.Errorf("任务参数无效: %v", err))
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return PermanentJobError(fmt.Errorf("任务参数无效: %v", err))
+	}
+	return nil
+}
+
+// fileJobPayload 针对单个文件的任务参数
+type fileJobPayload struct {
+	FileID uint `json:"file_id"`
+}
+
+// JobStat 各类型、状态的任务数量
+type JobStat struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// JobQueue 基于 PostgreSQL 的持久化后台任务队列
+// 任务在业务事务中写入 jobs 表，进程重启后不会丢失；多个工作协程（可分布在多个实例上）通过 SKIP LOCKED 并发领取，
+// 失败后按指数退避重试，重试次数用尽后进入死信，可通过管理接口查看和重试。
+type JobQueue struct {
+	db       *gorm.DB
+	handlers map[string]JobHandler
+	workerID string
+}
+
+// NewJobQueue 创建后台任务队列，只用于提交任务时无需注册处理函数
+func NewJobQueue(db *gorm.DB) *JobQueue {
+	hostname, _ := os.Hostname()
+	return &JobQueue{
+		db:       db,
+		handlers: make(map[string]JobHandler),
+		workerID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Register 注册任务处理函数，需在 Start 之前调用
+func (q *JobQueue) Register(jobType string, handler JobHandler) {
+	q.handlers[jobType] = handler
+}
+
+// Enqueue 提交后台任务
+func (q *JobQueue) Enqueue(jobType string, payload interface{}) (*models.Job, error) {
+	return q.EnqueueTx(q.db, jobType, payload)
+}
+
+// EnqueueTx 在事务中提交后台任务，事务提交后任务才可被领取
+func (q *JobQueue) EnqueueTx(tx *gorm.DB, jobType string, payload interface{}) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务参数失败: %v", err)
+	}
+	var fields models.JSONField
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("任务参数必须是对象: %v", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     fields,
+		Status:      models.JobStatusPending,
+		MaxAttempts: defaultJobMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := tx.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("创建后台任务失败: %v", err)
+	}
+	return job, nil
+}
+
+// enqueueFileProcessing 提交文件内容变化后的处理任务（拍摄信息、缩略图）
+func (q *JobQueue) enqueueFileProcessing(tx *gorm.DB, fileID uint) error {
+	for _, jobType := range []string{JobTypeFileMetadata, JobTypeFileThumbnail} {
+		if _, err := q.EnqueueTx(tx, jobType, fileJobPayload{FileID: fileID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start 启动工作协程和维护任务，retention 为已完成、已取消任务的保留时间
+func (q *JobQueue) Start(workers int, pollInterval, retention time.Duration) {
+	if workers <= 0 {
+		workers = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work(pollInterval)
+	}
+
+	go func() {
+		ticker := time.NewTicker(jobMaintainEvery)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			q.maintain(retention)
+		}
+	}()
+}
+
+// work 循环领取并执行任务，没有任务时等待 pollInterval
+func (q *JobQueue) work(pollInterval time.Duration) {
+	for {
+		job, err := q.claim()
+		if err != nil {
+			log.Printf("Warning: Failed to claim job: %v", err)
+		}
+		if job == nil {
+			time.Sleep(pollInterval)
+			continue
+		}
+		q.finish(job, q.run(job))
+	}
+}
+
+// claim 领取一个到期的任务并标记为执行中，没有任务时返回 nil
+func (q *JobQueue) claim() (*models.Job, error) {
+	types := make([]string, 0, len(q.handlers))
+	for jobType := range q.handlers {
+		types = append(types, jobType)
+	}
+	if len(types) == 0 {
+		return nil, nil
+	}
+	sort.Strings(types)
+
+	var job models.Job
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ? AND type IN ?", models.JobStatusPending, time.Now(), types).
+			Order("run_at, id").
+			Limit(1).
+			Take(&job).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedBy = q.workerID
+		job.LockedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_by": job.LockedBy,
+			"locked_at": now,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run 执行任务处理函数，处理函数 panic 时作为普通错误重试
+func (q *JobQueue) run(job *models.Job) (err error) {
+	handler, ok := q.handlers[job.Type]
+	if !ok {
+		return PermanentJobError(fmt.Errorf("未注册的任务类型: %s", job.Type))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %d (%s) panicked: %v\n%s", job.ID, job.Type, r, debug.Stack())
+			err = fmt.Errorf("任务执行异常: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// finish 记录任务执行结果：成功则完成，失败则按退避策略重试或进入死信
+// 只更新仍由本工作进程持有的任务，避免覆盖已被回收或取消的任务
+func (q *JobQueue) finish(job *models.Job, err error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by": "",
+		"locked_at": nil,
+	}
+	switch {
+	case err == nil:
+		updates["status"] = models.JobStatusCompleted
+		updates["completed_at"] = now
+		updates["last_error"] = ""
+	case isLastAttempt(job, err):
+		updates["status"] = models.JobStatusDead
+		updates["last_error"] = err.Error()
+		log.Printf("Warning: Job %d (%s) moved to dead letter after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+	default:
+		updates["status"] = models.JobStatusPending
+		updates["run_at"] = now.Add(jobBackoff(job.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if dbErr := q.db.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobStatusRunning, q.workerID).
+		Updates(updates).Error; dbErr != nil {
+		log.Printf("Warning: Failed to update job %d: %v", job.ID, dbErr)
+	}
+}
+
+// maintain 回收超时的任务，清理过期的已完成、已取消任务
+func (q *JobQueue) maintain(retention time.Duration) {
+	now := time.Now()
+	result := q.db.Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", models.JobStatusRunning, now.Add(-jobStaleAfter)).
+		Updates(map[string]interface{}{
+			"status":     gorm.Expr("CASE WHEN attempts >= max_attempts THEN ? ELSE ? END", models.JobStatusDead, models.JobStatusPending),
+			"run_at":     now,
+			"locked_by":  "",
+			"locked_at":  nil,
+			"last_error": jobStaleError,
+		})
+	if result.Error != nil {
+		log.Printf("Warning: Failed to recover stale jobs: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Recovered %d stale jobs", result.RowsAffected)
+	}
+
+	if err := q.db.Where("status IN ? AND updated_at < ?",
+		[]string{models.JobStatusCompleted, models.JobStatusCancelled}, now.Add(-retention)).
+		Delete(&models.Job{}).Error; err != nil {
+		log.Printf("Warning: Failed to delete finished jobs: %v", err)
+	}
+}
+
+// ListJobs 分页获取任务列表，按创建时间倒序
+func (q *JobQueue) ListJobs(status, jobType string, page, pageSize int) ([]models.Job, int64, error) {
+	query := q.db.Model(&models.Job{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取任务总数失败: %v", err)
+	}
+
+	var jobs []models.Job
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取任务列表失败: %v", err)
+	}
+	return jobs, total, nil
+}
+
+// GetJob 获取任务详情
+func (q *JobQueue) GetJob(id uint) (*models.Job, error) {
+	var job models.Job
+	if err := q.db.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("获取任务失败: %v", err)
+	}
+	return &job, nil
+}
+
+// GetStats 按类型和状态统计任务数量
+func (q *JobQueue) GetStats() ([]JobStat, error) {
+	var stats []JobStat
+	if err := q.db.Model(&models.Job{}).
+		Select("type, status, COUNT(*) AS count").
+		Group("type, status").
+		Order("type, status").
+		Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("获取任务统计失败: %v", err)
+	}
+	return stats, nil
+}
+
+// RetryJob 重新执行已进入死信或已取消的任务，执行次数清零
+func (q *JobQueue) RetryJob(id uint) (*models.Job, error) {
+	return q.transition(id, []string{models.JobStatusDead, models.JobStatusCancelled}, ErrJobNotRetryable, map[string]interface{}{
+		"status":   models.JobStatusPending,
+		"attempts": 0,
+		"run_at":   time.Now(),
+	})
+}
+
+// CancelJob 取消等待执行的任务
+func (q *JobQueue) CancelJob(id uint) (*models.Job, error) {
+	return q.transition(id, []string{models.JobStatusPending}, ErrJobNotPending, map[string]interface{}{
+		"status": models.JobStatusCancelled,
+	})
+}
+
+// transition 将处于 from 状态之一的任务更新为新状态，任务状态不符时返回 errInvalid
+func (q *JobQueue) transition(id uint, from []string, errInvalid error, updates map[string]interface{}) (*models.Job, error) {
+	result := q.db.Model(&models.Job{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新任务失败: %v", result.Error)
+	}
+	job, err := q.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalid
+	}
+	return job, nil
+}
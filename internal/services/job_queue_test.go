@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mcs-backend/internal/models"
+)
+
+func TestJobBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		5:  8 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		50: time.Hour,
+	} {
+		if got := jobBackoff(attempts); got != want {
+			t.Errorf("jobBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestPermanentJobError(t *testing.T) {
+	cause := errors.New("boom")
+	err := PermanentJobError(cause)
+	if !isPermanentJobError(err) || !errors.Is(err, cause) || err.Error() != "boom" {
+		t.Errorf("PermanentJobError(boom) = %v", err)
+	}
+	if PermanentJobError(nil) != nil {
+		t.Error("PermanentJobError(nil) != nil")
+	}
+	if isPermanentJobError(cause) {
+		t.Error("plain error reported as permanent")
+	}
+
+	job := &models.Job{Attempts: 2, MaxAttempts: 5}
+	if isLastAttempt(job, cause) {
+		t.Error("attempt 2 of 5 reported as last")
+	}
+	if !isLastAttempt(job, err) {
+		t.Error("permanent error not reported as last attempt")
+	}
+	job.Attempts = 5
+	if !isLastAttempt(job, cause) {
+		t.Error("attempt 5 of 5 not reported as last")
+	}
+}
+
+func TestDecodeJobPayload(t *testing.T) {
+	job := &models.Job{Payload: models.JSONField{"file_id": float64(42)}}
+	var payload fileJobPayload
+	if err := decodeJobPayload(job, &payload); err != nil {
+		t.Fatalf("decodeJobPayload: %v", err)
+	}
+	if payload.FileID != 42 {
+		t.Errorf("FileID = %d, want 42", payload.FileID)
+	}
+
+	job.Payload = models.JSONField{"file_id": "not a number"}
+	if err := decodeJobPayload(job, &payload); !isPermanentJobError(err) {
+		t.Errorf("decodeJobPayload(invalid) = %v, want permanent error", err)
+	}
+}
+
+func TestJobQueueRun(t *testing.T) {
+	q := NewJobQueue(nil)
+	q.Register("ok", func(ctx context.Context, job *models.Job) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("missing deadline")
+		}
+		return nil
+	})
+	q.Register("panic", func(ctx context.Context, job *models.Job) error {
+		panic("boom")
+	})
+
+	if err := q.run(&models.Job{Type: "ok"}); err != nil {
+		t.Errorf("run(ok) = %v", err)
+	}
+	if err := q.run(&models.Job{Type: "panic"}); err == nil || isPermanentJobError(err) {
+		t.Errorf("run(panic) = %v, want retryable error", err)
+	}
+	if err := q.run(&models.Job{Type: "unknown"}); !isPermanentJobError(err) {
+		t.Errorf("run(unknown) = %v, want permanent error", err)
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"mcs-backend/internal/media"
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// exifExtensions 可能包含 EXIF 信息的文件类型（JPEG 及 TIFF 结构的 RAW 格式）
+var exifExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".tif":  true,
+	".tiff": true,
+	".cr2":  true,
+	".nef":  true,
+	".dng":  true,
+	".arw":  true,
+}
+
+// MetadataService 文件拍摄信息提取服务
+// 文件上传完成（包括秒传和上传新版本）及恢复历史版本后，由后台任务提取当前内容的 EXIF 信息并保存到 file_metadata 表
+type MetadataService struct {
+	db      *gorm.DB
+	storage storage.Storage
+	files   *FileResolver
+	search  *SearchIndexer
+}
+
+// NewMetadataService 创建文件拍摄信息提取服务，uploadPath 为本地存储的上传目录，用于解析早期版本保存的磁盘路径
+func NewMetadataService(db *gorm.DB, store storage.Storage, uploadPath string) *MetadataService {
+	return &MetadataService{
+		db:      db,
+		storage: store,
+		files:   NewFileResolver(store, uploadPath),
+		search:  NewSearchIndexer(db),
+	}
+}
+
+// Extract 提取文件当前内容的拍摄信息并保存，文件类型不支持或内容中没有 EXIF 信息时删除已有记录并返回 nil
+// 读取或解析失败时返回错误，不修改已有记录
+func (s *MetadataService) Extract(fileID uint) (*models.FileMetadata, error) {
+	var file models.File
+	if err := s.db.Select("id, file_name, file_path").First(&file, fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errJobFileNotFound
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+
+	exif, err := s.readEXIF(&file)
+	if err != nil {
+		// 读取失败可能是暂时的，保留已有记录
+		return nil, err
+	}
+	if exif == nil {
+		// 新版本的内容可能不再包含拍摄信息
+		if err := s.db.Delete(&models.FileMetadata{}, fileID).Error; err != nil {
+			log.Printf("Warning: Failed to delete metadata of file %d: %v", fileID, err)
+		}
+		s.search.indexQuietly(fileID)
+		return nil, nil
+	}
+
+	metadata := toFileMetadata(fileID, exif)
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}},
+		UpdateAll: true,
+	}).Create(metadata).Error; err != nil {
+		return nil, fmt.Errorf("保存拍摄信息失败: %v", err)
+	}
+	s.search.indexQuietly(fileID)
+	return metadata, nil
+}
+
+// HandleJob 处理提取拍摄信息的后台任务，文件已被彻底删除或存储路径无效时不再重试
+func (s *MetadataService) HandleJob(ctx context.Context, job *models.Job) error {
+	var payload fileJobPayload
+	if err := decodeJobPayload(job, &payload); err != nil {
+		return err
+	}
+	if _, err := s.Extract(payload.FileID); err != nil {
+		if errors.Is(err, errJobFileNotFound) || errors.Is(err, ErrInvalidStoragePath) {
+			return PermanentJobError(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetMetadata 批量获取文件的拍摄信息，没有拍摄信息的文件不在结果中
+func (s *MetadataService) GetMetadata(fileIDs []uint) (map[uint]*models.FileMetadata, error) {
+	result := make(map[uint]*models.FileMetadata, len(fileIDs))
+	if len(fileIDs) == 0 {
+		return result, nil
+	}
+
+	var records []models.FileMetadata
+	if err := s.db.Where("file_id IN ?", fileIDs).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("获取拍摄信息失败: %v", err)
+	}
+	for i := range records {
+		result[records[i].FileID] = &records[i]
+	}
+	return result, nil
+}
+
+// readEXIF 读取文件头部并解析 EXIF，文件类型不支持或没有 EXIF 信息时返回 nil
+func (s *MetadataService) readEXIF(file *models.File) (*media.EXIF, error) {
+	if !exifExtensions[strings.ToLower(filepath.Ext(file.FileName))] {
+		return nil, nil
+	}
+
+	key, err := s.files.StorageKey(file.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.storage.GetRange(key, 0, media.EXIFReadLimit)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件内容失败: %v", err)
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(io.LimitReader(content, media.EXIFReadLimit))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件内容失败: %v", err)
+	}
+
+	exif, err := media.ParseEXIF(data)
+	if errors.Is(err, media.ErrNoEXIF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 EXIF 失败: %v", err)
+	}
+	return exif, nil
+}
+
+// toFileMetadata 将 EXIF 信息转换为数据库记录
+func toFileMetadata(fileID uint, exif *media.EXIF) *models.FileMetadata {
+	return &models.FileMetadata{
+		FileID:          fileID,
+		CameraMake:      truncateRunes(exif.Make, 100),
+		CameraModel:     truncateRunes(exif.Model, 100),
+		LensModel:       truncateRunes(exif.LensModel, 200),
+		ISO:             exif.ISO,
+		Aperture:        exif.FNumber,
+		ExposureTime:    exif.ExposureTime,
+		ShutterSpeed:    exif.ShutterSpeed(),
+		FocalLength:     exif.FocalLength,
+		FocalLength35mm: exif.FocalLength35mm,
+		Width:           exif.Width,
+		Height:          exif.Height,
+		Orientation:     exif.Orientation,
+		Latitude:        exif.Latitude,
+		Longitude:       exif.Longitude,
+		Altitude:        exif.Altitude,
+		CapturedAt:      exif.CapturedAt,
+	}
+}
+
+// truncateRunes 截断字符串到指定字符数，避免超出字段长度
+func truncateRunes(value string, n int) string {
+	runes := []rune(value)
+	if len(runes) <= n {
+		return value
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadErrQuotaExceeded 上传后将超出存储配额
+const UploadErrQuotaExceeded = "QUOTA_EXCEEDED"
+
+// ErrQuotaExceeded 存储配额不足
+var ErrQuotaExceeded = errors.New("存储配额不足")
+
+// QuotaService 存储配额服务
+// 已使用空间按未删除文件的大小统计，秒传的文件同样计入，与实际是否共享存储内容无关。
+type QuotaService struct {
+	db    *gorm.DB
+	authz *AuthzService
+}
+
+// NewQuotaService 创建存储配额服务
+func NewQuotaService(db *gorm.DB) *QuotaService {
+	return &QuotaService{db: db, authz: NewAuthzService(db)}
+}
+
+// SetQuotaRequest 设置配额请求
+type SetQuotaRequest struct {
+	ScopeType string `json:"scope_type" binding:"required,oneof=user group workflow"`
+	ScopeID   uint   `json:"scope_id" binding:"required"`
+	MaxBytes  int64  `json:"max_bytes" binding:"min=1"`
+}
+
+// QuotaUsage 配额使用情况
+type QuotaUsage struct {
+	QuotaID      uint    `json:"quota_id,omitempty"`
+	ScopeType    string  `json:"scope_type"`
+	ScopeID      uint    `json:"scope_id"`
+	UsedBytes    int64   `json:"used_bytes"`
+	MaxBytes     int64   `json:"max_bytes"`     // 0 表示未设置配额
+	UsagePercent float64 `json:"usage_percent"` // 未设置配额时为 0
+}
+
+// quotaScopeName 配额作用范围的中文名称
+func quotaScopeName(scopeType string) string {
+	switch scopeType {
+	case models.QuotaScopeUser:
+		return "用户"
+	case models.QuotaScopeGroup:
+		return "用户组"
+	case models.QuotaScopeWorkflow:
+		return "工作流"
+	}
+	return scopeType
+}
+
+// SetQuota 设置配额（已存在时更新）
+func (s *QuotaService) SetQuota(req *SetQuotaRequest, operatorID uint) (*QuotaUsage, error) {
+	if err := s.checkScope(req.ScopeType, req.ScopeID); err != nil {
+		return nil, err
+	}
+
+	quota := models.StorageQuota{
+		ScopeType: req.ScopeType,
+		ScopeID:   req.ScopeID,
+		MaxBytes:  req.MaxBytes,
+		UpdatedBy: operatorID,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope_type"}, {Name: "scope_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_bytes", "updated_by", "updated_at"}),
+	}).Create(&quota).Error; err != nil {
+		return nil, fmt.Errorf("保存配额失败: %v", err)
+	}
+
+	return s.GetUsage(req.ScopeType, req.ScopeID)
+}
+
+// DeleteQuota 删除配额，删除后不再限制
+func (s *QuotaService) DeleteQuota(scopeType string, scopeID uint) error {
+	result := s.db.Where("scope_type = ? AND scope_id = ?", scopeType, scopeID).Delete(&models.StorageQuota{})
+	if result.Error != nil {
+		return fmt.Errorf("删除配额失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("配额不存在")
+	}
+	return nil
+}
+
+// ListQuotas 获取已设置的配额及使用情况，scopeType 为空时返回全部
+func (s *QuotaService) ListQuotas(scopeType string) ([]QuotaUsage, error) {
+	query := s.db.Model(&models.StorageQuota{})
+	if scopeType != "" {
+		query = query.Where("scope_type = ?", scopeType)
+	}
+
+	var quotas []models.StorageQuota
+	if err := query.Order("scope_type, scope_id").Find(&quotas).Error; err != nil {
+		return nil, fmt.Errorf("获取配额列表失败: %v", err)
+	}
+
+	usages := make([]QuotaUsage, 0, len(quotas))
+	for i := range quotas {
+		usage, err := s.usageOf(&quotas[i])
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *usage)
+	}
+	return usages, nil
+}
+
+// GetUsage 获取指定范围的已使用空间与配额
+func (s *QuotaService) GetUsage(scopeType string, scopeID uint) (*QuotaUsage, error) {
+	var quota models.StorageQuota
+	if err := s.db.Where("scope_type = ? AND scope_id = ?", scopeType, scopeID).First(&quota).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("获取配额失败: %v", err)
+		}
+		quota = models.StorageQuota{ScopeType: scopeType, ScopeID: scopeID}
+	}
+	return s.usageOf(&quota)
+}
+
+// GetUserUsage 获取用户自身、所在用户组以及指定工作流（workflowID 不为 0 时）的配额使用情况
+// 查看工作流配额需有查看工作流的权限
+func (s *QuotaService) GetUserUsage(userID, workflowID uint) ([]QuotaUsage, error) {
+	if workflowID != 0 {
+		if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+			return nil, err
+		}
+	}
+
+	usages := make([]QuotaUsage, 0)
+
+	usage, err := s.GetUsage(models.QuotaScopeUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	usages = append(usages, *usage)
+
+	var groupIDs []uint
+	if err := s.userGroups(userID).Pluck("user_group_members.group_id", &groupIDs).Error; err != nil {
+		return nil, fmt.Errorf("获取用户组失败: %v", err)
+	}
+	for _, groupID := range groupIDs {
+		usage, err := s.GetUsage(models.QuotaScopeGroup, groupID)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *usage)
+	}
+
+	if workflowID != 0 {
+		usage, err := s.GetUsage(models.QuotaScopeWorkflow, workflowID)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *usage)
+	}
+
+	return usages, nil
+}
+
+// CheckQuota 检查用户向工作流新增 size 字节后是否超出用户、所在用户组或工作流的配额
+// 在事务中调用时会锁定涉及的配额记录，同一配额下的并发检查依次进行，需在同一事务中写入文件记录
+func (s *QuotaService) CheckQuota(db *gorm.DB, userID, workflowID uint, size int64) error {
+	query := db.Where("scope_type = ? AND scope_id = ?", models.QuotaScopeUser, userID).
+		Or("scope_type = ? AND scope_id IN (?)", models.QuotaScopeGroup,
+			s.userGroups(userID).Select("user_group_members.group_id"))
+	if workflowID != 0 {
+		query = query.Or("scope_type = ? AND scope_id = ?", models.QuotaScopeWorkflow, workflowID)
+	}
+
+	return s.checkQuotas(db, query, size)
+}
+
+// CheckWorkflowQuota 检查向工作流转入 size 字节后是否超出工作流的配额
+// 用于移动文件到其他工作流，文件所有者不变，不检查用户和用户组配额
+func (s *QuotaService) CheckWorkflowQuota(db *gorm.DB, workflowID uint, size int64) error {
+	if workflowID == 0 || size <= 0 {
+		return nil
+	}
+	return s.checkQuotas(db, db.Where("scope_type = ? AND scope_id = ?", models.QuotaScopeWorkflow, workflowID), size)
+}
+
+// checkQuotas 检查查询到的配额在新增 size 字节后是否超出
+// 配额记录按 ID 顺序加 FOR UPDATE 锁，避免并发上传各自通过检查后合计超出配额
+func (s *QuotaService) checkQuotas(db *gorm.DB, query *gorm.DB, size int64) error {
+	var quotas []models.StorageQuota
+	if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Order("id").Find(&quotas).Error; err != nil {
+		return fmt.Errorf("获取存储配额失败: %v", err)
+	}
+
+	for _, quota := range quotas {
+		// 历史数据中可能存在为 0 的配额，视为未设置
+		if quota.MaxBytes <= 0 {
+			continue
+		}
+		used, err := s.usedBytes(db, quota.ScopeType, quota.ScopeID)
+		if err != nil {
+			return err
+		}
+		if used+size > quota.MaxBytes {
+			return &UploadPolicyError{
+				Code: UploadErrQuotaExceeded,
+				Message: fmt.Sprintf("%v: %s配额 %d 字节，已使用 %d 字节，本次上传 %d 字节",
+					ErrQuotaExceeded, quotaScopeName(quota.ScopeType), quota.MaxBytes, used, size),
+				err: ErrQuotaExceeded,
+			}
+		}
+	}
+	return nil
+}
+
+// usageOf 统计配额的使用情况
+func (s *QuotaService) usageOf(quota *models.StorageQuota) (*QuotaUsage, error) {
+	used, err := s.usedBytes(s.db, quota.ScopeType, quota.ScopeID)
+	if err != nil {
+		return nil, err
+	}
+	return &QuotaUsage{
+		QuotaID:      quota.ID,
+		ScopeType:    quota.ScopeType,
+		ScopeID:      quota.ScopeID,
+		UsedBytes:    used,
+		MaxBytes:     quota.MaxBytes,
+		UsagePercent: models.GetStorageUsagePercent(used, quota.MaxBytes),
+	}, nil
+}
+
+// usedBytes 统计范围内未删除文件的总大小
+func (s *QuotaService) usedBytes(db *gorm.DB, scopeType string, scopeID uint) (int64, error) {
+	query := db.Model(&models.File{}).Where("is_deleted = false")
+	switch scopeType {
+	case models.QuotaScopeUser:
+		query = query.Where("owner_id = ?", scopeID)
+	case models.QuotaScopeGroup:
+		query = query.Where("owner_id IN (?)", db.Model(&models.UserGroupMember{}).
+			Select("user_id").Where("group_id = ? AND is_active = true", scopeID))
+	case models.QuotaScopeWorkflow:
+		query = query.Where("workflow_id = ?", scopeID)
+	default:
+		return 0, fmt.Errorf("不支持的配额范围: %s", scopeType)
+	}
+
+	var used int64
+	if err := query.Select("COALESCE(SUM(file_size), 0)").Scan(&used).Error; err != nil {
+		return 0, fmt.Errorf("统计已使用空间失败: %v", err)
+	}
+	return used, nil
+}
+
+// userGroups 用户以有效成员身份所在的有效用户组
+func (s *QuotaService) userGroups(userID uint) *gorm.DB {
+	return s.db.Model(&models.UserGroupMember{}).
+		Joins("JOIN user_groups ON user_groups.id = user_group_members.group_id AND user_groups.is_active = true").
+		Where("user_group_members.user_id = ? AND user_group_members.is_active = true", userID)
+}
+
+// checkScope 检查配额作用对象是否存在
+func (s *QuotaService) checkScope(scopeType string, scopeID uint) error {
+	var model interface{}
+	switch scopeType {
+	case models.QuotaScopeUser:
+		model = &models.User{}
+	case models.QuotaScopeGroup:
+		model = &models.UserGroup{}
+	case models.QuotaScopeWorkflow:
+		model = &models.Workflow{}
+	default:
+		return fmt.Errorf("不支持的配额范围: %s", scopeType)
+	}
+
+	var count int64
+	if err := s.db.Model(model).Where("id = ?", scopeID).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查%s失败: %v", quotaScopeName(scopeType), err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%s不存在", quotaScopeName(scopeType))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestCheckQuotaConcurrent(t *testing.T) {
+	db := newTestDB(t)
+	s := NewQuotaService(db)
+	user := createTestUser(t, db, "alice", "")
+	if err := db.Create(&models.StorageQuota{ScopeType: models.QuotaScopeUser, ScopeID: user.ID, MaxBytes: 300}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 每次上传 100 字节，配额只够 3 次；并发检查加锁后不应合计超出配额
+	const uploads = 8
+	var wg sync.WaitGroup
+	errs := make([]error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Transaction(func(tx *gorm.DB) error {
+				if err := s.CheckQuota(tx, user.ID, 0, 100); err != nil {
+					return err
+				}
+				return tx.Create(&models.File{
+					FileName: fmt.Sprintf("%d.jpg", i),
+					FilePath: fmt.Sprintf("blobs/%d", i),
+					FileSize: 100,
+					OwnerID:  user.ID,
+				}).Error
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrQuotaExceeded):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 3 {
+		t.Errorf("succeeded = %d, want 3", succeeded)
+	}
+	used, err := s.usedBytes(db, models.QuotaScopeUser, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 300 {
+		t.Errorf("used = %d, want 300", used)
+	}
+}
+
+func TestCheckQuotaSkipsZeroQuota(t *testing.T) {
+	db := newTestDB(t)
+	s := NewQuotaService(db)
+	user := createTestUser(t, db, "alice", "")
+	if err := db.Create(&models.StorageQuota{ScopeType: models.QuotaScopeUser, ScopeID: user.ID, MaxBytes: 0}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CheckQuota(db, user.ID, 0, 100); err != nil {
+		t.Errorf("CheckQuota with zero quota: %v", err)
+	}
+}
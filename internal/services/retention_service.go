@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetentionService 历史版本保留策略
+// 工作流未完成前保留所有历史版本；工作流完成并经过宽限期后，只保留每个文件的当前版本，
+// 非当前版本的记录被删除，其存储内容在没有其他引用后由 Blob 垃圾回收删除。
+type RetentionService struct {
+	db          *gorm.DB
+	blobs       *BlobService
+	authz       *AuthzService
+	gracePeriod time.Duration
+}
+
+// NewRetentionService 创建历史版本保留策略服务
+func NewRetentionService(db *gorm.DB, blobs *BlobService, gracePeriod time.Duration) *RetentionService {
+	return &RetentionService{
+		db:          db,
+		blobs:       blobs,
+		authz:       NewAuthzService(db),
+		gracePeriod: gracePeriod,
+	}
+}
+
+// RetentionReport 历史版本清理报告
+type RetentionReport struct {
+	DryRun        bool                `json:"dry_run"`
+	GracePeriod   string              `json:"grace_period"`
+	Workflows     []WorkflowRetention `json:"workflows"`
+	Versions      int                 `json:"versions"`       // 删除（试运行时为将删除）的版本数
+	ReleasedBytes int64               `json:"released_bytes"` // 版本大小之和，与其他文件共享的内容不会被实际回收
+	Failed        int                 `json:"failed"`         // 清理失败的工作流数，失败原因见对应工作流的 error
+}
+
+// WorkflowRetention 单个工作流的历史版本清理情况
+type WorkflowRetention struct {
+	WorkflowID    uint       `json:"workflow_id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	PruneAfter    *time.Time `json:"prune_after,omitempty"` // 宽限期结束时间，之后开始清理
+	Files         int        `json:"files"`                 // 存在历史版本的文件数
+	Versions      int        `json:"versions"`              // 非当前版本数
+	ReleasedBytes int64      `json:"released_bytes"`
+	Error         string     `json:"error,omitempty"` // 清理失败的原因，失败的工作流不计入报告的版本数和大小
+}
+
+// Run 清理所有已完成且超过宽限期的工作流的历史版本，dryRun 为 true 时只生成报告
+// 单个工作流清理失败时记录到报告中并继续处理其他工作流
+func (s *RetentionService) Run(dryRun bool) (*RetentionReport, error) {
+	report := &RetentionReport{
+		DryRun:      dryRun,
+		GracePeriod: s.gracePeriod.String(),
+		Workflows:   []WorkflowRetention{},
+	}
+
+	var workflows []models.Workflow
+	if err := s.db.Where("status = ? AND completed_at < ?", models.WorkflowStatusCompleted, time.Now().Add(-s.gracePeriod)).
+		Order("completed_at").Find(&workflows).Error; err != nil {
+		return nil, fmt.Errorf("获取已完成工作流失败: %v", err)
+	}
+
+	for i := range workflows {
+		var (
+			result *WorkflowRetention
+			err    error
+		)
+		if dryRun {
+			result, err = s.inspect(&workflows[i])
+		} else {
+			result, err = s.prune(workflows[i].ID)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to prune versions of workflow %d: %v", workflows[i].ID, err)
+			failed := s.summarize(&workflows[i], nil)
+			failed.Error = err.Error()
+			report.Workflows = append(report.Workflows, *failed)
+			report.Failed++
+			continue
+		}
+		if result == nil || result.Versions == 0 {
+			continue
+		}
+
+		report.Workflows = append(report.Workflows, *result)
+		report.Versions += result.Versions
+		report.ReleasedBytes += result.ReleasedBytes
+	}
+
+	return report, nil
+}
+
+// Preview 试运行单个工作流的历史版本清理（需有查看工作流的权限），不论工作流状态及宽限期
+func (s *RetentionService) Preview(workflowID uint, userID uint) (*WorkflowRetention, error) {
+	if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+		return nil, err
+	}
+
+	var workflow models.Workflow
+	if err := s.db.First(&workflow, workflowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("工作流不存在")
+		}
+		return nil, fmt.Errorf("获取工作流失败: %v", err)
+	}
+	return s.inspect(&workflow)
+}
+
+// inspect 统计工作流中将被清理的历史版本
+func (s *RetentionService) inspect(workflow *models.Workflow) (*WorkflowRetention, error) {
+	versions, err := s.inactiveVersions(s.db, workflow.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.summarize(workflow, versions), nil
+}
+
+// prune 删除工作流中的非当前版本并释放其对存储内容的引用
+// 在事务中锁定工作流并重新确认状态，避免与重新打开工作流的操作竞争
+func (s *RetentionService) prune(workflowID uint) (*WorkflowRetention, error) {
+	var result *WorkflowRetention
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var workflow models.Workflow
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&workflow, workflowID).Error; err != nil {
+			return err
+		}
+		if workflow.Status != models.WorkflowStatusCompleted || workflow.CompletedAt == nil ||
+			workflow.CompletedAt.After(time.Now().Add(-s.gracePeriod)) {
+			return nil
+		}
+
+		versions, err := s.inactiveVersions(tx, workflow.ID)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(versions))
+		for _, version := range versions {
+			if err := s.blobs.Release(tx, version.FilePath); err != nil {
+				return fmt.Errorf("更新文件引用失败: %v", err)
+			}
+			ids = append(ids, version.ID)
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.FileVersion{}).Error; err != nil {
+			return fmt.Errorf("删除历史版本失败: %v", err)
+		}
+
+		result = s.summarize(&workflow, versions)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("清理工作流 %d 的历史版本失败: %v", workflowID, err)
+	}
+	return result, nil
+}
+
+// inactiveVersions 获取工作流中所有文件（含已删除文件）的非当前版本
+func (s *RetentionService) inactiveVersions(db *gorm.DB, workflowID uint) ([]models.FileVersion, error) {
+	var versions []models.FileVersion
+	if err := db.Where("is_active = false AND file_id IN (?)",
+		db.Model(&models.File{}).Select("id").Where("workflow_id = ?", workflowID)).
+		Order("file_id, version").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("获取历史版本失败: %v", err)
+	}
+	return versions, nil
+}
+
+// summarize 汇总工作流的历史版本清理情况
+func (s *RetentionService) summarize(workflow *models.Workflow, versions []models.FileVersion) *WorkflowRetention {
+	result := &WorkflowRetention{
+		WorkflowID:  workflow.ID,
+		Name:        workflow.Name,
+		Status:      workflow.Status,
+		CompletedAt: workflow.CompletedAt,
+		Versions:    len(versions),
+	}
+	if workflow.CompletedAt != nil {
+		pruneAfter := workflow.CompletedAt.Add(s.gracePeriod)
+		result.PruneAfter = &pruneAfter
+	}
+
+	files := make(map[uint]bool)
+	for _, version := range versions {
+		files[version.FileID] = true
+		result.ReleasedBytes += version.FileSize
+	}
+	result.Files = len(files)
+	return result
+}
+
+// StartRetention 启动后台历史版本清理任务
+func (s *RetentionService) StartRetention(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := s.Run(false)
+			if err != nil {
+				log.Printf("Warning: Failed to prune file versions: %v", err)
+				continue
+			}
+			if report.Versions > 0 {
+				log.Printf("Version retention pruned %d versions (%d bytes) in %d workflows",
+					report.Versions, report.ReleasedBytes, len(report.Workflows)-report.Failed)
+			}
+		}
+	}()
+}
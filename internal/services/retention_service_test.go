@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mcs-backend/internal/models"
+)
+
+// createVersionedFile 在工作流中创建带有 versions 个历史版本的文件
+func createVersionedFile(t *testing.T, s *RetentionService, workflow *models.Workflow, name string, versions int) *models.File {
+	t.Helper()
+
+	file := createTestFile(t, s.db, &models.File{FileName: name, FileSize: 10, MD5Hash: name + "-0", OwnerID: workflow.MasterID, WorkflowID: workflow.ID})
+	for i := 1; i <= versions; i++ {
+		if _, err := saveFileVersion(s.db, s.blobs, file.ID, fmt.Sprintf("blobs/%s-%d", name, i), 10, fmt.Sprintf("%s-%d", name, i), "", workflow.MasterID); err != nil {
+			t.Fatalf("saveFileVersion: %v", err)
+		}
+	}
+	return file
+}
+
+// completeWorkflow 将工作流设置为在 completedAt 完成
+func completeWorkflow(t *testing.T, s *RetentionService, workflow *models.Workflow, completedAt time.Time) {
+	t.Helper()
+	if err := s.db.Model(workflow).Updates(map[string]interface{}{
+		"status":       models.WorkflowStatusCompleted,
+		"completed_at": completedAt,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countVersions(t *testing.T, s *RetentionService, fileID uint) int64 {
+	t.Helper()
+	var count int64
+	if err := s.db.Model(&models.FileVersion{}).Where("file_id = ?", fileID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRetentionRun(t *testing.T) {
+	db := newTestDB(t)
+	s := NewRetentionService(db, NewBlobService(db, nil), 24*time.Hour)
+	master := createTestUser(t, db, "master", "")
+	old := time.Now().Add(-48 * time.Hour)
+
+	completed := createTestWorkflow(t, db, "completed", master.ID, nil)
+	pruned := createVersionedFile(t, s, completed, "a.jpg", 2)
+	completeWorkflow(t, s, completed, old)
+
+	// 宽限期内的工作流保留历史版本
+	recent := createTestWorkflow(t, db, "recent", master.ID, nil)
+	kept := createVersionedFile(t, s, recent, "b.jpg", 2)
+	completeWorkflow(t, s, recent, time.Now())
+
+	report, err := s.Run(false)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// 初始版本与第一个新版本被清理，只保留当前版本
+	if report.Versions != 2 || report.Failed != 0 || len(report.Workflows) != 1 || report.Workflows[0].WorkflowID != completed.ID {
+		t.Errorf("report = %+v, want 2 versions in workflow %d", report, completed.ID)
+	}
+	if got := countVersions(t, s, pruned.ID); got != 1 {
+		t.Errorf("versions of pruned file = %d, want 1", got)
+	}
+	if got := countVersions(t, s, kept.ID); got != 3 {
+		t.Errorf("versions of kept file = %d, want 3", got)
+	}
+}
+
+func TestRetentionPruneSkipsReopenedWorkflow(t *testing.T) {
+	db := newTestDB(t)
+	s := NewRetentionService(db, NewBlobService(db, nil), 24*time.Hour)
+	master := createTestUser(t, db, "master", "")
+
+	workflow := createTestWorkflow(t, db, "reopened", master.ID, nil)
+	file := createVersionedFile(t, s, workflow, "a.jpg", 2)
+	completeWorkflow(t, s, workflow, time.Now().Add(-48*time.Hour))
+
+	// 列出待清理工作流之后、清理之前工作流被重新打开
+	if err := db.Model(workflow).Update("status", models.WorkflowStatusOpen).Error; err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.prune(workflow.ID)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if result != nil {
+		t.Errorf("prune result = %+v, want nil", result)
+	}
+	if got := countVersions(t, s, file.ID); got != 3 {
+		t.Errorf("versions = %d, want 3", got)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// searchIndexBatch 每批重建检索文档的文件数
+const searchIndexBatch = 500
+
+// searchDocumentSQL 生成并写入文件检索文档，%s 为筛选文件的条件（表别名 f）
+// 文件名按 . _ - 拆分后再次写入，使 IMG_1234.CR2 可按 1234 或 cr2 检索；
+// 权重：文件名 A，标签 B，描述、上传者及相机 C。
+const searchDocumentSQL = `
+INSERT INTO file_search_documents (file_id, tags, uploader, camera, content, document, updated_at)
+SELECT d.file_id, d.tags, d.uploader, d.camera,
+	concat_ws(' ', d.file_name, d.description, d.tags, d.uploader, d.camera),
+	setweight(to_tsvector('simple', d.file_name || ' ' || regexp_replace(d.file_name, '[._\-]+', ' ', 'g')), 'A') ||
+	setweight(to_tsvector('simple', d.tags), 'B') ||
+	setweight(to_tsvector('simple', d.description || ' ' || d.uploader || ' ' || d.camera), 'C'),
+	NOW()
+FROM (
+	SELECT f.id AS file_id,
+		COALESCE(f.file_name, '') AS file_name,
+		COALESCE(f.description, '') AS description,
+		COALESCE(t.names, '') AS tags,
+		TRIM(COALESCE(u.username, '') || ' ' || COALESCE(u.real_name, '')) AS uploader,
+		TRIM(COALESCE(m.camera_make, '') || ' ' || COALESCE(m.camera_model, '') || ' ' || COALESCE(m.lens_model, '')) AS camera
+	FROM files f
+	LEFT JOIN users u ON u.id = f.owner_id
+	LEFT JOIN file_metadata m ON m.file_id = f.id
+	LEFT JOIN (
+		SELECT ft.file_id, string_agg(tg.tag_name, ' ' ORDER BY tg.tag_name) AS names
+		FROM file_tags ft JOIN tags tg ON tg.id = ft.tag_id
+		GROUP BY ft.file_id
+	) t ON t.file_id = f.id
+	WHERE %s
+) d
+ON CONFLICT (file_id) DO UPDATE SET
+	tags = EXCLUDED.tags,
+	uploader = EXCLUDED.uploader,
+	camera = EXCLUDED.camera,
+	content = EXCLUDED.content,
+	document = EXCLUDED.document,
+	updated_at = EXCLUDED.updated_at`
+
+// SearchIndexer 维护文件全文检索文档
+// 文件上传、修改及标签变更时立即更新对应文件的检索文档；
+// 后台任务定期补建缺失或过期（文件、上传者或拍摄信息在文档生成后有修改）的文档，并删除已彻底删除文件的文档。
+type SearchIndexer struct {
+	db *gorm.DB
+}
+
+// NewSearchIndexer 创建检索文档维护服务
+func NewSearchIndexer(db *gorm.DB) *SearchIndexer {
+	return &SearchIndexer{db: db}
+}
+
+// IndexFiles 更新指定文件的检索文档
+func (s *SearchIndexer) IndexFiles(fileIDs ...uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return s.index("f.id IN ?", fileIDs)
+}
+
+// IndexTaggedFiles 更新使用了指定标签的文件的检索文档，用于标签改名或合并后
+func (s *SearchIndexer) IndexTaggedFiles(tagIDs ...uint) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	return s.index("f.id IN (SELECT file_id FROM file_tags WHERE tag_id IN ?)", tagIDs)
+}
+
+// Refresh 补建缺失或过期的检索文档，返回更新的文档数
+func (s *SearchIndexer) Refresh() (int, error) {
+	if err := s.db.Exec("DELETE FROM file_search_documents WHERE file_id NOT IN (SELECT id FROM files)").Error; err != nil {
+		return 0, fmt.Errorf("清理检索文档失败: %v", err)
+	}
+
+	total := 0
+	for {
+		var ids []uint
+		if err := s.db.Raw(`SELECT f.id FROM files f
+			LEFT JOIN file_search_documents d ON d.file_id = f.id
+			LEFT JOIN users u ON u.id = f.owner_id
+			LEFT JOIN file_metadata m ON m.file_id = f.id
+			WHERE d.file_id IS NULL OR f.updated_at > d.updated_at OR u.updated_at > d.updated_at
+				OR m.updated_at > d.updated_at
+			ORDER BY f.id LIMIT ?`, searchIndexBatch).Scan(&ids).Error; err != nil {
+			return total, fmt.Errorf("获取待更新检索文档失败: %v", err)
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		if err := s.IndexFiles(ids...); err != nil {
+			return total, err
+		}
+		total += len(ids)
+		if len(ids) < searchIndexBatch {
+			return total, nil
+		}
+	}
+}
+
+// StartIndexer 启动后台检索文档维护任务，启动时立即执行一次以建立已有文件的文档
+func (s *SearchIndexer) StartIndexer(interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if count, err := s.Refresh(); err != nil {
+				log.Printf("Warning: Failed to refresh search index: %v", err)
+			} else if count > 0 {
+				log.Printf("Search index refreshed %d documents", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// index 更新满足条件的文件的检索文档
+func (s *SearchIndexer) index(condition string, args ...interface{}) error {
+	if err := s.db.Exec(fmt.Sprintf(searchDocumentSQL, condition), args...).Error; err != nil {
+		return fmt.Errorf("更新检索文档失败: %v", err)
+	}
+	return nil
+}
+
+// indexQuietly 更新检索文档，失败时只记录日志，不影响调用方的操作结果
+func (s *SearchIndexer) indexQuietly(fileIDs ...uint) {
+	if err := s.IndexFiles(fileIDs...); err != nil {
+		log.Printf("Warning: Failed to index files %v: %v", fileIDs, err)
+	}
+}
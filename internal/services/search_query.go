@@ -0,0 +1,205 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchQuery 解析后的搜索语句
+// 支持的语法：普通关键词按全文检索匹配，引号内的内容作为整体；
+// tag:人像 按标签名称精确匹配，uploader:alice 按上传者用户名或姓名匹配，
+// camera:"Canon R5" 按相机型号匹配，before:2026-01-01 与 after:2025-12-01 按上传日期筛选。
+// 同一种条件出现多次时需同时满足，未识别的 key:value 作为普通关键词。
+type SearchQuery struct {
+	Terms     []string   `json:"terms"`
+	Tags      []string   `json:"tags"`
+	Uploaders []string   `json:"uploaders"`
+	Cameras   []string   `json:"cameras"`
+	Before    *time.Time `json:"before"` // 上传时间早于该日期（不含当天）
+	After     *time.Time `json:"after"`  // 上传时间不早于该日期（含当天）
+}
+
+// searchToken 搜索语句中的一个词，quoted 表示以引号开头，不解析为条件
+type searchToken struct {
+	text   string
+	quoted bool
+}
+
+// ParseSearchQuery 解析搜索语句
+func ParseSearchQuery(input string) (*SearchQuery, error) {
+	query := &SearchQuery{}
+	for _, token := range tokenizeSearch(input) {
+		key, value, found := strings.Cut(token.text, ":")
+		if token.quoted || !found || value == "" {
+			query.Terms = append(query.Terms, token.text)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "tag":
+			query.Tags = append(query.Tags, value)
+		case "uploader":
+			query.Uploaders = append(query.Uploaders, value)
+		case "camera":
+			query.Cameras = append(query.Cameras, value)
+		case "before", "after":
+			date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("日期格式错误，应为 YYYY-MM-DD: %s", token.text)
+			}
+			if strings.EqualFold(key, "before") {
+				query.Before = &date
+			} else {
+				query.After = &date
+			}
+		default:
+			query.Terms = append(query.Terms, token.text)
+		}
+	}
+
+	if query.Before != nil && query.After != nil && !query.After.Before(*query.Before) {
+		return nil, errors.New("after 必须早于 before")
+	}
+	return query, nil
+}
+
+// IsEmpty 是否没有任何搜索条件
+func (q *SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Tags) == 0 && len(q.Uploaders) == 0 &&
+		len(q.Cameras) == 0 && q.Before == nil && q.After == nil
+}
+
+// Text 全部普通关键词，用于全文检索排序
+func (q *SearchQuery) Text() string {
+	return strings.Join(q.Terms, " ")
+}
+
+// tokenizeSearch 按空白拆分搜索语句，引号内的空白不拆分，引号本身不保留
+func tokenizeSearch(input string) []searchToken {
+	var tokens []searchToken
+	var current strings.Builder
+	var quoted, inQuote, started bool
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			tokens = append(tokens, searchToken{text: text, quoted: quoted})
+		}
+		current.Reset()
+		quoted, started = false, false
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			if !started {
+				quoted = true
+			}
+			started = true
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			flush()
+		default:
+			started = true
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// highlightText 将文本中的关键词用 <mark> 标记（不区分大小写），其余内容做 HTML 转义
+// maxLen 大于 0 时只截取第一个匹配附近不超过 maxLen 个字符的片段；没有匹配时返回空字符串
+func highlightText(text string, terms []string, maxLen int) string {
+	runes := []rune(text)
+	lower := lowerRunes(text)
+
+	// 标记每个字符是否属于匹配
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		needle := lowerRunes(term)
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if !runesEqual(lower[i:i+len(needle)], needle) {
+				continue
+			}
+			for j := i; j < i+len(needle); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start, end := 0, len(runes)
+	if maxLen > 0 && len(runes) > maxLen {
+		start = first - maxLen/3
+		if start < 0 {
+			start = 0
+		}
+		end = start + maxLen
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxLen
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<mark>" + segment + "</mark>")
+		} else {
+			b.WriteString(segment)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// lowerRunes 逐字符转为小写，保证与原文字符一一对应
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// runesEqual 比较两个字符序列是否相同
+func runesEqual(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
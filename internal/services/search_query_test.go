@@ -0,0 +1,104 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	query, err := ParseSearchQuery(`婚礼 tag:人像 uploader:alice camera:"Canon R5" before:2026-01-01 after:2025-12-01 "a b" foo:bar`)
+	if err != nil {
+		t.Fatalf("ParseSearchQuery: %v", err)
+	}
+
+	if want := []string{"婚礼", "a b", "foo:bar"}; !reflect.DeepEqual(query.Terms, want) {
+		t.Errorf("Terms = %q, want %q", query.Terms, want)
+	}
+	if want := []string{"人像"}; !reflect.DeepEqual(query.Tags, want) {
+		t.Errorf("Tags = %q, want %q", query.Tags, want)
+	}
+	if want := []string{"alice"}; !reflect.DeepEqual(query.Uploaders, want) {
+		t.Errorf("Uploaders = %q, want %q", query.Uploaders, want)
+	}
+	if want := []string{"Canon R5"}; !reflect.DeepEqual(query.Cameras, want) {
+		t.Errorf("Cameras = %q, want %q", query.Cameras, want)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local); query.Before == nil || !query.Before.Equal(want) {
+		t.Errorf("Before = %v, want %v", query.Before, want)
+	}
+	if want := time.Date(2025, 12, 1, 0, 0, 0, 0, time.Local); query.After == nil || !query.After.Equal(want) {
+		t.Errorf("After = %v, want %v", query.After, want)
+	}
+}
+
+func TestParseSearchQueryQuotedFilter(t *testing.T) {
+	query, err := ParseSearchQuery(`"tag:人像" tag:`)
+	if err != nil {
+		t.Fatalf("ParseSearchQuery: %v", err)
+	}
+	if want := []string{"tag:人像", "tag:"}; !reflect.DeepEqual(query.Terms, want) {
+		t.Errorf("Terms = %q, want %q", query.Terms, want)
+	}
+	if len(query.Tags) != 0 {
+		t.Errorf("Tags = %q, want none", query.Tags)
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	for _, input := range []string{
+		"before:2026-13-01",
+		"after:yesterday",
+		"after:2026-01-01 before:2026-01-01",
+	} {
+		if _, err := ParseSearchQuery(input); err == nil {
+			t.Errorf("ParseSearchQuery(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestSearchQueryIsEmpty(t *testing.T) {
+	for input, want := range map[string]bool{
+		"":                 true,
+		`  "" `:            true,
+		"tag:人像":           false,
+		"keyword":          false,
+		"after:2026-01-01": false,
+	} {
+		query, err := ParseSearchQuery(input)
+		if err != nil {
+			t.Fatalf("ParseSearchQuery(%q): %v", input, err)
+		}
+		if got := query.IsEmpty(); got != want {
+			t.Errorf("IsEmpty(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got, want := escapeLike(`100%_a\b`), `100\%\_a\\b`; got != want {
+		t.Errorf("escapeLike = %q, want %q", got, want)
+	}
+}
+
+func TestHighlightText(t *testing.T) {
+	for _, tc := range []struct {
+		text   string
+		terms  []string
+		maxLen int
+		want   string
+	}{
+		{"IMG_0012.CR2", []string{"img", "cr2"}, 0, "<mark>IMG</mark>_0012.<mark>CR2</mark>"},
+		{"客户婚礼外景", []string{"婚礼"}, 0, "客户<mark>婚礼</mark>外景"},
+		{"<b>婚礼</b>", []string{"婚礼"}, 0, "&lt;b&gt;<mark>婚礼</mark>&lt;/b&gt;"},
+		{"abcabc", []string{"bca", "ab"}, 0, "<mark>abcab</mark>c"},
+		{"no match", []string{"x"}, 0, ""},
+		{"0123456789婚礼0123456789", []string{"婚礼"}, 6, "…89<mark>婚礼</mark>01…"},
+		{"婚礼0123456789", []string{"婚礼"}, 6, "<mark>婚礼</mark>0123…"},
+		{"0123456789婚礼", []string{"婚礼"}, 6, "…6789<mark>婚礼</mark>"},
+	} {
+		if got := highlightText(tc.text, tc.terms, tc.maxLen); got != tc.want {
+			t.Errorf("highlightText(%q, %q, %d) = %q, want %q", tc.text, tc.terms, tc.maxLen, got, tc.want)
+		}
+	}
+}
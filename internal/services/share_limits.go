@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 分享访问的密码尝试限制和访问计数去重，状态保存在进程内存中，多实例部署时各实例分别计算
+const (
+	// shareAttemptWindow 统计密码错误次数的时间窗口
+	shareAttemptWindow = 15 * time.Minute
+	// shareMaxFailuresPerIP 同一 IP 在时间窗口内允许的密码错误次数
+	shareMaxFailuresPerIP = 10
+	// shareViewWindow 同一访问者在该时间内重复访问（如视频播放的多次 Range 请求）只计一次访问
+	shareViewWindow = 30 * time.Minute
+	// shareTrackerPruneSize 记录数超过该值时清理过期记录
+	shareTrackerPruneSize = 1024
+)
+
+// ErrShareTooManyAttempts 密码错误次数过多，暂时拒绝访问
+var ErrShareTooManyAttempts = errors.New("密码错误次数过多，请稍后再试")
+
+// attemptLimiter 按键统计时间窗口内的失败次数，达到上限后在窗口结束前拒绝
+type attemptLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	failures map[string]*attemptRecord
+	now      func() time.Time
+}
+
+// attemptRecord 时间窗口内的失败记录
+type attemptRecord struct {
+	count int
+	start time.Time
+}
+
+// newAttemptLimiter 创建失败次数限制器
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:    limit,
+		window:   window,
+		failures: make(map[string]*attemptRecord),
+		now:      time.Now,
+	}
+}
+
+// Blocked 判断键在当前时间窗口内的失败次数是否已达上限
+func (l *attemptLimiter) Blocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.failures[key]
+	if !ok {
+		return false
+	}
+	if l.now().Sub(record.start) >= l.window {
+		delete(l.failures, key)
+		return false
+	}
+	return record.count >= l.limit
+}
+
+// Fail 记录一次失败，时间窗口从第一次失败开始计算
+func (l *attemptLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.failures) > shareTrackerPruneSize {
+		for k, record := range l.failures {
+			if now.Sub(record.start) >= l.window {
+				delete(l.failures, k)
+			}
+		}
+	}
+	record, ok := l.failures[key]
+	if !ok || now.Sub(record.start) >= l.window {
+		l.failures[key] = &attemptRecord{count: 1, start: now}
+		return
+	}
+	record.count++
+}
+
+// viewTracker 记录访问者最近一次成功访问分享的时间，用于避免重复计数
+type viewTracker struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	now    func() time.Time
+}
+
+// newViewTracker 创建访问计数去重记录
+func newViewTracker(window time.Duration) *viewTracker {
+	return &viewTracker{
+		window: window,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Recent 判断访问者是否在时间窗口内访问过
+func (t *viewTracker) Recent(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.seen[key]
+	return ok && t.now().Sub(last) < t.window
+}
+
+// Mark 记录访问者的访问时间，持续访问时时间窗口顺延
+func (t *viewTracker) Mark(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	if len(t.seen) > shareTrackerPruneSize {
+		for k, last := range t.seen {
+			if now.Sub(last) >= t.window {
+				delete(t.seen, k)
+			}
+		}
+	}
+	t.seen[key] = now
+}
+
+// shareVisitorKey 分享访问者的标识，按分享、IP 和 User-Agent 区分
+func shareVisitorKey(shareID uint, access *ShareAccess) string {
+	return fmt.Sprintf("%d|%s|%s", shareID, access.IPAddress, access.UserAgent)
+}
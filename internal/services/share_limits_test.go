@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newAttemptLimiter(3, 15*time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if l.Blocked("1.2.3.4") {
+			t.Fatalf("blocked after %d failures", i)
+		}
+		l.Fail("1.2.3.4")
+	}
+	if !l.Blocked("1.2.3.4") {
+		t.Error("not blocked after reaching the limit")
+	}
+	if l.Blocked("5.6.7.8") {
+		t.Error("other key should not be blocked")
+	}
+
+	now = now.Add(15 * time.Minute)
+	if l.Blocked("1.2.3.4") {
+		t.Error("still blocked after the window ended")
+	}
+	l.Fail("1.2.3.4")
+	if l.Blocked("1.2.3.4") {
+		t.Error("failure count should restart in a new window")
+	}
+}
+
+func TestViewTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := newViewTracker(30 * time.Minute)
+	tr.now = func() time.Time { return now }
+
+	visitor := shareVisitorKey(1, &ShareAccess{IPAddress: "1.2.3.4", UserAgent: "player"})
+	if tr.Recent(visitor) {
+		t.Fatal("new visitor reported as recent")
+	}
+	tr.Mark(visitor)
+
+	// 持续播放时每次请求都会顺延时间窗口
+	for i := 0; i < 3; i++ {
+		now = now.Add(20 * time.Minute)
+		if !tr.Recent(visitor) {
+			t.Fatalf("visitor not recent after %d requests", i+1)
+		}
+		tr.Mark(visitor)
+	}
+
+	if tr.Recent(shareVisitorKey(2, &ShareAccess{IPAddress: "1.2.3.4", UserAgent: "player"})) {
+		t.Error("visit to one share should not count for another")
+	}
+	if tr.Recent(shareVisitorKey(1, &ShareAccess{IPAddress: "1.2.3.4", UserAgent: "browser"})) {
+		t.Error("different user agent should be a new visitor")
+	}
+
+	now = now.Add(30 * time.Minute)
+	if tr.Recent(visitor) {
+		t.Error("visitor still recent after the window ended")
+	}
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// ActivityShareAccess 通过分享链接访问的活动日志类型
+const ActivityShareAccess = "share_access"
+
+var (
+	// ErrShareNotFound 分享不存在、已撤销或分享的内容已删除
+	ErrShareNotFound = errors.New("分享不存在或已失效")
+	// ErrShareExpired 分享已过期
+	ErrShareExpired = errors.New("分享已过期")
+	// ErrShareViewLimit 分享的访问次数已用完
+	ErrShareViewLimit = errors.New("分享访问次数已达上限")
+	// ErrSharePasswordRequired 分享需要密码
+	ErrSharePasswordRequired = errors.New("该分享需要密码")
+	// ErrSharePasswordInvalid 分享密码错误
+	ErrSharePasswordInvalid = errors.New("分享密码错误")
+)
+
+// ShareService 文件分享服务
+// 分享链接无需登录即可访问，密码以 bcrypt 哈希保存，每次访问（无论成功与否）都记录到活动日志。
+// 密码错误次数按 IP 限制，不按分享限制，避免他人通过故意输错密码使分享无法访问；同一访问者短时间内的重复请求只计一次访问。
+type ShareService struct {
+	db         *gorm.DB
+	files      *FileResolver
+	authz      *AuthzService
+	baseURL    string
+	ipFailures *attemptLimiter
+	views      *viewTracker
+}
+
+// NewShareService 创建文件分享服务
+func NewShareService(db *gorm.DB, files *FileResolver, baseURL string) *ShareService {
+	return &ShareService{
+		db:         db,
+		files:      files,
+		authz:      NewAuthzService(db),
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		ipFailures: newAttemptLimiter(shareMaxFailuresPerIP, shareAttemptWindow),
+		views:      newViewTracker(shareViewWindow),
+	}
+}
+
+// CreateShareRequest 创建分享请求，FileID 与 FolderID 二选一
+type CreateShareRequest struct {
+	FileID    uint       `json:"file_id"`
+	FolderID  uint       `json:"folder_id"`
+	Password  string     `json:"password" binding:"max=72"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxViews  uint       `json:"max_views"` // 0表示无限制
+}
+
+// UpdateShareRequest 更新分享请求，未传的字段保持不变
+type UpdateShareRequest struct {
+	Password  *string    `json:"password" binding:"omitempty,max=72"` // 空字符串表示取消密码
+	ExpiresAt *time.Time `json:"expires_at"`
+	NoExpiry  bool       `json:"no_expiry"` // 为 true 时取消过期时间
+	MaxViews  *uint      `json:"max_views"` // 0表示无限制
+}
+
+// ShareInfo 分享信息
+type ShareInfo struct {
+	ID          uint       `json:"id"`
+	TargetType  string     `json:"target_type"`
+	FileID      uint       `json:"file_id,omitempty"`
+	FolderID    uint       `json:"folder_id,omitempty"`
+	TargetName  string     `json:"target_name"`
+	ShareCode   string     `json:"share_code"`
+	URL         string     `json:"url"`
+	HasPassword bool       `json:"has_password"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	MaxViews    uint       `json:"max_views"`
+	ViewCount   uint       `json:"view_count"`
+	IsActive    bool       `json:"is_active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ShareAccess 分享访问者信息，用于记录活动日志
+type ShareAccess struct {
+	Password  string
+	IPAddress string
+	UserAgent string
+}
+
+// AccessShareRequest 通过 POST 访问分享时的请求体
+type AccessShareRequest struct {
+	Password string `json:"password" form:"password"`
+}
+
+// SharedContent 通过校验的分享内容，File 与 Folder 二者之一不为空
+type SharedContent struct {
+	Share  *models.FileShare
+	File   *models.File
+	Folder *models.FileFolder
+}
+
+// CreateShare 创建分享，需有分享文件或文件夹的权限
+func (s *ShareService) CreateShare(req *CreateShareRequest, userID uint) (*ShareInfo, error) {
+	if (req.FileID == 0) == (req.FolderID == 0) {
+		return nil, errors.New("必须指定文件或文件夹之一")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("过期时间必须晚于当前时间")
+	}
+
+	share := models.FileShare{
+		FileID:    req.FileID,
+		FolderID:  req.FolderID,
+		CreatorID: userID,
+		ExpiresAt: req.ExpiresAt,
+		MaxViews:  req.MaxViews,
+		IsActive:  true,
+	}
+	if req.FileID != 0 {
+		share.TargetType = models.ShareTargetFile
+		if err := s.checkFileShareable(req.FileID, userID); err != nil {
+			return nil, err
+		}
+	} else {
+		share.TargetType = models.ShareTargetFolder
+		if err := s.checkFolderShareable(req.FolderID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Password != "" {
+		hashed, err := utils.HashPassword(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("加密分享密码失败: %v", err)
+		}
+		share.Password = hashed
+	}
+
+	code, err := generateShareCode()
+	if err != nil {
+		return nil, fmt.Errorf("生成分享码失败: %v", err)
+	}
+	share.ShareCode = code
+
+	if err := s.db.Create(&share).Error; err != nil {
+		return nil, fmt.Errorf("创建分享失败: %v", err)
+	}
+
+	return s.toShareInfo(&share), nil
+}
+
+// ListShares 获取用户创建的分享列表
+func (s *ShareService) ListShares(userID uint, page, pageSize int) ([]ShareInfo, int64, error) {
+	query := s.db.Model(&models.FileShare{}).Where("creator_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取分享总数失败: %v", err)
+	}
+
+	var shares []models.FileShare
+	if err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&shares).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取分享列表失败: %v", err)
+	}
+
+	infos := make([]ShareInfo, 0, len(shares))
+	for i := range shares {
+		infos = append(infos, *s.toShareInfo(&shares[i]))
+	}
+	return infos, total, nil
+}
+
+// UpdateShare 更新分享的密码、过期时间或访问次数限制（仅分享创建者）
+func (s *ShareService) UpdateShare(shareID uint, req *UpdateShareRequest, userID uint) (*ShareInfo, error) {
+	share, err := s.ownShare(shareID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !share.IsActive {
+		return nil, errors.New("分享已撤销，无法修改")
+	}
+
+	updates := make(map[string]interface{})
+	if req.Password != nil {
+		updates["password"] = ""
+		if *req.Password != "" {
+			hashed, err := utils.HashPassword(*req.Password)
+			if err != nil {
+				return nil, fmt.Errorf("加密分享密码失败: %v", err)
+			}
+			updates["password"] = hashed
+		}
+	}
+	if req.NoExpiry {
+		updates["expires_at"] = nil
+	} else if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, errors.New("过期时间必须晚于当前时间")
+		}
+		updates["expires_at"] = req.ExpiresAt
+	}
+	if req.MaxViews != nil {
+		updates["max_views"] = *req.MaxViews
+	}
+	if len(updates) == 0 {
+		return s.toShareInfo(share), nil
+	}
+
+	if err := s.db.Model(share).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新分享失败: %v", err)
+	}
+	if err := s.db.First(share, shareID).Error; err != nil {
+		return nil, fmt.Errorf("获取分享失败: %v", err)
+	}
+	return s.toShareInfo(share), nil
+}
+
+// RevokeShare 撤销分享（仅分享创建者），撤销后链接立即失效，记录保留用于审计
+func (s *ShareService) RevokeShare(shareID uint, userID uint) error {
+	share, err := s.ownShare(shareID, userID)
+	if err != nil {
+		return err
+	}
+	if !share.IsActive {
+		return nil
+	}
+
+	if err := s.db.Model(share).Update("is_active", false).Error; err != nil {
+		return fmt.Errorf("撤销分享失败: %v", err)
+	}
+	return nil
+}
+
+// OpenShare 校验分享链接的有效期、访问次数及密码，通过后计入一次访问
+// 同一访问者在 shareViewWindow 内的重复请求（如视频播放的 Range 请求）不重复计数，也不受访问次数上限限制；
+// 每次访问的结果都记录到活动日志
+func (s *ShareService) OpenShare(code string, access *ShareAccess) (*SharedContent, error) {
+	content, err := s.openShare(code, access)
+	s.logAccess(code, content, access, err)
+	return content, err
+}
+
+// openShare 校验分享并获取分享内容，校验失败时仍返回已找到的分享用于记录日志
+func (s *ShareService) openShare(code string, access *ShareAccess) (*SharedContent, error) {
+	var share models.FileShare
+	if err := s.db.Where("share_code = ?", code).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("获取分享失败: %v", err)
+	}
+	content := &SharedContent{Share: &share}
+
+	if !share.IsActive {
+		return content, ErrShareNotFound
+	}
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		return content, ErrShareExpired
+	}
+	visitor := shareVisitorKey(share.ID, access)
+	revisit := s.views.Recent(visitor)
+	if !revisit && share.MaxViews > 0 && share.ViewCount >= share.MaxViews {
+		return content, ErrShareViewLimit
+	}
+	if share.Password != "" {
+		if s.ipFailures.Blocked(access.IPAddress) {
+			return content, ErrShareTooManyAttempts
+		}
+		if access.Password == "" {
+			return content, ErrSharePasswordRequired
+		}
+		if !utils.CheckPassword(access.Password, share.Password) {
+			s.ipFailures.Fail(access.IPAddress)
+			return content, ErrSharePasswordInvalid
+		}
+	}
+
+	if share.TargetType == models.ShareTargetFolder {
+		var folder models.FileFolder
+		if err := s.db.Where("id = ? AND is_deleted = false", share.FolderID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return content, ErrShareNotFound
+			}
+			return content, fmt.Errorf("获取文件夹失败: %v", err)
+		}
+		if err := creatorAccessError(s.authz.CheckFolder(share.CreatorID, &folder, ActionShare)); err != nil {
+			return content, err
+		}
+		content.Folder = &folder
+	} else {
+		var file models.File
+		if err := s.db.Where("id = ? AND is_deleted = false", share.FileID).First(&file).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return content, ErrShareNotFound
+			}
+			return content, fmt.Errorf("获取文件失败: %v", err)
+		}
+		if err := creatorAccessError(s.authz.CheckFile(share.CreatorID, &file, ActionShare)); err != nil {
+			return content, err
+		}
+		content.File = &file
+	}
+
+	if revisit {
+		s.views.Mark(visitor)
+		return content, nil
+	}
+
+	// 条件更新，并发访问时不会超出访问次数限制
+	result := s.db.Model(&models.FileShare{}).
+		Where("id = ? AND is_active = true AND (max_views = 0 OR view_count < max_views)", share.ID).
+		UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+	if result.Error != nil {
+		return content, fmt.Errorf("更新分享访问次数失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return content, ErrShareViewLimit
+	}
+	s.views.Mark(visitor)
+
+	return content, nil
+}
+
+// OpenFile 打开分享文件的内容，内容不存在时返回 storage.ErrNotExist
+func (s *ShareService) OpenFile(file *models.File) (*FileContent, error) {
+	return s.files.Open(file)
+}
+
+// WriteFolderZip 将分享的文件夹（含子文件夹）打包为 ZIP 写入 w
+// 只包含分享创建者有权查看的文件
+func (s *ShareService) WriteFolderZip(w io.Writer, share *models.FileShare, folder *models.FileFolder) error {
+	// 逐层获取子文件夹，记录各文件夹在 ZIP 中的相对路径
+	dirs := map[uint]string{folder.ID: ""}
+	level := []uint{folder.ID}
+	for len(level) > 0 {
+		var children []models.FileFolder
+		if err := s.db.Where("parent_id IN ? AND workflow_id = ? AND is_deleted = false", level, folder.WorkflowID).
+			Find(&children).Error; err != nil {
+			return fmt.Errorf("获取子文件夹失败: %v", err)
+		}
+
+		level = level[:0]
+		for _, child := range children {
+			if _, ok := dirs[child.ID]; ok {
+				continue
+			}
+			dirs[child.ID] = path.Join(dirs[child.ParentID], sanitizeZipName(child.Name))
+			level = append(level, child.ID)
+		}
+	}
+
+	folderIDs := make([]uint, 0, len(dirs))
+	for id := range dirs {
+		folderIDs = append(folderIDs, id)
+	}
+
+	visible, err := s.authz.VisibleFiles(share.CreatorID, "")
+	if err != nil {
+		return err
+	}
+	var files []models.File
+	if err := s.db.Scopes(visible).Where("folder_id IN ? AND is_deleted = false", folderIDs).
+		Order("folder_id, file_name, id").Find(&files).Error; err != nil {
+		return fmt.Errorf("获取文件列表失败: %v", err)
+	}
+
+	zipWriter := zip.NewWriter(w)
+	used := make(map[string]bool)
+	for _, file := range files {
+		name := uniqueZipName(path.Join(dirs[file.FolderID], sanitizeZipName(file.FileName)), used)
+		if err := s.addToZip(zipWriter, name, &file); err != nil {
+			return fmt.Errorf("添加文件到ZIP失败: %v", err)
+		}
+	}
+	return zipWriter.Close()
+}
+
+// addToZip 从文件存储读取文件内容并写入 ZIP 条目
+func (s *ShareService) addToZip(zipWriter *zip.Writer, name string, file *models.File) error {
+	content, err := s.files.Reader(file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	entry, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: file.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, content)
+	return err
+}
+
+// logAccess 记录分享访问的活动日志
+func (s *ShareService) logAccess(code string, content *SharedContent, access *ShareAccess, accessErr error) {
+	activity := models.ActivityLog{
+		Action:     ActivityShareAccess,
+		TargetType: "share",
+		Details:    models.JSONField{"share_code": code},
+		IPAddress:  access.IPAddress,
+		UserAgent:  access.UserAgent,
+		Success:    accessErr == nil,
+	}
+	if len(activity.UserAgent) > 500 {
+		activity.UserAgent = activity.UserAgent[:500]
+	}
+	if content != nil {
+		share := content.Share
+		activity.Details["share_id"] = share.ID
+		activity.Details["creator_id"] = share.CreatorID
+		activity.TargetType = share.TargetType
+		activity.TargetID = share.FileID
+		if share.TargetType == models.ShareTargetFolder {
+			activity.TargetID = share.FolderID
+		}
+	}
+	if accessErr != nil {
+		activity.ErrorMsg = accessErr.Error()
+	}
+
+	if err := s.db.Create(&activity).Error; err != nil {
+		log.Printf("Warning: Failed to log share access: %v", err)
+	}
+}
+
+// ownShare 获取用户创建的分享
+func (s *ShareService) ownShare(shareID uint, userID uint) (*models.FileShare, error) {
+	var share models.FileShare
+	if err := s.db.Where("id = ? AND creator_id = ?", shareID, userID).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("分享不存在或无权限操作")
+		}
+		return nil, fmt.Errorf("获取分享失败: %v", err)
+	}
+	return &share, nil
+}
+
+// checkFileShareable 检查用户能否分享文件
+func (s *ShareService) checkFileShareable(fileID uint, userID uint) error {
+	var file models.File
+	if err := s.db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("文件不存在")
+		}
+		return fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	if err := s.authz.CheckFile(userID, &file, ActionShare); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return fmt.Errorf("无权限分享该文件: %w", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkFolderShareable 检查用户能否分享文件夹
+func (s *ShareService) checkFolderShareable(folderID uint, userID uint) error {
+	var folder models.FileFolder
+	if err := s.db.Where("id = ? AND is_deleted = false", folderID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("文件夹不存在")
+		}
+		return fmt.Errorf("获取文件夹信息失败: %v", err)
+	}
+	if err := s.authz.CheckFolder(userID, &folder, ActionShare); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return fmt.Errorf("无权限分享该文件夹: %w", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// creatorAccessError 分享创建者已失去分享权限时分享失效
+func creatorAccessError(err error) error {
+	if errors.Is(err, ErrPermissionDenied) {
+		return ErrShareNotFound
+	}
+	return err
+}
+
+// toShareInfo 转换为分享信息
+func (s *ShareService) toShareInfo(share *models.FileShare) *ShareInfo {
+	info := &ShareInfo{
+		ID:          share.ID,
+		TargetType:  share.TargetType,
+		FileID:      share.FileID,
+		FolderID:    share.FolderID,
+		ShareCode:   share.ShareCode,
+		URL:         s.baseURL + "/s/" + share.ShareCode,
+		HasPassword: share.Password != "",
+		ExpiresAt:   share.ExpiresAt,
+		MaxViews:    share.MaxViews,
+		ViewCount:   share.ViewCount,
+		IsActive:    share.IsActive,
+		CreatedAt:   share.CreatedAt,
+		UpdatedAt:   share.UpdatedAt,
+	}
+
+	if share.TargetType == models.ShareTargetFolder {
+		s.db.Model(&models.FileFolder{}).Where("id = ?", share.FolderID).Pluck("name", &info.TargetName)
+	} else {
+		s.db.Model(&models.File{}).Where("id = ?", share.FileID).Pluck("file_name", &info.TargetName)
+	}
+	return info
+}
+
+// generateShareCode 生成随机分享码（16个URL安全字符）
+func generateShareCode() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sanitizeZipName 去除名称中的路径分隔符，避免 ZIP 条目逃逸出目标目录
+func sanitizeZipName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// uniqueZipName 为重名的 ZIP 条目添加序号，如 a.jpg、a (1).jpg
+func uniqueZipName(name string, used map[string]bool) string {
+	candidate := name
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
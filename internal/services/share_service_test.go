@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestGenerateShareCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := generateShareCode()
+		if err != nil {
+			t.Fatalf("generateShareCode: %v", err)
+		}
+		if len(code) != 16 {
+			t.Errorf("len(%q) = %d, want 16", code, len(code))
+		}
+		if seen[code] {
+			t.Fatalf("duplicate share code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestSanitizeZipName(t *testing.T) {
+	for name, want := range map[string]string{
+		"photo.jpg":        "photo.jpg",
+		"../../etc/passwd": ".._.._etc_passwd",
+		`a\b.txt`:          "a_b.txt",
+		"..":               "_",
+		"":                 "_",
+	} {
+		if got := sanitizeZipName(name); got != want {
+			t.Errorf("sanitizeZipName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUniqueZipName(t *testing.T) {
+	used := make(map[string]bool)
+	for _, want := range []string{"raw/a.cr2", "raw/a (1).cr2", "raw/a (2).cr2"} {
+		if got := uniqueZipName("raw/a.cr2", used); got != want {
+			t.Errorf("uniqueZipName = %q, want %q", got, want)
+		}
+	}
+	if got := uniqueZipName("raw/b.cr2", used); got != "raw/b.cr2" {
+		t.Errorf("uniqueZipName = %q, want raw/b.cr2", got)
+	}
+}
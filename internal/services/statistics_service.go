@@ -4,17 +4,24 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"mcs-backend/internal/models"
 )
 
 type StatisticsService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	capacity int64 // 存储总容量（字节），0 表示未配置
 }
 
 func NewStatisticsService(db *gorm.DB) *StatisticsService {
 	return &StatisticsService{db: db}
 }
 
+// SetStorageCapacity 设置存储总容量（字节），用于计算存储使用率
+func (s *StatisticsService) SetStorageCapacity(capacity int64) {
+	s.capacity = capacity
+}
+
 // LogOperation 记录操作日志
 func (s *StatisticsService) LogOperation(userID uint, action, resource string, resourceID *uint, description, ipAddress, userAgent string) error {
 	log := models.OperationLog{
@@ -152,13 +159,13 @@ func (s *StatisticsService) UpdateStorageStats() error {
 	var totalFiles int64
 	var totalSize int64
 	s.db.Model(&models.File{}).Count(&totalFiles)
-	s.db.Model(&models.File{}).Select("COALESCE(SUM(size), 0)").Scan(&totalSize)
+	s.db.Model(&models.File{}).Select("COALESCE(SUM(file_size), 0)").Scan(&totalSize)
 
 	// 计算当日上传文件数和大小
 	var uploadedFiles int64
 	var uploadedSize int64
 	s.db.Model(&models.File{}).Where("created_at >= ? AND created_at < ?", today, today.AddDate(0, 0, 1)).Count(&uploadedFiles)
-	s.db.Model(&models.File{}).Where("created_at >= ? AND created_at < ?", today, today.AddDate(0, 0, 1)).Select("COALESCE(SUM(size), 0)").Scan(&uploadedSize)
+	s.db.Model(&models.File{}).Where("created_at >= ? AND created_at < ?", today, today.AddDate(0, 0, 1)).Select("COALESCE(SUM(file_size), 0)").Scan(&uploadedSize)
 
 	// 计算当日删除文件数（从操作日志中统计）
 	var deletedFiles int64
@@ -177,12 +184,35 @@ func (s *StatisticsService) UpdateStorageStats() error {
 		UploadedSize:  uploadedSize,
 		DeletedFiles:  deletedFiles,
 		ActiveUsers:   activeUsers,
-		StorageUsage:  0, // 需要根据实际存储配置计算
+		StorageUsage:  models.GetStorageUsagePercent(totalSize, s.capacity),
 	}
 
 	return s.db.Where("date = ?", today).Assign(stats).FirstOrCreate(&stats).Error
 }
 
+// RecordReclaimedStorage 累加当日清理过期上传及无引用文件内容回收的存储空间
+func (s *StatisticsService) RecordReclaimedStorage(size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	stats := models.StorageStats{
+		Date:          today,
+		ReclaimedSize: size,
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"reclaimed_size": gorm.Expr("storage_stats.reclaimed_size + ?", size),
+			"updated_at":     now,
+		}),
+	}).Create(&stats).Error
+}
+
 // GetUserActivityStats 获取用户活跃度统计
 type UserActivityRequest struct {
 	UserID    *uint     `json:"user_id"`
@@ -319,7 +349,7 @@ func (s *StatisticsService) GetSystemOverview() (*SystemOverview, error) {
 
 	// 总文件数和大小
 	s.db.Model(&models.File{}).Count(&overview.TotalFiles)
-	s.db.Model(&models.File{}).Select("COALESCE(SUM(size), 0)").Scan(&overview.TotalSize)
+	s.db.Model(&models.File{}).Select("COALESCE(SUM(file_size), 0)").Scan(&overview.TotalSize)
 
 	// 总工作流数
 	s.db.Model(&models.Workflow{}).Count(&overview.TotalWorkflows)
@@ -331,8 +361,8 @@ func (s *StatisticsService) GetSystemOverview() (*SystemOverview, error) {
 	s.db.Model(&models.OperationLog{}).Where("action = ? AND created_at >= ?", "upload", today).Count(&overview.TodayUploads)
 	s.db.Model(&models.OperationLog{}).Where("action = ? AND created_at >= ?", "download", today).Count(&overview.TodayDownloads)
 
-	// 存储使用率（这里需要根据实际配置计算）
-	overview.StorageUsage = 0.0
+	// 存储使用率
+	overview.StorageUsage = models.GetStorageUsagePercent(overview.TotalSize, s.capacity)
 
 	return overview, nil
 }
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// tagColorPattern 标签颜色格式，如 #409EFF
+var tagColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// TagService 标签服务
+// 标签为全局标签，由管理员统一创建、修改、合并和删除，所有用户都可以用来标记自己的文件。
+type TagService struct {
+	db     *gorm.DB
+	search *SearchIndexer
+	authz  *AuthzService
+}
+
+// NewTagService 创建标签服务
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{db: db, search: NewSearchIndexer(db), authz: NewAuthzService(db)}
+}
+
+// TagRequest 创建或更新标签请求，更新时未传的字段保持不变
+type TagRequest struct {
+	TagName string `json:"tag_name" binding:"max=50"`
+	Color   string `json:"color"`
+}
+
+// MergeTagsRequest 合并标签请求，将 SourceID 合并到 TargetID
+type MergeTagsRequest struct {
+	SourceID uint `json:"source_id" binding:"required"`
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// BulkTagRequest 批量添加或移除标签请求
+type BulkTagRequest struct {
+	TagIDs  []uint `json:"tag_ids" binding:"required,min=1"`
+	FileIDs []uint `json:"file_ids" binding:"required,min=1,max=1000"`
+}
+
+// TagDetail 标签及使用次数
+type TagDetail struct {
+	ID        uint   `json:"id"`
+	TagName   string `json:"tag_name"`
+	Color     string `json:"color"`
+	CreaterID uint   `json:"creater_id"`
+	FileCount int64  `json:"file_count"` // 使用该标签的未删除文件数
+}
+
+// BulkTagResult 批量添加或移除标签结果
+type BulkTagResult struct {
+	Files   int    `json:"files"`   // 实际处理的文件数
+	Changed int64  `json:"changed"` // 新增或移除的文件标签关联数
+	Skipped []uint `json:"skipped"` // 不存在或无权限修改的文件
+}
+
+// ListTags 获取标签列表，按使用次数降序排列
+func (s *TagService) ListTags(keyword string) ([]TagDetail, error) {
+	query := s.tagDetails()
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		query = query.Where("tags.tag_name ILIKE ?", "%"+keyword+"%")
+	}
+
+	tags := make([]TagDetail, 0)
+	if err := query.Order("file_count DESC, tags.tag_name").Scan(&tags).Error; err != nil {
+		return nil, fmt.Errorf("获取标签列表失败: %v", err)
+	}
+	return tags, nil
+}
+
+// GetTag 获取标签详情
+func (s *TagService) GetTag(tagID uint) (*TagDetail, error) {
+	var tags []TagDetail
+	if err := s.tagDetails().Where("tags.id = ?", tagID).Scan(&tags).Error; err != nil {
+		return nil, fmt.Errorf("获取标签失败: %v", err)
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("标签不存在")
+	}
+	return &tags[0], nil
+}
+
+// CreateTag 创建标签（管理员）
+func (s *TagService) CreateTag(req *TagRequest, userID uint) (*TagDetail, error) {
+	name := strings.TrimSpace(req.TagName)
+	if name == "" {
+		return nil, errors.New("标签名称不能为空")
+	}
+	tag := models.Tag{
+		TagName:   name,
+		CreaterID: userID,
+		Color:     "#409EFF",
+	}
+	if req.Color != "" {
+		if !tagColorPattern.MatchString(req.Color) {
+			return nil, errors.New("标签颜色格式错误，应为 #RRGGBB")
+		}
+		tag.Color = req.Color
+	}
+	if err := s.checkNameAvailable(name, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&tag).Error; err != nil {
+		return nil, fmt.Errorf("创建标签失败: %v", err)
+	}
+	return s.GetTag(tag.ID)
+}
+
+// UpdateTag 重命名标签或修改标签颜色（管理员）
+func (s *TagService) UpdateTag(tagID uint, req *TagRequest) (*TagDetail, error) {
+	var tag models.Tag
+	if err := s.db.First(&tag, tagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("标签不存在")
+		}
+		return nil, fmt.Errorf("获取标签失败: %v", err)
+	}
+
+	updates := make(map[string]interface{})
+	if name := strings.TrimSpace(req.TagName); name != "" && name != tag.TagName {
+		if err := s.checkNameAvailable(name, tag.ID); err != nil {
+			return nil, err
+		}
+		updates["tag_name"] = name
+	}
+	if req.Color != "" {
+		if !tagColorPattern.MatchString(req.Color) {
+			return nil, errors.New("标签颜色格式错误，应为 #RRGGBB")
+		}
+		updates["color"] = req.Color
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&tag).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("更新标签失败: %v", err)
+		}
+		if _, renamed := updates["tag_name"]; renamed {
+			s.reindexTags(tag.ID)
+		}
+	}
+	return s.GetTag(tag.ID)
+}
+
+// DeleteTag 删除标签及其与文件的关联（管理员）
+func (s *TagService) DeleteTag(tagID uint) error {
+	var fileIDs []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Tag{}, tagID)
+		if result.Error != nil {
+			return fmt.Errorf("删除标签失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("标签不存在")
+		}
+		if err := tx.Model(&models.FileTag{}).Where("tag_id = ?", tagID).Pluck("file_id", &fileIDs).Error; err != nil {
+			return fmt.Errorf("获取文件标签失败: %v", err)
+		}
+		if err := tx.Where("tag_id = ?", tagID).Delete(&models.FileTag{}).Error; err != nil {
+			return fmt.Errorf("删除文件标签失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.search.indexQuietly(fileIDs...)
+	return nil
+}
+
+// MergeTags 将源标签合并到目标标签（管理员）
+// 使用源标签的文件改为使用目标标签，已有目标标签的文件不会重复关联，合并后删除源标签
+func (s *TagService) MergeTags(req *MergeTagsRequest) (*TagDetail, error) {
+	if req.SourceID == req.TargetID {
+		return nil, errors.New("不能将标签合并到自身")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Tag{}).Where("id IN ?", []uint{req.SourceID, req.TargetID}).Count(&count).Error; err != nil {
+			return fmt.Errorf("获取标签失败: %v", err)
+		}
+		if count != 2 {
+			return errors.New("标签不存在")
+		}
+
+		// 已有目标标签的文件直接删除源标签关联
+		if err := tx.Where("tag_id = ? AND file_id IN (?)", req.SourceID,
+			tx.Model(&models.FileTag{}).Select("file_id").Where("tag_id = ?", req.TargetID)).
+			Delete(&models.FileTag{}).Error; err != nil {
+			return fmt.Errorf("合并文件标签失败: %v", err)
+		}
+		if err := tx.Model(&models.FileTag{}).Where("tag_id = ?", req.SourceID).
+			Update("tag_id", req.TargetID).Error; err != nil {
+			return fmt.Errorf("合并文件标签失败: %v", err)
+		}
+		if err := tx.Delete(&models.Tag{}, req.SourceID).Error; err != nil {
+			return fmt.Errorf("删除源标签失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.reindexTags(req.TargetID)
+	return s.GetTag(req.TargetID)
+}
+
+// TagFiles 为多个文件批量添加标签，只处理用户有修改权限的文件，已有的标签不会重复添加
+func (s *TagService) TagFiles(req *BulkTagRequest, userID uint) (*BulkTagResult, error) {
+	tagIDs, err := s.existingTags(req.TagIDs)
+	if err != nil {
+		return nil, err
+	}
+	fileIDs, result, err := s.editableFiles(req.FileIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileIDs) == 0 {
+		return result, nil
+	}
+
+	insert := s.db.Exec(`INSERT INTO file_tags (tag_id, file_id)
+		SELECT tags.id, files.id FROM tags CROSS JOIN files
+		WHERE tags.id IN ? AND files.id IN ?
+		AND NOT EXISTS (SELECT 1 FROM file_tags WHERE file_tags.tag_id = tags.id AND file_tags.file_id = files.id)
+		ON CONFLICT DO NOTHING`, tagIDs, fileIDs)
+	if insert.Error != nil {
+		return nil, fmt.Errorf("添加文件标签失败: %v", insert.Error)
+	}
+	result.Changed = insert.RowsAffected
+	s.search.indexQuietly(fileIDs...)
+	return result, nil
+}
+
+// UntagFiles 从多个文件批量移除标签，只处理用户有修改权限的文件
+func (s *TagService) UntagFiles(req *BulkTagRequest, userID uint) (*BulkTagResult, error) {
+	fileIDs, result, err := s.editableFiles(req.FileIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileIDs) == 0 {
+		return result, nil
+	}
+
+	remove := s.db.Where("tag_id IN ? AND file_id IN ?", req.TagIDs, fileIDs).Delete(&models.FileTag{})
+	if remove.Error != nil {
+		return nil, fmt.Errorf("移除文件标签失败: %v", remove.Error)
+	}
+	result.Changed = remove.RowsAffected
+	s.search.indexQuietly(fileIDs...)
+	return result, nil
+}
+
+// reindexTags 标签名称变化后更新使用该标签的文件的检索文档，失败时只记录日志
+func (s *TagService) reindexTags(tagIDs ...uint) {
+	if err := s.search.IndexTaggedFiles(tagIDs...); err != nil {
+		log.Printf("Warning: Failed to index files tagged %v: %v", tagIDs, err)
+	}
+}
+
+// tagDetails 标签及使用次数查询
+func (s *TagService) tagDetails() *gorm.DB {
+	return s.db.Table("tags").
+		Select("tags.id, tags.tag_name, tags.color, tags.creater_id, COUNT(files.id) AS file_count").
+		Joins("LEFT JOIN file_tags ON file_tags.tag_id = tags.id").
+		Joins("LEFT JOIN files ON files.id = file_tags.file_id AND files.is_deleted = false").
+		Group("tags.id")
+}
+
+// checkNameAvailable 检查标签名称是否已被其他标签使用（不区分大小写）
+func (s *TagService) checkNameAvailable(name string, excludeID uint) error {
+	var count int64
+	if err := s.db.Model(&models.Tag{}).Where("LOWER(tag_name) = LOWER(?) AND id <> ?", name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查标签名称失败: %v", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("标签 %s 已存在", name)
+	}
+	return nil
+}
+
+// existingTags 检查标签是否都存在
+func (s *TagService) existingTags(tagIDs []uint) ([]uint, error) {
+	var ids []uint
+	if err := s.db.Model(&models.Tag{}).Where("id IN ?", tagIDs).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("获取标签失败: %v", err)
+	}
+	if len(ids) != len(uniqueIDs(tagIDs)) {
+		return nil, errors.New("标签不存在")
+	}
+	return ids, nil
+}
+
+// editableFiles 筛选出用户可以修改标签的文件，规则与 CheckFile(ActionEdit) 相同，其余文件记入跳过列表
+func (s *TagService) editableFiles(fileIDs []uint, userID uint) ([]uint, *BulkTagResult, error) {
+	fileIDs = uniqueIDs(fileIDs)
+
+	visible, err := s.authz.VisibleFiles(userID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []models.File
+	if err := s.db.Select("id, owner_id, workflow_id, task_id, is_private").Scopes(visible).
+		Where("id IN ? AND is_deleted = false", fileIDs).Find(&files).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取文件失败: %v", err)
+	}
+
+	checker := s.authz.newBatchChecker(userID)
+	allowed := make(map[uint]bool, len(files))
+	for i := range files {
+		err := checker.File(&files[i], ActionEdit)
+		if err == nil {
+			allowed[files[i].ID] = true
+		} else if !errors.Is(err, ErrPermissionDenied) && !errors.Is(err, ErrWorkflowAccessDenied) {
+			return nil, nil, err
+		}
+	}
+
+	editable := make([]uint, 0, len(allowed))
+	result := &BulkTagResult{Skipped: []uint{}}
+	for _, id := range fileIDs {
+		if allowed[id] {
+			editable = append(editable, id)
+		} else {
+			result.Skipped = append(result.Skipped, id)
+		}
+	}
+	result.Files = len(editable)
+	return editable, result, nil
+}
+
+// uniqueIDs 去除重复的ID，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
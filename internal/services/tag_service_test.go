@@ -0,0 +1,162 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+func TestUniqueIDs(t *testing.T) {
+	got := uniqueIDs([]uint{3, 1, 3, 2, 1})
+	if want := []uint{3, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueIDs = %v, want %v", got, want)
+	}
+	if got := uniqueIDs(nil); len(got) != 0 {
+		t.Errorf("uniqueIDs(nil) = %v, want empty", got)
+	}
+}
+
+func TestTagColorPattern(t *testing.T) {
+	for color, valid := range map[string]bool{
+		"#409EFF":  true,
+		"#ff6b6b":  true,
+		"409EFF":   false,
+		"#FFF":     false,
+		"#GGGGGG":  false,
+		"#409EFF0": false,
+	} {
+		if got := tagColorPattern.MatchString(color); got != valid {
+			t.Errorf("tagColorPattern.MatchString(%q) = %v, want %v", color, got, valid)
+		}
+	}
+}
+
+// createTestTag 创建标签并关联到指定文件
+func createTestTag(t *testing.T, db *gorm.DB, name string, fileIDs ...uint) *models.Tag {
+	t.Helper()
+	tag := &models.Tag{TagName: name, CreaterID: 1}
+	if err := db.Create(tag).Error; err != nil {
+		t.Fatalf("create tag %s: %v", name, err)
+	}
+	for _, fileID := range fileIDs {
+		if err := db.Create(&models.FileTag{TagID: tag.ID, FileID: fileID}).Error; err != nil {
+			t.Fatalf("tag file: %v", err)
+		}
+	}
+	return tag
+}
+
+// taggedFiles 返回关联了标签的文件ID
+func taggedFiles(t *testing.T, db *gorm.DB, tagID uint) []uint {
+	t.Helper()
+	var fileIDs []uint
+	if err := db.Model(&models.FileTag{}).Where("tag_id = ?", tagID).Order("file_id").Pluck("file_id", &fileIDs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return fileIDs
+}
+
+func TestMergeTags(t *testing.T) {
+	db := newTestDB(t)
+	s := NewTagService(db)
+	owner := createTestUser(t, db, "owner", "")
+	a := createTestFile(t, db, &models.File{FileName: "a.jpg", OwnerID: owner.ID})
+	b := createTestFile(t, db, &models.File{FileName: "b.jpg", OwnerID: owner.ID})
+	c := createTestFile(t, db, &models.File{FileName: "c.jpg", OwnerID: owner.ID})
+
+	// 文件 b 同时有源标签和目标标签，合并后只保留一个关联
+	source := createTestTag(t, db, "wedding", a.ID, b.ID)
+	target := createTestTag(t, db, "Wedding", b.ID, c.ID)
+
+	if _, err := s.MergeTags(&MergeTagsRequest{SourceID: source.ID, TargetID: source.ID}); err == nil {
+		t.Error("merge into itself succeeded, want error")
+	}
+
+	merged, err := s.MergeTags(&MergeTagsRequest{SourceID: source.ID, TargetID: target.ID})
+	if err != nil {
+		t.Fatalf("MergeTags: %v", err)
+	}
+	if merged.ID != target.ID || merged.FileCount != 3 {
+		t.Errorf("merged = %+v, want tag %d with 3 files", merged, target.ID)
+	}
+	if got, want := taggedFiles(t, db, target.ID), []uint{a.ID, b.ID, c.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("target files = %v, want %v", got, want)
+	}
+	if got := taggedFiles(t, db, source.ID); len(got) != 0 {
+		t.Errorf("source files = %v, want none", got)
+	}
+	var count int64
+	if err := db.Model(&models.Tag{}).Where("id = ?", source.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("source tag still exists after merge")
+	}
+}
+
+func TestTagFilesSkipsUneditableFiles(t *testing.T) {
+	db := newTestDB(t)
+	s := NewTagService(db)
+	owner := createTestUser(t, db, "owner", "")
+	other := createTestUser(t, db, "other", "")
+	own := createTestFile(t, db, &models.File{FileName: "own.jpg", OwnerID: owner.ID})
+	tagged := createTestFile(t, db, &models.File{FileName: "tagged.jpg", OwnerID: owner.ID})
+	foreign := createTestFile(t, db, &models.File{FileName: "foreign.jpg", OwnerID: other.ID, IsPrivate: false})
+	tag := createTestTag(t, db, "travel", tagged.ID)
+	const missing = 9999
+
+	result, err := s.TagFiles(&BulkTagRequest{TagIDs: []uint{tag.ID}, FileIDs: []uint{own.ID, tagged.ID, foreign.ID, missing, own.ID}}, owner.ID)
+	if err != nil {
+		t.Fatalf("TagFiles: %v", err)
+	}
+	// 已有的关联不重复添加，其他用户的文件和不存在的文件被跳过
+	if result.Files != 2 || result.Changed != 1 || !reflect.DeepEqual(result.Skipped, []uint{foreign.ID, missing}) {
+		t.Errorf("TagFiles = %+v, want 2 files, 1 changed, skipped [%d %d]", result, foreign.ID, missing)
+	}
+	if got, want := taggedFiles(t, db, tag.ID), []uint{own.ID, tagged.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tagged files = %v, want %v", got, want)
+	}
+
+	if err := db.Create(&models.FileTag{TagID: tag.ID, FileID: foreign.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	result, err = s.UntagFiles(&BulkTagRequest{TagIDs: []uint{tag.ID}, FileIDs: []uint{own.ID, foreign.ID}}, owner.ID)
+	if err != nil {
+		t.Fatalf("UntagFiles: %v", err)
+	}
+	if result.Files != 1 || result.Changed != 1 || !reflect.DeepEqual(result.Skipped, []uint{foreign.ID}) {
+		t.Errorf("UntagFiles = %+v, want 1 file, 1 changed, skipped [%d]", result, foreign.ID)
+	}
+	if got, want := taggedFiles(t, db, tag.ID), []uint{tagged.ID, foreign.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tagged files = %v, want %v", got, want)
+	}
+
+	if _, err := s.TagFiles(&BulkTagRequest{TagIDs: []uint{missing}, FileIDs: []uint{own.ID}}, owner.ID); err == nil {
+		t.Error("TagFiles with missing tag succeeded, want error")
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	db := newTestDB(t)
+	s := NewTagService(db)
+	owner := createTestUser(t, db, "owner", "")
+	file := createTestFile(t, db, &models.File{FileName: "a.jpg", OwnerID: owner.ID})
+	tag := createTestTag(t, db, "travel", file.ID)
+	kept := createTestTag(t, db, "family", file.ID)
+
+	if err := s.DeleteTag(tag.ID); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	if got := taggedFiles(t, db, tag.ID); len(got) != 0 {
+		t.Errorf("files of deleted tag = %v, want none", got)
+	}
+	if got := taggedFiles(t, db, kept.ID); !reflect.DeepEqual(got, []uint{file.ID}) {
+		t.Errorf("files of other tag = %v, want [%d]", got, file.ID)
+	}
+	if err := s.DeleteTag(tag.ID); err == nil {
+		t.Error("deleting a deleted tag succeeded, want error")
+	}
+}
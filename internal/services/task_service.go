@@ -16,16 +16,45 @@ import (
 type TaskService struct {
 	db     *gorm.DB
 	config *config.Config
+	authz  *AuthzService
 }
 
 // NewTaskService 创建任务服务
 func NewTaskService(cfg *config.Config) *TaskService {
+	db := database.GetDB()
 	return &TaskService{
-		db:     database.GetDB(),
+		db:     db,
 		config: cfg,
+		authz:  NewAuthzService(db),
 	}
 }
 
+// checkWorkflowMember 检查被指派的用户是否为工作流成员，不是成员时返回 message
+func (s *TaskService) checkWorkflowMember(workflowID, userID uint, message string) error {
+	role, err := s.authz.WorkflowRole(workflowID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// setTaskMember 设置任务中负责人、审核人等角色对应的任务成员，替换该角色原有的成员；userID 为 0 时只移除
+func setTaskMember(tx *gorm.DB, taskID uint, role string, userID uint) error {
+	if err := tx.Where("task_id = ? AND role = ?", taskID, role).Delete(&models.TaskMember{}).Error; err != nil {
+		return fmt.Errorf("更新任务成员失败: %v", err)
+	}
+	if userID == 0 {
+		return nil
+	}
+	if err := tx.Create(&models.TaskMember{TaskID: taskID, UserID: userID, Role: role}).Error; err != nil {
+		return fmt.Errorf("更新任务成员失败: %v", err)
+	}
+	return nil
+}
+
 // GetConfig 获取配置
 func (s *TaskService) GetConfig() *config.Config {
 	return s.config
@@ -145,22 +174,22 @@ type StagingAreaInfo struct {
 // CreateTask 创建任务
 func (s *TaskService) CreateTask(req *CreateTaskRequest, userID uint) (*TaskInfo, error) {
 	// 检查用户是否有权限在该工作流中创建任务
-	var member models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", req.WorkflowID, userID).First(&member).Error; err != nil {
-		return nil, errors.New("无权限在该工作流中创建任务")
+	if err := s.authz.CheckWorkflow(userID, req.WorkflowID, ActionUpload); err != nil {
+		if errors.Is(err, ErrWorkflowAccessDenied) {
+			return nil, fmt.Errorf("无权限在该工作流中创建任务: %w", err)
+		}
+		return nil, err
 	}
 
 	// 检查负责人是否为工作流成员
-	var responsibleMember models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", req.WorkflowID, req.ResponsibleID).First(&responsibleMember).Error; err != nil {
-		return nil, errors.New("负责人不是工作流成员")
+	if err := s.checkWorkflowMember(req.WorkflowID, req.ResponsibleID, "负责人不是工作流成员"); err != nil {
+		return nil, err
 	}
 
 	// 如果需要审核，检查审核人是否为工作流成员
 	if req.RequireReview && req.ReviewerID > 0 {
-		var reviewerMember models.WorkflowMember
-		if err := s.db.Where("workflow_id = ? AND user_id = ?", req.WorkflowID, req.ReviewerID).First(&reviewerMember).Error; err != nil {
-			return nil, errors.New("审核人不是工作流成员")
+		if err := s.checkWorkflowMember(req.WorkflowID, req.ReviewerID, "审核人不是工作流成员"); err != nil {
+			return nil, err
 		}
 	}
 
@@ -189,8 +218,16 @@ func (s *TaskService) CreateTask(req *CreateTaskRequest, userID uint) (*TaskInfo
 		Progress:       0,
 	}
 
-	if err := s.db.Create(&task).Error; err != nil {
-		return nil, fmt.Errorf("创建任务失败: %v", err)
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return fmt.Errorf("创建任务失败: %v", err)
+		}
+		if err := setTaskMember(tx, task.ID, TaskRoleResponsible, task.ResponsibleID); err != nil {
+			return err
+		}
+		return setTaskMember(tx, task.ID, TaskRoleReviewer, task.ReviewerID)
+	}); err != nil {
+		return nil, err
 	}
 
 	// 记录状态变更日志
@@ -216,9 +253,11 @@ func (s *TaskService) GetTaskByID(taskID uint, userID uint) (*TaskInfo, error) {
 	}
 
 	// 检查用户是否有权限访问该任务
-	var member models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", task.WorkflowID, userID).First(&member).Error; err != nil {
-		return nil, errors.New("无权限访问该任务")
+	if err := s.authz.CheckTask(userID, &task, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, fmt.Errorf("无权限访问该任务: %w", err)
+		}
+		return nil, err
 	}
 
 	// 获取相关用户信息
@@ -273,31 +312,15 @@ func (s *TaskService) GetTaskList(req *TaskListRequest, userID uint) (*TaskListR
 
 	offset := (req.Page - 1) * req.PageSize
 
-	// 构建查询条件
-	query := s.db.Model(&models.TaskEnhanced{}).Where("is_deleted = false")
+	// 构建查询条件，只返回有权查看的任务（所在工作流的任务及自己参与的任务）
+	visible, err := s.authz.VisibleTasks(userID)
+	if err != nil {
+		return nil, err
+	}
+	query := s.db.Model(&models.TaskEnhanced{}).Where("is_deleted = false").Scopes(visible)
 
-	// 如果指定了工作流ID，检查用户权限
 	if req.WorkflowID > 0 {
-		var member models.WorkflowMember
-		if err := s.db.Where("workflow_id = ? AND user_id = ?", req.WorkflowID, userID).First(&member).Error; err != nil {
-			return nil, errors.New("无权限访问该工作流的任务")
-		}
 		query = query.Where("workflow_id = ?", req.WorkflowID)
-	} else {
-		// 获取用户参与的工作流ID列表
-		var memberWorkflowIDs []uint
-		if err := s.db.Model(&models.WorkflowMember{}).Where("user_id = ?", userID).Pluck("workflow_id", &memberWorkflowIDs).Error; err != nil {
-			return nil, fmt.Errorf("获取用户工作流失败: %v", err)
-		}
-		if len(memberWorkflowIDs) == 0 {
-			return &TaskListResponse{
-				Tasks:    []TaskInfo{},
-				Total:    0,
-				Page:     req.Page,
-				PageSize: req.PageSize,
-			}, nil
-		}
-		query = query.Where("workflow_id IN ?", memberWorkflowIDs)
 	}
 
 	// 添加其他过滤条件
@@ -399,11 +422,11 @@ func (s *TaskService) UpdateTask(taskID uint, req *UpdateTaskRequest, userID uin
 	}
 
 	// 检查用户是否有权限更新任务（创建者或负责人或工作流主管）
-	var workflow models.Workflow
-	s.db.Where("id = ?", task.WorkflowID).First(&workflow)
-
-	if task.CreatorID != userID && task.ResponsibleID != userID && workflow.MasterID != userID {
-		return nil, errors.New("无权限更新该任务")
+	if err := s.authz.CheckTask(userID, &task, ActionEdit); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, fmt.Errorf("无权限更新该任务: %w", err)
+		}
+		return nil, err
 	}
 
 	// 如果任务已完成，不允许更新
@@ -421,9 +444,8 @@ func (s *TaskService) UpdateTask(taskID uint, req *UpdateTaskRequest, userID uin
 	}
 	if req.ResponsibleID > 0 {
 		// 检查新负责人是否为工作流成员
-		var member models.WorkflowMember
-		if err := s.db.Where("workflow_id = ? AND user_id = ?", task.WorkflowID, req.ResponsibleID).First(&member).Error; err != nil {
-			return nil, errors.New("新负责人不是工作流成员")
+		if err := s.checkWorkflowMember(task.WorkflowID, req.ResponsibleID, "新负责人不是工作流成员"); err != nil {
+			return nil, err
 		}
 		updateData["responsible_id"] = req.ResponsibleID
 	}
@@ -435,9 +457,8 @@ func (s *TaskService) UpdateTask(taskID uint, req *UpdateTaskRequest, userID uin
 	}
 	if req.ReviewerID > 0 {
 		// 检查审核人是否为工作流成员
-		var member models.WorkflowMember
-		if err := s.db.Where("workflow_id = ? AND user_id = ?", task.WorkflowID, req.ReviewerID).First(&member).Error; err != nil {
-			return nil, errors.New("审核人不是工作流成员")
+		if err := s.checkWorkflowMember(task.WorkflowID, req.ReviewerID, "审核人不是工作流成员"); err != nil {
+			return nil, err
 		}
 		updateData["reviewer_id"] = req.ReviewerID
 	}
@@ -460,10 +481,23 @@ func (s *TaskService) UpdateTask(taskID uint, req *UpdateTaskRequest, userID uin
 		updateData["tags"] = models.StringArray(req.Tags)
 	}
 
-	// 更新任务
+	// 更新任务，负责人或审核人变更时同步任务成员
 	if len(updateData) > 0 {
-		if err := s.db.Model(&task).Updates(updateData).Error; err != nil {
-			return nil, fmt.Errorf("更新任务失败: %v", err)
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&task).Updates(updateData).Error; err != nil {
+				return fmt.Errorf("更新任务失败: %v", err)
+			}
+			if req.ResponsibleID > 0 {
+				if err := setTaskMember(tx, task.ID, TaskRoleResponsible, req.ResponsibleID); err != nil {
+					return err
+				}
+			}
+			if req.ReviewerID > 0 {
+				return setTaskMember(tx, task.ID, TaskRoleReviewer, req.ReviewerID)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
 		}
 	}
 
@@ -480,11 +514,11 @@ func (s *TaskService) DeleteTask(taskID uint, userID uint) error {
 	}
 
 	// 检查用户是否有权限删除任务（创建者或工作流主管）
-	var workflow models.Workflow
-	s.db.Where("id = ?", task.WorkflowID).First(&workflow)
-
-	if task.CreatorID != userID && workflow.MasterID != userID {
-		return errors.New("无权限删除该任务")
+	if err := s.authz.CheckTask(userID, &task, ActionDelete); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return fmt.Errorf("无权限删除该任务: %w", err)
+		}
+		return err
 	}
 
 	// 如果任务正在进行中，不允许删除
@@ -513,9 +547,6 @@ func (s *TaskService) ChangeTaskStatus(taskID uint, req *ChangeStatusRequest, us
 	}
 
 	// 检查用户是否有权限更改状态
-	var workflow models.Workflow
-	s.db.Where("id = ?", task.WorkflowID).First(&workflow)
-
 	canChange := false
 	switch req.Status {
 	case "in_progress":
@@ -534,13 +565,17 @@ func (s *TaskService) ChangeTaskStatus(taskID uint, req *ChangeStatusRequest, us
 		}
 	case "cancelled":
 		// 创建者或工作流主管可以取消任务
-		canChange = task.CreatorID == userID || workflow.MasterID == userID
+		err := s.authz.CheckTask(userID, &task, ActionManage)
+		if err != nil && !errors.Is(err, ErrPermissionDenied) {
+			return nil, err
+		}
+		canChange = err == nil
 	default:
 		return nil, errors.New("无效的状态")
 	}
 
 	if !canChange {
-		return nil, errors.New("无权限更改任务状态")
+		return nil, fmt.Errorf("无权限更改任务状态: %w", ErrPermissionDenied)
 	}
 
 	// 检查状态流转是否合法
@@ -606,17 +641,25 @@ func (s *TaskService) AddToStagingArea(taskID uint, req *StagingAreaRequest, use
 		return nil, errors.New("任务不存在")
 	}
 
-	// 检查用户是否为任务负责人或工作流成员
-	var member models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", task.WorkflowID, userID).First(&member).Error; err != nil {
-		return nil, errors.New("无权限操作该任务")
+	// 检查用户是否为任务成员或可上传的工作流成员
+	if err := s.authz.CheckTask(userID, &task, ActionUpload); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, fmt.Errorf("无权限操作该任务: %w", err)
+		}
+		return nil, err
 	}
 
-	// 检查文件是否存在
+	// 检查文件是否存在且有权查看
 	var file models.File
 	if err := s.db.Where("id = ? AND is_deleted = false", req.FileID).First(&file).Error; err != nil {
 		return nil, errors.New("文件不存在")
 	}
+	if err := s.authz.CheckFile(userID, &file, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, errors.New("文件不存在")
+		}
+		return nil, err
+	}
 
 	// 检查是否已经在暂存区中
 	var existingStaging models.TaskStagingArea
@@ -667,10 +710,12 @@ func (s *TaskService) GetStagingArea(taskID uint, userID uint) ([]StagingAreaInf
 		return nil, errors.New("任务不存在")
 	}
 
-	// 检查用户是否为工作流成员
-	var member models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", task.WorkflowID, userID).First(&member).Error; err != nil {
-		return nil, errors.New("无权限访问该任务")
+	// 检查用户是否有权查看该任务
+	if err := s.authz.CheckTask(userID, &task, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, fmt.Errorf("无权限访问该任务: %w", err)
+		}
+		return nil, err
 	}
 
 	// 获取暂存区列表
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 连接 TEST_DATABASE_DSN 指定的 PostgreSQL 测试库，未设置时跳过测试
+// DSN 使用 key=value 格式（如 "host=localhost user=postgres dbname=mcs_test sslmode=disable"），
+// 每个测试在独立的 schema 中建表，测试结束后删除
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set, skipping database test")
+	}
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d_%d", time.Now().UnixNano(), rand.Intn(1000000))
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), config)
+	if err != nil {
+		t.Fatalf("connect test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.UserGroup{},
+		&models.UserGroupMember{},
+		&models.File{},
+		&models.FileFolder{},
+		&models.FileVersion{},
+		&models.Blob{},
+		&models.FileShare{},
+		&models.Tag{},
+		&models.FileTag{},
+		&models.FileMetadata{},
+		&models.Job{},
+		&models.Workflow{},
+		&models.WorkflowMember{},
+		&models.TaskEnhanced{},
+		&models.TaskMember{},
+		&models.StorageQuota{},
+		&models.StorageStats{},
+	); err != nil {
+		t.Fatalf("migrate test schema: %v", err)
+	}
+	return db
+}
+
+// createTestUser 创建测试用户，role 为空时为普通用户
+func createTestUser(t *testing.T, db *gorm.DB, name, role string) *models.User {
+	t.Helper()
+	if role == "" {
+		role = "user"
+	}
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "-", Role: role}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+// createTestWorkflow 创建测试工作流，members 为成员用户 ID 到角色的映射
+func createTestWorkflow(t *testing.T, db *gorm.DB, name string, masterID uint, members map[uint]string) *models.Workflow {
+	t.Helper()
+	workflow := &models.Workflow{Name: name, MasterID: masterID}
+	if err := db.Create(workflow).Error; err != nil {
+		t.Fatalf("create workflow %s: %v", name, err)
+	}
+	for userID, role := range members {
+		if err := db.Create(&models.WorkflowMember{WorkflowID: workflow.ID, UserID: userID, Role: role}).Error; err != nil {
+			t.Fatalf("add workflow member: %v", err)
+		}
+	}
+	return workflow
+}
+
+// createTestFile 创建测试文件记录，保留 IsPrivate 为 false 的设置（该字段数据库默认值为 true）
+func createTestFile(t *testing.T, db *gorm.DB, file *models.File) *models.File {
+	t.Helper()
+	private := file.IsPrivate
+	if file.FilePath == "" {
+		file.FilePath = fmt.Sprintf("blobs/%s", file.FileName)
+	}
+	if err := db.Create(file).Error; err != nil {
+		t.Fatalf("create file %s: %v", file.FileName, err)
+	}
+	if err := db.Model(file).Update("is_private", private).Error; err != nil {
+		t.Fatalf("update file %s: %v", file.FileName, err)
+	}
+	file.IsPrivate = private
+	return file
+}
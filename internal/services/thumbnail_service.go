@@ -0,0 +1,371 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mcs-backend/internal/config"
+	"mcs-backend/internal/database"
+	"mcs-backend/internal/media"
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// 缩略图错误
+var (
+	ErrThumbnailUnsupported = errors.New("该文件类型不支持缩略图")
+	ErrThumbnailSize        = errors.New("不支持的缩略图尺寸，可选 small、medium、large")
+	ErrThumbnailNotFound    = errors.New("文件不存在或无权限访问")
+)
+
+// ThumbnailSizes 缩略图尺寸（长边像素数）
+var ThumbnailSizes = map[string]int{
+	"small":  256,
+	"medium": 720,
+	"large":  1600,
+}
+
+// DefaultThumbnailSize 默认缩略图尺寸
+const DefaultThumbnailSize = "medium"
+
+// maxThumbnailSource 用于生成缩略图的图片文件最大字节数
+const maxThumbnailSource = 200 << 20
+
+// maxPosterSource 用于截取封面的视频文件最大字节数，截取前需将视频复制到临时目录
+const maxPosterSource = 4 << 30
+
+// posterTimeout 截取视频封面的超时时间
+const posterTimeout = 30 * time.Second
+
+// 缩略图来源类型
+const (
+	thumbnailImage = "image" // 可直接解码的 JPEG、PNG、GIF
+	thumbnailRAW   = "raw"   // 使用内嵌 JPEG 预览图的 RAW 文件
+	thumbnailVideo = "video" // 由外部命令截取封面帧的视频
+)
+
+// thumbnailSources 按扩展名识别缩略图来源类型
+var thumbnailSources = map[string]string{
+	".jpg":  thumbnailImage,
+	".jpeg": thumbnailImage,
+	".png":  thumbnailImage,
+	".gif":  thumbnailImage,
+	".cr2":  thumbnailRAW,
+	".nef":  thumbnailRAW,
+	".dng":  thumbnailRAW,
+	".arw":  thumbnailRAW,
+	".mp4":  thumbnailVideo,
+	".mov":  thumbnailVideo,
+	".avi":  thumbnailVideo,
+}
+
+// Thumbnail 已生成的缩略图
+type Thumbnail struct {
+	Path    string    // 缩略图文件路径
+	ETag    string    // 由内容 MD5 与尺寸组成，内容不变时缩略图不变
+	ModTime time.Time // 缩略图生成时间
+}
+
+// ThumbnailService 缩略图服务
+// 缩略图按文件内容 MD5 与尺寸缓存在 ThumbnailPath 下，内容相同的文件共享缩略图，上传新版本后自动使用新内容的缩略图。
+// 上传完成后由后台任务预先生成常用尺寸，其余尺寸在首次请求时生成。
+type ThumbnailService struct {
+	db      *gorm.DB
+	authz   *AuthzService
+	storage storage.Storage
+	files   *FileResolver
+	dir     string
+	tempDir string
+	poster  media.PosterExtractor
+}
+
+// NewThumbnailService 创建缩略图服务，poster 为空时不生成视频缩略图
+// uploadPath 为本地存储的上传目录，用于解析早期版本保存的磁盘路径
+func NewThumbnailService(db *gorm.DB, store storage.Storage, uploadPath, dir, tempDir string, poster media.PosterExtractor) *ThumbnailService {
+	return &ThumbnailService{
+		db:      db,
+		authz:   NewAuthzService(db),
+		storage: store,
+		files:   NewFileResolver(store, uploadPath),
+		dir:     dir,
+		tempDir: tempDir,
+		poster:  poster,
+	}
+}
+
+// NewThumbnailServiceFromConfig 按应用配置创建缩略图服务，配置了 THUMBNAIL_VIDEO_COMMAND 时支持视频缩略图
+func NewThumbnailServiceFromConfig(cfg *config.Config) *ThumbnailService {
+	var poster media.PosterExtractor
+	if cfg.File.ThumbnailVideoCmd != "" {
+		commandPoster, err := media.NewCommandPoster(cfg.File.ThumbnailVideoCmd, posterTimeout)
+		if err != nil {
+			log.Printf("Warning: Invalid THUMBNAIL_VIDEO_COMMAND, video thumbnails disabled: %v", err)
+		} else {
+			poster = commandPoster
+		}
+	}
+	return NewThumbnailService(database.GetDB(), storage.GetStorage(), cfg.File.UploadPath, cfg.File.ThumbnailPath, cfg.File.TempPath, poster)
+}
+
+// GetThumbnail 获取文件缩略图，没有缓存时立即生成；权限与文件详情相同
+func (s *ThumbnailService) GetThumbnail(ctx context.Context, fileID, userID uint, size string) (*Thumbnail, error) {
+	if size == "" {
+		size = DefaultThumbnailSize
+	}
+	if _, ok := ThumbnailSizes[size]; !ok {
+		return nil, ErrThumbnailSize
+	}
+
+	var file models.File
+	if err := s.db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrThumbnailNotFound
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	if err := s.authz.CheckFile(userID, &file, ActionView); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, ErrThumbnailNotFound
+		}
+		return nil, err
+	}
+	return s.thumbnail(ctx, &file, size)
+}
+
+// HandleJob 处理生成缩略图的后台任务，预先生成常用尺寸；文件类型不支持时直接完成
+func (s *ThumbnailService) HandleJob(ctx context.Context, job *models.Job) error {
+	var payload fileJobPayload
+	if err := decodeJobPayload(job, &payload); err != nil {
+		return err
+	}
+
+	var file models.File
+	if err := s.db.First(&file, payload.FileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return PermanentJobError(errJobFileNotFound)
+		}
+		return fmt.Errorf("获取文件信息失败: %v", err)
+	}
+
+	for _, size := range []string{"small", DefaultThumbnailSize} {
+		if _, err := s.thumbnail(ctx, &file, size); err != nil {
+			if errors.Is(err, ErrThumbnailUnsupported) || errors.Is(err, media.ErrImageTooLarge) {
+				return nil
+			}
+			if errors.Is(err, storage.ErrNotExist) || errors.Is(err, ErrInvalidStoragePath) {
+				return PermanentJobError(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// thumbnail 获取缓存的缩略图，不存在时生成
+func (s *ThumbnailService) thumbnail(ctx context.Context, file *models.File, size string) (*Thumbnail, error) {
+	source := thumbnailSource(file)
+	if source == "" || (source == thumbnailVideo && s.poster == nil) {
+		return nil, ErrThumbnailUnsupported
+	}
+
+	hash := strings.ToLower(file.MD5Hash)
+	if len(hash) < 2 {
+		return nil, errors.New("文件缺少内容哈希")
+	}
+	path := filepath.Join(s.dir, hash[:2], fmt.Sprintf("%s_%s.jpg", hash, size))
+	thumbnail := &Thumbnail{Path: path, ETag: fmt.Sprintf(`"%s-%s"`, hash, size)}
+
+	if info, err := os.Stat(path); err == nil {
+		thumbnail.ModTime = info.ModTime()
+		return thumbnail, nil
+	}
+
+	img, orientation, err := s.decode(ctx, file, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeThumbnail(path, media.Thumbnail(img, ThumbnailSizes[size], orientation)); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("保存缩略图失败: %v", err)
+	}
+	thumbnail.ModTime = info.ModTime()
+	return thumbnail, nil
+}
+
+// decode 解码用于生成缩略图的图片，返回图片及其 EXIF 方向
+func (s *ThumbnailService) decode(ctx context.Context, file *models.File, source string) (image.Image, int, error) {
+	switch source {
+	case thumbnailRAW:
+		return s.decodeRAW(file)
+	case thumbnailVideo:
+		return s.decodeVideo(ctx, file)
+	}
+
+	if file.FileSize > maxThumbnailSource {
+		return nil, 0, media.ErrImageTooLarge
+	}
+	data, err := s.read(file, 0, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("解码图片失败: %v", err)
+	}
+	return img, exifOrientation(data), nil
+}
+
+// decodeRAW 解码 RAW 文件中最大的可用内嵌预览图，方向以 RAW 文件的 EXIF 为准
+func (s *ThumbnailService) decodeRAW(file *models.File) (image.Image, int, error) {
+	header, err := s.read(file, 0, media.EXIFReadLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+	orientation := exifOrientation(header)
+
+	for _, segment := range media.EmbeddedJPEGs(header) {
+		if segment.Offset+segment.Length > file.FileSize || segment.Length > maxThumbnailSource {
+			continue
+		}
+		data, err := s.read(file, segment.Offset, segment.Length)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+			continue
+		}
+		if img, err := media.DecodeImage(data); err == nil {
+			return img, orientation, nil
+		}
+	}
+	return nil, 0, ErrThumbnailUnsupported
+}
+
+// decodeVideo 将视频复制到临时文件后截取封面帧，超过 maxPosterSource 的视频不生成缩略图
+func (s *ThumbnailService) decodeVideo(ctx context.Context, file *models.File) (image.Image, int, error) {
+	if file.FileSize > maxPosterSource {
+		return nil, 0, media.ErrImageTooLarge
+	}
+	content, err := s.files.Reader(file)
+	if errors.Is(err, storage.ErrNotExist) || errors.Is(err, ErrInvalidStoragePath) {
+		return nil, 0, err
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取文件内容失败: %v", err)
+	}
+	defer content.Close()
+
+	if err := os.MkdirAll(s.tempDir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	temp, err := os.CreateTemp(s.tempDir, "poster-*"+filepath.Ext(file.FileName))
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(temp.Name())
+	// 记录中的大小可能与实际内容不符，复制时同样限制大小
+	n, err := io.Copy(temp, io.LimitReader(content, maxPosterSource+1))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取文件内容失败: %v", err)
+	}
+	if n > maxPosterSource {
+		return nil, 0, media.ErrImageTooLarge
+	}
+
+	data, err := s.poster.Poster(ctx, temp.Name())
+	if err != nil {
+		return nil, 0, err
+	}
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("解码视频封面失败: %v", err)
+	}
+	return img, 1, nil
+}
+
+// read 读取文件内容的一部分，length < 0 表示读到末尾
+func (s *ThumbnailService) read(file *models.File, offset, length int64) ([]byte, error) {
+	key, err := s.files.StorageKey(file.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.storage.GetRange(key, offset, length)
+	if errors.Is(err, storage.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取文件内容失败: %v", err)
+	}
+	defer content.Close()
+
+	limit := int64(maxThumbnailSource)
+	if length >= 0 {
+		limit = length
+	}
+	data, err := io.ReadAll(io.LimitReader(content, limit))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件内容失败: %v", err)
+	}
+	return data, nil
+}
+
+// writeThumbnail 编码缩略图并原子地写入缓存目录
+func writeThumbnail(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建缩略图目录失败: %v", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".thumb-*")
+	if err != nil {
+		return fmt.Errorf("保存缩略图失败: %v", err)
+	}
+	defer os.Remove(temp.Name())
+
+	err = media.EncodeJPEG(temp, img)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("保存缩略图失败: %v", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("保存缩略图失败: %v", err)
+	}
+	return nil
+}
+
+// thumbnailSource 文件的缩略图来源类型，不支持时返回空字符串
+func thumbnailSource(file *models.File) string {
+	if source, ok := thumbnailSources[strings.ToLower(filepath.Ext(file.FileName))]; ok {
+		return source
+	}
+	if strings.HasPrefix(file.MimeType, "video/") {
+		return thumbnailVideo
+	}
+	return ""
+}
+
+// exifOrientation 读取 EXIF 方向，没有时返回 1
+func exifOrientation(data []byte) int {
+	if exif, err := media.ParseEXIF(data); err == nil && exif.Orientation > 0 {
+		return exif.Orientation
+	}
+	return 1
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+)
+
+// stubPoster 返回固定封面帧的提取器
+type stubPoster struct {
+	data []byte
+}
+
+func (p stubPoster) Poster(ctx context.Context, path string) ([]byte, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return p.data, nil
+}
+
+// newTestThumbnailService 创建使用本地存储、不依赖数据库的缩略图服务
+func newTestThumbnailService(t *testing.T, poster stubPoster) (*ThumbnailService, storage.Storage) {
+	t.Helper()
+
+	root := t.TempDir()
+	uploads := filepath.Join(root, "uploads")
+	store := storage.NewLocalStorage(uploads)
+	service := NewThumbnailService(nil, store, uploads, filepath.Join(root, "thumbnails"), filepath.Join(root, "temp"), nil)
+	if poster.data != nil {
+		service.poster = poster
+	}
+	return service, store
+}
+
+// putTestFile 保存文件内容并返回对应的文件记录
+func putTestFile(t *testing.T, store storage.Storage, name, hash string, data []byte) *models.File {
+	t.Helper()
+
+	if err := store.Put(hash, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	return &models.File{FileName: name, FilePath: hash, FileSize: int64(len(data)), MD5Hash: hash}
+}
+
+func encodeTestImage(t *testing.T, w, h int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }
+
+// thumbnailSize 读取缩略图文件的尺寸
+func thumbnailSize(t *testing.T, thumbnail *Thumbnail) image.Point {
+	t.Helper()
+
+	data, err := os.ReadFile(thumbnail.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Errorf("thumbnail format = %s, want jpeg", format)
+	}
+	return image.Pt(config.Width, config.Height)
+}
+
+func TestThumbnailImage(t *testing.T) {
+	service, store := newTestThumbnailService(t, stubPoster{})
+	file := putTestFile(t, store, "photo.PNG", "0123456789abcdef0123456789abcdef", encodeTestImage(t, 1000, 500, encodePNG))
+
+	thumbnail, err := service.thumbnail(context.Background(), file, "small")
+	if err != nil {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	if got := thumbnailSize(t, thumbnail); got != image.Pt(256, 128) {
+		t.Errorf("size = %v, want 256x128", got)
+	}
+	if want := `"0123456789abcdef0123456789abcdef-small"`; thumbnail.ETag != want {
+		t.Errorf("ETag = %s, want %s", thumbnail.ETag, want)
+	}
+
+	// 再次获取时直接使用缓存，即使原文件已被删除
+	if err := store.Delete(file.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := service.thumbnail(context.Background(), file, "small")
+	if err != nil {
+		t.Fatalf("cached thumbnail: %v", err)
+	}
+	if cached.Path != thumbnail.Path || !cached.ModTime.Equal(thumbnail.ModTime) {
+		t.Errorf("cached = %+v, want %+v", cached, thumbnail)
+	}
+
+	if _, err := service.thumbnail(context.Background(), file, "large"); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("thumbnail of deleted file = %v, want ErrNotExist", err)
+	}
+}
+
+func TestThumbnailStoragePath(t *testing.T) {
+	service, store := newTestThumbnailService(t, stubPoster{})
+	file := putTestFile(t, store, "photo.png", "00112233445566778899aabbccddeeff", encodeTestImage(t, 400, 400, encodePNG))
+
+	// 早期版本保存的是上传目录下的磁盘路径
+	file.FilePath = filepath.Join(service.files.uploadPath, file.FilePath)
+	if _, err := service.thumbnail(context.Background(), file, "small"); err != nil {
+		t.Fatalf("thumbnail of legacy path: %v", err)
+	}
+
+	escaped := &models.File{FileName: "escape.png", FilePath: "../secret.png", FileSize: 10, MD5Hash: "ffeeddccbbaa99887766554433221100"}
+	if _, err := service.thumbnail(context.Background(), escaped, "small"); !errors.Is(err, ErrInvalidStoragePath) {
+		t.Errorf("thumbnail of escaping path = %v, want ErrInvalidStoragePath", err)
+	}
+}
+
+func TestThumbnailRAWPreview(t *testing.T) {
+	service, store := newTestThumbnailService(t, stubPoster{})
+	preview := encodeTestImage(t, 1600, 1200, encodeJPEG)
+
+	// 最小的 TIFF 结构：IFD0 只记录内嵌 JPEG 预览图的位置
+	order := binary.LittleEndian
+	raw := []byte("II*\x00")
+	raw = order.AppendUint32(raw, 8)
+	raw = order.AppendUint16(raw, 2)
+	raw = append(raw, 0x01, 0x02, 4, 0, 1, 0, 0, 0)
+	raw = order.AppendUint32(raw, 38)
+	raw = append(raw, 0x02, 0x02, 4, 0, 1, 0, 0, 0)
+	raw = order.AppendUint32(raw, uint32(len(preview)))
+	raw = order.AppendUint32(raw, 0)
+	raw = append(raw, preview...)
+
+	file := putTestFile(t, store, "IMG_0001.CR2", "fedcba9876543210fedcba9876543210", raw)
+	thumbnail, err := service.thumbnail(context.Background(), file, "medium")
+	if err != nil {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	if got := thumbnailSize(t, thumbnail); got != image.Pt(720, 540) {
+		t.Errorf("size = %v, want 720x540", got)
+	}
+
+	// 没有预览图的 RAW 文件不支持缩略图
+	file = putTestFile(t, store, "IMG_0002.NEF", "00000000000000000000000000000000", raw[:8])
+	if _, err := service.thumbnail(context.Background(), file, "medium"); !errors.Is(err, ErrThumbnailUnsupported) {
+		t.Errorf("thumbnail without preview = %v, want ErrThumbnailUnsupported", err)
+	}
+}
+
+func TestThumbnailVideo(t *testing.T) {
+	video := []byte("not really a video")
+
+	service, store := newTestThumbnailService(t, stubPoster{})
+	file := putTestFile(t, store, "clip.mp4", "11111111111111111111111111111111", video)
+	if _, err := service.thumbnail(context.Background(), file, "small"); !errors.Is(err, ErrThumbnailUnsupported) {
+		t.Errorf("thumbnail without poster = %v, want ErrThumbnailUnsupported", err)
+	}
+
+	service, store = newTestThumbnailService(t, stubPoster{data: encodeTestImage(t, 1920, 1080, encodeJPEG)})
+	file = putTestFile(t, store, "clip.webm", "22222222222222222222222222222222", video)
+	file.MimeType = "video/webm"
+	thumbnail, err := service.thumbnail(context.Background(), file, "small")
+	if err != nil {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	if got := thumbnailSize(t, thumbnail); got != image.Pt(256, 144) {
+		t.Errorf("size = %v, want 256x144", got)
+	}
+}
+
+func TestThumbnailUnsupported(t *testing.T) {
+	service, store := newTestThumbnailService(t, stubPoster{})
+	file := putTestFile(t, store, "report.pdf", "33333333333333333333333333333333", []byte("%PDF-1.7"))
+	if _, err := service.thumbnail(context.Background(), file, "small"); !errors.Is(err, ErrThumbnailUnsupported) {
+		t.Errorf("thumbnail = %v, want ErrThumbnailUnsupported", err)
+	}
+
+	if _, err := service.GetThumbnail(context.Background(), 1, 1, "huge"); !errors.Is(err, ErrThumbnailSize) {
+		t.Errorf("GetThumbnail(huge) = %v, want ErrThumbnailSize", err)
+	}
+}
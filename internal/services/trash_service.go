@@ -0,0 +1,561 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 回收站项目类型
+const (
+	TrashItemFile   = "file"
+	TrashItemFolder = "folder"
+)
+
+var (
+	// ErrTrashItemNotFound 回收站中不存在该项目，或不是项目的所有者
+	ErrTrashItemNotFound = errors.New("回收站中不存在该项目或无权限操作")
+	// ErrTrashFolderNotEmpty 文件夹中仍有未删除的内容，不能彻底删除
+	ErrTrashFolderNotEmpty = errors.New("文件夹中仍有未删除的内容，无法彻底删除")
+)
+
+// TrashService 回收站
+// 删除的文件和文件夹保留在回收站中，可以恢复或彻底删除，超过保留期后由后台任务彻底删除。
+// 同一次操作中一起删除的项目（删除时间相同）作为一个整体列出和恢复，只列出最上层的项目。
+// 彻底删除时释放对存储内容的引用，存储内容在没有其他引用后由 Blob 垃圾回收删除。
+type TrashService struct {
+	db        *gorm.DB
+	blobs     *BlobService
+	quotas    *QuotaService
+	authz     *AuthzService
+	retention time.Duration
+}
+
+// NewTrashService 创建回收站服务，retention 为回收站中项目的保留时间
+func NewTrashService(db *gorm.DB, blobs *BlobService, retention time.Duration) *TrashService {
+	return &TrashService{
+		db:        db,
+		blobs:     blobs,
+		quotas:    NewQuotaService(db),
+		authz:     NewAuthzService(db),
+		retention: retention,
+	}
+}
+
+// TrashItem 回收站项目
+type TrashItem struct {
+	Type       string    `json:"type"` // file, folder
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`      // 删除前的完整路径
+	FolderID   uint      `json:"folder_id"` // 删除前所在的文件夹，0 表示根目录
+	WorkflowID uint      `json:"workflow_id"`
+	OwnerID    uint      `json:"owner_id"` // 文件所有者或文件夹创建者
+	Size       int64     `json:"size"`     // 文件大小，文件夹为 0
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAt    time.Time `json:"purge_at"` // 到期后将被彻底删除
+}
+
+// TrashListResponse 回收站列表响应
+type TrashListResponse struct {
+	Items    []TrashItem `json:"items"`
+	Total    int64       `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+// TrashPurgeResult 彻底删除结果
+type TrashPurgeResult struct {
+	Files         int   `json:"files"`
+	Folders       int   `json:"folders"`
+	ReleasedBytes int64 `json:"released_bytes"` // 删除文件的大小之和，与其他文件共享的内容不会被实际回收
+}
+
+// trashScope 回收站查询范围，字段为零值时不限制
+type trashScope struct {
+	ownerID    uint
+	workflowID uint
+	before     *time.Time // 只包含在此之前删除的项目
+}
+
+// trashRow 回收站查询结果
+type trashRow struct {
+	Type       string
+	ID         uint
+	Name       string
+	ParentPath string
+	Path       string
+	FolderID   uint
+	WorkflowID uint
+	OwnerID    uint
+	Size       int64
+	DeletedAt  time.Time
+}
+
+// trashItemsSQL 生成回收站最上层项目的查询
+// 与所在文件夹同时删除的项目（删除时间相同）随文件夹一起列出和恢复，不单独列出
+func trashItemsSQL(scope trashScope) (string, []interface{}) {
+	fileConds := []string{"f.is_deleted = true"}
+	folderConds := []string{"d.is_deleted = true"}
+	var fileArgs, folderArgs []interface{}
+	if scope.ownerID != 0 {
+		fileConds = append(fileConds, "f.owner_id = ?")
+		folderConds = append(folderConds, "d.creator_id = ?")
+		fileArgs = append(fileArgs, scope.ownerID)
+		folderArgs = append(folderArgs, scope.ownerID)
+	}
+	if scope.workflowID != 0 {
+		fileConds = append(fileConds, "f.workflow_id = ?")
+		folderConds = append(folderConds, "d.workflow_id = ?")
+		fileArgs = append(fileArgs, scope.workflowID)
+		folderArgs = append(folderArgs, scope.workflowID)
+	}
+	if scope.before != nil {
+		fileConds = append(fileConds, "COALESCE(f.deleted_at, f.updated_at) < ?")
+		folderConds = append(folderConds, "COALESCE(d.deleted_at, d.updated_at) < ?")
+		fileArgs = append(fileArgs, *scope.before)
+		folderArgs = append(folderArgs, *scope.before)
+	}
+
+	query := fmt.Sprintf(`
+SELECT 'file' AS type, f.id, f.file_name AS name, COALESCE(p.path, '') AS parent_path, '' AS path,
+	f.folder_id, f.workflow_id, f.owner_id, f.file_size AS size, COALESCE(f.deleted_at, f.updated_at) AS deleted_at
+FROM files f
+LEFT JOIN file_folders p ON p.id = f.folder_id
+WHERE %s AND NOT COALESCE(p.is_deleted AND p.deleted_at = f.deleted_at, false)
+UNION ALL
+SELECT 'folder' AS type, d.id, d.name, '' AS parent_path, d.path,
+	d.parent_id AS folder_id, d.workflow_id, d.creator_id AS owner_id, 0 AS size, COALESCE(d.deleted_at, d.updated_at) AS deleted_at
+FROM file_folders d
+LEFT JOIN file_folders p ON p.id = d.parent_id
+WHERE %s AND NOT COALESCE(p.is_deleted AND p.deleted_at = d.deleted_at, false)`,
+		strings.Join(fileConds, " AND "), strings.Join(folderConds, " AND "))
+	return query, append(fileArgs, folderArgs...)
+}
+
+// ListTrash 获取回收站列表
+// workflowID 为 0 时列出用户自己删除的项目；否则列出工作流中所有成员删除的项目，需有查看工作流的权限
+func (s *TrashService) ListTrash(userID, workflowID uint, page, pageSize int) (*TrashListResponse, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	scope := trashScope{ownerID: userID}
+	if workflowID != 0 {
+		scope = trashScope{workflowID: workflowID}
+		if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+			return nil, err
+		}
+	}
+
+	query, args := trashItemsSQL(scope)
+	var total int64
+	if err := s.db.Raw("SELECT COUNT(*) FROM ("+query+") t", args...).Scan(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取回收站失败: %v", err)
+	}
+
+	var rows []trashRow
+	if err := s.db.Raw("SELECT * FROM ("+query+") t ORDER BY deleted_at DESC, type, id LIMIT ? OFFSET ?",
+		append(args, pageSize, (page-1)*pageSize)...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取回收站失败: %v", err)
+	}
+
+	items := make([]TrashItem, len(rows))
+	for i, row := range rows {
+		items[i] = s.toTrashItem(&row)
+	}
+	return &TrashListResponse{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// toTrashItem 转换为回收站项目
+func (s *TrashService) toTrashItem(row *trashRow) TrashItem {
+	path := row.Path
+	if row.Type == TrashItemFile {
+		path = joinFolderPath(row.ParentPath, row.Name)
+	}
+	return TrashItem{
+		Type:       row.Type,
+		ID:         row.ID,
+		Name:       row.Name,
+		Path:       path,
+		FolderID:   row.FolderID,
+		WorkflowID: row.WorkflowID,
+		OwnerID:    row.OwnerID,
+		Size:       row.Size,
+		DeletedAt:  row.DeletedAt,
+		PurgeAt:    row.DeletedAt.Add(s.retention),
+	}
+}
+
+// deletedTogether 判断两个项目是否在同一次操作中删除
+func deletedTogether(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// Restore 恢复回收站中的文件或文件夹（仅所有者）
+// 原所在的文件夹已删除时一并恢复（不恢复其中的其他内容）；原位置已有同名文件夹时恢复到该文件夹中，
+// 原文件夹已不存在时恢复到工作流根目录。恢复文件夹时，与其同时删除的子文件夹和文件一起恢复。
+func (s *TrashService) Restore(itemType string, id, userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		switch itemType {
+		case TrashItemFile:
+			return s.restoreFile(tx, id, userID)
+		case TrashItemFolder:
+			return s.restoreFolder(tx, id, userID)
+		default:
+			return fmt.Errorf("不支持的回收站项目类型: %s", itemType)
+		}
+	})
+}
+
+// restoreFile 在事务中恢复文件
+func (s *TrashService) restoreFile(tx *gorm.DB, id, userID uint) error {
+	var file models.File
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND owner_id = ? AND is_deleted = true", id, userID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashItemNotFound
+		}
+		return fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	if err := checkWorkflowWritable(tx, file.WorkflowID); err != nil {
+		return err
+	}
+	if err := s.quotas.CheckQuota(tx, userID, file.WorkflowID, file.FileSize); err != nil {
+		return err
+	}
+
+	folderID, _, err := restoreFolderChain(tx, file.WorkflowID, file.FolderID)
+	if err != nil {
+		return err
+	}
+	target := &moveTarget{folderID: folderID, workflowID: file.WorkflowID}
+	if err := checkFileNameConflict(tx, target, file.FileName, file.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&file).Updates(map[string]interface{}{
+		"is_deleted": false,
+		"deleted_at": nil,
+		"folder_id":  folderID,
+	}).Error; err != nil {
+		return fmt.Errorf("恢复文件失败: %v", err)
+	}
+	return nil
+}
+
+// restoreFolder 在事务中恢复文件夹及与其同时删除的内容
+func (s *TrashService) restoreFolder(tx *gorm.DB, id, userID uint) error {
+	var folder models.FileFolder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND creator_id = ? AND is_deleted = true", id, userID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashItemNotFound
+		}
+		return fmt.Errorf("获取文件夹信息失败: %v", err)
+	}
+	if err := checkWorkflowWritable(tx, folder.WorkflowID); err != nil {
+		return err
+	}
+
+	subtree, err := folderSubtree(tx, &folder, true)
+	if err != nil {
+		return err
+	}
+	// 父文件夹先于子文件夹，只恢复父文件夹被恢复且与根文件夹同时删除的子文件夹
+	restored := map[uint]bool{folder.ID: true}
+	restoredIDs := []uint{folder.ID}
+	for _, f := range subtree[1:] {
+		if restored[f.ParentID] && f.IsDeleted && deletedTogether(f.DeletedAt, folder.DeletedAt) {
+			restored[f.ID] = true
+			restoredIDs = append(restoredIDs, f.ID)
+		}
+	}
+
+	// 与根文件夹同时删除的文件
+	restoredFiles := func() *gorm.DB {
+		query := tx.Model(&models.File{}).Where("folder_id IN ? AND workflow_id = ? AND is_deleted = true", restoredIDs, folder.WorkflowID)
+		if folder.DeletedAt == nil {
+			return query.Where("deleted_at IS NULL")
+		}
+		return query.Where("deleted_at = ?", *folder.DeletedAt)
+	}
+	var size int64
+	if err := restoredFiles().Select("COALESCE(SUM(file_size), 0)").Scan(&size).Error; err != nil {
+		return fmt.Errorf("统计文件大小失败: %v", err)
+	}
+	if err := s.quotas.CheckQuota(tx, userID, folder.WorkflowID, size); err != nil {
+		return err
+	}
+
+	parentID, parentPath, err := restoreFolderChain(tx, folder.WorkflowID, folder.ParentID)
+	if err != nil {
+		return err
+	}
+	target := &moveTarget{folderID: parentID, workflowID: folder.WorkflowID}
+	if err := checkFolderNameConflict(tx, target, folder.Name, folder.ID); err != nil {
+		return err
+	}
+
+	paths := rebaseFolderPaths(subtree, joinFolderPath(parentPath, folder.Name))
+	for _, f := range subtree {
+		updates := map[string]interface{}{"path": paths[f.ID]}
+		if restored[f.ID] {
+			updates["is_deleted"] = false
+			updates["deleted_at"] = nil
+		}
+		if f.ID == folder.ID {
+			updates["parent_id"] = parentID
+		}
+		if err := tx.Model(&models.FileFolder{}).Where("id = ?", f.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("恢复文件夹失败: %v", err)
+		}
+	}
+
+	if err := restoredFiles().Updates(map[string]interface{}{
+		"is_deleted": false,
+		"deleted_at": nil,
+	}).Error; err != nil {
+		return fmt.Errorf("恢复文件失败: %v", err)
+	}
+	return nil
+}
+
+// restoreFolderChain 确保文件夹及其上级文件夹未被删除，返回可用的文件夹 ID 和路径
+// 已删除的文件夹被恢复（不恢复其中的其他内容）；同一位置已有同名文件夹时改用该文件夹；
+// 文件夹已不存在时返回根目录
+func restoreFolderChain(tx *gorm.DB, workflowID, folderID uint) (uint, string, error) {
+	if folderID == 0 {
+		return 0, "", nil
+	}
+
+	var folder models.FileFolder
+	if err := tx.Where("id = ? AND workflow_id = ?", folderID, workflowID).First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, "", nil
+		}
+		return 0, "", fmt.Errorf("获取文件夹信息失败: %v", err)
+	}
+	if !folder.IsDeleted {
+		return folder.ID, folder.Path, nil
+	}
+
+	parentID, parentPath, err := restoreFolderChain(tx, workflowID, folder.ParentID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var existing models.FileFolder
+	err = tx.Where("parent_id = ? AND workflow_id = ? AND name = ? AND is_deleted = false", parentID, workflowID, folder.Name).
+		First(&existing).Error
+	if err == nil {
+		return existing.ID, existing.Path, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", fmt.Errorf("检查同名文件夹失败: %v", err)
+	}
+
+	path := joinFolderPath(parentPath, folder.Name)
+	if err := tx.Model(&folder).Updates(map[string]interface{}{
+		"is_deleted": false,
+		"deleted_at": nil,
+		"parent_id":  parentID,
+		"path":       path,
+	}).Error; err != nil {
+		return 0, "", fmt.Errorf("恢复文件夹失败: %v", err)
+	}
+	return folder.ID, path, nil
+}
+
+// Purge 彻底删除回收站中的文件或文件夹（仅所有者）
+// 彻底删除文件夹时一并删除其中的全部内容，文件夹中仍有未删除的内容时不能彻底删除
+func (s *TrashService) Purge(itemType string, id, userID uint) (*TrashPurgeResult, error) {
+	result := &TrashPurgeResult{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		switch itemType {
+		case TrashItemFile:
+			var file models.File
+			if err := tx.Where("id = ? AND owner_id = ? AND is_deleted = true", id, userID).First(&file).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrTrashItemNotFound
+				}
+				return fmt.Errorf("获取文件信息失败: %v", err)
+			}
+			return s.purgeFile(tx, &file, result)
+		case TrashItemFolder:
+			var folder models.FileFolder
+			if err := tx.Where("id = ? AND creator_id = ? AND is_deleted = true", id, userID).First(&folder).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrTrashItemNotFound
+				}
+				return fmt.Errorf("获取文件夹信息失败: %v", err)
+			}
+			return s.purgeFolder(tx, &folder, userID, result)
+		default:
+			return fmt.Errorf("不支持的回收站项目类型: %s", itemType)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// purgeFile 在事务中彻底删除文件
+func (s *TrashService) purgeFile(tx *gorm.DB, file *models.File, result *TrashPurgeResult) error {
+	if err := purgeFile(tx, s.blobs, file); err != nil {
+		return err
+	}
+	result.Files++
+	result.ReleasedBytes += file.FileSize
+	return nil
+}
+
+// purgeFolder 在事务中代表 userID 彻底删除文件夹及其中的全部内容
+// userID 无权删除的文件（如随文件夹一起删除的其他用户的私有文件）不彻底删除，而是移到工作流根目录并重新开始计算保留期，
+// 作为单独的项目出现在文件所有者的回收站中，由所有者恢复或彻底删除
+func (s *TrashService) purgeFolder(tx *gorm.DB, folder *models.FileFolder, userID uint, result *TrashPurgeResult) error {
+	subtree, err := folderSubtree(tx, folder, true)
+	if err != nil {
+		return err
+	}
+	for _, f := range subtree {
+		if !f.IsDeleted {
+			return ErrTrashFolderNotEmpty
+		}
+	}
+	ids := folderIDs(subtree)
+
+	var files []models.File
+	if err := tx.Where("folder_id IN ? AND workflow_id = ?", ids, folder.WorkflowID).Find(&files).Error; err != nil {
+		return fmt.Errorf("获取文件列表失败: %v", err)
+	}
+	checker := s.authz.newBatchChecker(userID)
+	var detached []uint
+	for i := range files {
+		if !files[i].IsDeleted {
+			return ErrTrashFolderNotEmpty
+		}
+		if err := checker.File(&files[i], ActionDelete); err != nil {
+			if !errors.Is(err, ErrPermissionDenied) {
+				return err
+			}
+			detached = append(detached, files[i].ID)
+			continue
+		}
+		if err := s.purgeFile(tx, &files[i], result); err != nil {
+			return err
+		}
+	}
+	if len(detached) > 0 {
+		now := time.Now()
+		if err := tx.Model(&models.File{}).Where("id IN ?", detached).
+			Updates(map[string]interface{}{"folder_id": 0, "deleted_at": &now}).Error; err != nil {
+			return fmt.Errorf("移出文件失败: %v", err)
+		}
+	}
+
+	if err := tx.Where("target_type = ? AND folder_id IN ?", models.ShareTargetFolder, ids).Delete(&models.FileShare{}).Error; err != nil {
+		return fmt.Errorf("删除文件夹分享失败: %v", err)
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&models.FileFolder{}).Error; err != nil {
+		return fmt.Errorf("删除文件夹失败: %v", err)
+	}
+	result.Folders += len(ids)
+	return nil
+}
+
+// EmptyTrash 清空用户的回收站，workflowID 不为 0 时只清空该工作流中的项目
+func (s *TrashService) EmptyTrash(userID, workflowID uint) (*TrashPurgeResult, error) {
+	return s.purgeItems(trashScope{ownerID: userID, workflowID: workflowID})
+}
+
+// PurgeExpired 彻底删除超过保留期的回收站项目
+func (s *TrashService) PurgeExpired() (*TrashPurgeResult, error) {
+	cutoff := time.Now().Add(-s.retention)
+	return s.purgeItems(trashScope{before: &cutoff})
+}
+
+// purgeItems 逐个彻底删除范围内的回收站项目，单个项目失败时记录日志并继续
+func (s *TrashService) purgeItems(scope trashScope) (*TrashPurgeResult, error) {
+	query, args := trashItemsSQL(scope)
+	var rows []trashRow
+	if err := s.db.Raw("SELECT * FROM ("+query+") t ORDER BY deleted_at, type, id", args...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取回收站失败: %v", err)
+	}
+
+	total := &TrashPurgeResult{}
+	for _, row := range rows {
+		result := &TrashPurgeResult{}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if row.Type == TrashItemFile {
+				var file models.File
+				if err := tx.Where("id = ? AND is_deleted = true", row.ID).First(&file).Error; err != nil {
+					return err
+				}
+				return s.purgeFile(tx, &file, result)
+			}
+			var folder models.FileFolder
+			if err := tx.Where("id = ? AND is_deleted = true", row.ID).First(&folder).Error; err != nil {
+				return err
+			}
+			return s.purgeFolder(tx, &folder, folder.CreatorID, result)
+		})
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("Warning: Failed to purge trashed %s %d: %v", row.Type, row.ID, err)
+			}
+			continue
+		}
+		total.Files += result.Files
+		total.Folders += result.Folders
+		total.ReleasedBytes += result.ReleasedBytes
+	}
+	return total, nil
+}
+
+// StartPurge 启动回收站过期清理后台任务
+func (s *TrashService) StartPurge(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	if s.retention <= 0 {
+		log.Printf("Warning: Trash retention must be positive, scheduled purge disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			result, err := s.PurgeExpired()
+			if err != nil {
+				log.Printf("Warning: Failed to purge expired trash: %v", err)
+				continue
+			}
+			if result.Files > 0 || result.Folders > 0 {
+				log.Printf("Trash purge deleted %d files (%d bytes) and %d folders",
+					result.Files, result.ReleasedBytes, result.Folders)
+			}
+		}
+	}()
+}
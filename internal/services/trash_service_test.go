@@ -0,0 +1,142 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
+)
+
+func TestTrashItemsSQL(t *testing.T) {
+	cutoff := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		scope trashScope
+		conds []string
+		args  []interface{}
+	}{
+		{
+			name:  "user",
+			scope: trashScope{ownerID: 5},
+			conds: []string{"f.owner_id = ?", "d.creator_id = ?"},
+			args:  []interface{}{uint(5), uint(5)},
+		},
+		{
+			name:  "user in workflow",
+			scope: trashScope{ownerID: 5, workflowID: 2},
+			conds: []string{"f.owner_id = ? AND f.workflow_id = ?", "d.creator_id = ? AND d.workflow_id = ?"},
+			args:  []interface{}{uint(5), uint(2), uint(5), uint(2)},
+		},
+		{
+			name:  "expired",
+			scope: trashScope{before: &cutoff},
+			conds: []string{"COALESCE(f.deleted_at, f.updated_at) < ?", "COALESCE(d.deleted_at, d.updated_at) < ?"},
+			args:  []interface{}{cutoff, cutoff},
+		},
+	}
+
+	for _, tt := range tests {
+		query, args := trashItemsSQL(tt.scope)
+		for _, cond := range tt.conds {
+			if !strings.Contains(query, cond) {
+				t.Errorf("%s: query missing %q", tt.name, cond)
+			}
+		}
+		if strings.Count(query, "?") != len(args) {
+			t.Errorf("%s: %d placeholders, %d args", tt.name, strings.Count(query, "?"), len(args))
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.args)
+		}
+	}
+}
+
+func TestDeletedTogether(t *testing.T) {
+	a := time.Date(2024, 6, 1, 10, 0, 0, 123000, time.UTC)
+	b := a.In(time.FixedZone("CST", 8*3600))
+	c := a.Add(time.Microsecond)
+
+	if !deletedTogether(&a, &b) {
+		t.Error("same instant in different zones not deleted together")
+	}
+	if deletedTogether(&a, &c) {
+		t.Error("different instants deleted together")
+	}
+	if !deletedTogether(nil, nil) || deletedTogether(&a, nil) {
+		t.Error("nil deletion times handled incorrectly")
+	}
+}
+
+func TestToTrashItem(t *testing.T) {
+	s := &TrashService{retention: 30 * 24 * time.Hour}
+	deletedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	file := s.toTrashItem(&trashRow{Type: TrashItemFile, Name: "a.jpg", ParentPath: "拍摄/第一天", DeletedAt: deletedAt})
+	if file.Path != "拍摄/第一天/a.jpg" {
+		t.Errorf("file path = %q", file.Path)
+	}
+	if !file.PurgeAt.Equal(deletedAt.Add(30 * 24 * time.Hour)) {
+		t.Errorf("purge at = %v", file.PurgeAt)
+	}
+
+	root := s.toTrashItem(&trashRow{Type: TrashItemFile, Name: "b.jpg"})
+	if root.Path != "b.jpg" {
+		t.Errorf("root file path = %q", root.Path)
+	}
+
+	folder := s.toTrashItem(&trashRow{Type: TrashItemFolder, Name: "第一天", Path: "拍摄/第一天"})
+	if folder.Path != "拍摄/第一天" {
+		t.Errorf("folder path = %q", folder.Path)
+	}
+}
+
+func TestPurgeFolderKeepsOtherUsersFiles(t *testing.T) {
+	db := newTestDB(t)
+	master := createTestUser(t, db, "master", "")
+	alice := createTestUser(t, db, "alice", "")
+	bob := createTestUser(t, db, "bob", "")
+	workflow := createTestWorkflow(t, db, "shoot", master.ID, map[uint]string{
+		alice.ID: WorkflowRoleNormal,
+		bob.ID:   WorkflowRoleNormal,
+	})
+
+	// 主管删除了 alice 的文件夹，其中有 bob 的私有文件，文件夹在 alice 的回收站中
+	deletedAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	folder := &models.FileFolder{Name: "raw", Path: "raw", WorkflowID: workflow.ID, CreatorID: alice.ID, IsDeleted: true, DeletedAt: &deletedAt}
+	if err := db.Create(folder).Error; err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, db, &models.File{FileName: "a.cr2", OwnerID: alice.ID, FolderID: folder.ID, WorkflowID: workflow.ID,
+		IsDeleted: true, DeletedAt: &deletedAt})
+	kept := createTestFile(t, db, &models.File{FileName: "b.cr2", OwnerID: bob.ID, FolderID: folder.ID, WorkflowID: workflow.ID,
+		IsPrivate: true, IsDeleted: true, DeletedAt: &deletedAt})
+
+	s := NewTrashService(db, NewBlobService(db, storage.NewLocalStorage(t.TempDir())), 30*24*time.Hour)
+	result, err := s.Purge(TrashItemFolder, folder.ID, alice.ID)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if result.Files != 1 || result.Folders != 1 {
+		t.Errorf("purged %d files and %d folders, want 1 and 1", result.Files, result.Folders)
+	}
+
+	var file models.File
+	if err := db.First(&file, kept.ID).Error; err != nil {
+		t.Fatalf("other user's file purged: %v", err)
+	}
+	if !file.IsDeleted || file.FolderID != 0 || file.DeletedAt == nil || !file.DeletedAt.After(deletedAt) {
+		t.Errorf("kept file = folder %d, deleted %v at %v, want detached to root with a new deletion time",
+			file.FolderID, file.IsDeleted, file.DeletedAt)
+	}
+
+	trash, err := s.ListTrash(bob.ID, 0, 1, 20)
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if len(trash.Items) != 1 || trash.Items[0].ID != kept.ID {
+		t.Errorf("owner's trash = %+v, want the detached file", trash.Items)
+	}
+}
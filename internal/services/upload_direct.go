@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirectUploadRequest 单次请求上传的文件信息（multipart 表单字段）
+type DirectUploadRequest struct {
+	MD5Hash     string `form:"md5_hash"`  // 可选，与 file_size 一同提供时先尝试秒传，并校验上传内容
+	FileSize    int64  `form:"file_size"` // 可选
+	FolderID    uint   `form:"folder_id"`
+	WorkflowID  uint   `form:"workflow_id"`
+	TaskID      uint   `form:"task_id"`
+	Description string `form:"description"`
+	IsPrivate   bool   `form:"is_private"`
+	FileID      uint   `form:"file_id"`    // 非0时上传为该文件的新版本
+	ChangeLog   string `form:"change_log"` // 新版本的变更说明
+}
+
+// policyFor 获取工作流生效的上传策略
+func (s *UploadService) policyFor(workflowID uint) (*UploadPolicy, error) {
+	return resolveUploadPolicy(s.db, s.config, workflowID)
+}
+
+// DirectUpload 单次请求上传文件
+// 内容边读取边写入临时文件并计算MD5，通过上传策略校验后按内容哈希保存到文件存储；
+// 已存在相同内容的文件时按秒传处理，不再重复保存；指定 FileID 时作为该文件的新版本。
+func (s *UploadService) DirectUpload(req *DirectUploadRequest, fileName string, content io.Reader, userID uint) (*File, error) {
+	fileName = filepath.Base(fileName)
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		return nil, errors.New("文件名不能为空")
+	}
+
+	meta := &InitUploadRequest{
+		FileName:    fileName,
+		FileSize:    req.FileSize,
+		MD5Hash:     strings.ToLower(req.MD5Hash),
+		FolderID:    req.FolderID,
+		WorkflowID:  req.WorkflowID,
+		TaskID:      req.TaskID,
+		Description: req.Description,
+		IsPrivate:   req.IsPrivate,
+		FileID:      req.FileID,
+		ChangeLog:   req.ChangeLog,
+	}
+
+	target, err := s.versionTarget(meta, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUploadAccess(meta, userID); err != nil {
+		return nil, err
+	}
+	if err := checkWorkflowWritable(s.db, meta.WorkflowID); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.policyFor(meta.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.CheckDeclared(fileName, req.FileSize); err != nil {
+		return nil, err
+	}
+
+	// 声明了大小时在接收内容前检查配额
+	if meta.FileSize > 0 {
+		if err := s.checkQuota(s.db, userID, meta.WorkflowID, target, meta.FileSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// 客户端提供了MD5和大小时，先尝试秒传，无需读取文件内容
+	if meta.MD5Hash != "" && meta.FileSize > 0 {
+		if file, ok, err := s.instantUpload(meta, userID); err != nil {
+			return nil, err
+		} else if ok {
+			return file, nil
+		}
+	}
+
+	tmpPath, size, md5Hash, err := s.receiveDirectUpload(content, policy)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	if err := policy.CheckContent(fileName, tmpPath); err != nil {
+		return nil, err
+	}
+
+	if meta.MD5Hash != "" && meta.MD5Hash != md5Hash {
+		return nil, errors.New("文件MD5校验失败")
+	}
+	if meta.FileSize > 0 && meta.FileSize != size {
+		return nil, fmt.Errorf("文件大小不匹配: 期望 %d 字节，实际 %d 字节", meta.FileSize, size)
+	}
+	meta.MD5Hash = md5Hash
+	meta.FileSize = size
+
+	// 未声明大小时按实际大小检查配额
+	if req.FileSize <= 0 {
+		if err := s.checkQuota(s.db, userID, meta.WorkflowID, target, size); err != nil {
+			return nil, err
+		}
+	}
+
+	// 内容已存在时复用已有存储
+	if file, ok, err := s.instantUpload(meta, userID); err != nil {
+		return nil, err
+	} else if ok {
+		return file, nil
+	}
+
+	storageKey, err := s.blobs.Store(md5Hash, size, tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.commitUpload(meta, userID, storageKey, size, md5Hash, getMimeType(fileName))
+}
+
+// receiveDirectUpload 将上传内容写入临时文件，同时计算MD5并检查大小限制
+func (s *UploadService) receiveDirectUpload(content io.Reader, policy *UploadPolicy) (string, int64, string, error) {
+	if err := os.MkdirAll(s.config.File.TempPath, 0755); err != nil {
+		return "", 0, "", fmt.Errorf("创建临时目录失败: %v", err)
+	}
+
+	tmpFile, err := os.CreateTemp(s.config.File.TempPath, "direct_*.tmp")
+	if err != nil {
+		return "", 0, "", fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	// 多读取一个字节，用于判断是否超过大小限制
+	reader := content
+	if limit := policy.MaxBytes(); limit > 0 {
+		reader = io.LimitReader(content, limit+1)
+	}
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), reader)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", 0, "", fmt.Errorf("接收文件失败: %w", err)
+	}
+	if err := policy.CheckSize(size); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, "", err
+	}
+
+	return tmpPath, size, fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
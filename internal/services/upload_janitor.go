@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UploadCleanupResult 过期上传清理结果
+type UploadCleanupResult struct {
+	ExpiredSessions int   `json:"expired_sessions"` // 清理的过期会话数
+	OrphanDirs      int   `json:"orphan_dirs"`      // 清理的孤立临时目录/文件数
+	ReclaimedBytes  int64 `json:"reclaimed_bytes"`  // 回收的空间（字节）
+}
+
+// sessionTTL 上传会话过期时间
+func (s *UploadService) sessionTTL() time.Duration {
+	ttl := time.Duration(s.config.File.UploadSessionTTL) * time.Hour
+	if ttl <= 0 {
+		ttl = 72 * time.Hour
+	}
+	return ttl
+}
+
+// StartJanitor 启动后台清理任务，定期清理过期的上传会话和孤立的临时目录
+func (s *UploadService) StartJanitor() {
+	interval := time.Duration(s.config.File.UploadCleanupEvery) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			result, err := s.CleanupExpiredUploads()
+			if err != nil {
+				log.Printf("Warning: Failed to clean up expired uploads: %v", err)
+			} else if result.ExpiredSessions > 0 || result.OrphanDirs > 0 {
+				log.Printf("Cleaned up %d expired upload sessions and %d orphan temp entries, reclaimed %d bytes",
+					result.ExpiredSessions, result.OrphanDirs, result.ReclaimedBytes)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// CleanupExpiredUploads 清理过期的上传会话及其临时目录，并移除没有对应会话的孤立临时目录
+func (s *UploadService) CleanupExpiredUploads() (*UploadCleanupResult, error) {
+	result := &UploadCleanupResult{}
+	cutoff := time.Now().Add(-s.sessionTTL())
+
+	expired, err := s.sessions.ListExpired(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range expired {
+		reclaimed, removed := s.removeExpiredSession(session.UploadID, cutoff)
+		if removed {
+			result.ExpiredSessions++
+			result.ReclaimedBytes += reclaimed
+		}
+	}
+
+	// 清理孤立的临时目录（例如服务崩溃时遗留、或会话记录已丢失的目录）
+	entries, err := os.ReadDir(s.config.File.TempPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if _, err := s.sessions.Get(entry.Name()); !errors.Is(err, ErrUploadSessionNotFound) {
+			continue
+		}
+
+		path := filepath.Join(s.config.File.TempPath, entry.Name())
+		size := pathSize(path)
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("Warning: Failed to remove orphan temp entry %s: %v", path, err)
+			continue
+		}
+		result.OrphanDirs++
+		result.ReclaimedBytes += size
+	}
+
+	if s.stats != nil {
+		if err := s.stats.RecordReclaimedStorage(result.ReclaimedBytes); err != nil {
+			log.Printf("Warning: Failed to record reclaimed storage: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// removeExpiredSession 删除过期会话及其临时目录，返回回收的字节数
+func (s *UploadService) removeExpiredSession(uploadID string, cutoff time.Time) (int64, bool) {
+	unlock := s.locks.Lock(uploadID)
+	defer unlock()
+
+	session, err := s.sessions.Get(uploadID)
+	if err != nil {
+		return 0, false
+	}
+
+	// 加锁后再次确认分片目录在过期时间内没有新的写入
+	if info, err := os.Stat(session.TempDir); err == nil && info.ModTime().After(cutoff) {
+		return 0, false
+	}
+
+	size := pathSize(session.TempDir)
+	if err := os.RemoveAll(session.TempDir); err != nil {
+		log.Printf("Warning: Failed to remove temp dir %s: %v", session.TempDir, err)
+		return 0, false
+	}
+	if err := s.sessions.Delete(uploadID); err != nil {
+		log.Printf("Warning: Failed to delete upload session %s: %v", uploadID, err)
+		return size, false
+	}
+
+	return size, true
+}
+
+// pathSize 计算文件或目录占用的字节数
+func pathSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
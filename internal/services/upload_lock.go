@@ -0,0 +1,71 @@
+package services
+
+import (
+	"sync"
+)
+
+// uploadLocks 按上传ID划分的读写锁
+// 分片上传、进度查询持有读锁，可并行执行；完成上传、取消上传持有写锁，与其他操作互斥。
+// 锁仅在当前进程内有效，多副本部署时同一上传会话的请求应路由到同一实例。
+type uploadLocks struct {
+	mu    sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock 单个上传会话的锁，refs 为当前持有或等待该锁的请求数
+type uploadLock struct {
+	mu   sync.RWMutex
+	refs int
+}
+
+// newUploadLocks 创建上传会话锁
+func newUploadLocks() *uploadLocks {
+	return &uploadLocks{
+		locks: make(map[string]*uploadLock),
+	}
+}
+
+// acquire 获取（必要时创建）上传会话锁并增加引用计数
+func (l *uploadLocks) acquire(uploadID string) *uploadLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, exists := l.locks[uploadID]
+	if !exists {
+		lock = &uploadLock{}
+		l.locks[uploadID] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+// release 减少引用计数，无人使用时移除锁
+func (l *uploadLocks) release(uploadID string, lock *uploadLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, uploadID)
+	}
+}
+
+// RLock 获取上传会话读锁，返回解锁函数
+func (l *uploadLocks) RLock(uploadID string) func() {
+	lock := l.acquire(uploadID)
+	lock.mu.RLock()
+	return func() {
+		lock.mu.RUnlock()
+		l.release(uploadID, lock)
+	}
+}
+
+// Lock 获取上传会话写锁，返回解锁函数
+func (l *uploadLocks) Lock(uploadID string) func() {
+	lock := l.acquire(uploadID)
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		l.release(uploadID, lock)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcs-backend/internal/config"
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 上传策略错误码
+const (
+	UploadErrFileTooLarge        = "FILE_TOO_LARGE"        // 文件超过大小限制
+	UploadErrFileTypeNotAllowed  = "FILE_TYPE_NOT_ALLOWED" // 扩展名不在允许的类型中
+	UploadErrContentTypeMismatch = "CONTENT_TYPE_MISMATCH" // 文件内容与扩展名不符
+)
+
+var (
+	// ErrFileTooLarge 文件超过允许的最大大小
+	ErrFileTooLarge = errors.New("文件大小超过限制")
+	// ErrFileTypeNotAllowed 文件类型不在允许的范围内
+	ErrFileTypeNotAllowed = errors.New("不支持的文件类型")
+	// ErrContentTypeMismatch 文件内容与扩展名不符
+	ErrContentTypeMismatch = errors.New("文件内容与扩展名不符")
+)
+
+// UploadPolicyError 上传策略校验错误，Code 为返回给客户端的错误码
+type UploadPolicyError struct {
+	Code    string
+	Message string
+	err     error
+}
+
+func (e *UploadPolicyError) Error() string {
+	return e.Message
+}
+
+func (e *UploadPolicyError) Unwrap() error {
+	return e.err
+}
+
+// UploadPolicy 上传策略
+type UploadPolicy struct {
+	MaxFileSize  int64    `json:"max_file_size"` // 最大文件大小（MB），0 表示不限制
+	AllowedTypes []string `json:"allowed_types"` // 允许的扩展名（不含"."），为空表示不限制
+}
+
+// parseAllowedTypes 解析逗号分隔的扩展名列表
+func parseAllowedTypes(types string) []string {
+	result := make([]string, 0)
+	for _, t := range strings.Split(types, ",") {
+		t = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(t)), ".")
+		if t != "" {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// defaultUploadPolicy 全局上传策略
+func defaultUploadPolicy(cfg *config.Config) *UploadPolicy {
+	return &UploadPolicy{
+		MaxFileSize:  cfg.File.MaxFileSize,
+		AllowedTypes: parseAllowedTypes(cfg.File.AllowedTypes),
+	}
+}
+
+// resolveUploadPolicy 获取工作流生效的上传策略：工作流设置了覆盖项时使用覆盖值，否则沿用全局配置
+func resolveUploadPolicy(db *gorm.DB, cfg *config.Config, workflowID uint) (*UploadPolicy, error) {
+	policy := defaultUploadPolicy(cfg)
+	if workflowID == 0 || db == nil {
+		return policy, nil
+	}
+
+	var override models.WorkflowUploadPolicy
+	if err := db.Where("workflow_id = ?", workflowID).First(&override).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return policy, nil
+		}
+		return nil, fmt.Errorf("获取上传策略失败: %v", err)
+	}
+
+	if override.MaxFileSize != nil {
+		policy.MaxFileSize = *override.MaxFileSize
+	}
+	if override.AllowedTypes != nil {
+		policy.AllowedTypes = parseAllowedTypes(*override.AllowedTypes)
+	}
+	return policy, nil
+}
+
+// MaxBytes 最大文件大小（字节），0 表示不限制
+func (p *UploadPolicy) MaxBytes() int64 {
+	return p.MaxFileSize * 1024 * 1024
+}
+
+// CheckSize 检查文件大小
+func (p *UploadPolicy) CheckSize(size int64) error {
+	if limit := p.MaxBytes(); limit > 0 && size > limit {
+		return &UploadPolicyError{
+			Code:    UploadErrFileTooLarge,
+			Message: fmt.Sprintf("%v: 最大 %d MB", ErrFileTooLarge, p.MaxFileSize),
+			err:     ErrFileTooLarge,
+		}
+	}
+	return nil
+}
+
+// CheckType 检查文件扩展名
+func (p *UploadPolicy) CheckType(fileName string) error {
+	if len(p.AllowedTypes) == 0 {
+		return nil
+	}
+
+	ext := fileExt(fileName)
+	for _, allowed := range p.AllowedTypes {
+		if ext != "" && ext == allowed {
+			return nil
+		}
+	}
+	return &UploadPolicyError{
+		Code:    UploadErrFileTypeNotAllowed,
+		Message: fmt.Sprintf("%v: %s（允许: %s）", ErrFileTypeNotAllowed, fileName, strings.Join(p.AllowedTypes, ",")),
+		err:     ErrFileTypeNotAllowed,
+	}
+}
+
+// CheckDeclared 校验客户端声明的文件名和大小（size 为 0 时不检查大小）
+func (p *UploadPolicy) CheckDeclared(fileName string, size int64) error {
+	if err := p.CheckType(fileName); err != nil {
+		return err
+	}
+	return p.CheckSize(size)
+}
+
+// CheckContent 根据文件头部的魔数校验文件的真实类型与扩展名是否一致
+// 未收录魔数的扩展名（如 raw）不做内容校验
+func (p *UploadPolicy) CheckContent(fileName, path string) error {
+	header, err := readFileHeader(path)
+	if err != nil {
+		return fmt.Errorf("读取文件内容失败: %v", err)
+	}
+
+	match, known := magicSignatures[fileExt(fileName)]
+	if !known || match(header) {
+		return nil
+	}
+	return &UploadPolicyError{
+		Code:    UploadErrContentTypeMismatch,
+		Message: fmt.Sprintf("%v: %s", ErrContentTypeMismatch, fileName),
+		err:     ErrContentTypeMismatch,
+	}
+}
+
+// fileExt 获取小写且不含"."的扩展名
+func fileExt(fileName string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+}
+
+// readFileHeader 读取文件开头用于类型识别的字节
+func readFileHeader(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// magicSignatures 扩展名对应的文件头校验
+var magicSignatures = map[string]func([]byte) bool{
+	"jpg":  isJPEG,
+	"jpeg": isJPEG,
+	"png":  hasMagic(0, "\x89PNG\r\n\x1a\n"),
+	"gif": func(h []byte) bool {
+		return hasMagic(0, "GIF87a")(h) || hasMagic(0, "GIF89a")(h)
+	},
+	"bmp":  hasMagic(0, "BM"),
+	"webp": isRIFF("WEBP"),
+	"tif":  isTIFF,
+	"tiff": isTIFF,
+	"cr2": func(h []byte) bool {
+		return isTIFF(h) && hasMagic(8, "CR")(h)
+	},
+	"nef":  isTIFF,
+	"arw":  isTIFF,
+	"dng":  isTIFF,
+	"mp4":  isISOBMFF,
+	"m4v":  isISOBMFF,
+	"mov":  isISOBMFF,
+	"heic": isISOBMFF,
+	"avi":  isRIFF("AVI "),
+	"wav":  isRIFF("WAVE"),
+	"mp3": func(h []byte) bool {
+		return hasMagic(0, "ID3")(h) || (len(h) >= 2 && h[0] == 0xFF && h[1]&0xE0 == 0xE0)
+	},
+	"pdf": hasMagic(0, "%PDF-"),
+	"zip": hasMagic(0, "PK\x03\x04"),
+}
+
+// hasMagic 判断文件头在 offset 处是否为指定字节
+func hasMagic(offset int, magic string) func([]byte) bool {
+	return func(h []byte) bool {
+		return len(h) >= offset+len(magic) && bytes.Equal(h[offset:offset+len(magic)], []byte(magic))
+	}
+}
+
+func isJPEG(h []byte) bool {
+	return len(h) >= 3 && h[0] == 0xFF && h[1] == 0xD8 && h[2] == 0xFF
+}
+
+func isTIFF(h []byte) bool {
+	return hasMagic(0, "II*\x00")(h) || hasMagic(0, "MM\x00*")(h)
+}
+
+// isRIFF RIFF 容器（AVI、WAV、WebP）
+func isRIFF(format string) func([]byte) bool {
+	return func(h []byte) bool {
+		return hasMagic(0, "RIFF")(h) && hasMagic(8, format)(h)
+	}
+}
+
+// isISOBMFF ISO 基础媒体文件格式（MP4、MOV、HEIC）
+func isISOBMFF(h []byte) bool {
+	if len(h) < 8 {
+		return false
+	}
+	switch string(h[4:8]) {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
@@ -2,31 +2,63 @@ package services
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mcs-backend/internal/config"
 	"mcs-backend/internal/database"
 	"mcs-backend/internal/models"
+	"mcs-backend/internal/storage"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UploadService 文件上传服务
 type UploadService struct {
-	db     *gorm.DB
-	config *config.Config
+	db       *gorm.DB
+	config   *config.Config
+	sessions UploadSessionStore
+	locks    *uploadLocks
+	stats    *StatisticsService
+	blobs    *BlobService
+	quotas   *QuotaService
+	authz    *AuthzService
+	search   *SearchIndexer
+	jobs     *JobQueue
 }
 
 // NewUploadService 创建文件上传服务
 func NewUploadService(cfg *config.Config) *UploadService {
+	db := database.GetDB()
+
+	sessions, err := NewUploadSessionStore(cfg.File.UploadSessionStore, db)
+	if err != nil {
+		log.Printf("Warning: Failed to create upload session store, falling back to memory: %v", err)
+		sessions = NewMemoryUploadSessionStore()
+	}
+
+	store := storage.GetStorage()
+
 	return &UploadService{
-		db:     database.GetDB(),
-		config: cfg,
+		db:       db,
+		config:   cfg,
+		sessions: sessions,
+		locks:    newUploadLocks(),
+		stats:    NewStatisticsService(db),
+		blobs:    NewBlobService(db, store),
+		quotas:   NewQuotaService(db),
+		authz:    NewAuthzService(db),
+		search:   NewSearchIndexer(db),
+		jobs:     NewJobQueue(db),
 	}
 }
 
@@ -57,6 +89,8 @@ type InitUploadRequest struct {
 	TaskID      uint   `json:"task_id"`
 	Description string `json:"description"`
 	IsPrivate   bool   `json:"is_private"`
+	FileID      uint   `json:"file_id"`    // 非0时上传为该文件的新版本，文件夹、工作流等沿用原文件
+	ChangeLog   string `json:"change_log"` // 新版本的变更说明
 }
 
 // InitUploadResponse 初始化上传响应
@@ -88,89 +122,160 @@ type File struct {
 
 // UploadProgress 上传进度
 type UploadProgress struct {
-	UploadID        string `json:"upload_id"`
-	FileName        string `json:"file_name"`
-	FileSize        int64  `json:"file_size"`
-	UploadedSize    int64  `json:"uploaded_size"`
-	TotalChunks     int    `json:"total_chunks"`
-	UploadedChunks  int    `json:"uploaded_chunks"`
-	Progress        float64 `json:"progress"`
-	Status          string `json:"status"` // uploading, completed, failed
-	CreatedAt       time.Time `json:"created_at"`
-}
-
-// UploadSession 上传会话（存储在内存或Redis中）
+	UploadID       string    `json:"upload_id"`
+	FileName       string    `json:"file_name"`
+	FileSize       int64     `json:"file_size"`
+	UploadedSize   int64     `json:"uploaded_size"`
+	TotalChunks    int       `json:"total_chunks"`
+	UploadedChunks int       `json:"uploaded_chunks"`
+	Progress       float64   `json:"progress"`
+	Status         string    `json:"status"` // uploading, completed, failed
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ResumeUploadRequest 断点续传查询请求
+type ResumeUploadRequest struct {
+	MD5Hash  string `json:"md5_hash" binding:"required"`
+	FileSize int64  `json:"file_size" binding:"required"`
+}
+
+// ResumeUploadResponse 断点续传查询响应
+type ResumeUploadResponse struct {
+	UploadID       string    `json:"upload_id"`
+	FileName       string    `json:"file_name"`
+	FileSize       int64     `json:"file_size"`
+	ChunkSize      int64     `json:"chunk_size"`
+	TotalChunks    int       `json:"total_chunks"`
+	UploadedChunks []int     `json:"uploaded_chunks"`
+	MissingChunks  []int     `json:"missing_chunks"`
+	FileID         uint      `json:"file_id,omitempty"` // 上传为新版本时的目标文件
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// UploadSession 上传会话（由 UploadSessionStore 保存）
+// UploadedChunks 可能被并发的分片上传修改，访问时需通过 markChunk / uploadedChunkList
 type UploadSession struct {
-	UploadID     string
-	FileName     string
-	FileSize     int64
-	MD5Hash      string
-	ChunkSize    int64
-	TotalChunks  int
+	mu sync.Mutex
+
+	UploadID       string
+	FileName       string
+	FileSize       int64
+	MD5Hash        string
+	ChunkSize      int64
+	TotalChunks    int
 	UploadedChunks map[int]bool
-	UserID       uint
-	FolderID     uint
-	WorkflowID   uint
-	TaskID       uint
-	Description  string
-	IsPrivate    bool
-	CreatedAt    time.Time
-	TempDir      string
+	UserID         uint
+	FolderID       uint
+	WorkflowID     uint
+	TaskID         uint
+	Description    string
+	IsPrivate      bool
+	FileID         uint   // 非0时为该文件的新版本
+	ChangeLog      string // 新版本的变更说明
+	CreatedAt      time.Time
+	TempDir        string
 }
 
-// 内存中的上传会话存储
-var uploadSessions = make(map[string]*UploadSession)
+// markChunk 标记分片已上传
+func (us *UploadSession) markChunk(chunkIndex int) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.UploadedChunks == nil {
+		us.UploadedChunks = make(map[int]bool)
+	}
+	us.UploadedChunks[chunkIndex] = true
+}
+
+// unmarkChunk 取消分片的已上传标记（分片损坏时使用）
+func (us *UploadSession) unmarkChunk(chunkIndex int) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	delete(us.UploadedChunks, chunkIndex)
+}
+
+// chunkLength 获取分片应有的大小（最后一个分片可能不是完整大小）
+func (us *UploadSession) chunkLength(chunkIndex int) int64 {
+	if chunkIndex == us.TotalChunks-1 {
+		return us.FileSize - int64(chunkIndex)*us.ChunkSize
+	}
+	return us.ChunkSize
+}
+
+// uploadedChunkList 获取已上传和缺失的分片索引（按索引升序）
+func (us *UploadSession) uploadedChunkList() (uploaded []int, missing []int) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	uploaded = make([]int, 0, us.TotalChunks)
+	missing = make([]int, 0)
+	for i := 0; i < us.TotalChunks; i++ {
+		if us.UploadedChunks[i] {
+			uploaded = append(uploaded, i)
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	return uploaded, missing
+}
+
+const (
+	// chunkFilePrefix 分片文件名前缀，分片文件保存为 <TempDir>/chunk_<index>
+	chunkFilePrefix = "chunk_"
+	// chunkHashSuffix 分片MD5文件后缀，保存为 <TempDir>/chunk_<index>.md5
+	chunkHashSuffix = ".md5"
+)
 
 // InitUpload 初始化上传
 func (s *UploadService) InitUpload(req *InitUploadRequest, userID uint) (*InitUploadResponse, error) {
-	// 检查是否可以秒传
-	var existingFile models.File
-	if err := s.db.Where("md5_hash = ? AND file_size = ? AND is_deleted = false", req.MD5Hash, req.FileSize).First(&existingFile).Error; err == nil {
-		// 秒传：复制文件记录
-		newFile := models.File{
-			FileName:    req.FileName,
-			FilePath:    existingFile.FilePath,
-			FileSize:    existingFile.FileSize,
-			MD5Hash:     existingFile.MD5Hash,
-			MimeType:    existingFile.MimeType,
-			OwnerID:     userID,
-			FolderID:    req.FolderID,
-			WorkflowID:  req.WorkflowID,
-			TaskID:      req.TaskID,
-			IsPrivate:   req.IsPrivate,
-			Description: req.Description,
-		}
+	// 客户端可能提交大写的十六进制MD5，统一转为小写后保存和比较
+	req.MD5Hash = strings.ToLower(req.MD5Hash)
+	if req.FileSize <= 0 || req.ChunkSize <= 0 {
+		return nil, errors.New("文件大小和分片大小必须大于0")
+	}
 
-		if err := s.db.Create(&newFile).Error; err != nil {
-			return nil, fmt.Errorf("创建文件记录失败: %v", err)
-		}
+	target, err := s.versionTarget(req, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUploadAccess(req, userID); err != nil {
+		return nil, err
+	}
+	if err := checkWorkflowWritable(s.db, req.WorkflowID); err != nil {
+		return nil, err
+	}
+
+	// 校验声明的文件类型和大小
+	policy, err := s.policyFor(req.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.CheckDeclared(req.FileName, req.FileSize); err != nil {
+		return nil, err
+	}
 
+	// 秒传同样占用配额，在秒传前检查
+	if err := s.checkQuota(s.db, userID, req.WorkflowID, target, req.FileSize); err != nil {
+		return nil, err
+	}
+
+	// 检查是否可以秒传
+	if file, ok, err := s.instantUpload(req, userID); err != nil {
+		return nil, err
+	} else if ok {
 		return &InitUploadResponse{
-			ExistingFile: &File{
-				ID:          newFile.ID,
-				FileName:    newFile.FileName,
-				FilePath:    newFile.FilePath,
-				FileSize:    newFile.FileSize,
-				MD5Hash:     newFile.MD5Hash,
-				MimeType:    newFile.MimeType,
-				OwnerID:     newFile.OwnerID,
-				FolderID:    newFile.FolderID,
-				WorkflowID:  newFile.WorkflowID,
-				TaskID:      newFile.TaskID,
-				IsPrivate:   newFile.IsPrivate,
-				Description: newFile.Description,
-				CreatedAt:   newFile.CreatedAt,
-				UpdatedAt:   newFile.UpdatedAt,
-			},
-			IsSecUpload: true,
+			ExistingFile: file,
+			IsSecUpload:  true,
 		}, nil
 	}
 
 	// 计算分片数量
 	totalChunks := int((req.FileSize + req.ChunkSize - 1) / req.ChunkSize)
 
-	// 生成上传ID
-	uploadID := fmt.Sprintf("%d_%s_%d", userID, req.MD5Hash, time.Now().Unix())
+	// 生成随机上传ID，同一文件的多次初始化不会共用会话和临时目录
+	uploadID, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建临时目录
 	tempDir := filepath.Join(s.config.File.TempPath, uploadID)
@@ -193,11 +298,16 @@ func (s *UploadService) InitUpload(req *InitUploadRequest, userID uint) (*InitUp
 		TaskID:         req.TaskID,
 		Description:    req.Description,
 		IsPrivate:      req.IsPrivate,
+		FileID:         req.FileID,
+		ChangeLog:      req.ChangeLog,
 		CreatedAt:      time.Now(),
 		TempDir:        tempDir,
 	}
 
-	uploadSessions[uploadID] = session
+	if err := s.sessions.Save(session); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("保存上传会话失败: %v", err)
+	}
 
 	return &InitUploadResponse{
 		UploadID:    uploadID,
@@ -207,115 +317,183 @@ func (s *UploadService) InitUpload(req *InitUploadRequest, userID uint) (*InitUp
 	}, nil
 }
 
-// UploadChunk 上传分片
-func (s *UploadService) UploadChunk(uploadID string, chunkIndex int, chunkData []byte, chunkMD5 string) error {
-	session, exists := uploadSessions[uploadID]
-	if !exists {
-		return errors.New("上传会话不存在")
-	}
-
-	// 验证分片MD5
-	hash := md5.Sum(chunkData)
-	if fmt.Sprintf("%x", hash) != chunkMD5 {
-		return errors.New("分片MD5校验失败")
+// instantUpload 秒传：已存在相同内容（MD5与大小相同）的文件时，直接创建共享存储内容的文件记录或新版本
+func (s *UploadService) instantUpload(req *InitUploadRequest, userID uint) (*File, bool, error) {
+	var existingFile models.File
+	if err := s.db.Where("md5_hash = ? AND file_size = ? AND is_deleted = false", req.MD5Hash, req.FileSize).First(&existingFile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("检查秒传失败: %v", err)
 	}
 
-	// 保存分片文件
-	chunkPath := filepath.Join(session.TempDir, fmt.Sprintf("chunk_%d", chunkIndex))
-	file, err := os.Create(chunkPath)
+	file, err := s.commitUpload(req, userID, existingFile.FilePath, existingFile.FileSize, existingFile.MD5Hash, existingFile.MimeType)
 	if err != nil {
-		return fmt.Errorf("创建分片文件失败: %v", err)
+		return nil, false, err
 	}
-	defer file.Close()
+	return file, true, nil
+}
 
-	if _, err := file.Write(chunkData); err != nil {
-		return fmt.Errorf("写入分片文件失败: %v", err)
+// versionTarget 上传为已有文件的新版本时获取目标文件（需有修改文件的权限），
+// 并使上传沿用目标文件的文件夹、工作流、任务和私有设置；上传新文件时返回 nil
+func (s *UploadService) versionTarget(req *InitUploadRequest, userID uint) (*models.File, error) {
+	if req.FileID == 0 {
+		return nil, nil
 	}
 
-	// 标记分片已上传
-	session.UploadedChunks[chunkIndex] = true
+	target, err := s.editableFile(req.FileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.MD5Hash != "" && strings.EqualFold(req.MD5Hash, target.MD5Hash) && req.FileSize == target.FileSize {
+		return nil, ErrVersionUnchanged
+	}
 
-	return nil
+	req.FolderID = target.FolderID
+	req.WorkflowID = target.WorkflowID
+	req.TaskID = target.TaskID
+	req.IsPrivate = target.IsPrivate
+	return target, nil
 }
 
-// CompleteUpload 完成上传
-func (s *UploadService) CompleteUpload(uploadID string) (*File, error) {
-	session, exists := uploadSessions[uploadID]
-	if !exists {
-		return nil, errors.New("上传会话不存在")
+// editableFile 获取可上传新版本的文件（需有修改文件的权限）
+func (s *UploadService) editableFile(fileID, userID uint) (*models.File, error) {
+	var target models.File
+	if err := s.db.Where("id = ? AND is_deleted = false", fileID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件不存在或无权限上传新版本")
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
 	}
-
-	// 检查所有分片是否都已上传
-	for i := 0; i < session.TotalChunks; i++ {
-		if !session.UploadedChunks[i] {
-			return nil, fmt.Errorf("分片 %d 未上传", i)
+	if err := s.authz.CheckFile(userID, &target, ActionEdit); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return nil, errors.New("文件不存在或无权限上传新版本")
 		}
+		return nil, err
 	}
+	return &target, nil
+}
 
-	// 合并分片
-	finalPath := filepath.Join(s.config.File.UploadPath, fmt.Sprintf("%s_%s", session.MD5Hash, session.FileName))
-	if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
-		return nil, fmt.Errorf("创建目标目录失败: %v", err)
+// checkUploadAccess 检查用户能否向目标位置上传新文件：指定文件夹时需能在文件夹中添加内容，
+// 否则需能向工作流上传；指定任务时任务须属于该工作流，且需能向任务添加文件。上传新版本时由 versionTarget 检查
+func (s *UploadService) checkUploadAccess(req *InitUploadRequest, userID uint) error {
+	if req.FileID != 0 {
+		return nil
 	}
 
-	finalFile, err := os.Create(finalPath)
-	if err != nil {
-		return nil, fmt.Errorf("创建最终文件失败: %v", err)
+	if req.FolderID > 0 {
+		var folder models.FileFolder
+		if err := s.db.Where("id = ? AND workflow_id = ? AND is_deleted = false", req.FolderID, req.WorkflowID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("目标文件夹不存在")
+			}
+			return fmt.Errorf("获取文件夹信息失败: %v", err)
+		}
+		if err := s.authz.CheckFolder(userID, &folder, ActionUpload); err != nil {
+			return err
+		}
+	} else if req.WorkflowID > 0 {
+		if err := s.authz.CheckWorkflow(userID, req.WorkflowID, ActionUpload); err != nil {
+			return err
+		}
 	}
-	defer finalFile.Close()
 
-	// 按顺序合并分片
-	for i := 0; i < session.TotalChunks; i++ {
-		chunkPath := filepath.Join(session.TempDir, fmt.Sprintf("chunk_%d", i))
-		chunkFile, err := os.Open(chunkPath)
-		if err != nil {
-			return nil, fmt.Errorf("打开分片文件失败: %v", err)
+	if req.TaskID > 0 {
+		var task models.TaskEnhanced
+		if err := s.db.Where("id = ? AND workflow_id = ? AND is_deleted = false", req.TaskID, req.WorkflowID).First(&task).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("任务不存在或不属于该工作流")
+			}
+			return fmt.Errorf("获取任务信息失败: %v", err)
 		}
-
-		if _, err := io.Copy(finalFile, chunkFile); err != nil {
-			chunkFile.Close()
-			return nil, fmt.Errorf("合并分片失败: %v", err)
+		if err := s.authz.CheckTask(userID, &task, ActionUpload); err != nil {
+			return err
 		}
-		chunkFile.Close()
 	}
+	return nil
+}
 
-	// 验证最终文件MD5
-	finalFile.Seek(0, 0)
-	hash := md5.New()
-	if _, err := io.Copy(hash, finalFile); err != nil {
-		return nil, fmt.Errorf("计算文件MD5失败: %v", err)
+// checkQuota 检查上传是否超出配额，新版本只计算相对当前版本增加的大小
+// 接收内容前的检查用于尽早拒绝，写入文件记录的事务中会再次检查
+func (s *UploadService) checkQuota(db *gorm.DB, userID, workflowID uint, target *models.File, size int64) error {
+	if target != nil {
+		size -= target.FileSize
+	}
+	if size <= 0 {
+		return nil
 	}
+	return s.quotas.CheckQuota(db, userID, workflowID, size)
+}
 
-	if fmt.Sprintf("%x", hash.Sum(nil)) != session.MD5Hash {
-		return nil, errors.New("文件MD5校验失败")
+// commitUpload 内容保存后创建文件记录并增加对存储内容的引用；上传为新版本时为目标文件创建新版本
+// 同一事务中锁定配额后重新检查配额，并提交提取拍摄信息、生成缩略图的后台任务
+func (s *UploadService) commitUpload(req *InitUploadRequest, userID uint, storageKey string, size int64, md5Hash, mimeType string) (*File, error) {
+	if req.FileID != 0 {
+		var file models.File
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			var current models.File
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, req.FileID).Error; err != nil {
+				return fmt.Errorf("获取文件信息失败: %v", err)
+			}
+			if err := s.checkQuota(tx, userID, current.WorkflowID, &current, size); err != nil {
+				return err
+			}
+			if _, err := saveFileVersion(tx, s.blobs, req.FileID, storageKey, size, md5Hash, req.ChangeLog, userID); err != nil {
+				return err
+			}
+			if err := s.jobs.enqueueFileProcessing(tx, req.FileID); err != nil {
+				return err
+			}
+			return tx.First(&file, req.FileID).Error
+		}); err != nil {
+			return nil, err
+		}
+		return toUploadedFile(&file), nil
 	}
 
-	// 获取文件MIME类型
-	mimeType := getMimeType(session.FileName)
+	// 上传期间工作流可能已被归档或完成
+	if err := checkWorkflowWritable(s.db, req.WorkflowID); err != nil {
+		return nil, err
+	}
 
-	// 创建文件记录
 	file := models.File{
-		FileName:    session.FileName,
-		FilePath:    finalPath,
-		FileSize:    session.FileSize,
-		MD5Hash:     session.MD5Hash,
+		FileName:    req.FileName,
+		FilePath:    storageKey,
+		FileSize:    size,
+		MD5Hash:     md5Hash,
 		MimeType:    mimeType,
-		OwnerID:     session.UserID,
-		FolderID:    session.FolderID,
-		WorkflowID:  session.WorkflowID,
-		TaskID:      session.TaskID,
-		IsPrivate:   session.IsPrivate,
-		Description: session.Description,
+		OwnerID:     userID,
+		FolderID:    req.FolderID,
+		WorkflowID:  req.WorkflowID,
+		TaskID:      req.TaskID,
+		IsPrivate:   req.IsPrivate,
+		Description: req.Description,
 	}
 
-	if err := s.db.Create(&file).Error; err != nil {
-		return nil, fmt.Errorf("创建文件记录失败: %v", err)
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkQuota(tx, userID, req.WorkflowID, nil, size); err != nil {
+			return err
+		}
+		if err := tx.Create(&file).Error; err != nil {
+			return fmt.Errorf("创建文件记录失败: %v", err)
+		}
+		if err := s.jobs.enqueueFileProcessing(tx, file.ID); err != nil {
+			return fmt.Errorf("创建文件记录失败: %v", err)
+		}
+		if err := s.blobs.Acquire(tx, storageKey); err != nil {
+			return fmt.Errorf("创建文件记录失败: %v", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
+	s.search.indexQuietly(file.ID)
 
-	// 清理临时文件和会话
-	os.RemoveAll(session.TempDir)
-	delete(uploadSessions, uploadID)
+	return toUploadedFile(&file), nil
+}
 
+// toUploadedFile 将文件记录转换为上传结果
+func toUploadedFile(file *models.File) *File {
 	return &File{
 		ID:          file.ID,
 		FileName:    file.FileName,
@@ -331,28 +509,257 @@ func (s *UploadService) CompleteUpload(uploadID string) (*File, error) {
 		Description: file.Description,
 		CreatedAt:   file.CreatedAt,
 		UpdatedAt:   file.UpdatedAt,
-	}, nil
+	}
 }
 
-// GetUploadProgress 获取上传进度
-func (s *UploadService) GetUploadProgress(uploadID string) (*UploadProgress, error) {
-	session, exists := uploadSessions[uploadID]
-	if !exists {
-		return nil, errors.New("上传会话不存在")
+// UploadChunk 上传分片
+func (s *UploadService) UploadChunk(uploadID string, userID uint, chunkIndex int, chunkData []byte, chunkMD5 string) error {
+	// 验证分片MD5
+	hash := md5.Sum(chunkData)
+	if !strings.EqualFold(fmt.Sprintf("%x", hash), chunkMD5) {
+		return errors.New("分片MD5校验失败")
 	}
+	chunkMD5 = strings.ToLower(chunkMD5)
 
-	uploadedChunks := 0
-	uploadedSize := int64(0)
+	// 分片上传之间共享读锁，可并行写入不同分片；完成或取消上传时会等待进行中的分片写入结束
+	unlock := s.locks.RLock(uploadID)
+	defer unlock()
+
+	session, err := s.ownSession(uploadID, userID)
+	if err != nil {
+		return err
+	}
+
+	if chunkIndex < 0 || chunkIndex >= session.TotalChunks {
+		return fmt.Errorf("分片索引超出范围: %d", chunkIndex)
+	}
+
+	// 分片大小必须与初始化时声明的一致，避免上传内容超出已校验的文件大小
+	if expected := session.chunkLength(chunkIndex); int64(len(chunkData)) != expected {
+		return fmt.Errorf("分片 %d 大小不正确: 期望 %d 字节，实际 %d 字节", chunkIndex, expected, len(chunkData))
+	}
+
+	// 保存分片文件：先写入临时文件再重命名，保证重启后扫描到的分片都是完整的
+	if err := os.MkdirAll(session.TempDir, 0755); err != nil {
+		return fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	// 同一分片可能被客户端重试并发上传，临时文件名需唯一
+	chunkPath := chunkFilePath(session.TempDir, chunkIndex)
+	tmpFile, err := os.CreateTemp(session.TempDir, filepath.Base(chunkPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建分片文件失败: %v", err)
+	}
+	if _, err := tmpFile.Write(chunkData); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("写入分片文件失败: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("写入分片文件失败: %v", err)
+	}
+	// 分片MD5先于分片数据落盘，合并时逐片校验
+	if err := os.WriteFile(chunkPath+chunkHashSuffix, []byte(chunkMD5), 0644); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("保存分片MD5失败: %v", err)
+	}
+	if err := os.Rename(tmpFile.Name(), chunkPath); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("保存分片文件失败: %v", err)
+	}
+
+	// 标记分片已上传
+	session.markChunk(chunkIndex)
+
+	return s.sessions.Touch(uploadID)
+}
+
+// ownSession 获取当前用户的上传会话，其他用户的会话按不存在处理
+func (s *UploadService) ownSession(uploadID string, userID uint) (*UploadSession, error) {
+	session, err := s.sessions.Get(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrUploadSessionNotFound
+	}
+	return session, nil
+}
+
+// newUploadID 生成随机上传ID（32位十六进制），同时用作临时目录名
+func newUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成上传ID失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// chunkFilePath 获取分片文件路径
+func chunkFilePath(tempDir string, chunkIndex int) string {
+	return filepath.Join(tempDir, fmt.Sprintf("%s%d", chunkFilePrefix, chunkIndex))
+}
+
+// CompleteUpload 完成上传
+func (s *UploadService) CompleteUpload(uploadID string, userID uint) (*File, error) {
+	// 合并期间独占会话，阻止新的分片写入和取消操作
+	unlock := s.locks.Lock(uploadID)
+	defer unlock()
+
+	session, err := s.ownSession(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 上传期间用户可能已失去对目标位置的权限，提交前重新检查
+	req := &InitUploadRequest{
+		FileName:    session.FileName,
+		FolderID:    session.FolderID,
+		WorkflowID:  session.WorkflowID,
+		TaskID:      session.TaskID,
+		Description: session.Description,
+		IsPrivate:   session.IsPrivate,
+		FileID:      session.FileID,
+		ChangeLog:   session.ChangeLog,
+	}
+	if req.FileID != 0 {
+		if _, err := s.editableFile(req.FileID, userID); err != nil {
+			return nil, err
+		}
+	} else if err := s.checkUploadAccess(req, userID); err != nil {
+		return nil, err
+	}
+
+	// 检查所有分片是否都已上传
+	if _, missing := session.uploadedChunkList(); len(missing) > 0 {
+		return nil, fmt.Errorf("分片 %d 未上传", missing[0])
+	}
+
+	// 合并分片到会话临时目录，校验通过后写入文件存储
+	mergedPath := filepath.Join(session.TempDir, "merged")
+	if err := mergeChunks(session, mergedPath); err != nil {
+		return nil, err
+	}
+
+	// 根据文件头识别真实类型，与扩展名不符的文件无法通过重传修复，直接结束会话
+	policy, err := s.policyFor(session.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.CheckContent(session.FileName, mergedPath); err != nil {
+		os.RemoveAll(session.TempDir)
+		s.sessions.Delete(uploadID)
+		return nil, err
+	}
+
+	// 按内容哈希保存，内容相同的文件共享同一份存储
+	storageKey, err := s.blobs.Store(session.MD5Hash, session.FileSize, mergedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建文件记录或新版本
+	file, err := s.commitUpload(req, session.UserID, storageKey, session.FileSize, session.MD5Hash, getMimeType(session.FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	// 清理临时文件和会话
+	os.RemoveAll(session.TempDir)
+	s.sessions.Delete(uploadID)
+
+	return file, nil
+}
+
+// mergeChunks 按顺序合并分片到目标文件
+// 合并时逐片校验分片MD5并增量计算整个文件的MD5，结果先写入同目录下的临时文件，
+// 校验通过后再原子重命名为目标文件，校验失败不会留下不完整的文件。
+func mergeChunks(session *UploadSession, finalPath string) error {
+	dir := filepath.Dir(finalPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %v", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(finalPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建最终文件失败: %v", err)
+	}
+	tmpPath := tmpFile.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	fileHash := md5.New()
+	var written int64
 	for i := 0; i < session.TotalChunks; i++ {
-		if session.UploadedChunks[i] {
-			uploadedChunks++
-			if i == session.TotalChunks-1 {
-				// 最后一个分片可能不是完整大小
-				uploadedSize += session.FileSize - int64(i)*session.ChunkSize
-			} else {
-				uploadedSize += session.ChunkSize
-			}
+		chunkPath := chunkFilePath(session.TempDir, i)
+		expected, err := os.ReadFile(chunkPath + chunkHashSuffix)
+		if err != nil {
+			return fmt.Errorf("读取分片 %d 的MD5失败: %v", i, err)
 		}
+
+		chunkFile, err := os.Open(chunkPath)
+		if err != nil {
+			return fmt.Errorf("打开分片文件失败: %v", err)
+		}
+
+		chunkHash := md5.New()
+		n, err := io.Copy(io.MultiWriter(tmpFile, fileHash, chunkHash), chunkFile)
+		chunkFile.Close()
+		if err != nil {
+			return fmt.Errorf("合并分片失败: %v", err)
+		}
+
+		if !strings.EqualFold(fmt.Sprintf("%x", chunkHash.Sum(nil)), strings.TrimSpace(string(expected))) {
+			// 移除损坏的分片，客户端可通过断点续传重新上传
+			os.Remove(chunkPath)
+			os.Remove(chunkPath + chunkHashSuffix)
+			session.unmarkChunk(i)
+			return fmt.Errorf("分片 %d MD5校验失败", i)
+		}
+		written += n
+	}
+
+	if written != session.FileSize {
+		return fmt.Errorf("文件大小不匹配: 期望 %d 字节，实际 %d 字节", session.FileSize, written)
+	}
+	if !strings.EqualFold(fmt.Sprintf("%x", fileHash.Sum(nil)), session.MD5Hash) {
+		return errors.New("文件MD5校验失败")
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("写入最终文件失败: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("写入最终文件失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return fmt.Errorf("保存最终文件失败: %v", err)
+	}
+	committed = true
+
+	return nil
+}
+
+// GetUploadProgress 获取上传进度
+func (s *UploadService) GetUploadProgress(uploadID string, userID uint) (*UploadProgress, error) {
+	unlock := s.locks.RLock(uploadID)
+	defer unlock()
+
+	session, err := s.ownSession(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded, _ := session.uploadedChunkList()
+	uploadedChunks := len(uploaded)
+	uploadedSize := int64(0)
+	for _, i := range uploaded {
+		uploadedSize += session.chunkLength(i)
 	}
 
 	progress := float64(uploadedChunks) / float64(session.TotalChunks) * 100
@@ -374,18 +781,50 @@ func (s *UploadService) GetUploadProgress(uploadID string) (*UploadProgress, err
 	}, nil
 }
 
+// ResumeUpload 查找用户对同一文件未完成的上传会话，返回缺失的分片索引
+func (s *UploadService) ResumeUpload(req *ResumeUploadRequest, userID uint) (*ResumeUploadResponse, error) {
+	found, err := s.sessions.FindByFile(userID, strings.ToLower(req.MD5Hash), req.FileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.locks.RLock(found.UploadID)
+	defer unlock()
+
+	// 加锁后重新获取，避免返回刚被完成或取消的会话
+	session, err := s.sessions.Get(found.UploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadedChunks, missingChunks := session.uploadedChunkList()
+
+	return &ResumeUploadResponse{
+		UploadID:       session.UploadID,
+		FileName:       session.FileName,
+		FileSize:       session.FileSize,
+		ChunkSize:      session.ChunkSize,
+		TotalChunks:    session.TotalChunks,
+		UploadedChunks: uploadedChunks,
+		MissingChunks:  missingChunks,
+		FileID:         session.FileID,
+		CreatedAt:      session.CreatedAt,
+	}, nil
+}
+
 // CancelUpload 取消上传
-func (s *UploadService) CancelUpload(uploadID string) error {
-	session, exists := uploadSessions[uploadID]
-	if !exists {
-		return errors.New("上传会话不存在")
+func (s *UploadService) CancelUpload(uploadID string, userID uint) error {
+	unlock := s.locks.Lock(uploadID)
+	defer unlock()
+
+	session, err := s.ownSession(uploadID, userID)
+	if err != nil {
+		return err
 	}
 
 	// 清理临时文件和会话
 	os.RemoveAll(session.TempDir)
-	delete(uploadSessions, uploadID)
-
-	return nil
+	return s.sessions.Delete(uploadID)
 }
 
 // getMimeType 根据文件扩展名获取MIME类型
@@ -416,4 +855,4 @@ func getMimeType(fileName string) string {
 		return mimeType
 	}
 	return "application/octet-stream"
-}
\ No newline at end of file
+}
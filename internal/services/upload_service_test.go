@@ -0,0 +1,524 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mcs-backend/internal/config"
+	"mcs-backend/internal/storage"
+)
+
+// newTestUploadService 创建使用内存会话存储、不依赖数据库的上传服务
+func newTestUploadService(t *testing.T) *UploadService {
+	t.Helper()
+
+	root := t.TempDir()
+	cfg := &config.Config{
+		File: config.FileConfig{
+			UploadPath: filepath.Join(root, "uploads"),
+			TempPath:   filepath.Join(root, "temp"),
+		},
+	}
+
+	return &UploadService{
+		config:   cfg,
+		sessions: NewMemoryUploadSessionStore(),
+		locks:    newUploadLocks(),
+		blobs:    NewBlobService(nil, storage.NewLocalStorage(cfg.File.UploadPath)),
+	}
+}
+
+// newTestSession 创建测试用上传会话
+func newTestSession(t *testing.T, s *UploadService, uploadID string, totalChunks int, chunkSize int64) *UploadSession {
+	t.Helper()
+
+	tempDir := filepath.Join(s.config.File.TempPath, uploadID)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+
+	session := &UploadSession{
+		UploadID:       uploadID,
+		FileName:       "photo.cr2",
+		FileSize:       int64(totalChunks) * chunkSize,
+		MD5Hash:        "d41d8cd98f00b204e9800998ecf8427e",
+		ChunkSize:      chunkSize,
+		TotalChunks:    totalChunks,
+		UploadedChunks: make(map[int]bool),
+		UserID:         1,
+		CreatedAt:      time.Now(),
+		TempDir:        tempDir,
+	}
+	if err := s.sessions.Save(session); err != nil {
+		t.Fatalf("save session: %v", err)
+	}
+	return session
+}
+
+// testChunk 生成第 index 个分片的数据及其MD5
+func testChunk(index int, size int64) ([]byte, string) {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(index + i)
+	}
+	return data, fmt.Sprintf("%x", md5.Sum(data))
+}
+
+func TestUploadChunkConcurrent(t *testing.T) {
+	s := newTestUploadService(t)
+	const totalChunks = 64
+	const chunkSize = 1024
+	session := newTestSession(t, s, "concurrent", totalChunks, chunkSize)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, totalChunks*2)
+
+	// 每个分片上传两次，模拟客户端并行上传和重试
+	for round := 0; round < 2; round++ {
+		for i := 0; i < totalChunks; i++ {
+			wg.Add(1)
+			go func(index int) {
+				defer wg.Done()
+				data, sum := testChunk(index, chunkSize)
+				if err := s.UploadChunk(session.UploadID, session.UserID, index, data, sum); err != nil {
+					errs <- err
+				}
+			}(i)
+		}
+	}
+
+	// 上传过程中并发查询进度和续传信息
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.GetUploadProgress(session.UploadID, session.UserID); err != nil {
+				errs <- err
+			}
+			if _, err := s.ResumeUpload(&ResumeUploadRequest{MD5Hash: session.MD5Hash, FileSize: session.FileSize}, session.UserID); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	progress, err := s.GetUploadProgress(session.UploadID, session.UserID)
+	if err != nil {
+		t.Fatalf("GetUploadProgress: %v", err)
+	}
+	if progress.UploadedChunks != totalChunks || progress.UploadedSize != session.FileSize {
+		t.Fatalf("progress = %d chunks / %d bytes, want %d / %d", progress.UploadedChunks, progress.UploadedSize, totalChunks, session.FileSize)
+	}
+
+	entries, err := os.ReadDir(session.TempDir)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	// 每个分片对应数据文件和MD5文件
+	if len(entries) != totalChunks*2 {
+		t.Fatalf("temp dir has %d entries, want %d (leftover temporary files?)", len(entries), totalChunks*2)
+	}
+}
+
+func TestUploadChunkConcurrentWithCancel(t *testing.T) {
+	s := newTestUploadService(t)
+	const totalChunks = 32
+	const chunkSize = 512
+	session := newTestSession(t, s, "cancel", totalChunks, chunkSize)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, totalChunks+1)
+
+	for i := 0; i < totalChunks; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			data, sum := testChunk(index, chunkSize)
+			if err := s.UploadChunk(session.UploadID, session.UserID, index, data, sum); err != nil && !errors.Is(err, ErrUploadSessionNotFound) {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := s.CancelUpload(session.UploadID, session.UserID); err != nil {
+			errs <- err
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := s.GetUploadProgress(session.UploadID, session.UserID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Fatalf("GetUploadProgress after cancel: err = %v, want ErrUploadSessionNotFound", err)
+	}
+	if _, err := os.Stat(session.TempDir); !os.IsNotExist(err) {
+		t.Fatalf("temp dir still exists after cancel: %v", err)
+	}
+}
+
+func TestUploadChunkRejectsInvalidChunk(t *testing.T) {
+	s := newTestUploadService(t)
+	session := newTestSession(t, s, "invalid", 4, 128)
+
+	data, sum := testChunk(0, 128)
+	if err := s.UploadChunk(session.UploadID, session.UserID, 4, data, sum); err == nil {
+		t.Error("UploadChunk accepted out-of-range chunk index")
+	}
+	if err := s.UploadChunk(session.UploadID, session.UserID, 0, data, "00000000000000000000000000000000"); err == nil {
+		t.Error("UploadChunk accepted chunk with wrong MD5")
+	}
+	if err := s.UploadChunk("missing", 1, 0, data, sum); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("UploadChunk on unknown session: err = %v, want ErrUploadSessionNotFound", err)
+	}
+}
+
+func TestUploadSessionRejectsOtherUser(t *testing.T) {
+	s := newTestUploadService(t)
+	session := newTestSession(t, s, "other-user", 2, 128)
+	other := session.UserID + 1
+
+	data, sum := testChunk(0, 128)
+	if err := s.UploadChunk(session.UploadID, other, 0, data, sum); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("UploadChunk by other user: err = %v, want ErrUploadSessionNotFound", err)
+	}
+	if _, err := s.GetUploadProgress(session.UploadID, other); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("GetUploadProgress by other user: err = %v, want ErrUploadSessionNotFound", err)
+	}
+	if _, err := s.CompleteUpload(session.UploadID, other); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("CompleteUpload by other user: err = %v, want ErrUploadSessionNotFound", err)
+	}
+	if err := s.CancelUpload(session.UploadID, other); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("CancelUpload by other user: err = %v, want ErrUploadSessionNotFound", err)
+	}
+
+	progress, err := s.GetUploadProgress(session.UploadID, session.UserID)
+	if err != nil {
+		t.Fatalf("GetUploadProgress by owner: %v", err)
+	}
+	if progress.UploadedChunks != 0 {
+		t.Errorf("uploaded chunks = %d, want 0", progress.UploadedChunks)
+	}
+}
+
+// uploadTestChunks 上传会话的全部分片，返回合并后应得到的完整内容
+func uploadTestChunks(t *testing.T, s *UploadService, session *UploadSession) []byte {
+	t.Helper()
+
+	var content []byte
+	for i := 0; i < session.TotalChunks; i++ {
+		data, sum := testChunk(i, session.ChunkSize)
+		if err := s.UploadChunk(session.UploadID, session.UserID, i, data, sum); err != nil {
+			t.Fatalf("UploadChunk(%d): %v", i, err)
+		}
+		content = append(content, data...)
+	}
+	return content
+}
+
+func TestMergeChunks(t *testing.T) {
+	s := newTestUploadService(t)
+	session := newTestSession(t, s, "merge", 8, 256)
+	content := uploadTestChunks(t, s, session)
+	session.MD5Hash = fmt.Sprintf("%x", md5.Sum(content))
+
+	finalPath := filepath.Join(s.config.File.UploadPath, "merged.bin")
+	if err := mergeChunks(session, finalPath); err != nil {
+		t.Fatalf("mergeChunks: %v", err)
+	}
+
+	merged, err := os.ReadFile(finalPath)
+	if err != nil {
+		t.Fatalf("read merged file: %v", err)
+	}
+	if string(merged) != string(content) {
+		t.Fatal("merged content does not match uploaded chunks")
+	}
+}
+
+func TestMergeChunksUppercaseMD5(t *testing.T) {
+	s := newTestUploadService(t)
+	session := newTestSession(t, s, "upper", 4, 256)
+
+	// 客户端以大写十六进制提交分片和文件的MD5
+	var content []byte
+	for i := 0; i < session.TotalChunks; i++ {
+		data, sum := testChunk(i, session.ChunkSize)
+		if err := s.UploadChunk(session.UploadID, session.UserID, i, data, strings.ToUpper(sum)); err != nil {
+			t.Fatalf("UploadChunk(%d): %v", i, err)
+		}
+		content = append(content, data...)
+	}
+	session.MD5Hash = strings.ToUpper(fmt.Sprintf("%x", md5.Sum(content)))
+
+	finalPath := filepath.Join(s.config.File.UploadPath, "upper.bin")
+	if err := mergeChunks(session, finalPath); err != nil {
+		t.Fatalf("mergeChunks: %v", err)
+	}
+}
+
+func TestMergeChunksCorruptChunk(t *testing.T) {
+	s := newTestUploadService(t)
+	session := newTestSession(t, s, "corrupt", 4, 256)
+	content := uploadTestChunks(t, s, session)
+	session.MD5Hash = fmt.Sprintf("%x", md5.Sum(content))
+
+	// 模拟磁盘上的分片在上传后被破坏
+	if err := os.WriteFile(chunkFilePath(session.TempDir, 2), make([]byte, 256), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	finalPath := filepath.Join(s.config.File.UploadPath, "corrupt.bin")
+	if err := mergeChunks(session, finalPath); err == nil {
+		t.Fatal("mergeChunks succeeded with a corrupt chunk")
+	}
+
+	entries, _ := os.ReadDir(s.config.File.UploadPath)
+	if len(entries) != 0 {
+		t.Fatalf("upload dir has %d entries after failed merge, want 0", len(entries))
+	}
+
+	resume, err := s.ResumeUpload(&ResumeUploadRequest{MD5Hash: session.MD5Hash, FileSize: session.FileSize}, session.UserID)
+	if err != nil {
+		t.Fatalf("ResumeUpload: %v", err)
+	}
+	if len(resume.MissingChunks) != 1 || resume.MissingChunks[0] != 2 {
+		t.Fatalf("missing chunks = %v, want [2]", resume.MissingChunks)
+	}
+}
+
+func TestMemoryUploadSessionStoreSaveConflict(t *testing.T) {
+	store := NewMemoryUploadSessionStore()
+	first := &UploadSession{UploadID: "same", UserID: 1}
+	if err := store.Save(first); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Save(&UploadSession{UploadID: "same", UserID: 2}); !errors.Is(err, ErrUploadSessionExists) {
+		t.Fatalf("Save with existing ID: err = %v, want ErrUploadSessionExists", err)
+	}
+	if got, _ := store.Get("same"); got != first {
+		t.Error("existing session was overwritten")
+	}
+
+	if err := store.Touch("same"); err != nil {
+		t.Errorf("Touch: %v", err)
+	}
+	if err := store.Touch("missing"); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("Touch on unknown session: err = %v, want ErrUploadSessionNotFound", err)
+	}
+}
+
+func TestNewUploadID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := newUploadID()
+		if err != nil {
+			t.Fatalf("newUploadID: %v", err)
+		}
+		if len(id) != 32 {
+			t.Errorf("len(%q) = %d, want 32", id, len(id))
+		}
+		if seen[id] {
+			t.Fatalf("duplicate upload ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestScanUploadedChunks(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"chunk_0", "chunk_2", "chunk_0.md5", "chunk_1.123.tmp", "chunk_9", "other"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chunks, err := scanUploadedChunks(dir, 4)
+	if err != nil {
+		t.Fatalf("scanUploadedChunks: %v", err)
+	}
+	if len(chunks) != 2 || !chunks[0] || !chunks[2] {
+		t.Fatalf("scanUploadedChunks = %v, want chunks 0 and 2", chunks)
+	}
+
+	chunks, err = scanUploadedChunks(filepath.Join(dir, "missing"), 4)
+	if err != nil || len(chunks) != 0 {
+		t.Fatalf("scanUploadedChunks on missing dir = %v, %v", chunks, err)
+	}
+}
+
+func TestCleanupExpiredUploads(t *testing.T) {
+	s := newTestUploadService(t)
+	s.config.File.UploadSessionTTL = 1
+
+	stale := newTestSession(t, s, "stale", 2, 128)
+	uploadTestChunks(t, s, stale)
+	active := newTestSession(t, s, "active", 2, 128)
+	uploadTestChunks(t, s, active)
+
+	orphan := filepath.Join(s.config.File.TempPath, "orphan")
+	if err := os.MkdirAll(orphan, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(orphan, "chunk_0"), make([]byte, 64), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 将过期会话和孤立目录的活跃时间调整到过期时间之前
+	old := time.Now().Add(-2 * time.Hour)
+	s.sessions.(*MemoryUploadSessionStore).touched[stale.UploadID] = old
+	for _, dir := range []string{stale.TempDir, orphan} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := s.CleanupExpiredUploads()
+	if err != nil {
+		t.Fatalf("CleanupExpiredUploads: %v", err)
+	}
+	if result.ExpiredSessions != 1 || result.OrphanDirs != 1 {
+		t.Fatalf("cleaned %d sessions / %d orphans, want 1 / 1", result.ExpiredSessions, result.OrphanDirs)
+	}
+	if result.ReclaimedBytes < 2*128+64 {
+		t.Fatalf("reclaimed %d bytes, want at least %d", result.ReclaimedBytes, 2*128+64)
+	}
+
+	if _, err := s.sessions.Get(stale.UploadID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("stale session still exists: %v", err)
+	}
+	for _, dir := range []string{stale.TempDir, orphan} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after cleanup", dir)
+		}
+	}
+	if _, err := s.GetUploadProgress(active.UploadID, active.UserID); err != nil {
+		t.Errorf("active session removed: %v", err)
+	}
+}
+
+func TestUploadPolicyCheckType(t *testing.T) {
+	policy := &UploadPolicy{AllowedTypes: parseAllowedTypes("jpg, .CR2,png")}
+
+	for name, allowed := range map[string]bool{
+		"a.jpg":     true,
+		"B.JPG":     true,
+		"raw.cr2":   true,
+		"x.png":     true,
+		"doc.pdf":   false,
+		"noext":     false,
+		"jpg":       false,
+		"a.jpg.exe": false,
+	} {
+		err := policy.CheckType(name)
+		if allowed && err != nil {
+			t.Errorf("CheckType(%q) = %v, want allowed", name, err)
+		}
+		var policyErr *UploadPolicyError
+		if !allowed && (!errors.As(err, &policyErr) || policyErr.Code != UploadErrFileTypeNotAllowed) {
+			t.Errorf("CheckType(%q) = %v, want %s", name, err, UploadErrFileTypeNotAllowed)
+		}
+	}
+
+	if err := (&UploadPolicy{}).CheckType("anything.bin"); err != nil {
+		t.Errorf("CheckType with no restriction = %v", err)
+	}
+}
+
+func TestUploadPolicyCheckContent(t *testing.T) {
+	dir := t.TempDir()
+	policy := &UploadPolicy{}
+
+	cases := []struct {
+		name    string
+		content []byte
+		ok      bool
+	}{
+		{"photo.jpg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), true},
+		{"photo.jpg", []byte("\x89PNG\r\n\x1a\n...."), false},
+		{"image.png", []byte("\x89PNG\r\n\x1a\n...."), true},
+		{"shot.cr2", []byte("II*\x00\x10\x00\x00\x00CR\x02\x00"), true},
+		{"shot.cr2", []byte("II*\x00\x10\x00\x00\x00\x00\x00"), false},
+		{"shot.nef", []byte("MM\x00*\x00\x00\x00\x08"), true},
+		{"clip.mp4", []byte("\x00\x00\x00\x18ftypmp42"), true},
+		{"clip.mov", []byte("<html>not a video</html>"), false},
+		{"clip.avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), true},
+		{"empty.jpg", []byte{}, false},
+		{"shot.raw", []byte("anything"), true}, // 未收录魔数的扩展名不校验内容
+	}
+
+	for i, tc := range cases {
+		path := filepath.Join(dir, fmt.Sprintf("%d", i))
+		if err := os.WriteFile(path, tc.content, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := policy.CheckContent(tc.name, path)
+		if tc.ok && err != nil {
+			t.Errorf("CheckContent(%q, case %d) = %v, want ok", tc.name, i, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrContentTypeMismatch) {
+			t.Errorf("CheckContent(%q, case %d) = %v, want ErrContentTypeMismatch", tc.name, i, err)
+		}
+	}
+}
+
+func TestUploadChunkRejectsWrongSize(t *testing.T) {
+	s := newTestUploadService(t)
+	session := newTestSession(t, s, "size", 2, 128)
+	session.FileSize = 128 + 50
+
+	data, sum := testChunk(1, 128)
+	if err := s.UploadChunk(session.UploadID, session.UserID, 1, data, sum); err == nil {
+		t.Error("UploadChunk accepted a last chunk larger than the declared file size")
+	}
+	data, sum = testChunk(1, 50)
+	if err := s.UploadChunk(session.UploadID, session.UserID, 1, data, sum); err != nil {
+		t.Errorf("UploadChunk rejected a correctly sized last chunk: %v", err)
+	}
+}
+
+func TestReceiveDirectUpload(t *testing.T) {
+	s := newTestUploadService(t)
+	policy := &UploadPolicy{MaxFileSize: 1} // MB
+
+	data, sum := testChunk(7, 4096)
+	tmpPath, size, md5Hash, err := s.receiveDirectUpload(bytes.NewReader(data), policy)
+	if err != nil {
+		t.Fatalf("receiveDirectUpload: %v", err)
+	}
+	if size != int64(len(data)) || md5Hash != sum {
+		t.Fatalf("receiveDirectUpload = %d bytes / %s, want %d / %s", size, md5Hash, len(data), sum)
+	}
+	if stored, err := os.ReadFile(tmpPath); err != nil || !bytes.Equal(stored, data) {
+		t.Fatalf("temp file content mismatch: %v", err)
+	}
+
+	tooLarge := bytes.NewReader(make([]byte, 1<<20+1))
+	if _, _, _, err := s.receiveDirectUpload(tooLarge, policy); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("receiveDirectUpload over limit: err = %v, want ErrFileTooLarge", err)
+	}
+
+	entries, _ := os.ReadDir(s.config.File.TempPath)
+	if len(entries) != 1 {
+		t.Fatalf("temp dir has %d entries, want 1 (rejected upload left a file?)", len(entries))
+	}
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUploadSessionNotFound 上传会话不存在
+	ErrUploadSessionNotFound = errors.New("上传会话不存在")
+	// ErrUploadSessionExists 上传ID已被其他会话使用
+	ErrUploadSessionExists = errors.New("上传会话已存在")
+)
+
+// UploadSessionStore 上传会话存储接口
+type UploadSessionStore interface {
+	// Save 保存新建的上传会话，上传ID已存在时返回 ErrUploadSessionExists，不覆盖已有会话
+	Save(session *UploadSession) error
+	// Touch 更新会话的最后活跃时间，会话不存在时返回 ErrUploadSessionNotFound
+	Touch(uploadID string) error
+	// Get 获取上传会话，不存在时返回 ErrUploadSessionNotFound
+	Get(uploadID string) (*UploadSession, error)
+	// Delete 删除上传会话
+	Delete(uploadID string) error
+	// FindByFile 查找用户针对同一文件（MD5与大小相同）最近的未完成会话
+	FindByFile(userID uint, md5Hash string, fileSize int64) (*UploadSession, error)
+	// ListExpired 列出最后活跃时间早于 before 的会话
+	ListExpired(before time.Time) ([]*UploadSession, error)
+}
+
+// NewUploadSessionStore 根据驱动名称创建上传会话存储（memory, postgres）
+func NewUploadSessionStore(driver string, db *gorm.DB) (UploadSessionStore, error) {
+	switch strings.ToLower(driver) {
+	case "memory":
+		return NewMemoryUploadSessionStore(), nil
+	case "", "postgres", "database":
+		if db == nil {
+			return nil, errors.New("数据库未初始化")
+		}
+		return NewDBUploadSessionStore(db), nil
+	default:
+		return nil, fmt.Errorf("不支持的上传会话存储: %s", driver)
+	}
+}
+
+// MemoryUploadSessionStore 内存上传会话存储（服务重启后会话丢失）
+type MemoryUploadSessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*UploadSession
+	touched  map[string]time.Time // 会话最后活跃时间
+}
+
+// NewMemoryUploadSessionStore 创建内存上传会话存储
+func NewMemoryUploadSessionStore() *MemoryUploadSessionStore {
+	return &MemoryUploadSessionStore{
+		sessions: make(map[string]*UploadSession),
+		touched:  make(map[string]time.Time),
+	}
+}
+
+// Save 保存新建的上传会话
+func (m *MemoryUploadSessionStore) Save(session *UploadSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.sessions[session.UploadID]; exists {
+		return ErrUploadSessionExists
+	}
+	m.sessions[session.UploadID] = session
+	m.touched[session.UploadID] = time.Now()
+	return nil
+}
+
+// Touch 更新会话的最后活跃时间
+func (m *MemoryUploadSessionStore) Touch(uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.sessions[uploadID]; !exists {
+		return ErrUploadSessionNotFound
+	}
+	m.touched[uploadID] = time.Now()
+	return nil
+}
+
+// Get 获取上传会话
+func (m *MemoryUploadSessionStore) Get(uploadID string) (*UploadSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, exists := m.sessions[uploadID]
+	if !exists {
+		return nil, ErrUploadSessionNotFound
+	}
+	return session, nil
+}
+
+// Delete 删除上传会话
+func (m *MemoryUploadSessionStore) Delete(uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, uploadID)
+	delete(m.touched, uploadID)
+	return nil
+}
+
+// FindByFile 查找用户针对同一文件最近的未完成会话
+func (m *MemoryUploadSessionStore) FindByFile(userID uint, md5Hash string, fileSize int64) (*UploadSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *UploadSession
+	for _, session := range m.sessions {
+		if session.UserID != userID || session.MD5Hash != md5Hash || session.FileSize != fileSize {
+			continue
+		}
+		if latest == nil || session.CreatedAt.After(latest.CreatedAt) {
+			latest = session
+		}
+	}
+	if latest == nil {
+		return nil, ErrUploadSessionNotFound
+	}
+	return latest, nil
+}
+
+// ListExpired 列出最后活跃时间早于 before 的会话
+func (m *MemoryUploadSessionStore) ListExpired(before time.Time) ([]*UploadSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var expired []*UploadSession
+	for uploadID, touchedAt := range m.touched {
+		if touchedAt.Before(before) {
+			expired = append(expired, m.sessions[uploadID])
+		}
+	}
+	return expired, nil
+}
+
+// DBUploadSessionStore 基于PostgreSQL的上传会话存储
+// 会话元数据保存在数据库中，已上传的分片由临时目录中的分片文件重建
+type DBUploadSessionStore struct {
+	db *gorm.DB
+}
+
+// NewDBUploadSessionStore 创建数据库上传会话存储
+func NewDBUploadSessionStore(db *gorm.DB) *DBUploadSessionStore {
+	return &DBUploadSessionStore{db: db}
+}
+
+// Save 保存新建的上传会话
+func (d *DBUploadSessionStore) Save(session *UploadSession) error {
+	record := models.UploadSession{
+		UploadID:    session.UploadID,
+		UserID:      session.UserID,
+		FileName:    session.FileName,
+		FileSize:    session.FileSize,
+		MD5Hash:     session.MD5Hash,
+		ChunkSize:   session.ChunkSize,
+		TotalChunks: session.TotalChunks,
+		FolderID:    session.FolderID,
+		WorkflowID:  session.WorkflowID,
+		TaskID:      session.TaskID,
+		Description: session.Description,
+		IsPrivate:   session.IsPrivate,
+		FileID:      session.FileID,
+		ChangeLog:   session.ChangeLog,
+		TempDir:     session.TempDir,
+		CreatedAt:   session.CreatedAt,
+	}
+
+	result := d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "upload_id"}},
+		DoNothing: true,
+	}).Create(&record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadSessionExists
+	}
+	return nil
+}
+
+// Touch 更新会话的最后活跃时间
+func (d *DBUploadSessionStore) Touch(uploadID string) error {
+	result := d.db.Model(&models.UploadSession{}).Where("upload_id = ?", uploadID).Update("updated_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("更新上传会话失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadSessionNotFound
+	}
+	return nil
+}
+
+// Get 获取上传会话并根据临时目录重建分片状态
+func (d *DBUploadSessionStore) Get(uploadID string) (*UploadSession, error) {
+	var record models.UploadSession
+	if err := d.db.Where("upload_id = ?", uploadID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadSessionNotFound
+		}
+		return nil, fmt.Errorf("获取上传会话失败: %v", err)
+	}
+
+	return d.toSession(&record)
+}
+
+// FindByFile 查找用户针对同一文件最近的未完成会话
+func (d *DBUploadSessionStore) FindByFile(userID uint, md5Hash string, fileSize int64) (*UploadSession, error) {
+	var record models.UploadSession
+	if err := d.db.Where("user_id = ? AND md5_hash = ? AND file_size = ?", userID, md5Hash, fileSize).
+		Order("created_at DESC").First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadSessionNotFound
+		}
+		return nil, fmt.Errorf("查找上传会话失败: %v", err)
+	}
+
+	return d.toSession(&record)
+}
+
+// ListExpired 列出最后活跃时间早于 before 的会话
+func (d *DBUploadSessionStore) ListExpired(before time.Time) ([]*UploadSession, error) {
+	var records []models.UploadSession
+	if err := d.db.Where("updated_at < ?", before).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询过期上传会话失败: %v", err)
+	}
+
+	// 过期会话只需要元数据，不扫描分片目录
+	sessions := make([]*UploadSession, 0, len(records))
+	for i := range records {
+		sessions = append(sessions, &UploadSession{
+			UploadID:    records[i].UploadID,
+			FileName:    records[i].FileName,
+			FileSize:    records[i].FileSize,
+			MD5Hash:     records[i].MD5Hash,
+			ChunkSize:   records[i].ChunkSize,
+			TotalChunks: records[i].TotalChunks,
+			UserID:      records[i].UserID,
+			CreatedAt:   records[i].CreatedAt,
+			TempDir:     records[i].TempDir,
+		})
+	}
+	return sessions, nil
+}
+
+// toSession 将数据库记录转换为上传会话
+func (d *DBUploadSessionStore) toSession(record *models.UploadSession) (*UploadSession, error) {
+	uploadedChunks, err := scanUploadedChunks(record.TempDir, record.TotalChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadSession{
+		UploadID:       record.UploadID,
+		FileName:       record.FileName,
+		FileSize:       record.FileSize,
+		MD5Hash:        record.MD5Hash,
+		ChunkSize:      record.ChunkSize,
+		TotalChunks:    record.TotalChunks,
+		UploadedChunks: uploadedChunks,
+		UserID:         record.UserID,
+		FolderID:       record.FolderID,
+		WorkflowID:     record.WorkflowID,
+		TaskID:         record.TaskID,
+		Description:    record.Description,
+		IsPrivate:      record.IsPrivate,
+		FileID:         record.FileID,
+		ChangeLog:      record.ChangeLog,
+		CreatedAt:      record.CreatedAt,
+		TempDir:        record.TempDir,
+	}, nil
+}
+
+// Delete 删除上传会话
+func (d *DBUploadSessionStore) Delete(uploadID string) error {
+	return d.db.Where("upload_id = ?", uploadID).Delete(&models.UploadSession{}).Error
+}
+
+// scanUploadedChunks 扫描临时目录，重建已上传的分片索引
+func scanUploadedChunks(tempDir string, totalChunks int) (map[int]bool, error) {
+	uploadedChunks := make(map[int]bool)
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return uploadedChunks, nil
+		}
+		return nil, fmt.Errorf("读取临时目录失败: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), chunkFilePrefix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), chunkFilePrefix))
+		if err != nil || index < 0 || index >= totalChunks {
+			continue
+		}
+		uploadedChunks[index] = true
+	}
+
+	return uploadedChunks, nil
+}
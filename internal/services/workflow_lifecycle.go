@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mcs-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrWorkflowReadOnly 工作流已归档或已完成，不能再上传或修改文件内容
+var ErrWorkflowReadOnly = errors.New("工作流已归档或已完成，无法修改文件")
+
+// workflowTransitions 允许的工作流状态流转
+// 已完成的工作流可以重新打开，宽限期内重新打开不会清理历史版本
+var workflowTransitions = map[string][]string{
+	models.WorkflowStatusOpen:      {models.WorkflowStatusArchived, models.WorkflowStatusCompleted},
+	models.WorkflowStatusArchived:  {models.WorkflowStatusOpen, models.WorkflowStatusCompleted},
+	models.WorkflowStatusCompleted: {models.WorkflowStatusOpen},
+}
+
+// ChangeWorkflowStatusRequest 变更工作流状态请求
+type ChangeWorkflowStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=open archived completed"`
+}
+
+// canTransitWorkflow 判断工作流状态能否从 from 流转到 to
+func canTransitWorkflow(from, to string) bool {
+	for _, next := range workflowTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeStatus 变更工作流状态（需有管理工作流的权限）
+func (s *WorkflowService) ChangeStatus(workflowID uint, req *ChangeWorkflowStatusRequest, userID uint) (*WorkflowInfo, error) {
+	workflow, err := s.authorizedWorkflow(workflowID, userID, ActionManage, "无权限修改该工作流的状态")
+	if err != nil {
+		return nil, err
+	}
+
+	if workflow.Status == req.Status {
+		return s.GetWorkflowByID(workflowID, userID)
+	}
+	if !canTransitWorkflow(workflow.Status, req.Status) {
+		return nil, fmt.Errorf("工作流状态不能从 %s 变更为 %s", workflow.Status, req.Status)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            req.Status,
+		"status_changed_at": &now,
+		"completed_at":      nil,
+	}
+	if req.Status == models.WorkflowStatusCompleted {
+		updates["completed_at"] = &now
+	}
+
+	// 条件更新，避免与并发的状态变更相互覆盖
+	result := s.db.Model(&models.Workflow{}).Where("id = ? AND status = ?", workflowID, workflow.Status).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新工作流状态失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("工作流状态已被修改，请刷新后重试")
+	}
+
+	return s.GetWorkflowByID(workflowID, userID)
+}
+
+// checkWorkflowWritable 检查工作流是否允许上传或修改文件内容（workflowID 为 0 表示不属于工作流）
+func checkWorkflowWritable(db *gorm.DB, workflowID uint) error {
+	if workflowID == 0 {
+		return nil
+	}
+
+	var status string
+	if err := db.Model(&models.Workflow{}).Where("id = ?", workflowID).Pluck("status", &status).Error; err != nil {
+		return fmt.Errorf("获取工作流状态失败: %v", err)
+	}
+	if status != "" && status != models.WorkflowStatusOpen {
+		return ErrWorkflowReadOnly
+	}
+	return nil
+}
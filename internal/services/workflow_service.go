@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"mcs-backend/internal/config"
@@ -16,16 +17,38 @@ import (
 type WorkflowService struct {
 	db     *gorm.DB
 	config *config.Config
+	authz  *AuthzService
 }
 
 // NewWorkflowService 创建工作流服务
 func NewWorkflowService(cfg *config.Config) *WorkflowService {
+	db := database.GetDB()
 	return &WorkflowService{
-		db:     database.GetDB(),
+		db:     db,
 		config: cfg,
+		authz:  NewAuthzService(db),
 	}
 }
 
+// authorizedWorkflow 检查用户能否对工作流执行操作并返回工作流，无权限时返回以 message 开头的 ErrWorkflowAccessDenied
+func (s *WorkflowService) authorizedWorkflow(workflowID, userID uint, action, message string) (*models.Workflow, error) {
+	if err := s.authz.CheckWorkflow(userID, workflowID, action); err != nil {
+		if errors.Is(err, ErrWorkflowAccessDenied) {
+			return nil, fmt.Errorf("%s: %w", message, err)
+		}
+		return nil, err
+	}
+
+	var workflow models.Workflow
+	if err := s.db.Where("id = ?", workflowID).First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("工作流不存在")
+		}
+		return nil, fmt.Errorf("获取工作流失败: %v", err)
+	}
+	return &workflow, nil
+}
+
 // GetConfig 获取配置
 func (s *WorkflowService) GetConfig() *config.Config {
 	return s.config
@@ -53,14 +76,16 @@ type WorkflowListResponse struct {
 
 // WorkflowInfo 工作流信息
 type WorkflowInfo struct {
-	ID          uint      `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	MasterID    uint      `json:"master_id"`
-	MasterName  string    `json:"master_name"`
-	MemberCount int64     `json:"member_count"`
-	TaskCount   int64     `json:"task_count"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	MasterID    uint       `json:"master_id"`
+	MasterName  string     `json:"master_name"`
+	MemberCount int64      `json:"member_count"`
+	TaskCount   int64      `json:"task_count"`
+	Status      string     `json:"status"` // open, archived, completed
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
 
 // WorkflowMemberInfo 工作流成员信息
@@ -73,15 +98,15 @@ type WorkflowMemberInfo struct {
 	JoinedAt   time.Time `json:"joined_at"`
 }
 
-// AddWorkflowMemberRequest 添加工作流成员请求
+// AddWorkflowMemberRequest 添加工作流成员请求，角色为 master、normal 或 viewer，权限见 AuthzService
 type AddWorkflowMemberRequest struct {
 	UserID uint   `json:"user_id" binding:"required"`
-	Role   string `json:"role" binding:"required"`
+	Role   string `json:"role" binding:"required,oneof=master normal viewer"`
 }
 
 // UpdateWorkflowMemberRequest 更新工作流成员请求
 type UpdateWorkflowMemberRequest struct {
-	Role string `json:"role" binding:"required"`
+	Role string `json:"role" binding:"required,oneof=master normal viewer"`
 }
 
 // CreateWorkflow 创建工作流
@@ -97,6 +122,7 @@ func (s *WorkflowService) CreateWorkflow(req *CreateWorkflowRequest, userID uint
 		Name:        req.Name,
 		Description: req.Description,
 		MasterID:    userID,
+		Status:      models.WorkflowStatusOpen,
 	}
 
 	if err := s.db.Create(&workflow).Error; err != nil {
@@ -128,6 +154,7 @@ func (s *WorkflowService) CreateWorkflow(req *CreateWorkflowRequest, userID uint
 		MasterName:  user.Username,
 		MemberCount: 1,
 		TaskCount:   0,
+		Status:      workflow.Status,
 		CreatedAt:   workflow.CreatedAt,
 	}, nil
 }
@@ -135,9 +162,8 @@ func (s *WorkflowService) CreateWorkflow(req *CreateWorkflowRequest, userID uint
 // GetWorkflowByID 根据ID获取工作流
 func (s *WorkflowService) GetWorkflowByID(workflowID uint, userID uint) (*WorkflowInfo, error) {
 	// 检查用户是否有权限访问该工作流
-	var member models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", workflowID, userID).First(&member).Error; err != nil {
-		return nil, errors.New("无权限访问该工作流")
+	if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+		return nil, err
 	}
 
 	// 获取工作流信息
@@ -168,6 +194,8 @@ func (s *WorkflowService) GetWorkflowByID(workflowID uint, userID uint) (*Workfl
 		MasterName:  master.Username,
 		MemberCount: memberCount,
 		TaskCount:   taskCount,
+		Status:      workflow.Status,
+		CompletedAt: workflow.CompletedAt,
 		CreatedAt:   workflow.CreatedAt,
 	}, nil
 }
@@ -233,6 +261,8 @@ func (s *WorkflowService) GetWorkflowList(userID uint, page, pageSize int) (*Wor
 			MasterName:  master.Username,
 			MemberCount: memberCount,
 			TaskCount:   taskCount,
+			Status:      workflow.Status,
+			CompletedAt: workflow.CompletedAt,
 			CreatedAt:   workflow.CreatedAt,
 		})
 	}
@@ -247,10 +277,10 @@ func (s *WorkflowService) GetWorkflowList(userID uint, page, pageSize int) (*Wor
 
 // UpdateWorkflow 更新工作流
 func (s *WorkflowService) UpdateWorkflow(workflowID uint, req *UpdateWorkflowRequest, userID uint) (*WorkflowInfo, error) {
-	// 检查用户是否为工作流主管
-	var workflow models.Workflow
-	if err := s.db.Where("id = ? AND master_id = ?", workflowID, userID).First(&workflow).Error; err != nil {
-		return nil, errors.New("无权限更新该工作流")
+	// 检查用户是否有权限修改工作流
+	workflow, err := s.authorizedWorkflow(workflowID, userID, ActionEdit, "无权限更新该工作流")
+	if err != nil {
+		return nil, err
 	}
 
 	// 检查工作流名称是否已存在（排除当前工作流）
@@ -271,7 +301,7 @@ func (s *WorkflowService) UpdateWorkflow(workflowID uint, req *UpdateWorkflowReq
 	}
 
 	if len(updateData) > 0 {
-		if err := s.db.Model(&workflow).Updates(updateData).Error; err != nil {
+		if err := s.db.Model(workflow).Updates(updateData).Error; err != nil {
 			return nil, fmt.Errorf("更新工作流失败: %v", err)
 		}
 	}
@@ -282,10 +312,10 @@ func (s *WorkflowService) UpdateWorkflow(workflowID uint, req *UpdateWorkflowReq
 
 // DeleteWorkflow 删除工作流
 func (s *WorkflowService) DeleteWorkflow(workflowID uint, userID uint) error {
-	// 检查用户是否为工作流主管
-	var workflow models.Workflow
-	if err := s.db.Where("id = ? AND master_id = ?", workflowID, userID).First(&workflow).Error; err != nil {
-		return errors.New("无权限删除该工作流")
+	// 检查用户是否有权限删除工作流
+	workflow, err := s.authorizedWorkflow(workflowID, userID, ActionDelete, "无权限删除该工作流")
+	if err != nil {
+		return err
 	}
 
 	// 检查是否还有未完成的任务
@@ -316,7 +346,7 @@ func (s *WorkflowService) DeleteWorkflow(workflowID uint, userID uint) error {
 	}
 
 	// 删除工作流
-	if err := tx.Delete(&workflow).Error; err != nil {
+	if err := tx.Delete(workflow).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("删除工作流失败: %v", err)
 	}
@@ -326,10 +356,9 @@ func (s *WorkflowService) DeleteWorkflow(workflowID uint, userID uint) error {
 
 // AddMember 添加工作流成员
 func (s *WorkflowService) AddMember(workflowID uint, req *AddWorkflowMemberRequest, operatorID uint) (*WorkflowMemberInfo, error) {
-	// 检查操作者是否为工作流主管
-	var workflow models.Workflow
-	if err := s.db.Where("id = ? AND master_id = ?", workflowID, operatorID).First(&workflow).Error; err != nil {
-		return nil, errors.New("无权限添加成员")
+	// 检查操作者是否有权限管理工作流
+	if _, err := s.authorizedWorkflow(workflowID, operatorID, ActionManage, "无权限添加成员"); err != nil {
+		return nil, err
 	}
 
 	// 检查用户是否已经是成员
@@ -367,10 +396,10 @@ func (s *WorkflowService) AddMember(workflowID uint, req *AddWorkflowMemberReque
 
 // RemoveMember 移除工作流成员
 func (s *WorkflowService) RemoveMember(workflowID uint, userID uint, operatorID uint) error {
-	// 检查操作者是否为工作流主管
-	var workflow models.Workflow
-	if err := s.db.Where("id = ? AND master_id = ?", workflowID, operatorID).First(&workflow).Error; err != nil {
-		return errors.New("无权限移除成员")
+	// 检查操作者是否有权限管理工作流
+	workflow, err := s.authorizedWorkflow(workflowID, operatorID, ActionManage, "无权限移除成员")
+	if err != nil {
+		return err
 	}
 
 	// 不能移除主管
@@ -401,10 +430,10 @@ func (s *WorkflowService) RemoveMember(workflowID uint, userID uint, operatorID
 
 // UpdateMemberRole 更新工作流成员角色
 func (s *WorkflowService) UpdateMemberRole(workflowID uint, userID uint, req *UpdateWorkflowMemberRequest, operatorID uint) (*WorkflowMemberInfo, error) {
-	// 检查操作者是否为工作流主管
-	var workflow models.Workflow
-	if err := s.db.Where("id = ? AND master_id = ?", workflowID, operatorID).First(&workflow).Error; err != nil {
-		return nil, errors.New("无权限更新成员角色")
+	// 检查操作者是否有权限管理工作流
+	workflow, err := s.authorizedWorkflow(workflowID, operatorID, ActionManage, "无权限更新成员角色")
+	if err != nil {
+		return nil, err
 	}
 
 	// 不能更新主管角色
@@ -442,9 +471,8 @@ func (s *WorkflowService) UpdateMemberRole(workflowID uint, userID uint, req *Up
 // GetWorkflowMembers 获取工作流成员列表
 func (s *WorkflowService) GetWorkflowMembers(workflowID uint, userID uint) ([]WorkflowMemberInfo, error) {
 	// 检查用户是否有权限访问该工作流
-	var member models.WorkflowMember
-	if err := s.db.Where("workflow_id = ? AND user_id = ?", workflowID, userID).First(&member).Error; err != nil {
-		return nil, errors.New("无权限访问该工作流")
+	if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+		return nil, err
 	}
 
 	// 获取成员列表
@@ -473,4 +501,88 @@ func (s *WorkflowService) GetWorkflowMembers(workflowID uint, userID uint) ([]Wo
 	}
 
 	return memberInfos, nil
-}
\ No newline at end of file
+}
+
+// UploadPolicyRequest 设置工作流上传策略请求
+type UploadPolicyRequest struct {
+	MaxFileSize  *int64   `json:"max_file_size"` // 最大文件大小（MB），不传表示沿用全局配置，0 表示不限制
+	AllowedTypes []string `json:"allowed_types"` // 允许的扩展名，不传表示沿用全局配置，空数组表示不限制
+}
+
+// WorkflowUploadPolicyInfo 工作流上传策略信息
+type WorkflowUploadPolicyInfo struct {
+	WorkflowID uint                         `json:"workflow_id"`
+	Effective  *UploadPolicy                `json:"effective"`          // 当前生效的策略
+	Override   *models.WorkflowUploadPolicy `json:"override,omitempty"` // 工作流设置的覆盖项
+}
+
+// GetUploadPolicy 获取工作流上传策略
+func (s *WorkflowService) GetUploadPolicy(workflowID uint, userID uint) (*WorkflowUploadPolicyInfo, error) {
+	if err := s.authz.CheckWorkflow(userID, workflowID, ActionView); err != nil {
+		return nil, err
+	}
+
+	return s.uploadPolicyInfo(workflowID)
+}
+
+// SetUploadPolicy 设置工作流上传策略（需有管理工作流的权限）
+func (s *WorkflowService) SetUploadPolicy(workflowID uint, req *UploadPolicyRequest, userID uint) (*WorkflowUploadPolicyInfo, error) {
+	if _, err := s.authorizedWorkflow(workflowID, userID, ActionManage, "无权限修改该工作流的上传策略"); err != nil {
+		return nil, err
+	}
+
+	if req.MaxFileSize != nil && *req.MaxFileSize < 0 {
+		return nil, errors.New("最大文件大小不能为负数")
+	}
+
+	var policy models.WorkflowUploadPolicy
+	if err := s.db.Where("workflow_id = ?", workflowID).First(&policy).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取上传策略失败: %v", err)
+	}
+
+	policy.WorkflowID = workflowID
+	policy.MaxFileSize = req.MaxFileSize
+	policy.AllowedTypes = nil
+	if req.AllowedTypes != nil {
+		types := strings.Join(parseAllowedTypes(strings.Join(req.AllowedTypes, ",")), ",")
+		policy.AllowedTypes = &types
+	}
+	policy.UpdatedBy = userID
+
+	if err := s.db.Save(&policy).Error; err != nil {
+		return nil, fmt.Errorf("保存上传策略失败: %v", err)
+	}
+
+	return s.uploadPolicyInfo(workflowID)
+}
+
+// DeleteUploadPolicy 删除工作流上传策略，恢复使用全局配置（需有管理工作流的权限）
+func (s *WorkflowService) DeleteUploadPolicy(workflowID uint, userID uint) error {
+	if _, err := s.authorizedWorkflow(workflowID, userID, ActionManage, "无权限修改该工作流的上传策略"); err != nil {
+		return err
+	}
+
+	if err := s.db.Where("workflow_id = ?", workflowID).Delete(&models.WorkflowUploadPolicy{}).Error; err != nil {
+		return fmt.Errorf("删除上传策略失败: %v", err)
+	}
+	return nil
+}
+
+// uploadPolicyInfo 获取工作流生效的上传策略及覆盖项
+func (s *WorkflowService) uploadPolicyInfo(workflowID uint) (*WorkflowUploadPolicyInfo, error) {
+	effective, err := resolveUploadPolicy(s.db, s.config, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &WorkflowUploadPolicyInfo{
+		WorkflowID: workflowID,
+		Effective:  effective,
+	}
+
+	var override models.WorkflowUploadPolicy
+	if err := s.db.Where("workflow_id = ?", workflowID).First(&override).Error; err == nil {
+		info.Override = &override
+	}
+	return info, nil
+}
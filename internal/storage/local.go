@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LocalStorage 本地磁盘存储
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage 创建本地磁盘存储，root 为存储根目录
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+// path 获取对象在磁盘上的路径，对象键不能逃逸出根目录
+func (l *LocalStorage) path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Put 写入对象：先写入同目录下的临时文件，再重命名为目标文件
+func (l *LocalStorage) Put(key string, r io.Reader, size int64) error {
+	dst := l.path(key)
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建存储目录失败: %v", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	n, err := io.Copy(tmpFile, r)
+	if err == nil && size >= 0 && n != size {
+		err = fmt.Errorf("文件大小不匹配: 期望 %d 字节，实际 %d 字节", size, n)
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("保存文件失败: %v", err)
+	}
+	return nil
+}
+
+// PutFile 将本地文件移动到存储中，跨文件系统时退化为复制
+func (l *LocalStorage) PutFile(key, src string) error {
+	dst := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("创建存储目录失败: %v", err)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := l.Put(key, file, -1); err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(src)
+}
+
+// Get 读取整个对象
+func (l *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	file, err := os.Open(l.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetRange 读取对象的一段内容
+func (l *LocalStorage) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(l.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return &limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
+// Stat 获取对象信息
+func (l *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	info, err := os.Stat(l.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNotExist
+	}
+
+	return &ObjectInfo{
+		Key:     key,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// Delete 删除对象
+func (l *LocalStorage) Delete(key string) error {
+	if err := os.Remove(l.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// limitedReadCloser 限制读取长度的 ReadCloser
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// readSeeker 基于 GetRange 的可定位读取器
+// Seek 只记录位置，读取位置与当前打开的内容不一致时才按新位置重新打开，用于 HTTP Range 请求
+type readSeeker struct {
+	store      Storage
+	key        string
+	size       int64
+	offset     int64         // 下一次读取的位置
+	body       io.ReadCloser // 当前打开的内容，从 bodyOffset 开始
+	bodyOffset int64
+}
+
+// NewReadSeeker 打开对象内容，返回可定位的读取器；size 为对象大小，对象不存在时返回 ErrNotExist
+// 创建时即打开对象开头，使不存在的对象在开始响应之前就能被发现
+func NewReadSeeker(s Storage, key string, size int64) (io.ReadSeekCloser, error) {
+	body, err := s.GetRange(key, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &readSeeker{store: s, key: key, size: size, body: body}, nil
+}
+
+// Read 从当前位置读取，不超过对象大小
+func (r *readSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil || r.bodyOffset != r.offset {
+		if r.body != nil {
+			r.body.Close()
+			r.body = nil
+		}
+		body, err := r.store.GetRange(r.key, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body, r.bodyOffset = body, r.offset
+	}
+
+	if remaining := r.size - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	r.bodyOffset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek 设置下一次读取的位置
+func (r *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("无效的 whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("读取位置不能为负数")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close 关闭当前打开的内容
+func (r *readSeeker) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
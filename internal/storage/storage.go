@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"mcs-backend/internal/config"
+)
+
+// ErrNotExist 对象不存在
+var ErrNotExist = errors.New("对象不存在")
+
+// ObjectInfo 存储对象信息
+type ObjectInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	ETag    string    `json:"etag"`
+}
+
+// Storage 文件内容存储接口
+// key 为相对于存储根目录（或存储桶）的对象键，使用 "/" 分隔
+type Storage interface {
+	// Put 写入对象，size 为内容的准确长度；写入是原子的，失败不会留下不完整的对象
+	Put(key string, r io.Reader, size int64) error
+	// Get 读取整个对象，不存在时返回 ErrNotExist
+	Get(key string) (io.ReadCloser, error)
+	// GetRange 读取对象从 offset 开始的 length 个字节，length < 0 表示读到末尾
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+	// Stat 获取对象信息，不存在时返回 ErrNotExist
+	Stat(key string) (*ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(key string) error
+}
+
+// filePutter 可直接移动本地文件的存储（避免再复制一次文件内容）
+type filePutter interface {
+	PutFile(key, path string) error
+}
+
+// PutFile 将本地文件写入存储，成功后本地文件不再保留
+func PutFile(s Storage, key, path string) error {
+	if p, ok := s.(filePutter); ok {
+		return p.PutFile(key, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := s.Put(key, file, info.Size()); err != nil {
+		return err
+	}
+
+	file.Close()
+	return os.Remove(path)
+}
+
+// Exists 判断对象是否存在
+func Exists(s Storage, key string) (bool, error) {
+	if _, err := s.Stat(key); err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// New 根据配置创建存储（local, s3）
+func New(cfg *config.Config) (Storage, error) {
+	switch strings.ToLower(cfg.Storage.Driver) {
+	case "", "local":
+		return NewLocalStorage(cfg.File.UploadPath), nil
+	case "s3", "minio":
+		return NewS3Storage(S3Options{
+			Endpoint:     cfg.Storage.S3Endpoint,
+			Region:       cfg.Storage.S3Region,
+			Bucket:       cfg.Storage.S3Bucket,
+			AccessKey:    cfg.Storage.S3AccessKey,
+			SecretKey:    cfg.Storage.S3SecretKey,
+			UsePathStyle: cfg.Storage.S3UsePathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("不支持的存储驱动: %s", cfg.Storage.Driver)
+	}
+}
+
+var defaultStorage Storage
+
+// InitStorage 初始化全局文件存储
+func InitStorage(cfg *config.Config) error {
+	s, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	defaultStorage = s
+	log.Printf("File storage initialized (driver: %s)", cfg.Storage.Driver)
+	return nil
+}
+
+// GetStorage 获取全局文件存储
+func GetStorage() Storage {
+	return defaultStorage
+}